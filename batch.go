@@ -0,0 +1,116 @@
+package stereoscope
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+// BatchOptions tunes how images are fetched with GetImages (and Prefetch).
+type BatchOptions struct {
+	// Concurrency is the maximum number of images fetched (and read) at once. A value of zero or less fetches the
+	// images one at a time. Note: any limits set with SetFetchLimit or SetSourceFetchLimit still apply.
+	Concurrency int
+	// RegistryOptions are used for every image fetched from a registry.
+	RegistryOptions *image.RegistryOptions
+	// DetectSourceOptions tune the source detection for every input (see GetImage).
+	DetectSourceOptions []image.DetectSourceOption
+	// LayerCache is the layer cache shared by all images in the batch. When not given the cache set with SetLayerCache
+	// is used, otherwise a layer cache is created for the batch (and removed on cleanup).
+	LayerCache *image.LayerCache
+}
+
+// GetImages fetches and reads the image for each of the given user inputs (as with GetImage).
+func GetImages(inputs []string, options BatchOptions) ([]*image.Image, func() error, error) {
+	return GetImagesContext(context.Background(), inputs, options)
+}
+
+// GetImagesContext fetches and reads the image for each of the given user inputs (as with GetImageContext), sharing a
+// single layer cache between all images so that layers common to several images (e.g. from a shared base image) are
+// only fetched and extracted once. The images are returned in the same order as the inputs, along with a cleanup
+// function that removes all temp files for the batch (the images must not be used after cleanup). If any image cannot
+// be fetched then the remaining fetches are cancelled, the batch is cleaned up, and the first error is returned.
+func GetImagesContext(ctx context.Context, inputs []string, options BatchOptions) ([]*image.Image, func() error, error) {
+	tmpDirGen := file.NewTempDirGeneratorWithCreator(currentTempDirCreator())
+	cleanup := tmpDirGen.Cleanup
+
+	layerCache := options.LayerCache
+	if layerCache == nil {
+		layerCache = currentLayerCache()
+	}
+	if layerCache == nil {
+		cacheDir, err := tmpDirGen.NewTempDir()
+		if err != nil {
+			return nil, nil, err
+		}
+		layerCache = image.NewLayerCache(cacheDir)
+	}
+
+	images := make([]*image.Image, len(inputs))
+	err := forEachInput(ctx, inputs, options.Concurrency, func(ctx context.Context, idx int, input string) error {
+		img, err := getImage(ctx, input, options.RegistryOptions, options.DetectSourceOptions, &tmpDirGen, image.WithLayerCache(layerCache))
+		if err != nil {
+			return err
+		}
+		images[idx] = img
+		return nil
+	})
+	if err != nil {
+		_ = cleanup()
+		return nil, nil, err
+	}
+	return images, cleanup, nil
+}
+
+// forEachInput calls the given function for each of the given user inputs, with up to the given number of calls
+// running at once (at least one). Upon the first error the context given to the remaining calls is cancelled, no
+// further calls are made, and the error is returned (once all running calls return).
+func forEachInput(ctx context.Context, inputs []string, concurrency int, fn func(ctx context.Context, idx int, input string) error) error {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		slots    = make(chan struct{}, concurrency)
+		wg       sync.WaitGroup
+		errLock  sync.Mutex
+		firstErr error
+	)
+
+	for idx, input := range inputs {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(idx int, input string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			if err := fn(ctx, idx, input); err != nil {
+				errLock.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("unable to get image=%q: %w", input, err)
+				}
+				errLock.Unlock()
+				cancel()
+			}
+		}(idx, input)
+	}
+	wg.Wait()
+
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = ctx.Err()
+	}
+	return firstErr
+}
@@ -0,0 +1,55 @@
+package stereoscope
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetImages(t *testing.T) {
+	fixture := "pkg/image/docker/test-fixtures/docker-save-classic.tar"
+	inputs := []string{fixture, "docker-archive:" + fixture, fixture}
+
+	for _, concurrency := range []int{0, 2} {
+		images, cleanup, err := GetImages(inputs, BatchOptions{Concurrency: concurrency})
+		require.NoError(t, err)
+		require.Len(t, images, len(inputs))
+
+		var cacheDirs []string
+		for _, img := range images {
+			require.NotNil(t, img)
+			assert.Equal(t, images[0].Metadata.ID, img.Metadata.ID)
+			require.NotEmpty(t, img.Layers)
+
+			reader, err := img.OpenLayerTar(img.Layers[0].Metadata.DiffID)
+			require.NoError(t, err)
+			if fh, ok := reader.(*os.File); ok {
+				cacheDirs = append(cacheDirs, filepath.Dir(fh.Name()))
+			}
+			require.NoError(t, reader.Close())
+		}
+
+		// all images share the same layer cache
+		require.Len(t, cacheDirs, len(inputs))
+		for _, dir := range cacheDirs {
+			assert.Equal(t, cacheDirs[0], dir)
+		}
+
+		require.NoError(t, cleanup())
+		_, err = os.Stat(cacheDirs[0])
+		assert.True(t, os.IsNotExist(err))
+	}
+}
+
+func TestGetImages_Error(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.tar")
+	fixture := "pkg/image/docker/test-fixtures/docker-save-classic.tar"
+
+	_, cleanup, err := GetImages([]string{fixture, "docker-archive:" + missing}, BatchOptions{Concurrency: 2})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), missing)
+	assert.Nil(t, cleanup)
+}
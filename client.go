@@ -1,10 +1,17 @@
 package stereoscope
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
 
 	"github.com/anchore/stereoscope/internal/bus"
 	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/event"
 	"github.com/anchore/stereoscope/pkg/file"
 	"github.com/anchore/stereoscope/pkg/image"
 	"github.com/anchore/stereoscope/pkg/image/docker"
@@ -13,60 +20,310 @@ import (
 	"github.com/wagoodman/go-partybus"
 )
 
-var tempDirGenerator = file.NewTempDirGenerator()
+var (
+	tempDirGenerator = file.NewTempDirGenerator()
+
+	tempDirCreatorLock sync.RWMutex
+	tempDirCreator     file.TempDirCreator
+)
+
+// SetTempDirCreator sets how all temp dirs are created (and removed) for images fetched afterwards, e.g. to extract
+// images within a memory-backed tmpfs mount or to create dirs with specific permissions. A nil creator restores the
+// default (dirs within the platform temp dir, see file.DefaultTempDirCreator). Temp dirs are still removed with
+// Cleanup (or the cleanup returned from GetImages).
+func SetTempDirCreator(create file.TempDirCreator) {
+	tempDirCreatorLock.Lock()
+	defer tempDirCreatorLock.Unlock()
+
+	tempDirCreator = create
+	tempDirGenerator.SetCreator(create)
+}
+
+// SetExtractionDir sets the caller-provided dir that image contents (layer tars and file contents) are extracted to
+// for images fetched afterwards, e.g. a RAM disk or a pre-sized volume. The dir must already exist. Ownership of the dir
+// stays with the caller: stereoscope only creates (and on Cleanup removes) its own temp dirs within it, the given dir
+// itself is never removed. An empty dir restores the default (dirs within the platform temp dir). This is a shorthand
+// for SetTempDirCreator with file.DirCreatorWithin.
+func SetExtractionDir(dir string) error {
+	if dir == "" {
+		SetTempDirCreator(nil)
+		return nil
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("unable to access extraction dir=%q: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("extraction dir=%q is not a directory", dir)
+	}
+
+	SetTempDirCreator(file.DirCreatorWithin(dir))
+	return nil
+}
+
+func currentTempDirCreator() file.TempDirCreator {
+	tempDirCreatorLock.RLock()
+	defer tempDirCreatorLock.RUnlock()
+
+	return tempDirCreator
+}
 
 // GetImageFromSource returns an image from the explicitly provided source.
 func GetImageFromSource(imgStr string, source image.Source, registryOptions *image.RegistryOptions) (*image.Image, error) {
+	return GetImageFromSourceContext(context.Background(), imgStr, source, registryOptions)
+}
+
+// GetImageFromSourceContext returns an image from the explicitly provided source. The given context is used to
+// abandon waiting for a fetch slot when a fetch limit has been set (see SetFetchLimit) and to cancel fetching the
+// image from sources that support cancellation (e.g. the docker daemon or a registry). If the context deadline passes
+// while fetching from the docker daemon or a registry then a *image.ErrFetchDeadlineExceeded is returned (see
+// errors.As), describing how far the fetch got.
+func GetImageFromSourceContext(ctx context.Context, imgStr string, source image.Source, registryOptions *image.RegistryOptions) (*image.Image, error) {
+	return getImageFromSource(ctx, imgStr, source, registryOptions, &tempDirGenerator, layerCacheOptions()...)
+}
+
+// getImageFromSource fetches the image from the given source into temp dirs from the given generator, reading the
+// image with the given options.
+func getImageFromSource(ctx context.Context, imgStr string, source image.Source, registryOptions *image.RegistryOptions, tmpDirGen *file.TempDirGenerator, readOptions ...image.AdditionalMetadata) (*image.Image, error) {
+	if disabled := event.DisabledTypes(ctx); len(disabled) > 0 {
+		readOptions = append(readOptions, image.WithDisabledEvents(disabled.Types()...))
+	}
+	// options for the call are applied last, overriding the process-wide defaults
+	readOptions = append(readOptions, image.ReadOptions(ctx)...)
+
+	var img *image.Image
+	err := withImageFromSource(ctx, imgStr, source, registryOptions, tmpDirGen, func(provided *image.Image) error {
+		if err := provided.Read(readOptions...); err != nil {
+			if source == image.OciRegistrySource && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				// registry layers are fetched while the image is read
+				return image.NewErrFetchDeadlineExceeded(imgStr, readProgress(provided))
+			}
+			return fmt.Errorf("could not read image: %+v", err)
+		}
+		img = provided
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// withImageFromSource provides the (unread) image from the given source into temp dirs from the given generator and
+// calls the given function with the image, all while holding a fetch slot for the source (see SetFetchLimit).
+func withImageFromSource(ctx context.Context, imgStr string, source image.Source, registryOptions *image.RegistryOptions, tmpDirGen *file.TempDirGenerator, fn func(*image.Image) error) error {
 	var provider image.Provider
 	log.Debugf("image: source=%+v location=%+v", source, imgStr)
 
+	release, err := fetchLimiterFor(ctx).acquire(ctx, source)
+	if err != nil {
+		return fmt.Errorf("unable to wait for %s source: %w", source, err)
+	}
+	defer release()
+
+	switch source {
+	case image.DockerTarballSource, image.OciTarballSource:
+		imgStr, err = resolveArchiveURL(ctx, imgStr, registryOptions, tmpDirGen)
+		if err != nil {
+			return err
+		}
+		imgStr, err = resolveNestedArchive(imgStr, tmpDirGen)
+		if err != nil {
+			return err
+		}
+	}
+
 	switch source {
 	case image.DockerTarballSource:
 		// note: the imgStr is the path on disk to the tar file
-		provider = docker.NewProviderFromTarball(imgStr, &tempDirGenerator, nil, nil)
+		provider = docker.NewProviderFromTarball(imgStr, tmpDirGen, nil, nil)
 	case image.DockerDaemonSource:
-		provider = docker.NewProviderFromDaemon(imgStr, &tempDirGenerator)
+		provider = docker.NewProviderFromDaemon(imgStr, tmpDirGen)
 	case image.OciDirectorySource:
-		provider = oci.NewProviderFromPath(imgStr, &tempDirGenerator)
+		path, tag := image.ParseOCIDirectoryLocation(imgStr)
+		provider = oci.NewProviderFromPathWithTag(path, tmpDirGen, tag, ociProviderOptions(registryOptions)...)
 	case image.OciTarballSource:
-		provider = oci.NewProviderFromTarball(imgStr, &tempDirGenerator)
+		provider = oci.NewProviderFromTarball(imgStr, tmpDirGen, ociProviderOptions(registryOptions)...)
 	case image.OciRegistrySource:
-		provider = oci.NewProviderFromRegistry(imgStr, &tempDirGenerator, registryOptions)
+		provider = oci.NewProviderFromRegistry(imgStr, tmpDirGen, registryOptions)
 	default:
-		return nil, fmt.Errorf("unable determine image source")
+		return fmt.Errorf("unable determine image source")
 	}
 
-	img, err := provider.Provide()
+	var img *image.Image
+	if contextProvider, ok := provider.(image.ContextProvider); ok {
+		img, err = contextProvider.ProvideContext(ctx)
+	} else {
+		img, err = provider.Provide()
+	}
 	if err != nil {
-		return nil, fmt.Errorf("unable to use %s source: %w", source, err)
+		return fmt.Errorf("unable to use %s source: %w", source, err)
+	}
+
+	return fn(img)
+}
+
+// ociProviderOptions returns the options for the OCI layout providers that follow from the given registry options.
+func ociProviderOptions(registryOptions *image.RegistryOptions) []oci.ProviderOption {
+	if registryOptions == nil {
+		return nil
 	}
+	return []oci.ProviderOption{oci.WithRequireLayers(registryOptions.RequireLayers)}
+}
 
-	err = img.Read()
+// readProgress describes how far reading the given image got (before being interrupted).
+func readProgress(img *image.Image) image.FetchProgress {
+	fetchProgress := image.FetchProgress{
+		Stage:           "reading layers",
+		LayersCompleted: len(img.Metadata.Layers),
+		LayersTotal:     len(img.Metadata.Config.RootFS.DiffIDs),
+	}
+	// note: layers may still be fetched by in-flight requests, thus the stats are copied (see CurrentFetchStats)
+	if stats := img.CurrentFetchStats(); stats != nil {
+		fetchProgress.Bytes = stats.Bytes
+		fetchProgress.Duration = stats.Duration
+	}
+	return fetchProgress
+}
+
+// GetImageFromReader returns an image from an OCI image layout archive (optionally gzip compressed) read from the given
+// stream, such as the export of a buildkit build piped to stdin ("docker buildx build --output type=oci,dest=- ."),
+// without pushing the image to a registry or loading it into a docker daemon. Any attestation manifests within the
+// archive are skipped (see oci.ReaderImageProvider). The stream is read to the end.
+func GetImageFromReader(reader io.Reader) (*image.Image, error) {
+	img, err := oci.NewProviderFromReader(reader, &tempDirGenerator).Provide()
 	if err != nil {
+		return nil, fmt.Errorf("unable to use OCI stream: %w", err)
+	}
+	if err := img.Read(layerCacheOptions()...); err != nil {
 		return nil, fmt.Errorf("could not read image: %+v", err)
 	}
-
 	return img, nil
 }
 
 // GetImage parses the user provided image string and provides an image object; note: the source where the image should
-// be referenced from is automatically inferred.
-func GetImage(userStr string, registryOptions *image.RegistryOptions) (*image.Image, error) {
-	source, imgStr, err := image.DetectSource(userStr)
+// be referenced from is automatically inferred (which can be tuned with the given detection options).
+func GetImage(userStr string, registryOptions *image.RegistryOptions, options ...image.DetectSourceOption) (*image.Image, error) {
+	return GetImageContext(context.Background(), userStr, registryOptions, options...)
+}
+
+// GetImageContext parses the user provided image string and provides an image object; note: the source where the
+// image should be referenced from is automatically inferred (which can be tuned with the given detection options).
+func GetImageContext(ctx context.Context, userStr string, registryOptions *image.RegistryOptions, options ...image.DetectSourceOption) (*image.Image, error) {
+	return getImage(ctx, userStr, registryOptions, options, &tempDirGenerator, layerCacheOptions()...)
+}
+
+// getImage detects the source of the user provided image string and fetches the image into temp dirs from the given
+// generator, reading the image with the given options.
+func getImage(ctx context.Context, userStr string, registryOptions *image.RegistryOptions, options []image.DetectSourceOption, tmpDirGen *file.TempDirGenerator, readOptions ...image.AdditionalMetadata) (*image.Image, error) {
+	source, imgStr, err := detectSource(ctx, userStr, registryOptions, options, tmpDirGen)
 	if err != nil {
 		return nil, err
 	}
-	return GetImageFromSource(imgStr, source, registryOptions)
+	return getImageFromSource(ctx, imgStr, source, registryOptions, tmpDirGen, readOptions...)
+}
+
+// detectSource detects the source of the user provided image string, downloading any archive URL and extracting any
+// nested archive into temp dirs from the given generator.
+func detectSource(ctx context.Context, userStr string, registryOptions *image.RegistryOptions, options []image.DetectSourceOption, tmpDirGen *file.TempDirGenerator) (image.Source, string, error) {
+	userStr, err := resolveArchiveURL(ctx, userStr, registryOptions, tmpDirGen)
+	if err != nil {
+		return image.UnknownSource, "", err
+	}
+
+	userStr, err = resolveNestedArchive(userStr, tmpDirGen)
+	if err != nil {
+		return image.UnknownSource, "", err
+	}
+
+	return image.DetectSource(userStr, options...)
+}
+
+// splitSourceScheme splits the source scheme (e.g. "docker-archive:") from the given user string, returning an empty
+// scheme when there is none.
+func splitSourceScheme(userStr string) (string, string) {
+	if candidates := strings.SplitN(userStr, image.SchemeSeparator, 2); len(candidates) == 2 && image.ParseSourceScheme(candidates[0]) != image.UnknownSource {
+		return candidates[0] + image.SchemeSeparator, candidates[1]
+	}
+	return "", userStr
+}
+
+// resolveArchiveURL downloads an image archive at a http(s) URL (e.g. "https://example.com/image.tar", optionally with
+// an archive scheme such as "oci-archive:https://...") to a temp dir, returning the given user string with the URL
+// replaced by the path to the downloaded archive. User strings that do not refer to a URL are returned as-is.
+func resolveArchiveURL(ctx context.Context, userStr string, registryOptions *image.RegistryOptions, tmpDirGen *file.TempDirGenerator) (string, error) {
+	scheme, location := splitSourceScheme(userStr)
+
+	if !oci.IsArchiveURL(location) {
+		return userStr, nil
+	}
+
+	downloaded, err := oci.DownloadArchive(ctx, location, tmpDirGen, registryOptions)
+	if err != nil {
+		return "", err
+	}
+	return scheme + downloaded, nil
+}
+
+// resolveNestedArchive extracts an image archive nested within another archive (e.g. "artifacts.zip!image.tar") to a
+// temp dir, returning the given user string with the nested path replaced by the path to the extracted archive. User
+// strings that do not refer to a nested archive are returned as-is, where extraction is only attempted when the user
+// string holds the separator and the outer archive exists (see file.SplitNestedArchivePath).
+func resolveNestedArchive(userStr string, tmpDirGen *file.TempDirGenerator) (string, error) {
+	if !strings.Contains(userStr, file.NestedArchiveSeparator) {
+		// e.g. image references, which never hold the separator
+		return userStr, nil
+	}
+
+	scheme, location := splitSourceScheme(userStr)
+
+	outer, inner, ok := file.SplitNestedArchivePath(location)
+	if !ok {
+		return userStr, nil
+	}
+
+	extracted, err := file.ExtractNestedArchive(outer, inner, tmpDirGen)
+	if err != nil {
+		return "", fmt.Errorf("unable to extract nested archive: %w", err)
+	}
+	return scheme + extracted, nil
 }
 
 func SetLogger(logger logger.Logger) {
 	log.Log = logger
 }
 
+// SetTraceMode enables (or disables) verbose trace logging of how images are read by default: every tar entry indexed
+// within each layer, and every decision made while squashing layers (whiteouts applied, opaque directories cleared, and
+// which layer wins for paths that exist in several layers). Trace messages are logged at the debug level to the logger
+// given to SetLogger. This is off by default since the volume of messages scales with the number of files in the image.
+// The default is overridden for a single image with image.WithTraceMode (or for a single call with
+// image.WithReadOptions).
+func SetTraceMode(enabled bool) {
+	log.SetTraceMode(enabled)
+}
+
+// SetProgressReporter sets a reporter that is given the progress of pulling, fetching, and reading images through
+// callbacks, for consumers that would rather not consume the events published on the bus (see SetBus). Both a reporter
+// and a bus may be used at the same time. A nil reporter removes the reporter.
+func SetProgressReporter(reporter event.ProgressReporter) {
+	bus.SetProgressReporter(reporter)
+}
+
 func SetBus(b *partybus.Bus) {
 	bus.SetPublisher(b)
 }
 
+// SetDisabledEvents sets the event types that are never published on the bus (see SetBus), e.g. to suppress
+// event.PullDockerImage but keep event.FetchImage, or to suppress all events in a headless service that renders none of
+// them. Calling with no types restores publishing all events. Event types can also be disabled for a single call by
+// giving a context from event.WithDisabledTypes (to GetImageContext, GetImageFromSourceContext, etc.), or for reading a
+// single image with image.WithDisabledEvents. Note: the progress reporter (see SetProgressReporter) is not affected.
+func SetDisabledEvents(types ...partybus.EventType) {
+	bus.SetDisabledTypes(types...)
+}
+
 func Cleanup() {
 	if err := tempDirGenerator.Cleanup(); err != nil {
 		log.Errorf("failed to cleanup: %w", err)
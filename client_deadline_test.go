@@ -0,0 +1,68 @@
+package stereoscope
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetImageFromSourceContext_DeadlineExceededWhileReading(t *testing.T) {
+	// once pushed, the registry serves the manifest, config, and first layer but stalls on the second layer
+	var pushed int32
+	var blobs int32
+	handler := registry.New()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&pushed) == 1 && r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/blobs/") {
+			// note: the first blob fetched is the config
+			if atomic.AddInt32(&blobs, 1) > 2 {
+				<-r.Context().Done()
+				return
+			}
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	imageStr := fmt.Sprintf("%s/stereoscope/deadline:latest", u.Host)
+	ref, err := name.ParseReference(imageStr, name.Insecure)
+	require.NoError(t, err)
+
+	img, err := random.Image(1024, 2)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+	atomic.StoreInt32(&pushed, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	_, err = GetImageFromSourceContext(ctx, imageStr, image.OciRegistrySource, &image.RegistryOptions{InsecureUseHTTP: true})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "unexpected error: %+v", err)
+
+	var deadlineErr *image.ErrFetchDeadlineExceeded
+	require.True(t, errors.As(err, &deadlineErr), "unexpected error: %+v", err)
+
+	actual := deadlineErr.Progress()
+	assert.Equal(t, "reading layers", actual.Stage)
+	assert.Equal(t, 1, actual.LayersCompleted)
+	assert.Equal(t, 2, actual.LayersTotal)
+	assert.Greater(t, actual.Bytes, int64(1024))
+}
@@ -0,0 +1,191 @@
+package stereoscope
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anchore/stereoscope/pkg/event"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetImage_NestedArchive(t *testing.T) {
+	fixture, err := ioutil.ReadFile("pkg/image/docker/test-fixtures/docker-save-classic.tar")
+	require.NoError(t, err)
+
+	zipPath := filepath.Join(t.TempDir(), "artifacts.zip")
+	fh, err := os.Create(zipPath)
+	require.NoError(t, err)
+	zipWriter := zip.NewWriter(fh)
+	w, err := zipWriter.Create("build/image.tar")
+	require.NoError(t, err)
+	_, err = w.Write(fixture)
+	require.NoError(t, err)
+	require.NoError(t, zipWriter.Close())
+	require.NoError(t, fh.Close())
+
+	t.Cleanup(Cleanup)
+
+	for _, userStr := range []string{zipPath + "!build/image.tar", "docker-archive:" + zipPath + "!build/image.tar"} {
+		t.Run(userStr, func(t *testing.T) {
+			resolved, err := resolveNestedArchive(userStr, &tempDirGenerator)
+			require.NoError(t, err)
+			assert.Equal(t, strings.HasPrefix(userStr, "docker-archive:"), strings.HasPrefix(resolved, "docker-archive:"))
+			assert.NotContains(t, resolved, "!")
+
+			img, err := GetImage(userStr, nil)
+			require.NoError(t, err)
+			require.Len(t, img.Metadata.Tags, 1)
+			assert.Equal(t, "stereoscope-fixture-docker-save:latest", img.Metadata.Tags[0].String())
+		})
+	}
+}
+
+func TestResolveNestedArchive_NotNested(t *testing.T) {
+	existing := filepath.Join(t.TempDir(), "image!v1.tar")
+	require.NoError(t, ioutil.WriteFile(existing, []byte("contents"), 0600))
+
+	for _, userStr := range []string{
+		"alpine:latest",
+		"registry:localhost:5000/alpine@sha256:abc",
+		filepath.Join(t.TempDir(), "missing.zip") + "!image.tar",
+		existing,
+	} {
+		t.Run(userStr, func(t *testing.T) {
+			tmpDirGen := file.NewTempDirGenerator()
+			t.Cleanup(func() {
+				assert.NoError(t, tmpDirGen.Cleanup())
+			})
+
+			resolved, err := resolveNestedArchive(userStr, &tmpDirGen)
+			require.NoError(t, err)
+			assert.Equal(t, userStr, resolved)
+		})
+	}
+}
+
+func TestGetImage_ArchiveURL(t *testing.T) {
+	fixture, err := ioutil.ReadFile("pkg/image/docker/test-fixtures/docker-save-classic.tar")
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/images/image.tar" {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, "image.tar", time.Time{}, bytes.NewReader(fixture))
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(Cleanup)
+
+	for _, userStr := range []string{server.URL + "/images/image.tar", "docker-archive:" + server.URL + "/images/image.tar"} {
+		t.Run(userStr, func(t *testing.T) {
+			img, err := GetImage(userStr, nil)
+			require.NoError(t, err)
+			require.Len(t, img.Metadata.Tags, 1)
+			assert.Equal(t, "stereoscope-fixture-docker-save:latest", img.Metadata.Tags[0].String())
+		})
+	}
+
+	_, err = GetImage(server.URL+"/images/missing.tar", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "404")
+}
+
+func TestSetTempDirCreator(t *testing.T) {
+	root := t.TempDir()
+
+	var created int
+	SetTempDirCreator(func() (string, func() error, error) {
+		created++
+		dir, err := ioutil.TempDir(root, "custom")
+		return dir, func() error { return os.RemoveAll(dir) }, err
+	})
+	t.Cleanup(func() {
+		SetTempDirCreator(nil)
+	})
+	t.Cleanup(Cleanup)
+
+	_, err := GetImage("docker-archive:pkg/image/docker/test-fixtures/docker-save-classic.tar", nil)
+	require.NoError(t, err)
+	assert.Greater(t, created, 0)
+
+	entries, err := ioutil.ReadDir(root)
+	require.NoError(t, err)
+	assert.Len(t, entries, created)
+
+	Cleanup()
+	entries, err = ioutil.ReadDir(root)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestSetExtractionDir(t *testing.T) {
+	root := t.TempDir()
+
+	require.NoError(t, SetExtractionDir(root))
+	t.Cleanup(func() {
+		require.NoError(t, SetExtractionDir(""))
+	})
+	t.Cleanup(Cleanup)
+
+	img, err := GetImage("docker-archive:pkg/image/docker/test-fixtures/docker-save-classic.tar", nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, img.Layers)
+	assert.True(t, strings.HasPrefix(img.Layers[0].Metadata.Digest, "sha256:"))
+
+	entries, err := ioutil.ReadDir(root)
+	require.NoError(t, err)
+	assert.NotEmpty(t, entries)
+
+	// the caller-provided dir is kept, only the dirs created within it are removed
+	Cleanup()
+	assert.DirExists(t, root)
+	entries, err = ioutil.ReadDir(root)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	assert.Error(t, SetExtractionDir(filepath.Join(root, "missing")))
+}
+
+// stageRecorder is an event.ProgressReporter that records the stages reported for each image.
+type stageRecorder struct {
+	lock   sync.Mutex
+	stages map[string][]string
+}
+
+func (r *stageRecorder) OnStage(image string, _ event.StageCode, description string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.stages[image] = append(r.stages[image], description)
+}
+
+func (r *stageRecorder) OnProgress(string, event.StageCode, int64, int64) {}
+
+func TestSetProgressReporter(t *testing.T) {
+	reporter := &stageRecorder{stages: make(map[string][]string)}
+	SetProgressReporter(reporter)
+	t.Cleanup(func() {
+		SetProgressReporter(nil)
+	})
+	t.Cleanup(Cleanup)
+
+	img, err := GetImage("docker-archive:pkg/image/docker/test-fixtures/docker-save-classic.tar", nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, img.Metadata.Tags)
+
+	assert.Equal(t, map[string][]string{
+		img.Metadata.Tags[0].String(): {"reading layers", "squashing layers"},
+	}, reporter.stages)
+}
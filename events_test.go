@@ -0,0 +1,87 @@
+package stereoscope
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/anchore/stereoscope/internal/bus"
+	"github.com/anchore/stereoscope/pkg/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wagoodman/go-partybus"
+)
+
+// eventRecorder is a partybus.Publisher that counts the published events by type.
+type eventRecorder struct {
+	lock   sync.Mutex
+	counts map[partybus.EventType]int
+}
+
+func (r *eventRecorder) Publish(e partybus.Event) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.counts[e.Type]++
+}
+
+func TestSetDisabledEvents(t *testing.T) {
+	fixture := "docker-archive:pkg/image/docker/test-fixtures/docker-save-classic.tar"
+	t.Cleanup(Cleanup)
+
+	tests := []struct {
+		name     string
+		global   []partybus.EventType
+		perCall  []partybus.EventType
+		expected map[partybus.EventType]int
+	}{
+		{
+			name: "all events published by default",
+			expected: map[partybus.EventType]int{
+				event.ReadImage: 1,
+				event.ReadLayer: 1,
+			},
+		},
+		{
+			name:   "globally disabled",
+			global: []partybus.EventType{event.ReadLayer},
+			expected: map[partybus.EventType]int{
+				event.ReadImage: 1,
+			},
+		},
+		{
+			name:    "disabled for the call",
+			perCall: []partybus.EventType{event.ReadImage},
+			expected: map[partybus.EventType]int{
+				event.ReadLayer: 1,
+			},
+		},
+		{
+			name:     "disabled globally and for the call",
+			global:   []partybus.EventType{event.ReadLayer},
+			perCall:  []partybus.EventType{event.ReadImage, event.PullDockerImage},
+			expected: map[partybus.EventType]int{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := &eventRecorder{counts: make(map[partybus.EventType]int)}
+			bus.SetPublisher(recorder)
+			SetDisabledEvents(test.global...)
+			t.Cleanup(func() {
+				bus.SetPublisher(nil)
+				SetDisabledEvents()
+			})
+
+			ctx := context.Background()
+			if len(test.perCall) > 0 {
+				ctx = event.WithDisabledTypes(ctx, test.perCall...)
+			}
+
+			_, err := GetImageContext(ctx, fixture, nil)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expected, recorder.counts)
+		})
+	}
+}
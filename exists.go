@@ -0,0 +1,79 @@
+package stereoscope
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/anchore/stereoscope/pkg/image/docker"
+	"github.com/anchore/stereoscope/pkg/image/oci"
+)
+
+// ImageExists indicates if the image for the user provided image string exists, without fetching the image (see
+// ImageExistsContext).
+func ImageExists(userStr string, registryOptions *image.RegistryOptions, options ...image.DetectSourceOption) (bool, error) {
+	return ImageExistsContext(context.Background(), userStr, registryOptions, options...)
+}
+
+// ImageExistsContext indicates if the image for the user provided image string exists, where the source is inferred
+// just as with GetImage. No image content is fetched: registry images are checked with a manifest HEAD request (using
+// the given registry options for auth and transport) and docker daemon images are inspected. Since the docker daemon
+// pulls images that it does not hold, an image that is missing from the daemon is then checked for within the
+// registry. Archives and directories exist if the path does, where archives at a http(s) URL are checked with a HEAD
+// request (nothing is downloaded) and archives nested within another archive (e.g. "artifacts.zip!image.tar") exist if
+// the outer archive holds the nested archive (nothing is extracted). An image that does not exist is reported as false
+// without an error, whereas an error is returned when existence cannot be determined (e.g. the registry rejected the
+// credentials or the docker daemon is not running).
+func ImageExistsContext(ctx context.Context, userStr string, registryOptions *image.RegistryOptions, options ...image.DetectSourceOption) (bool, error) {
+	if registryOptions == nil {
+		registryOptions = &image.RegistryOptions{}
+	}
+
+	// archives are resolved as with GetImage (see resolveArchiveURL and resolveNestedArchive)
+	_, location := splitSourceScheme(userStr)
+	if oci.IsArchiveURL(location) {
+		return oci.ArchiveExists(ctx, location, registryOptions)
+	}
+	if strings.Contains(location, file.NestedArchiveSeparator) {
+		if outer, inner, ok := file.SplitNestedArchivePath(location); ok {
+			return file.NestedArchiveExists(outer, inner)
+		}
+	}
+
+	source, location, err := image.DetectSource(userStr, options...)
+	if err != nil {
+		return false, err
+	}
+
+	switch source {
+	case image.DockerTarballSource, image.OciTarballSource, image.OciDirectorySource:
+		if source == image.OciDirectorySource {
+			// note: the tag selector is not checked (only the layout)
+			location, _ = image.ParseOCIDirectoryLocation(location)
+		}
+		_, err := os.Stat(location)
+		switch {
+		case err == nil:
+			return true, nil
+		case os.IsNotExist(err):
+			return false, nil
+		default:
+			return false, fmt.Errorf("unable to check for image at path=%q: %w", location, err)
+		}
+	case image.DockerDaemonSource:
+		exists, err := docker.NewProviderFromDaemon(location, nil).Exists(ctx)
+		if err != nil || exists {
+			return exists, err
+		}
+		log.Debugf("image=%q is not held by the docker daemon, checking the registry", location)
+		return oci.NewProviderFromRegistry(location, nil, registryOptions).Exists(ctx)
+	case image.OciRegistrySource:
+		return oci.NewProviderFromRegistry(location, nil, registryOptions).Exists(ctx)
+	default:
+		return false, fmt.Errorf("unable determine image source")
+	}
+}
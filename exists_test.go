@@ -0,0 +1,102 @@
+package stereoscope
+
+import (
+	"archive/zip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageExists(t *testing.T) {
+	var heads int
+	regHandler := registry.New()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/archives/image.tar":
+			if r.Method != http.MethodHead {
+				t.Errorf("unexpected download: %s %s", r.Method, r.URL.Path)
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		case r.URL.Path == "/archives/missing.tar":
+			http.NotFound(w, r)
+			return
+		case r.URL.Path == "/v2/private/test/manifests/latest":
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		case r.Method == http.MethodHead:
+			heads++
+		case r.Method == http.MethodGet && r.URL.Path != "/v2/":
+			t.Errorf("unexpected fetch: %s %s", r.Method, r.URL.Path)
+		}
+		regHandler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	ref, err := name.ParseReference(fmt.Sprintf("%s/stereoscope/test:latest", u.Host), name.Insecure)
+	require.NoError(t, err)
+	img, err := random.Image(1024, 1)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+	heads = 0
+
+	registryOptions := &image.RegistryOptions{InsecureUseHTTP: true}
+
+	zipPath := filepath.Join(t.TempDir(), "artifacts.zip")
+	fh, err := os.Create(zipPath)
+	require.NoError(t, err)
+	zipWriter := zip.NewWriter(fh)
+	_, err = zipWriter.Create("build/image.tar")
+	require.NoError(t, err)
+	require.NoError(t, zipWriter.Close())
+	require.NoError(t, fh.Close())
+
+	tests := []struct {
+		name    string
+		input   string
+		want    bool
+		wantErr bool
+	}{
+		{name: "registry image", input: "registry:" + u.Host + "/stereoscope/test:latest", want: true},
+		{name: "registry image missing tag", input: "registry:" + u.Host + "/stereoscope/test:missing"},
+		{name: "registry image missing repo", input: "registry:" + u.Host + "/stereoscope/missing:latest"},
+		{name: "registry auth failure", input: "registry:" + u.Host + "/private/test:latest", wantErr: true},
+		{name: "archive", input: "pkg/image/docker/test-fixtures/docker-save-classic.tar", want: true},
+		{name: "explicit archive missing", input: "docker-archive:pkg/image/docker/test-fixtures/missing.tar"},
+		{name: "archive URL", input: server.URL + "/archives/image.tar", want: true},
+		{name: "explicit archive URL", input: "docker-archive:" + server.URL + "/archives/image.tar", want: true},
+		{name: "archive URL missing", input: server.URL + "/archives/missing.tar"},
+		{name: "nested archive", input: zipPath + "!build/image.tar", want: true},
+		{name: "nested archive missing", input: "docker-archive:" + zipPath + "!build/missing.tar"},
+		{name: "unknown source", input: "!!not-an-image!!", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := ImageExists(test.input, registryOptions)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, actual)
+		})
+	}
+
+	assert.Equal(t, 3, heads)
+}
@@ -0,0 +1,63 @@
+package stereoscope
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/anchore/stereoscope/pkg/image/oci"
+)
+
+// GetImagesFromIndex fetches and reads an image for every platform within the manifest list (or OCI index) at the
+// given registry reference (see GetImagesFromIndexContext).
+func GetImagesFromIndex(ref string, registryOptions *image.RegistryOptions) ([]*image.Image, func() error, error) {
+	return GetImagesFromIndexContext(context.Background(), ref, registryOptions)
+}
+
+// GetImagesFromIndexContext fetches and reads an image for every platform within the manifest list (or OCI index) at
+// the given registry reference (by tag or by index digest, optionally prefixed with "registry:"). Attestation manifests
+// within the index are skipped. The images are returned in index order along with a cleanup function that removes all
+// temp files for the images (the images must not be used after cleanup). If the reference is for a single image
+// manifest then only that image is returned.
+func GetImagesFromIndexContext(ctx context.Context, ref string, registryOptions *image.RegistryOptions) ([]*image.Image, func() error, error) {
+	if candidates := strings.SplitN(ref, image.SchemeSeparator, 2); len(candidates) == 2 && image.ParseSourceScheme(candidates[0]) != image.UnknownSource {
+		if source := image.ParseSourceScheme(candidates[0]); source != image.OciRegistrySource {
+			return nil, nil, fmt.Errorf("unable to get images from an index with the %s source", source)
+		}
+		ref = candidates[1]
+	}
+
+	if registryOptions == nil {
+		registryOptions = &image.RegistryOptions{}
+	}
+
+	release, err := fetchLimiterFor(ctx).acquire(ctx, image.OciRegistrySource)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to wait for %s source: %w", image.OciRegistrySource, err)
+	}
+	defer release()
+
+	tmpDirGen := file.NewTempDirGeneratorWithCreator(currentTempDirCreator())
+	cleanup := tmpDirGen.Cleanup
+
+	images, err := oci.NewProviderFromRegistry(ref, &tmpDirGen, registryOptions).ProvideAll()
+	if err != nil {
+		_ = cleanup()
+		return nil, nil, fmt.Errorf("unable to use %s source: %w", image.OciRegistrySource, err)
+	}
+
+	for _, img := range images {
+		if err := ctx.Err(); err != nil {
+			_ = cleanup()
+			return nil, nil, err
+		}
+		if err := img.Read(layerCacheOptions()...); err != nil {
+			_ = cleanup()
+			return nil, nil, fmt.Errorf("could not read image=%q: %+v", img.Metadata.ID, err)
+		}
+	}
+
+	return images, cleanup, nil
+}
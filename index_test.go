@@ -0,0 +1,15 @@
+package stereoscope
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetImagesFromIndex_UnsupportedSource(t *testing.T) {
+	_, cleanup, err := GetImagesFromIndex("docker-archive:some/image.tar", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to get images from an index")
+	assert.Nil(t, cleanup)
+}
@@ -1,19 +1,51 @@
 package bus
 
-import "github.com/wagoodman/go-partybus"
+import (
+	"sync"
+
+	"github.com/anchore/stereoscope/pkg/event"
+	"github.com/wagoodman/go-partybus"
+)
 
 var publisher partybus.Publisher
 var active bool
 
+var disabledLock sync.RWMutex
+var disabled event.TypeSet
+
 func SetPublisher(p partybus.Publisher) {
 	publisher = p
-	if p != nil {
-		active = true
+	active = p != nil
+}
+
+// SetDisabledTypes sets the event types that are never published (no types restores publishing all events).
+func SetDisabledTypes(types ...partybus.EventType) {
+	disabledLock.Lock()
+	defer disabledLock.Unlock()
+
+	disabled = event.NewTypeSet(types...)
+}
+
+// Enabled indicates if events of the given type are published, given the additionally disabled types (e.g. for a
+// single call, see event.WithDisabledTypes).
+func Enabled(t partybus.EventType, additional event.TypeSet) bool {
+	if !active || additional.Contains(t) {
+		return false
 	}
+
+	disabledLock.RLock()
+	defer disabledLock.RUnlock()
+
+	return !disabled.Contains(t)
+}
+
+func Publish(e partybus.Event) {
+	PublishUnless(e, nil)
 }
 
-func Publish(event partybus.Event) {
-	if active {
-		publisher.Publish(event)
+// PublishUnless publishes the given event unless the event type is disabled, either globally or by the given types.
+func PublishUnless(e partybus.Event, additional event.TypeSet) {
+	if Enabled(e.Type, additional) {
+		publisher.Publish(e)
 	}
 }
@@ -0,0 +1,39 @@
+package bus
+
+import (
+	"sync"
+
+	"github.com/anchore/stereoscope/pkg/event"
+)
+
+var reporterLock sync.RWMutex
+var reporter event.ProgressReporter
+
+// SetProgressReporter sets the reporter given all stage and progress updates (a nil reporter removes the reporter).
+func SetProgressReporter(r event.ProgressReporter) {
+	reporterLock.Lock()
+	defer reporterLock.Unlock()
+
+	reporter = r
+}
+
+func currentReporter() event.ProgressReporter {
+	reporterLock.RLock()
+	defer reporterLock.RUnlock()
+
+	return reporter
+}
+
+// ReportStage gives the stage transition of the given image to the progress reporter (if any).
+func ReportStage(image string, stage event.StageCode, description string) {
+	if r := currentReporter(); r != nil {
+		r.OnStage(image, stage, description)
+	}
+}
+
+// ReportProgress gives the progress of the current stage of the given image to the progress reporter (if any).
+func ReportProgress(image string, stage event.StageCode, current, total int64) {
+	if r := currentReporter(); r != nil {
+		r.OnProgress(image, stage, current, total)
+	}
+}
@@ -1,66 +1,172 @@
 package docker
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/anchore/stereoscope/internal/log"
 	"github.com/docker/cli/cli/connhelper"
 
+	"github.com/docker/docker/api/types/versions"
 	"github.com/docker/docker/client"
 )
 
 var instanceErr error
 var instance *client.Client
-var once sync.Once
+var initialized bool
+var lock sync.Mutex
 
+// apiVersion and maxAPIVersion are the configured API version pin and cap (empty when not configured)
+var apiVersion, maxAPIVersion string
+
+// pingTimeout bounds the daemon ping used to negotiate a capped API version
+const pingTimeout = 10 * time.Second
+
+// SetAPIVersion pins the docker API version used by clients created after this call (an empty version removes the
+// pin). A pinned version takes precedence over the DOCKER_API_VERSION environment variable and disables negotiation.
+func SetAPIVersion(version string) {
+	lock.Lock()
+	defer lock.Unlock()
+	apiVersion = version
+}
+
+// SetMaxAPIVersion caps the docker API version negotiated with the daemon by clients created after this call (an empty
+// version removes the cap). The cap does not apply when the version is pinned (with SetAPIVersion or the
+// DOCKER_API_VERSION environment variable).
+func SetMaxAPIVersion(version string) {
+	lock.Lock()
+	defer lock.Unlock()
+	maxAPIVersion = version
+}
+
+// APIVersionLimit returns the highest docker API version that may be used with the daemon, which is the pinned
+// version (from SetAPIVersion or the DOCKER_API_VERSION environment variable) or otherwise the cap (from
+// SetMaxAPIVersion). An empty version is returned when neither is configured.
+func APIVersionLimit() string {
+	lock.Lock()
+	defer lock.Unlock()
+
+	switch {
+	case apiVersion != "":
+		return apiVersion
+	case os.Getenv("DOCKER_API_VERSION") != "":
+		return os.Getenv("DOCKER_API_VERSION")
+	}
+	return maxAPIVersion
+}
+
+// GetClient returns a docker client that is shared by all callers within the process. The client is lazily created
+// upon the first call and is reused until Close is called, after which the next call will create a new client.
 func GetClient() (*client.Client, error) {
-	once.Do(func() {
-		var clientOpts = []client.Opt{
-			client.FromEnv,
-			client.WithAPIVersionNegotiation(),
-		}
+	lock.Lock()
+	defer lock.Unlock()
 
-		host := os.Getenv("DOCKER_HOST")
+	if !initialized {
+		instance, instanceErr = newClient()
+		initialized = true
+	}
 
-		if strings.HasPrefix(host, "ssh") {
-			helper, err := connhelper.GetConnectionHelper(host)
-			if err != nil {
-				log.Errorf("failed to fetch docker connection helper: %w", err)
-				instanceErr = err
-				return
-			}
-			clientOpts = append(clientOpts, func(c *client.Client) error {
-				httpClient := &http.Client{
-					Transport: &http.Transport{
-						DialContext: helper.Dialer,
-					},
-				}
-				return client.WithHTTPClient(httpClient)(c)
-			})
-			clientOpts = append(clientOpts, client.WithHost(helper.Host))
-			clientOpts = append(clientOpts, client.WithDialContext(helper.Dialer))
-		}
+	return instance, instanceErr
+}
 
-		if os.Getenv("DOCKER_TLS_VERIFY") != "" && os.Getenv("DOCKER_CERT_PATH") == "" {
-			err := os.Setenv("DOCKER_CERT_PATH", "~/.docker")
-			if err != nil {
-				log.Errorf("failed create docker client: %w", err)
-				instanceErr = err
-				return
-			}
+// Close releases all resources (e.g. idle connections) held by the shared docker client. This should only be
+// called when no other callers are using the client.
+func Close() error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	var err error
+	if instance != nil {
+		err = instance.Close()
+	}
+
+	instance = nil
+	instanceErr = nil
+	initialized = false
+
+	return err
+}
+
+func newClient() (*client.Client, error) {
+	var clientOpts = []client.Opt{
+		client.FromEnv,
+		client.WithAPIVersionNegotiation(),
+	}
+
+	host := os.Getenv("DOCKER_HOST")
+
+	if strings.HasPrefix(host, "ssh") {
+		helper, err := connhelper.GetConnectionHelper(host)
+		if err != nil {
+			log.Errorf("failed to fetch docker connection helper: %w", err)
+			return nil, err
 		}
-		dockerClient, err := client.NewClientWithOpts(clientOpts...)
+		clientOpts = append(clientOpts, func(c *client.Client) error {
+			httpClient := &http.Client{
+				Transport: &http.Transport{
+					DialContext: helper.Dialer,
+				},
+			}
+			return client.WithHTTPClient(httpClient)(c)
+		})
+		clientOpts = append(clientOpts, client.WithHost(helper.Host))
+		clientOpts = append(clientOpts, client.WithDialContext(helper.Dialer))
+	}
+
+	if os.Getenv("DOCKER_TLS_VERIFY") != "" && os.Getenv("DOCKER_CERT_PATH") == "" {
+		err := os.Setenv("DOCKER_CERT_PATH", "~/.docker")
 		if err != nil {
 			log.Errorf("failed create docker client: %w", err)
-			instanceErr = err
-			return
+			return nil, err
 		}
+	}
+	// note: an explicit pin must be applied after the environment (DOCKER_API_VERSION) to take precedence
+	clientOpts = append(clientOpts, client.WithVersion(apiVersion))
 
-		instance = dockerClient
-	})
+	dockerClient, err := client.NewClientWithOpts(clientOpts...)
+	if err != nil {
+		log.Errorf("failed create docker client: %w", err)
+		return nil, err
+	}
 
-	return instance, instanceErr
+	switch {
+	case apiVersion != "":
+		log.Debugf("using pinned docker API version=%q", apiVersion)
+	case os.Getenv("DOCKER_API_VERSION") != "":
+		log.Debugf("using docker API version=%q from DOCKER_API_VERSION", dockerClient.ClientVersion())
+	case maxAPIVersion != "":
+		if err := negotiateMaxAPIVersion(dockerClient, maxAPIVersion); err != nil {
+			return nil, err
+		}
+	}
+
+	return dockerClient, nil
+}
+
+// negotiateMaxAPIVersion negotiates the API version with the daemon, using the given version if the daemon supports
+// a newer API version (or if the daemon cannot be reached).
+func negotiateMaxAPIVersion(dockerClient *client.Client, maxVersion string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+
+	version := maxVersion
+	ping, err := dockerClient.Ping(ctx)
+	switch {
+	case err != nil:
+		log.Debugf("unable to ping docker daemon to negotiate API version (using version=%q): %+v", maxVersion, err)
+	case ping.APIVersion != "" && versions.LessThan(ping.APIVersion, maxVersion):
+		version = ping.APIVersion
+	}
+
+	if err := client.WithVersion(version)(dockerClient); err != nil {
+		return fmt.Errorf("unable to set docker API version=%q: %w", version, err)
+	}
+
+	log.Debugf("negotiated docker API version=%q (daemon=%q max=%q)", version, ping.APIVersion, maxVersion)
+	return nil
 }
@@ -0,0 +1,114 @@
+package docker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetClient_SharedUntilClosed(t *testing.T) {
+	first, err := GetClient()
+	require.NoError(t, err)
+
+	second, err := GetClient()
+	require.NoError(t, err)
+	assert.Same(t, first, second, "expected the client to be shared")
+
+	require.NoError(t, Close())
+
+	third, err := GetClient()
+	require.NoError(t, err)
+	assert.NotSame(t, first, third, "expected a new client after close")
+
+	require.NoError(t, Close())
+	// closing an already closed client is a nop
+	assert.NoError(t, Close())
+}
+
+func TestGetClient_APIVersion(t *testing.T) {
+	// a fake daemon that only supports API version 1.40
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("API-Version", "1.40")
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	setEnv(t, "DOCKER_HOST", "tcp://"+server.Listener.Addr().String())
+
+	tests := []struct {
+		name       string
+		envVersion string
+		version    string
+		maxVersion string
+		expected   string
+	}{
+		{
+			name:     "pinned version",
+			version:  "1.25",
+			expected: "1.25",
+		},
+		{
+			name:       "pinned version takes precedence over the environment",
+			envVersion: "1.30",
+			version:    "1.25",
+			expected:   "1.25",
+		},
+		{
+			name:       "version from the environment",
+			envVersion: "1.30",
+			maxVersion: "1.28",
+			expected:   "1.30",
+		},
+		{
+			name:       "capped below the daemon version",
+			maxVersion: "1.35",
+			expected:   "1.35",
+		},
+		{
+			name:       "capped above the daemon version",
+			maxVersion: "1.41",
+			expected:   "1.40",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setEnv(t, "DOCKER_API_VERSION", test.envVersion)
+			SetAPIVersion(test.version)
+			SetMaxAPIVersion(test.maxVersion)
+			t.Cleanup(func() {
+				SetAPIVersion("")
+				SetMaxAPIVersion("")
+				assert.NoError(t, Close())
+			})
+
+			c, err := GetClient()
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, c.ClientVersion())
+		})
+	}
+}
+
+// setEnv sets (or unsets, when empty) the environment variable for the duration of the test.
+func setEnv(t *testing.T, key, value string) {
+	t.Helper()
+
+	original, exists := os.LookupEnv(key)
+	t.Cleanup(func() {
+		if exists {
+			_ = os.Setenv(key, original)
+		} else {
+			_ = os.Unsetenv(key)
+		}
+	})
+
+	if value == "" {
+		require.NoError(t, os.Unsetenv(key))
+		return
+	}
+	require.NoError(t, os.Setenv(key, value))
+}
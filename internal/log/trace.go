@@ -0,0 +1,47 @@
+package log
+
+import "sync/atomic"
+
+var traceMode int32
+
+// SetTraceMode enables (or disables) trace logging by default, which is logged at the debug level. The default is
+// overridden by the tracers given to the code being traced (see Tracer).
+func SetTraceMode(enabled bool) {
+	var value int32
+	if enabled {
+		value = 1
+	}
+	atomic.StoreInt32(&traceMode, value)
+}
+
+// IsTraceMode indicates if trace logging is enabled by default (useful to skip preparing expensive trace messages).
+func IsTraceMode() bool {
+	return atomic.LoadInt32(&traceMode) == 1
+}
+
+// Tracef logs a (high volume) trace message at the debug level, only when trace mode is enabled by default.
+func Tracef(format string, args ...interface{}) {
+	DefaultTracer().Tracef(format, args...)
+}
+
+// Tracer logs trace messages only when enabled, allowing trace logging to be configured for a single operation (e.g.
+// reading a single image) independent of the default (see SetTraceMode).
+type Tracer bool
+
+// DefaultTracer returns a tracer that is enabled when trace mode is enabled by default (see SetTraceMode).
+func DefaultTracer() Tracer {
+	return Tracer(IsTraceMode())
+}
+
+// Enabled indicates if trace logging is enabled for the tracer (useful to skip preparing expensive trace messages).
+func (t Tracer) Enabled() bool {
+	return bool(t)
+}
+
+// Tracef logs a (high volume) trace message at the debug level, only when the tracer is enabled.
+func (t Tracer) Tracef(format string, args ...interface{}) {
+	if !t {
+		return
+	}
+	Log.Debugf("[trace] "+format, args...)
+}
@@ -0,0 +1,60 @@
+package log
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingLogger struct {
+	nopLogger
+	messages []string
+}
+
+func (l *recordingLogger) Debugf(format string, args ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, args...))
+}
+
+func TestTracef(t *testing.T) {
+	original := Log
+	t.Cleanup(func() {
+		Log = original
+		SetTraceMode(false)
+	})
+
+	recorder := &recordingLogger{}
+	Log = recorder
+
+	Tracef("hidden %d", 1)
+	assert.Empty(t, recorder.messages)
+
+	SetTraceMode(true)
+	assert.True(t, IsTraceMode())
+	Tracef("shown %d", 2)
+
+	SetTraceMode(false)
+	Tracef("hidden %d", 3)
+
+	assert.Equal(t, []string{"[trace] shown 2"}, recorder.messages)
+}
+
+func TestTracer(t *testing.T) {
+	original := Log
+	t.Cleanup(func() {
+		Log = original
+		SetTraceMode(false)
+	})
+
+	recorder := &recordingLogger{}
+	Log = recorder
+
+	assert.False(t, DefaultTracer().Enabled())
+	Tracer(true).Tracef("shown %d", 1)
+
+	SetTraceMode(true)
+	assert.True(t, DefaultTracer().Enabled())
+	Tracer(false).Tracef("hidden %d", 2)
+
+	assert.Equal(t, []string{"[trace] shown 1"}, recorder.messages)
+}
@@ -0,0 +1,124 @@
+package stereoscope
+
+import (
+	"context"
+	"sync"
+
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+// fetchLimits is the process-wide limiter shared by all GetImage* calls, unless a call is given its own limiter (see
+// WithFetchLimiter).
+var fetchLimits = NewFetchLimiter()
+
+// SetFetchLimit sets the maximum number of images that may be fetched concurrently from the docker daemon or a
+// registry (combined) by all calls that are not given their own limiter (see WithFetchLimiter). Callers beyond the
+// limit block until a slot is free or their context is cancelled. A limit of zero or less removes the limit (the
+// default).
+func SetFetchLimit(limit int) {
+	fetchLimits.SetLimit(limit)
+}
+
+// SetSourceFetchLimit sets the maximum number of images that may be fetched concurrently from the given source (e.g.
+// image.DockerDaemonSource) by all calls that are not given their own limiter (see WithFetchLimiter), in addition to
+// any limit set with SetFetchLimit. A limit of zero or less removes the limit for the source (the default).
+func SetSourceFetchLimit(source image.Source, limit int) {
+	fetchLimits.SetSourceLimit(source, limit)
+}
+
+// FetchLimiter is a set of counting semaphores that bound the number of concurrent image fetches among the calls that
+// share the limiter. Since the limits only hold across calls that share a limiter the process-wide limiter is used by
+// default (see SetFetchLimit), while WithFetchLimiter allows a group of calls (e.g. a batch against a rate-limited
+// registry) to be bound by limits of their own. A new limiter has no limits.
+type FetchLimiter struct {
+	lock     sync.Mutex
+	global   chan struct{}
+	bySource map[image.Source]chan struct{}
+}
+
+// NewFetchLimiter creates a limiter without any limits (see FetchLimiter).
+func NewFetchLimiter() *FetchLimiter {
+	return &FetchLimiter{
+		bySource: make(map[image.Source]chan struct{}),
+	}
+}
+
+type fetchLimiterKey struct{}
+
+// WithFetchLimiter returns a copy of the given context where the calls given the context (e.g. GetImageContext) are
+// bound by the limits of the given limiter instead of the process-wide limits (see SetFetchLimit).
+func WithFetchLimiter(ctx context.Context, limiter *FetchLimiter) context.Context {
+	return context.WithValue(ctx, fetchLimiterKey{}, limiter)
+}
+
+// fetchLimiterFor returns the limiter for calls given the context (see WithFetchLimiter), which is the process-wide
+// limiter unless the context carries its own.
+func fetchLimiterFor(ctx context.Context) *FetchLimiter {
+	if ctx != nil {
+		if limiter, ok := ctx.Value(fetchLimiterKey{}).(*FetchLimiter); ok && limiter != nil {
+			return limiter
+		}
+	}
+	return fetchLimits
+}
+
+func newSemaphore(limit int) chan struct{} {
+	if limit <= 0 {
+		return nil
+	}
+	return make(chan struct{}, limit)
+}
+
+// SetLimit sets the maximum number of images that may be fetched concurrently from the docker daemon or a registry
+// (combined). A limit of zero or less removes the limit.
+func (l *FetchLimiter) SetLimit(limit int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.global = newSemaphore(limit)
+}
+
+// SetSourceLimit sets the maximum number of images that may be fetched concurrently from the given source, in addition
+// to the limit set with SetLimit. A limit of zero or less removes the limit for the source.
+func (l *FetchLimiter) SetSourceLimit(source image.Source, limit int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.bySource[source] = newSemaphore(limit)
+}
+
+// acquire blocks until a fetch from the given source is allowed, returning a function that must be called to release
+// the slot once the fetch has completed. An error is returned if the context is done before a slot is acquired.
+func (l *FetchLimiter) acquire(ctx context.Context, source image.Source) (func(), error) {
+	l.lock.Lock()
+	var semaphores []chan struct{}
+	if l.global != nil && (source == image.DockerDaemonSource || source == image.OciRegistrySource) {
+		semaphores = append(semaphores, l.global)
+	}
+	if sem := l.bySource[source]; sem != nil {
+		semaphores = append(semaphores, sem)
+	}
+	l.lock.Unlock()
+
+	// note: a release must return the slot to the semaphore it was taken from, even if the limits have since changed
+	release := func(acquired []chan struct{}) {
+		for _, sem := range acquired {
+			<-sem
+		}
+	}
+
+	var acquired []chan struct{}
+	for _, sem := range semaphores {
+		select {
+		case sem <- struct{}{}:
+			acquired = append(acquired, sem)
+		case <-ctx.Done():
+			release(acquired)
+			return nil, ctx.Err()
+		}
+	}
+
+	return func() {
+		release(acquired)
+	}, nil
+}
@@ -0,0 +1,129 @@
+package stereoscope
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchLimiter_Unlimited(t *testing.T) {
+	limiter := NewFetchLimiter()
+
+	for i := 0; i < 10; i++ {
+		_, err := limiter.acquire(context.Background(), image.OciRegistrySource)
+		require.NoError(t, err)
+	}
+}
+
+func TestFetchLimiter_Acquire(t *testing.T) {
+	tests := []struct {
+		name         string
+		globalLimit  int
+		sourceLimits map[image.Source]int
+		held         image.Source
+		requested    image.Source
+		blocks       bool
+	}{
+		{
+			name:        "global limit blocks registry after daemon",
+			globalLimit: 1,
+			held:        image.DockerDaemonSource,
+			requested:   image.OciRegistrySource,
+			blocks:      true,
+		},
+		{
+			name:        "global limit does not apply to local sources",
+			globalLimit: 1,
+			held:        image.DockerDaemonSource,
+			requested:   image.DockerTarballSource,
+			blocks:      false,
+		},
+		{
+			name:         "source limit blocks the same source",
+			sourceLimits: map[image.Source]int{image.DockerDaemonSource: 1},
+			held:         image.DockerDaemonSource,
+			requested:    image.DockerDaemonSource,
+			blocks:       true,
+		},
+		{
+			name:         "source limit does not block other sources",
+			sourceLimits: map[image.Source]int{image.DockerDaemonSource: 1},
+			held:         image.DockerDaemonSource,
+			requested:    image.OciRegistrySource,
+			blocks:       false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limiter := NewFetchLimiter()
+			limiter.SetLimit(test.globalLimit)
+			for source, limit := range test.sourceLimits {
+				limiter.SetSourceLimit(source, limit)
+			}
+
+			release, err := limiter.acquire(context.Background(), test.held)
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			_, err = limiter.acquire(ctx, test.requested)
+			if test.blocks {
+				assert.ErrorIs(t, err, context.DeadlineExceeded)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			// once the held slot is released the request must always succeed
+			release()
+			otherRelease, err := limiter.acquire(context.Background(), test.requested)
+			require.NoError(t, err)
+			otherRelease()
+		})
+	}
+}
+
+func TestFetchLimiter_ReleaseAfterLimitChange(t *testing.T) {
+	limiter := NewFetchLimiter()
+	limiter.SetLimit(1)
+
+	release, err := limiter.acquire(context.Background(), image.OciRegistrySource)
+	require.NoError(t, err)
+
+	// changing the limit while a fetch is in flight must not cause the release to block or to free a new slot
+	limiter.SetLimit(1)
+	otherRelease, err := limiter.acquire(context.Background(), image.OciRegistrySource)
+	require.NoError(t, err)
+
+	release()
+	otherRelease()
+}
+
+func TestWithFetchLimiter(t *testing.T) {
+	assert.Same(t, fetchLimits, fetchLimiterFor(context.Background()))
+
+	limiter := NewFetchLimiter()
+	limiter.SetLimit(1)
+	ctx := WithFetchLimiter(context.Background(), limiter)
+	assert.Same(t, limiter, fetchLimiterFor(ctx))
+
+	release, err := fetchLimiterFor(ctx).acquire(ctx, image.OciRegistrySource)
+	require.NoError(t, err)
+	defer release()
+
+	// the limits of the call do not affect calls using the process-wide limiter...
+	otherRelease, err := fetchLimiterFor(context.Background()).acquire(context.Background(), image.OciRegistrySource)
+	require.NoError(t, err)
+	otherRelease()
+
+	// ...while calls sharing the limiter are bound by it
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = fetchLimiterFor(timeout).acquire(timeout, image.OciRegistrySource)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,57 @@
+package event
+
+import (
+	"context"
+
+	"github.com/wagoodman/go-partybus"
+)
+
+// TypeSet is a set of event types (e.g. the event types that are not published).
+type TypeSet map[partybus.EventType]struct{}
+
+// NewTypeSet creates a set of the given event types.
+func NewTypeSet(types ...partybus.EventType) TypeSet {
+	set := make(TypeSet, len(types))
+	for _, t := range types {
+		set[t] = struct{}{}
+	}
+	return set
+}
+
+// Contains indicates if the given event type is within the set (a nil set contains nothing).
+func (s TypeSet) Contains(t partybus.EventType) bool {
+	_, ok := s[t]
+	return ok
+}
+
+// Types returns the event types within the set.
+func (s TypeSet) Types() []partybus.EventType {
+	var types []partybus.EventType
+	for t := range s {
+		types = append(types, t)
+	}
+	return types
+}
+
+type disabledTypesKey struct{}
+
+// WithDisabledTypes returns a copy of the given context where the given event types are not published for the calls
+// given the context (e.g. suppress PullDockerImage but keep FetchImage for a single GetImageContext call), in addition
+// to any event types disabled by the parent context or disabled globally.
+func WithDisabledTypes(ctx context.Context, types ...partybus.EventType) context.Context {
+	set := NewTypeSet(types...)
+	for t := range DisabledTypes(ctx) {
+		set[t] = struct{}{}
+	}
+	return context.WithValue(ctx, disabledTypesKey{}, set)
+}
+
+// DisabledTypes returns the event types that are not published for calls given the context (see WithDisabledTypes),
+// which is nil when none are disabled.
+func DisabledTypes(ctx context.Context) TypeSet {
+	if ctx == nil {
+		return nil
+	}
+	set, _ := ctx.Value(disabledTypesKey{}).(TypeSet)
+	return set
+}
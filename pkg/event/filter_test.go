@@ -0,0 +1,24 @@
+package event
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wagoodman/go-partybus"
+)
+
+func TestWithDisabledTypes(t *testing.T) {
+	ctx := context.Background()
+	assert.Nil(t, DisabledTypes(ctx))
+	assert.False(t, DisabledTypes(ctx).Contains(PullDockerImage))
+
+	ctx = WithDisabledTypes(ctx, PullDockerImage)
+	assert.True(t, DisabledTypes(ctx).Contains(PullDockerImage))
+	assert.False(t, DisabledTypes(ctx).Contains(FetchImage))
+
+	// types disabled by the parent context remain disabled
+	child := WithDisabledTypes(ctx, ReadLayer)
+	assert.ElementsMatch(t, []partybus.EventType{PullDockerImage, ReadLayer}, DisabledTypes(child).Types())
+	assert.ElementsMatch(t, []partybus.EventType{PullDockerImage}, DisabledTypes(ctx).Types())
+}
@@ -0,0 +1,13 @@
+package event
+
+// ProgressReporter receives the progress of pulling, fetching, and reading images through callbacks, which is an
+// alternative to consuming the events published on the bus for consumers that do not use go-partybus. Callbacks are
+// made synchronously as progress is made (so must not block), and may be made concurrently for different images.
+type ProgressReporter interface {
+	// OnStage is called when fetching or reading the given image transitions to another stage.
+	OnStage(image string, stage StageCode, description string)
+	// OnProgress is called when progress is made within the current stage of the given image. This is the number of
+	// bytes when pulling and saving images, the number of manifests fetched from a registry, and the number of layer
+	// reads and squashes when reading an image. The total is zero when unknown.
+	OnProgress(image string, stage StageCode, current, total int64)
+}
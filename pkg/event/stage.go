@@ -0,0 +1,72 @@
+package event
+
+import "sync"
+
+const (
+	UnknownStage StageCode = iota
+	PullingStage
+	SavingStage
+	ExtractingStage
+	SquashingStage
+)
+
+var stageCodeStr = [...]string{
+	"unknown",
+	"pulling",
+	"saving",
+	"extracting",
+	"squashing",
+}
+
+// StageCode is a stable identifier for a step in fetching or reading an image, suitable for consumers that need to
+// render (or localize) stages without relying on the human readable stage descriptions.
+type StageCode uint8
+
+// String returns a stable display string for the stage code.
+func (c StageCode) String() string {
+	if int(c) >= len(stageCodeStr) {
+		return stageCodeStr[UnknownStage]
+	}
+	return stageCodeStr[c]
+}
+
+// StageCoder is implemented by event progress values that report a stage code alongside the human readable stage
+// (e.g. the FetchImage and ReadImage event values).
+type StageCoder interface {
+	StageCode() StageCode
+}
+
+// Stage is a progress.Stager that tracks both a human readable description and a stable code for the current stage.
+type Stage struct {
+	lock        sync.RWMutex
+	code        StageCode
+	description string
+}
+
+// Set transitions to the given stage. If no description is given then the stage code string is used.
+func (s *Stage) Set(code StageCode, description string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if description == "" {
+		description = code.String()
+	}
+	s.code = code
+	s.description = description
+}
+
+// Stage returns the human readable description of the current stage (implementing progress.Stager).
+func (s *Stage) Stage() string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.description
+}
+
+// StageCode returns the stable code of the current stage.
+func (s *Stage) StageCode() StageCode {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.code
+}
@@ -0,0 +1,30 @@
+package event
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wagoodman/go-progress"
+)
+
+func TestStage_Set(t *testing.T) {
+	var stager progress.Stager
+	stage := &Stage{}
+	stager = stage
+
+	assert.Equal(t, UnknownStage, stage.StageCode())
+	assert.Equal(t, "", stager.Stage())
+
+	stage.Set(SavingStage, "saving image to disk")
+	assert.Equal(t, SavingStage, stage.StageCode())
+	assert.Equal(t, "saving image to disk", stager.Stage())
+
+	stage.Set(SquashingStage, "")
+	assert.Equal(t, SquashingStage, stage.StageCode())
+	assert.Equal(t, "squashing", stager.Stage())
+}
+
+func TestStageCode_String(t *testing.T) {
+	assert.Equal(t, "pulling", PullingStage.String())
+	assert.Equal(t, "unknown", StageCode(200).String())
+}
@@ -0,0 +1,76 @@
+package event
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/wagoodman/go-progress"
+)
+
+// ErrCancelled is reported as the progress error for an operation that was cancelled before completion (e.g. the
+// FetchImage event value when the context given to the fetch is cancelled). Use errors.Is to check for cancellation.
+var ErrCancelled = errors.New("cancelled")
+
+// TerminableProgress is a progress.Progressable that can be explicitly marked as completed, cancelled, or failed by the
+// producer, overriding the state of the underlying progress. This ensures that consumers (e.g. a UI) never observe an
+// operation as in-progress after it has ended, regardless of how the underlying progress was tracked.
+type TerminableProgress struct {
+	progress.Progressable
+	lock sync.RWMutex
+	err  error
+}
+
+// NewTerminableProgress wraps the given progress.
+func NewTerminableProgress(p progress.Progressable) *TerminableProgress {
+	return &TerminableProgress{
+		Progressable: p,
+	}
+}
+
+// SetCompleted marks the operation as successfully completed.
+func (t *TerminableProgress) SetCompleted() {
+	t.terminate(progress.ErrCompleted)
+}
+
+// SetCancelled marks the operation as cancelled for the given reason (e.g. context.Canceled).
+func (t *TerminableProgress) SetCancelled(reason error) {
+	t.terminate(fmt.Errorf("%w: %v", ErrCancelled, reason))
+}
+
+// SetFailed marks the operation as failed with the given error.
+func (t *TerminableProgress) SetFailed(err error) {
+	t.terminate(err)
+}
+
+// terminate records the terminal state, where only the first terminal state is kept.
+func (t *TerminableProgress) terminate(err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.err == nil {
+		t.err = err
+	}
+}
+
+// Current returns the current progress, which is the full size once the operation has successfully completed.
+func (t *TerminableProgress) Current() int64 {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if t.err == progress.ErrCompleted {
+		return t.Progressable.Size()
+	}
+	return t.Progressable.Current()
+}
+
+// Error returns the terminal state of the operation (if it has ended), otherwise any error from the underlying progress.
+func (t *TerminableProgress) Error() error {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if t.err != nil {
+		return t.err
+	}
+	return t.Progressable.Error()
+}
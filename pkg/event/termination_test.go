@@ -0,0 +1,73 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wagoodman/go-progress"
+)
+
+func TestTerminableProgress(t *testing.T) {
+	tests := []struct {
+		name          string
+		terminate     func(*TerminableProgress)
+		wantCurrent   int64
+		wantCompleted bool
+		wantCancelled bool
+		wantErr       bool
+	}{
+		{
+			name:        "in progress",
+			terminate:   func(*TerminableProgress) {},
+			wantCurrent: 2,
+		},
+		{
+			name:          "completed",
+			terminate:     (*TerminableProgress).SetCompleted,
+			wantCurrent:   10,
+			wantCompleted: true,
+		},
+		{
+			name: "cancelled",
+			terminate: func(p *TerminableProgress) {
+				p.SetCancelled(context.Canceled)
+			},
+			wantCurrent:   2,
+			wantCancelled: true,
+			wantErr:       true,
+		},
+		{
+			name: "failed",
+			terminate: func(p *TerminableProgress) {
+				p.SetFailed(fmt.Errorf("bad things"))
+			},
+			wantCurrent: 2,
+			wantErr:     true,
+		},
+		{
+			name: "first terminal state is kept",
+			terminate: func(p *TerminableProgress) {
+				p.SetCancelled(context.DeadlineExceeded)
+				p.SetCompleted()
+			},
+			wantCurrent:   2,
+			wantCancelled: true,
+			wantErr:       true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			prog := NewTerminableProgress(&progress.Manual{N: 2, Total: 10})
+			test.terminate(prog)
+
+			assert.Equal(t, test.wantCurrent, prog.Current())
+			assert.Equal(t, test.wantCompleted, progress.IsCompleted(prog))
+			assert.Equal(t, test.wantCancelled, errors.Is(prog.Error(), ErrCancelled))
+			assert.Equal(t, test.wantErr, prog.Error() != nil && !progress.IsErrCompleted(prog.Error()))
+		})
+	}
+}
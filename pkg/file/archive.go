@@ -0,0 +1,93 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// gzipMagic is the header found at the start of every gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// archiveReader is a ReadCloser over the (possibly decompressed) contents of an archive. Closing the archiveReader
+// closes the decompressor (if any) and the underlying archive.
+type archiveReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *archiveReader) Close() error {
+	var allErrors error
+	for _, c := range r.closers {
+		if err := c.Close(); err != nil {
+			allErrors = multierror.Append(allErrors, err)
+		}
+	}
+	return allErrors
+}
+
+// OpenArchive opens the tar archive at the given path, transparently decompressing the archive if it is gzip
+// compressed (e.g. a .tar.gz or .tgz file).
+func OpenArchive(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := NewArchiveReader(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return reader, nil
+}
+
+// NewArchiveReader wraps the given tar archive reader, transparently decompressing the contents if the archive
+// is gzip compressed (determined by the leading magic bytes, not by any file extension). The archive is streamed
+// through a buffer of DecompressionBufferSize (see the decompression memory profile).
+func NewArchiveReader(reader io.ReadCloser) (io.ReadCloser, error) {
+	buffered := bufio.NewReaderSize(reader, DecompressionBufferSize())
+
+	header, err := buffered.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("unable to read archive header: %w", err)
+	}
+
+	if !IsGzipHeader(header) {
+		return &archiveReader{
+			Reader:  buffered,
+			closers: []io.Closer{reader},
+		}, nil
+	}
+
+	gzipReader, err := NewGzipReader(buffered, -1)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress archive: %w", err)
+	}
+
+	return &archiveReader{
+		Reader:  gzipReader,
+		closers: []io.Closer{gzipReader, reader},
+	}, nil
+}
+
+// IsGzipHeader indicates if the given leading bytes of a stream are the gzip magic bytes (as used by NewArchiveReader
+// to detect compressed archives).
+func IsGzipHeader(header []byte) bool {
+	return bytes.HasPrefix(header, gzipMagic)
+}
+
+// IsGzipCompressed indicates if the given stream is gzip compressed, determined by the leading magic bytes (see
+// IsGzipHeader). Note: the leading bytes are consumed from the stream, use NewArchiveReader to read the contents.
+func IsGzipCompressed(reader io.Reader) (bool, error) {
+	header := make([]byte, len(gzipMagic))
+	n, err := io.ReadFull(reader, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, fmt.Errorf("unable to read header: %w", err)
+	}
+	return IsGzipHeader(header[:n]), nil
+}
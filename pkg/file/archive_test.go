@@ -0,0 +1,125 @@
+package file
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenArchive(t *testing.T) {
+	tests := []struct {
+		name     string
+		compress bool
+	}{
+		{
+			name:     "uncompressed tar",
+			compress: false,
+		},
+		{
+			name:     "gzip compressed tar",
+			compress: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			archivePath := writeArchive(t, test.compress, map[string]string{
+				"manifest.json": "{}",
+				"some/file.txt": "hello, world!",
+			})
+
+			reader, err := OpenArchive(archivePath)
+			require.NoError(t, err)
+
+			fileReader, err := ReaderFromTar(reader, "some/file.txt")
+			require.NoError(t, err)
+
+			contents, err := ioutil.ReadAll(fileReader)
+			require.NoError(t, err)
+			assert.Equal(t, "hello, world!", string(contents))
+			assert.NoError(t, fileReader.Close())
+		})
+	}
+}
+
+func writeArchive(t *testing.T, compress bool, files map[string]string) string {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	tarWriter := tar.NewWriter(buf)
+	for name, contents := range files {
+		require.NoError(t, tarWriter.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(contents)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tarWriter.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tarWriter.Close())
+
+	archivePath := filepath.Join(t.TempDir(), "archive.tar")
+	fh, err := os.Create(archivePath)
+	require.NoError(t, err)
+	defer fh.Close()
+
+	if !compress {
+		_, err = fh.Write(buf.Bytes())
+		require.NoError(t, err)
+		return archivePath
+	}
+
+	gzipWriter := gzip.NewWriter(fh)
+	_, err = gzipWriter.Write(buf.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, gzipWriter.Close())
+
+	return archivePath
+}
+
+func TestIsGzipCompressed(t *testing.T) {
+	compressed := &bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(compressed)
+	_, err := gzipWriter.Write([]byte("contents"))
+	require.NoError(t, err)
+	require.NoError(t, gzipWriter.Close())
+
+	tests := []struct {
+		name     string
+		contents []byte
+		want     bool
+	}{
+		{
+			name:     "gzip compressed",
+			contents: compressed.Bytes(),
+			want:     true,
+		},
+		{
+			name:     "uncompressed",
+			contents: []byte("contents"),
+		},
+		{
+			name:     "shorter than the magic bytes",
+			contents: []byte{0x1f},
+		},
+		{
+			name: "empty",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := IsGzipCompressed(bytes.NewReader(test.contents))
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
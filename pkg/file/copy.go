@@ -0,0 +1,57 @@
+package file
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultCopyBufferSize is the buffer size used when copying image content to disk (the same as io.Copy).
+const DefaultCopyBufferSize = 32 * KB
+
+var (
+	// copyBufferSize is the buffer size set with SetCopyBufferSize (zero when not set)
+	copyBufferSize int64
+	copyBuffers    sync.Pool
+)
+
+// SetCopyBufferSize sets the size of the buffer used when copying image content to disk (e.g. saving an image from
+// the docker daemon, extracting archives, and caching uncompressed layers). Larger buffers reduce syscall overhead
+// for large images on fast storage, however, an explicit buffer disables the copy fast paths of the platform (e.g.
+// copy_file_range and sendfile between files), thus is only used once set. A size of zero or less restores the
+// default, where content is copied as with io.Copy (with a buffer of DefaultCopyBufferSize when no fast path applies).
+func SetCopyBufferSize(size int) {
+	if size < 0 {
+		size = 0
+	}
+	atomic.StoreInt64(&copyBufferSize, int64(size))
+}
+
+// CopyBufferSize returns the size of the buffer used when copying image content to disk.
+func CopyBufferSize() int {
+	if size := atomic.LoadInt64(&copyBufferSize); size > 0 {
+		return int(size)
+	}
+	return DefaultCopyBufferSize
+}
+
+// Copy copies from src to dst as with io.Copy, unless a buffer size has been set (see SetCopyBufferSize) in which case
+// the buffer of that size is always used.
+func Copy(dst io.Writer, src io.Reader) (int64, error) {
+	size := int(atomic.LoadInt64(&copyBufferSize))
+	if size <= 0 {
+		// note: io.Copy keeps the io.ReaderFrom and io.WriterTo fast paths (e.g. *os.File.ReadFrom)
+		return io.Copy(dst, src)
+	}
+
+	buf, ok := copyBuffers.Get().(*[]byte)
+	if !ok || len(*buf) != size {
+		b := make([]byte, size)
+		buf = &b
+	}
+	defer copyBuffers.Put(buf)
+
+	// note: the writer and reader are wrapped to hide any io.ReaderFrom or io.WriterTo implementations, otherwise
+	// io.CopyBuffer would ignore the given buffer (e.g. *os.File.ReadFrom falls back to io.Copy).
+	return io.CopyBuffer(struct{ io.Writer }{dst}, struct{ io.Reader }{src}, *buf)
+}
@@ -0,0 +1,119 @@
+package file
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// benchmarkCopySize is the amount of content copied per benchmark iteration (large enough for the buffer size to
+// dominate, while keeping each iteration short).
+const benchmarkCopySize = 256 * MB
+
+// zeroReader is an endless reader of zeros that does not implement io.WriterTo.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+// countingWriter records the size of each write given to it.
+type countingWriter struct {
+	writes []int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes = append(w.writes, len(p))
+	return len(p), nil
+}
+
+func TestCopy(t *testing.T) {
+	t.Cleanup(func() {
+		SetCopyBufferSize(0)
+	})
+
+	contents := bytes.Repeat([]byte("a"), 3*MB)
+
+	tests := []struct {
+		name              string
+		bufferSize        int
+		expectedSize      int
+		expectedFirstSize int
+	}{
+		{
+			name:         "default keeps the io.Copy fast paths",
+			bufferSize:   0,
+			expectedSize: DefaultCopyBufferSize,
+			// note: bytes.Reader implements io.WriterTo, which writes the contents in a single call
+			expectedFirstSize: len(contents),
+		},
+		{
+			name:              "custom buffer",
+			bufferSize:        MB,
+			expectedSize:      MB,
+			expectedFirstSize: MB,
+		},
+		{
+			name:              "negative size restores the default",
+			bufferSize:        -1,
+			expectedSize:      DefaultCopyBufferSize,
+			expectedFirstSize: len(contents),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			SetCopyBufferSize(test.bufferSize)
+			assert.Equal(t, test.expectedSize, CopyBufferSize())
+
+			dst := &countingWriter{}
+			n, err := Copy(dst, bytes.NewReader(contents))
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(contents)), n)
+
+			require.NotEmpty(t, dst.writes)
+			assert.Equal(t, test.expectedFirstSize, dst.writes[0])
+		})
+	}
+}
+
+func BenchmarkCopy(b *testing.B) {
+	if testing.Short() {
+		b.Skip("skipping large copy benchmark in short mode")
+	}
+
+	b.Cleanup(func() {
+		SetCopyBufferSize(0)
+	})
+
+	for _, size := range []int{DefaultCopyBufferSize, MB} {
+		b.Run(fmt.Sprintf("buffer=%dKiB", size/KB), func(b *testing.B) {
+			SetCopyBufferSize(size)
+			dst, err := os.Create(filepath.Join(b.TempDir(), "image.tar"))
+			if err != nil {
+				b.Fatalf("unable to create destination: %+v", err)
+			}
+			defer dst.Close()
+
+			b.SetBytes(benchmarkCopySize)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := dst.Seek(0, io.SeekStart); err != nil {
+					b.Fatalf("unable to rewind destination: %+v", err)
+				}
+				if _, err := Copy(dst, io.LimitReader(zeroReader{}, benchmarkCopySize)); err != nil {
+					b.Fatalf("failure during benchmark: %+v", err)
+				}
+			}
+		})
+	}
+}
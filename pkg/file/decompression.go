@@ -0,0 +1,156 @@
+package file
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/anchore/stereoscope/internal/log"
+)
+
+// DefaultDecompressionBufferSize is the size of the read buffer ahead of each decompressor (the same as bufio).
+const DefaultDecompressionBufferSize = 4 * KB
+
+// Layer extraction memory profile: layer blobs are always streamed (never held in memory as a whole). Each layer is
+// read through a buffer of DecompressionBufferSize, decompressed, and copied to the uncompressed layer tar on disk
+// through a buffer of CopyBufferSize (see SetCopyBufferSize). A gzip decompressor additionally holds a fixed 32 KiB
+// window along with roughly 40 KiB of decoder state, while a zstd decompressor holds the window declared by the blob
+// (commonly 8 MiB at most). Thus the memory used per layer is bounded regardless of the layer size, and layers are
+// extracted one at a time. The file index of each layer is held in memory, which grows with the number of files (see
+// the image package low memory mode), not the size of the files.
+var (
+	decompressionBufferSize int64 = DefaultDecompressionBufferSize
+
+	externalGzipLock sync.RWMutex
+	externalGzip     *ExternalDecompressor
+)
+
+// SetDecompressionBufferSize sets the size of the read buffer ahead of each layer and archive decompressor, which
+// bounds how much compressed content is read at a time. Larger buffers reduce syscall overhead for large layers, while
+// smaller buffers reduce the memory held per layer. A size of zero or less restores the default
+// (DefaultDecompressionBufferSize).
+func SetDecompressionBufferSize(size int) {
+	if size <= 0 {
+		size = DefaultDecompressionBufferSize
+	}
+	atomic.StoreInt64(&decompressionBufferSize, int64(size))
+}
+
+// DecompressionBufferSize returns the size of the read buffer ahead of each layer and archive decompressor.
+func DecompressionBufferSize() int {
+	return int(atomic.LoadInt64(&decompressionBufferSize))
+}
+
+// ExternalDecompressor describes a program that gzip content is offloaded to for decompression (e.g. pigz), moving the
+// decompression work (and memory) out of process.
+type ExternalDecompressor struct {
+	// Command is the program (and arguments) that reads gzip content from stdin and writes the decompressed content to
+	// stdout (e.g. []string{"pigz", "-dc"}).
+	Command []string
+	// MinSize is the minimum compressed size in bytes of the content to offload, where smaller (or unknown size)
+	// content is decompressed in-process. Zero offloads all content (including content of unknown size).
+	MinSize int64
+}
+
+// SetExternalGzipDecompressor offloads the decompression of gzip layers (and archives) to the given program. A nil
+// decompressor (or one without a command) restores in-process decompression.
+func SetExternalGzipDecompressor(decompressor *ExternalDecompressor) {
+	externalGzipLock.Lock()
+	defer externalGzipLock.Unlock()
+
+	if decompressor == nil || len(decompressor.Command) == 0 {
+		externalGzip = nil
+		return
+	}
+	clone := *decompressor
+	clone.Command = append([]string(nil), decompressor.Command...)
+	externalGzip = &clone
+}
+
+func currentExternalGzipDecompressor() *ExternalDecompressor {
+	externalGzipLock.RLock()
+	defer externalGzipLock.RUnlock()
+
+	return externalGzip
+}
+
+// NewGzipReader decompresses the given gzip content of the given compressed size (or -1 if unknown), either
+// in-process or with the external decompressor (see SetExternalGzipDecompressor). Closing the returned reader does not
+// close the given reader.
+func NewGzipReader(reader io.Reader, size int64) (io.ReadCloser, error) {
+	external := currentExternalGzipDecompressor()
+	if external == nil || (external.MinSize > 0 && size < external.MinSize) {
+		return gzip.NewReader(reader)
+	}
+	return newExternalReader(external.Command, reader)
+}
+
+// externalReader is the stdout of an external decompressor process.
+type externalReader struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	stderr *bytes.Buffer
+	done   bool
+	err    error
+}
+
+func newExternalReader(command []string, reader io.Reader) (*externalReader, error) {
+	cmd := exec.Command(command[0], command[1:]...) //nolint:gosec // the command is configured by the caller
+	cmd.Stdin = reader
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("unable to start external decompressor=%q: %w", strings.Join(command, " "), err)
+	}
+	log.Debugf("decompressing with external decompressor=%q", strings.Join(command, " "))
+
+	return &externalReader{
+		cmd:    cmd,
+		stdout: stdout,
+		stderr: stderr,
+	}, nil
+}
+
+// Read reads the decompressed content, where a failure of the decompressor is raised instead of the end of the content
+// (e.g. corrupt content).
+func (r *externalReader) Read(p []byte) (int, error) {
+	n, err := r.stdout.Read(p)
+	if err == io.EOF {
+		if waitErr := r.wait(); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+func (r *externalReader) wait() error {
+	if r.done {
+		return r.err
+	}
+	r.done = true
+	if err := r.cmd.Wait(); err != nil {
+		r.err = fmt.Errorf("external decompressor failed: %w (stderr=%q)", err, strings.TrimSpace(r.stderr.String()))
+	}
+	return r.err
+}
+
+// Close stops the decompressor. Note: the decompressor failing is only reported when all content has been read, since
+// closing early interrupts the decompressor.
+func (r *externalReader) Close() error {
+	if r.done {
+		return nil
+	}
+	_ = r.stdout.Close()
+	_ = r.wait()
+	return nil
+}
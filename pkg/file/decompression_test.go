@@ -0,0 +1,201 @@
+package file
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// benchmarkLayerSize is the uncompressed size of the gzip layer decompressed per benchmark iteration (a 2 GiB layer,
+// comparable to large real-world layers).
+const benchmarkLayerSize = 2 * GB
+
+func gzipContents(t testing.TB, contents []byte) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	writer := gzip.NewWriter(buf)
+	_, err := writer.Write(contents)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+func TestSetDecompressionBufferSize(t *testing.T) {
+	t.Cleanup(func() {
+		SetDecompressionBufferSize(0)
+	})
+
+	assert.Equal(t, DefaultDecompressionBufferSize, DecompressionBufferSize())
+
+	SetDecompressionBufferSize(MB)
+	assert.Equal(t, MB, DecompressionBufferSize())
+
+	SetDecompressionBufferSize(-1)
+	assert.Equal(t, DefaultDecompressionBufferSize, DecompressionBufferSize())
+}
+
+func TestNewGzipReader(t *testing.T) {
+	if _, err := exec.LookPath("gzip"); err != nil {
+		t.Skip("gzip is not available")
+	}
+	t.Cleanup(func() {
+		SetExternalGzipDecompressor(nil)
+	})
+
+	contents := bytes.Repeat([]byte("stereoscope"), 10*KB)
+	compressed := gzipContents(t, contents)
+
+	tests := []struct {
+		name             string
+		decompressor     *ExternalDecompressor
+		size             int64
+		expectedExternal bool
+	}{
+		{
+			name:             "in-process by default",
+			size:             int64(len(compressed)),
+			expectedExternal: false,
+		},
+		{
+			name:             "external for all content",
+			decompressor:     &ExternalDecompressor{Command: []string{"gzip", "-dc"}},
+			size:             -1,
+			expectedExternal: true,
+		},
+		{
+			name:             "external above the minimum size",
+			decompressor:     &ExternalDecompressor{Command: []string{"gzip", "-dc"}, MinSize: 10},
+			size:             int64(len(compressed)),
+			expectedExternal: true,
+		},
+		{
+			name:             "in-process below the minimum size",
+			decompressor:     &ExternalDecompressor{Command: []string{"gzip", "-dc"}, MinSize: int64(len(compressed)) + 1},
+			size:             int64(len(compressed)),
+			expectedExternal: false,
+		},
+		{
+			name:             "in-process for unknown size with a minimum size",
+			decompressor:     &ExternalDecompressor{Command: []string{"gzip", "-dc"}, MinSize: 10},
+			size:             -1,
+			expectedExternal: false,
+		},
+		{
+			name:             "in-process without a command",
+			decompressor:     &ExternalDecompressor{},
+			size:             int64(len(compressed)),
+			expectedExternal: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			SetExternalGzipDecompressor(test.decompressor)
+
+			reader, err := NewGzipReader(bytes.NewReader(compressed), test.size)
+			require.NoError(t, err)
+
+			_, isExternal := reader.(*externalReader)
+			assert.Equal(t, test.expectedExternal, isExternal)
+
+			actual, err := ioutil.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, contents, actual)
+			assert.NoError(t, reader.Close())
+		})
+	}
+}
+
+func TestNewGzipReader_ExternalFailure(t *testing.T) {
+	if _, err := exec.LookPath("gzip"); err != nil {
+		t.Skip("gzip is not available")
+	}
+	t.Cleanup(func() {
+		SetExternalGzipDecompressor(nil)
+	})
+	SetExternalGzipDecompressor(&ExternalDecompressor{Command: []string{"gzip", "-dc"}})
+
+	reader, err := NewGzipReader(bytes.NewReader([]byte("not gzip content")), -1)
+	require.NoError(t, err)
+
+	_, err = ioutil.ReadAll(reader)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "external decompressor failed")
+	assert.NoError(t, reader.Close())
+}
+
+func TestNewGzipReader_ExternalCloseEarly(t *testing.T) {
+	if _, err := exec.LookPath("gzip"); err != nil {
+		t.Skip("gzip is not available")
+	}
+	t.Cleanup(func() {
+		SetExternalGzipDecompressor(nil)
+	})
+	SetExternalGzipDecompressor(&ExternalDecompressor{Command: []string{"gzip", "-dc"}})
+
+	compressed := gzipContents(t, bytes.Repeat([]byte("a"), 10*MB))
+	reader, err := NewGzipReader(bytes.NewReader(compressed), -1)
+	require.NoError(t, err)
+
+	_, err = io.ReadFull(reader, make([]byte, KB))
+	require.NoError(t, err)
+	assert.NoError(t, reader.Close())
+}
+
+func TestNewGzipReader_ExternalMissingCommand(t *testing.T) {
+	t.Cleanup(func() {
+		SetExternalGzipDecompressor(nil)
+	})
+	SetExternalGzipDecompressor(&ExternalDecompressor{Command: []string{"stereoscope-does-not-exist"}})
+
+	_, err := NewGzipReader(bytes.NewReader(nil), -1)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to start external decompressor")
+}
+
+func BenchmarkNewArchiveReader(b *testing.B) {
+	b.Cleanup(func() {
+		SetDecompressionBufferSize(0)
+	})
+
+	// a 2 GiB (uncompressed) gzip layer, where the benchmark measures the cost of decompression alone
+	buf := &bytes.Buffer{}
+	writer, err := gzip.NewWriterLevel(buf, gzip.BestSpeed)
+	if err != nil {
+		b.Fatalf("unable to create gzip writer: %+v", err)
+	}
+	if _, err := io.Copy(writer, io.LimitReader(zeroReader{}, benchmarkLayerSize)); err != nil {
+		b.Fatalf("unable to compress layer: %+v", err)
+	}
+	if err := writer.Close(); err != nil {
+		b.Fatalf("unable to compress layer: %+v", err)
+	}
+	compressed := buf.Bytes()
+
+	for _, size := range []int{DefaultDecompressionBufferSize, 64 * KB, MB} {
+		b.Run(fmt.Sprintf("buffer=%dKiB", size/KB), func(b *testing.B) {
+			SetDecompressionBufferSize(size)
+
+			b.SetBytes(benchmarkLayerSize)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				reader, err := NewArchiveReader(ioutil.NopCloser(bytes.NewReader(compressed)))
+				if err != nil {
+					b.Fatalf("unable to open layer: %+v", err)
+				}
+				if _, err := Copy(ioutil.Discard, reader); err != nil {
+					b.Fatalf("failure during benchmark: %+v", err)
+				}
+				_ = reader.Close()
+			}
+		})
+	}
+}
@@ -26,6 +26,9 @@ type Metadata struct {
 	IsDir    bool
 	Mode     os.FileMode
 	MIMEType string
+	// Xattrs are the extended attributes of the file by name (e.g. "security.capability"), including POSIX ACLs (the
+	// "system.posix_acl_access" and "system.posix_acl_default" attributes). This is nil when there are none.
+	Xattrs map[string]string
 }
 
 func NewMetadata(header tar.Header, sequence int64, content io.Reader) Metadata {
@@ -41,5 +44,6 @@ func NewMetadata(header tar.Header, sequence int64, content io.Reader) Metadata
 		GroupID:       header.Gid,
 		IsDir:         header.FileInfo().IsDir(),
 		MIMEType:      MIMEType(content),
+		Xattrs:        xattrsFromHeader(header),
 	}
 }
@@ -0,0 +1,199 @@
+package file
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/anchore/stereoscope/internal/log"
+)
+
+// NestedArchiveSeparator separates the path of an outer archive from the path of an archive within it
+// (e.g. "artifacts.zip!image.tar").
+const NestedArchiveSeparator = "!"
+
+// nestedArchiveReadLimit is the maximum size of an archive extracted from another archive (see perFileReadLimit).
+var nestedArchiveReadLimit int64 = perFileReadLimit
+
+// zipMagic is the header found at the start of every (non-empty) zip file.
+var zipMagic = []byte("PK\x03\x04")
+
+// SplitNestedArchivePath splits the given path into the path of the outer archive and the path of the archive within
+// it (e.g. "artifacts.zip!image.tar" into "artifacts.zip" and "image.tar"). The path is only considered to be nested
+// if the outer archive exists and the given path does not (since "!" is valid within a filename).
+func SplitNestedArchivePath(p string) (outer, inner string, ok bool) {
+	parts := strings.SplitN(p, NestedArchiveSeparator, 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+
+	if _, err := os.Stat(p); err == nil {
+		return "", "", false
+	}
+
+	info, err := os.Stat(parts[0])
+	if err != nil || !info.Mode().IsRegular() {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// ExtractNestedArchive extracts the archive at the given inner path from the zip or tar (possibly gzip compressed)
+// archive at the outer path to a new temp dir (cleaned up with the given generator), returning the path to the
+// extracted archive. As with UntarToDirectory, the nested archive may not exceed the per-file read limit (2 GB).
+func ExtractNestedArchive(outerPath, innerPath string, tmpDirGen *TempDirGenerator) (string, error) {
+	innerPath = strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(innerPath)), "/")
+
+	reader, err := nestedArchiveReader(outerPath, innerPath)
+	if err != nil {
+		return "", fmt.Errorf("unable to find %q within archive=%q: %w", innerPath, outerPath, err)
+	}
+	defer reader.Close()
+
+	tempDir, err := tmpDirGen.NewTempDir()
+	if err != nil {
+		return "", err
+	}
+
+	extractedPath := filepath.Join(tempDir, path.Base(innerPath))
+	fh, err := os.Create(extractedPath)
+	if err != nil {
+		return "", fmt.Errorf("unable to create nested archive file: %w", err)
+	}
+	defer func() {
+		if err := fh.Close(); err != nil {
+			log.Errorf("unable to close nested archive file (%s): %w", extractedPath, err)
+		}
+	}()
+
+	// limit the read to prevent decompression bomb attacks (as with UntarToDirectory)
+	numBytes, err := Copy(fh, io.LimitReader(reader, nestedArchiveReadLimit))
+	if err != nil {
+		return "", fmt.Errorf("unable to extract %q from archive=%q: %w", innerPath, outerPath, err)
+	}
+	if numBytes >= nestedArchiveReadLimit {
+		return "", fmt.Errorf("nested archive %q within archive=%q exceeds the read limit of %d bytes (potential decompression bomb attack)", innerPath, outerPath, nestedArchiveReadLimit)
+	}
+
+	log.Debugf("extracted nested archive %q from %q to %q", innerPath, outerPath, extractedPath)
+	return extractedPath, nil
+}
+
+// NestedArchiveExists indicates if the archive at the given inner path exists within the zip or tar (possibly gzip
+// compressed) archive at the outer path, without extracting the nested archive (see ExtractNestedArchive).
+func NestedArchiveExists(outerPath, innerPath string) (bool, error) {
+	innerPath = strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(innerPath)), "/")
+
+	reader, err := nestedArchiveReader(outerPath, innerPath)
+	if err != nil {
+		var notFound *ErrFileNotFound
+		if errors.As(err, &notFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("unable to search archive=%q: %w", outerPath, err)
+	}
+	return true, reader.Close()
+}
+
+// nestedArchiveReader returns a reader for the archive at the given inner path within the archive at the outer path.
+func nestedArchiveReader(outerPath, innerPath string) (io.ReadCloser, error) {
+	isZip, err := isZipArchive(outerPath)
+	if err != nil {
+		return nil, err
+	}
+	if isZip {
+		return readerFromZip(outerPath, innerPath)
+	}
+	return readerFromArchive(outerPath, innerPath)
+}
+
+// isZipArchive indicates if the file at the given path is a zip file (determined by the leading magic bytes).
+func isZipArchive(p string) (bool, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	header, err := bufio.NewReader(f).Peek(len(zipMagic))
+	if err != nil && err != io.EOF {
+		return false, fmt.Errorf("unable to read archive header: %w", err)
+	}
+	return bytes.Equal(header, zipMagic), nil
+}
+
+// readerFromZip returns a reader for the given entry within the zip file at the given path.
+func readerFromZip(zipPath, entryPath string) (io.ReadCloser, error) {
+	zipReader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range zipReader.File {
+		if !f.Mode().IsRegular() || strings.TrimPrefix(path.Clean("/"+f.Name), "/") != entryPath {
+			continue
+		}
+		entryReader, err := f.Open()
+		if err != nil {
+			_ = zipReader.Close()
+			return nil, err
+		}
+		return &tarFile{
+			Reader: entryReader,
+			Closer: multiCloser{entryReader, zipReader},
+		}, nil
+	}
+
+	_ = zipReader.Close()
+	return nil, &ErrFileNotFound{entryPath}
+}
+
+// readerFromArchive returns a reader for the given entry within the (possibly compressed) tar at the given path.
+func readerFromArchive(archivePath, entryPath string) (io.ReadCloser, error) {
+	f, err := OpenArchive(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var result io.ReadCloser
+	visitor := func(entry TarFileEntry) error {
+		if !entry.Header.FileInfo().Mode().IsRegular() || strings.TrimPrefix(path.Clean("/"+entry.Header.Name), "/") != entryPath {
+			return nil
+		}
+		result = &tarFile{
+			Reader: entry.Reader,
+			Closer: f,
+		}
+		return ErrTarStopIteration
+	}
+	if err := IterateTar(f, visitor); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	if result == nil {
+		_ = f.Close()
+		return nil, &ErrFileNotFound{entryPath}
+	}
+	return result, nil
+}
+
+// multiCloser closes all closers in order, returning the first error encountered.
+type multiCloser []io.Closer
+
+func (m multiCloser) Close() error {
+	var firstErr error
+	for _, c := range m {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,183 @@
+package file
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeZip(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	zipPath := filepath.Join(t.TempDir(), "archive.zip")
+	fh, err := os.Create(zipPath)
+	require.NoError(t, err)
+	defer fh.Close()
+
+	zipWriter := zip.NewWriter(fh)
+	for name, contents := range files {
+		w, err := zipWriter.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zipWriter.Close())
+
+	return zipPath
+}
+
+func TestExtractNestedArchive(t *testing.T) {
+	files := map[string]string{
+		"README.md":        "not an image",
+		"images/image.tar": "the image contents",
+	}
+
+	tests := []struct {
+		name      string
+		outerPath string
+		innerPath string
+		wantErr   bool
+	}{
+		{
+			name:      "zip",
+			outerPath: writeZip(t, files),
+			innerPath: "images/image.tar",
+		},
+		{
+			name:      "tar",
+			outerPath: writeArchive(t, false, files),
+			innerPath: "images/image.tar",
+		},
+		{
+			name:      "gzip compressed tar",
+			outerPath: writeArchive(t, true, files),
+			innerPath: "images/image.tar",
+		},
+		{
+			name:      "leading slash on inner path",
+			outerPath: writeZip(t, files),
+			innerPath: "/images/image.tar",
+		},
+		{
+			name:      "missing inner archive",
+			outerPath: writeArchive(t, false, files),
+			innerPath: "images/other.tar",
+			wantErr:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tmpDirGen := NewTempDirGenerator()
+			extracted, err := ExtractNestedArchive(test.outerPath, test.innerPath, &tmpDirGen)
+			if test.wantErr {
+				assert.Error(t, err)
+				require.NoError(t, tmpDirGen.Cleanup())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "image.tar", filepath.Base(extracted))
+
+			contents, err := ioutil.ReadFile(extracted)
+			require.NoError(t, err)
+			assert.Equal(t, "the image contents", string(contents))
+
+			// the extracted archive is removed along with the generated temp dirs
+			require.NoError(t, tmpDirGen.Cleanup())
+			_, err = os.Stat(extracted)
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
+
+func TestExtractNestedArchive_ReadLimit(t *testing.T) {
+	original := nestedArchiveReadLimit
+	nestedArchiveReadLimit = 10
+	t.Cleanup(func() {
+		nestedArchiveReadLimit = original
+	})
+
+	for _, outerPath := range []string{
+		writeZip(t, map[string]string{"image.tar": "the image contents"}),
+		writeArchive(t, true, map[string]string{"image.tar": "the image contents"}),
+	} {
+		tmpDirGen := NewTempDirGenerator()
+		_, err := ExtractNestedArchive(outerPath, "image.tar", &tmpDirGen)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "exceeds the read limit")
+		require.NoError(t, tmpDirGen.Cleanup())
+	}
+}
+
+func TestSplitNestedArchivePath(t *testing.T) {
+	outer := writeZip(t, map[string]string{"image.tar": "contents"})
+
+	literal := filepath.Join(t.TempDir(), "weird!name.tar")
+	require.NoError(t, ioutil.WriteFile(literal, []byte("contents"), 0644))
+
+	tests := []struct {
+		name          string
+		path          string
+		expectedOuter string
+		expectedInner string
+		expectedOK    bool
+	}{
+		{
+			name:          "nested archive",
+			path:          outer + "!image.tar",
+			expectedOuter: outer,
+			expectedInner: "image.tar",
+			expectedOK:    true,
+		},
+		{
+			name: "no separator",
+			path: outer,
+		},
+		{
+			name: "missing outer archive",
+			path: "/does-not-exist.zip!image.tar",
+		},
+		{
+			name: "outer archive is a directory",
+			path: filepath.Dir(outer) + "!image.tar",
+		},
+		{
+			name: "separator within an existing file name",
+			path: literal,
+		},
+		{
+			name: "empty inner path",
+			path: outer + "!",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actualOuter, actualInner, ok := SplitNestedArchivePath(test.path)
+			assert.Equal(t, test.expectedOK, ok)
+			assert.Equal(t, test.expectedOuter, actualOuter)
+			assert.Equal(t, test.expectedInner, actualInner)
+		})
+	}
+}
+
+func TestNestedArchiveExists(t *testing.T) {
+	files := map[string]string{
+		"images/image.tar": "the image contents",
+	}
+
+	for _, outerPath := range []string{writeZip(t, files), writeArchive(t, true, files)} {
+		exists, err := NestedArchiveExists(outerPath, "/images/image.tar")
+		require.NoError(t, err)
+		assert.True(t, exists)
+
+		exists, err = NestedArchiveExists(outerPath, "images/other.tar")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	}
+}
@@ -0,0 +1,62 @@
+package file
+
+import (
+	"fmt"
+	"io"
+)
+
+var _ io.ReadCloser = (*sizeLimitedReadCloser)(nil)
+
+// ErrFileTooLarge is returned when reading the contents of a file that exceeds the maximum allowed read size.
+type ErrFileTooLarge struct {
+	Path  string
+	Size  int64
+	Limit int64
+}
+
+func (e *ErrFileTooLarge) Error() string {
+	if e.Size > 0 {
+		return fmt.Sprintf("file too large (path=%s size=%d limit=%d)", e.Path, e.Size, e.Limit)
+	}
+	return fmt.Sprintf("file too large (path=%s limit=%d)", e.Path, e.Limit)
+}
+
+// sizeLimitedReadCloser is a read closer that raises ErrFileTooLarge once more than the limit is read (as opposed to
+// io.LimitedReader, which silently truncates).
+type sizeLimitedReadCloser struct {
+	io.ReadCloser
+	path      string
+	limit     int64
+	remaining int64
+}
+
+// NewSizeLimitedReadCloser wraps the given reader for the contents of the file at the given path, raising
+// ErrFileTooLarge if more than the given limit of bytes can be read. A limit <= 0 indicates there is no limit.
+func NewSizeLimitedReadCloser(reader io.ReadCloser, path string, limit int64) io.ReadCloser {
+	if limit <= 0 {
+		return reader
+	}
+	return &sizeLimitedReadCloser{
+		ReadCloser: reader,
+		path:       path,
+		limit:      limit,
+		remaining:  limit,
+	}
+}
+
+func (r *sizeLimitedReadCloser) Read(p []byte) (int, error) {
+	if r.remaining < 0 {
+		return 0, &ErrFileTooLarge{Path: r.path, Limit: r.limit}
+	}
+
+	// allow reading a single byte past the limit to detect content that exceeds the limit
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.remaining -= int64(n)
+	if r.remaining < 0 {
+		return n - 1, &ErrFileTooLarge{Path: r.path, Limit: r.limit}
+	}
+	return n, err
+}
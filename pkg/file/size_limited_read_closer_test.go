@@ -0,0 +1,58 @@
+package file
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSizeLimitedReadCloser(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		limit    int64
+		wantErr  bool
+	}{
+		{
+			name:     "no limit",
+			contents: "some contents",
+		},
+		{
+			name:     "under the limit",
+			contents: "some contents",
+			limit:    100,
+		},
+		{
+			name:     "at the limit",
+			contents: "some contents",
+			limit:    13,
+		},
+		{
+			name:     "over the limit",
+			contents: "some contents",
+			limit:    12,
+			wantErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader := NewSizeLimitedReadCloser(ioutil.NopCloser(strings.NewReader(test.contents)), "/some/path", test.limit)
+			actual, err := ioutil.ReadAll(reader)
+			if !test.wantErr {
+				assert.NoError(t, err)
+				assert.Equal(t, test.contents, string(actual))
+				return
+			}
+
+			var tooLarge *ErrFileTooLarge
+			assert.True(t, errors.As(err, &tooLarge), "unexpected error: %+v", err)
+			assert.Equal(t, "/some/path", tooLarge.Path)
+			assert.Equal(t, test.limit, tooLarge.Limit)
+			assert.Equal(t, test.contents[:test.limit], string(actual))
+		})
+	}
+}
@@ -23,3 +23,12 @@ func (t *TarIndexEntry) ToTarFileEntry() TarFileEntry {
 func (t *TarIndexEntry) Open() io.ReadCloser {
 	return newLazyBoundedReadCloser(t.path, t.seekPosition, t.header.Size)
 }
+
+// Opener returns an Opener for the entry contents that only retains the location of the contents within the tar (not
+// the entire entry and tar header), which is preferable for references that outlive the index.
+func (t *TarIndexEntry) Opener() Opener {
+	path, start, size := t.path, t.seekPosition, t.header.Size
+	return func() io.ReadCloser {
+		return newLazyBoundedReadCloser(path, start, size)
+	}
+}
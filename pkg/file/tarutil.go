@@ -13,6 +13,9 @@ import (
 
 const perFileReadLimit = 2 * GB
 
+// defaultDirMode is the mode of directories created during extraction that are not (yet) listed within the tar.
+const defaultDirMode = 0755
+
 var ErrTarStopIteration = fmt.Errorf("halt iterating tar")
 
 // tarFile is a ReadCloser of a tar file on disk.
@@ -123,27 +126,70 @@ func MetadataFromTar(reader io.ReadCloser, tarPath string) (Metadata, error) {
 	return *metadata, nil
 }
 
-// UntarToDirectory writes the contents of the given tar reader to the given destination
-func UntarToDirectory(reader io.Reader, dst string) error {
+// UntarOption configures UntarToDirectory.
+type UntarOption func(*untarConfig)
+
+type untarConfig struct {
+	stripXattrs bool
+	setXattr    func(path, name string, value []byte) error
+}
+
+// WithoutXattrs drops the extended attributes and ACLs of all entries during extraction (instead of applying them to
+// the written files), such that no attribute is attempted at all. Note: the attributes are still recorded within the
+// file metadata read from tars (see Metadata.Xattrs).
+func WithoutXattrs() UntarOption {
+	return func(cfg *untarConfig) {
+		cfg.stripXattrs = true
+	}
+}
+
+// UntarToDirectory writes the contents of the given tar reader to the given destination. Only directories and regular
+// files are written (with the file mode from the tar header). The extended attributes and ACLs of each entry (the
+// "SCHILY.xattr.*" PAX records) are applied to the written files on linux (unless dropped, see WithoutXattrs).
+// Attributes that cannot be applied, either since the filesystem does not support them (e.g. tmpfs or some overlay
+// configurations) or since the caller lacks the privileges (e.g. "security.capability"), are skipped, while any
+// other failure to apply an attribute fails the extraction.
+//
+// Some build tools write tars that list files before their parent directories (or never list the parent directories
+// at all), thus missing parent directories are created on demand (with mode 0755). The mode of each directory listed
+// within the tar is applied once all entries have been written, such that the mode is corrected regardless of where
+// the directory entry appears and restrictive modes do not prevent writing the children. Note: the owner always
+// retains full access to the written directories, such that the contents can be read and removed afterwards.
+func UntarToDirectory(reader io.Reader, dst string, options ...UntarOption) error {
+	cfg := untarConfig{
+		setXattr: setXattr,
+	}
+	for _, option := range options {
+		option(&cfg)
+	}
+
+	dirModes := make(map[string]os.FileMode)
+
 	visitor := func(entry TarFileEntry) error {
 		target := filepath.Join(dst, entry.Header.Name)
 
 		switch entry.Header.Typeflag {
 		case tar.TypeDir:
-			if _, err := os.Stat(target); err != nil {
-				if err := os.MkdirAll(target, 0755); err != nil {
-					return err
-				}
+			if err := os.MkdirAll(target, defaultDirMode); err != nil {
+				return err
+			}
+			dirModes[target] = os.FileMode(entry.Header.Mode).Perm() | 0700
+			if err := cfg.applyXattrs(target, entry.Header); err != nil {
+				return err
 			}
 
 		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), defaultDirMode); err != nil {
+				return err
+			}
+
 			f, err := os.OpenFile(target, os.O_CREATE|os.O_RDWR, os.FileMode(entry.Header.Mode))
 			if err != nil {
 				return err
 			}
 
 			// limit the reader on each file read to prevent decompression bomb attacks
-			numBytes, err := io.Copy(f, io.LimitReader(entry.Reader, perFileReadLimit))
+			numBytes, err := Copy(f, io.LimitReader(entry.Reader, perFileReadLimit))
 			if numBytes >= perFileReadLimit || errors.Is(err, io.EOF) {
 				return fmt.Errorf("zip read limit hit (potential decompression bomb attack)")
 			}
@@ -154,9 +200,40 @@ func UntarToDirectory(reader io.Reader, dst string) error {
 			if err = f.Close(); err != nil {
 				log.Errorf("failed to close file during untar of path=%q: %w", f.Name(), err)
 			}
+
+			if err := cfg.applyXattrs(target, entry.Header); err != nil {
+				return err
+			}
 		}
 		return nil
 	}
 
-	return IterateTar(reader, visitor)
+	if err := IterateTar(reader, visitor); err != nil {
+		return err
+	}
+
+	for dir, mode := range dirModes {
+		if err := os.Chmod(dir, mode); err != nil {
+			return fmt.Errorf("unable to set mode of dir=%q: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+// applyXattrs sets the extended attributes of the given tar entry on the written path (unless they are dropped).
+func (cfg untarConfig) applyXattrs(target string, header tar.Header) error {
+	if cfg.stripXattrs {
+		return nil
+	}
+	for name, value := range xattrsFromHeader(header) {
+		err := cfg.setXattr(target, name, []byte(value))
+		if err != nil && isXattrNotApplicable(err) {
+			log.Debugf("skipping xattr=%q on path=%q: %+v", name, target, err)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("unable to set xattr=%q on path=%q (xattrs can be dropped during extraction, see WithoutXattrs): %w", name, target, err)
+		}
+	}
+	return nil
 }
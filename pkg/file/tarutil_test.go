@@ -4,7 +4,10 @@
 package file
 
 import (
+	"archive/tar"
+	"bytes"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -12,9 +15,12 @@ import (
 	"os/exec"
 	"path"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -179,3 +185,164 @@ func fileExists(t testing.TB, filename string) bool {
 	}
 	return !info.IsDir()
 }
+
+// withXattrSetter replaces how extended attributes are set on the extracted files (e.g. to mimic a filesystem).
+func withXattrSetter(setter func(path, name string, value []byte) error) UntarOption {
+	return func(cfg *untarConfig) {
+		cfg.setXattr = setter
+	}
+}
+
+// xattrTar returns a tar with xattrs (including ACLs) on a directory and a regular file.
+func xattrTar(t *testing.T) []byte {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	writer := tar.NewWriter(buf)
+	contents := "capable\n"
+	require.NoError(t, writer.WriteHeader(&tar.Header{
+		Name:     "bin/",
+		Typeflag: tar.TypeDir,
+		Mode:     0755,
+		Format:   tar.FormatPAX,
+		PAXRecords: map[string]string{
+			"SCHILY.xattr.system.posix_acl_default": "\x02\x00\x00\x00",
+		},
+	}))
+	require.NoError(t, writer.WriteHeader(&tar.Header{
+		Name:     "bin/ping",
+		Typeflag: tar.TypeReg,
+		Mode:     0755,
+		Size:     int64(len(contents)),
+		Format:   tar.FormatPAX,
+		PAXRecords: map[string]string{
+			"SCHILY.xattr.security.capability":     "\x01\x00\x00\x02\x00\x20\x00\x00",
+			"SCHILY.xattr.system.posix_acl_access": "\x02\x00\x00\x00",
+			"SCHILY.xattr.user.custom":             "value",
+		},
+	}))
+	_, err := writer.Write([]byte(contents))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+func TestUntarToDirectory_Xattrs(t *testing.T) {
+	contents := xattrTar(t)
+
+	t.Run("preserved by default", func(t *testing.T) {
+		dst := t.TempDir()
+		applied := make(map[string]string)
+		err := UntarToDirectory(bytes.NewReader(contents), dst, withXattrSetter(func(path, name string, value []byte) error {
+			rel, err := filepath.Rel(dst, path)
+			require.NoError(t, err)
+			applied[rel+":"+name] = string(value)
+			return nil
+		}))
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]string{
+			"bin:system.posix_acl_default":     "\x02\x00\x00\x00",
+			"bin/ping:security.capability":     "\x01\x00\x00\x02\x00\x20\x00\x00",
+			"bin/ping:system.posix_acl_access": "\x02\x00\x00\x00",
+			"bin/ping:user.custom":             "value",
+		}, applied)
+	})
+
+	// mimics a filesystem without xattr support (e.g. tmpfs for some attributes)
+	unsupported := withXattrSetter(func(string, string, []byte) error {
+		return syscall.ENOTSUP
+	})
+
+	// mimics an unprivileged caller, which may not set "security.*" (or "trusted.*") attributes
+	unprivileged := withXattrSetter(func(_, name string, _ []byte) error {
+		if strings.HasPrefix(name, "security.") {
+			return &os.SyscallError{Syscall: "lsetxattr", Err: syscall.EPERM}
+		}
+		return nil
+	})
+
+	for _, test := range []struct {
+		name   string
+		setter UntarOption
+	}{
+		{name: "filesystem rejects xattrs", setter: unsupported},
+		{name: "unprivileged caller", setter: unprivileged},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dst := t.TempDir()
+			require.NoError(t, UntarToDirectory(bytes.NewReader(contents), dst, test.setter))
+
+			actual, err := ioutil.ReadFile(filepath.Join(dst, "bin", "ping"))
+			require.NoError(t, err)
+			assert.Equal(t, "capable\n", string(actual))
+		})
+	}
+
+	t.Run("other failures are errors", func(t *testing.T) {
+		err := UntarToDirectory(bytes.NewReader(contents), t.TempDir(), withXattrSetter(func(string, string, []byte) error {
+			return syscall.E2BIG
+		}))
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, syscall.E2BIG), "unexpected error: %+v", err)
+	})
+
+	t.Run("dropped", func(t *testing.T) {
+		dst := t.TempDir()
+		err := UntarToDirectory(bytes.NewReader(contents), dst, withXattrSetter(func(string, string, []byte) error {
+			return syscall.E2BIG
+		}), WithoutXattrs())
+		require.NoError(t, err)
+
+		actual, err := ioutil.ReadFile(filepath.Join(dst, "bin", "ping"))
+		require.NoError(t, err)
+		assert.Equal(t, "capable\n", string(actual))
+	})
+
+	t.Run("recorded within metadata", func(t *testing.T) {
+		metadata, err := MetadataFromTar(ioutil.NopCloser(bytes.NewReader(contents)), "bin/ping")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"security.capability":     "\x01\x00\x00\x02\x00\x20\x00\x00",
+			"system.posix_acl_access": "\x02\x00\x00\x00",
+			"user.custom":             "value",
+		}, metadata.Xattrs)
+	})
+}
+
+func TestUntarToDirectory_ChildrenBeforeParents(t *testing.T) {
+	// note: the fixture lists files before their parent directories (as some build tools do), and never lists the
+	// parent of etc/conf.d/app.conf at all
+	f, err := os.Open(path.Join(fixturesPath, "children-before-parents.tar"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, f.Close())
+	})
+
+	dst := t.TempDir()
+	require.NoError(t, UntarToDirectory(f, dst))
+
+	for p, expected := range map[string]string{
+		"usr/share/doc/readme.txt": "read me\n",
+		"etc/conf.d/app.conf":      "key=value\n",
+	} {
+		actual, err := ioutil.ReadFile(filepath.Join(dst, p))
+		require.NoError(t, err, "path=%q", p)
+		assert.Equal(t, expected, string(actual), "path=%q", p)
+	}
+
+	for p, expected := range map[string]os.FileMode{
+		// the modes of listed directories are applied, even though the dirs were created before their entries
+		"usr":           0755,
+		"usr/share":     0711,
+		"usr/share/doc": 0750,
+		// unlisted directories are given the default mode
+		"etc":        defaultDirMode,
+		"etc/conf.d": defaultDirMode,
+	} {
+		info, err := os.Stat(filepath.Join(dst, p))
+		require.NoError(t, err, "path=%q", p)
+		assert.True(t, info.IsDir(), "path=%q", p)
+		assert.Equal(t, expected, info.Mode().Perm(), "path=%q", p)
+	}
+}
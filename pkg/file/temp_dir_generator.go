@@ -9,29 +9,92 @@ import (
 	"github.com/hashicorp/go-multierror"
 )
 
+// TempDirCreator creates a new, empty dir for temporary files, returning the path to the dir and a function that
+// removes the dir (and everything within it). A custom creator can be used to control where and how temp dirs are
+// created (e.g. within a memory-backed tmpfs mount, or with specific permissions).
+type TempDirCreator func() (string, func() error, error)
+
+// DefaultTempDirCreator creates a dir within the platform temp dir, which is removed recursively on cleanup.
+func DefaultTempDirCreator() (string, func() error, error) {
+	dir, err := ioutil.TempDir("", "stereoscope-cache")
+	if err != nil {
+		return "", nil, err
+	}
+	return dir, func() error {
+		return os.RemoveAll(dir)
+	}, nil
+}
+
+// DirCreatorWithin creates temp dirs within the given caller-provided dir (e.g. a RAM disk or a pre-sized volume).
+// The caller owns the given dir: it must already exist and is never removed, only the temp dirs created within it are
+// removed on cleanup.
+func DirCreatorWithin(root string) TempDirCreator {
+	return func() (string, func() error, error) {
+		info, err := os.Stat(root)
+		if err != nil {
+			return "", nil, fmt.Errorf("unable to access extraction dir=%q: %w", root, err)
+		}
+		if !info.IsDir() {
+			return "", nil, fmt.Errorf("extraction dir=%q is not a directory", root)
+		}
+
+		dir, err := ioutil.TempDir(root, "stereoscope-cache")
+		if err != nil {
+			return "", nil, err
+		}
+		return dir, func() error {
+			return os.RemoveAll(dir)
+		}, nil
+	}
+}
+
 type TempDirGenerator struct {
-	tempDir []string
-	lock    *sync.Mutex
+	create   TempDirCreator
+	cleanups []func() error
+	lock     *sync.Mutex
 }
 
 func NewTempDirGenerator() TempDirGenerator {
+	return NewTempDirGeneratorWithCreator(nil)
+}
+
+// NewTempDirGeneratorWithCreator returns a generator that creates temp dirs with the given creator (or the
+// DefaultTempDirCreator when nil).
+func NewTempDirGeneratorWithCreator(create TempDirCreator) TempDirGenerator {
 	return TempDirGenerator{
-		tempDir: make([]string, 0),
-		lock:    &sync.Mutex{},
+		create:   create,
+		cleanups: make([]func() error, 0),
+		lock:     &sync.Mutex{},
 	}
 }
 
-// NewTempDir creates an empty dir in the platform temp dir
+// SetCreator sets the creator used for all temp dirs created afterwards (or the DefaultTempDirCreator when nil).
+// Temp dirs that were already created are still removed with the cleanup given by the creator that made them.
+func (t *TempDirGenerator) SetCreator(create TempDirCreator) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.create = create
+}
+
+// NewTempDir creates an empty dir in the platform temp dir (or wherever the configured creator chooses)
 func (t *TempDirGenerator) NewTempDir() (string, error) {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
-	dir, err := ioutil.TempDir("", "stereoscope-cache")
+	create := t.create
+	if create == nil {
+		create = DefaultTempDirCreator
+	}
+
+	dir, cleanup, err := create()
 	if err != nil {
 		return "", fmt.Errorf("could not create temp dir: %w", err)
 	}
 
-	t.tempDir = append(t.tempDir, dir)
+	if cleanup != nil {
+		t.cleanups = append(t.cleanups, cleanup)
+	}
 	return dir, nil
 }
 
@@ -40,11 +103,12 @@ func (t *TempDirGenerator) Cleanup() error {
 	defer t.lock.Unlock()
 
 	var allErrors error
-	for _, dir := range t.tempDir {
-		err := os.RemoveAll(dir)
+	for _, cleanup := range t.cleanups {
+		err := cleanup()
 		if err != nil {
 			allErrors = multierror.Append(allErrors, err)
 		}
 	}
+	t.cleanups = nil
 	return allErrors
 }
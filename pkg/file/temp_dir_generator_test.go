@@ -0,0 +1,107 @@
+package file
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTempDirGenerator_Default(t *testing.T) {
+	generator := NewTempDirGenerator()
+
+	dir, err := generator.NewTempDir()
+	require.NoError(t, err)
+	assert.DirExists(t, dir)
+	assert.Equal(t, filepath.Clean(os.TempDir()), filepath.Dir(dir))
+
+	require.NoError(t, generator.Cleanup())
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestTempDirGenerator_Creator(t *testing.T) {
+	root := t.TempDir()
+
+	var created, removed []string
+	create := func() (string, func() error, error) {
+		dir, err := ioutil.TempDir(root, "custom")
+		if err != nil {
+			return "", nil, err
+		}
+		require.NoError(t, os.Chmod(dir, 0700))
+		created = append(created, dir)
+		return dir, func() error {
+			removed = append(removed, dir)
+			return os.RemoveAll(dir)
+		}, nil
+	}
+
+	generator := NewTempDirGeneratorWithCreator(create)
+	first, err := generator.NewTempDir()
+	require.NoError(t, err)
+	assert.Equal(t, root, filepath.Dir(first))
+
+	info, err := os.Stat(first)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+
+	// dirs created after restoring the default creator are still cleaned up, along with the custom dirs
+	generator.SetCreator(nil)
+	second, err := generator.NewTempDir()
+	require.NoError(t, err)
+	assert.NotEqual(t, root, filepath.Dir(second))
+
+	require.NoError(t, generator.Cleanup())
+	assert.Equal(t, []string{first}, created)
+	assert.Equal(t, []string{first}, removed)
+	for _, dir := range []string{first, second} {
+		_, err = os.Stat(dir)
+		assert.True(t, os.IsNotExist(err))
+	}
+
+	// cleanup is only done once per dir
+	require.NoError(t, generator.Cleanup())
+	assert.Len(t, removed, 1)
+}
+
+func TestTempDirGenerator_CreatorError(t *testing.T) {
+	expected := errors.New("no tmpfs available")
+	generator := NewTempDirGeneratorWithCreator(func() (string, func() error, error) {
+		return "", nil, expected
+	})
+
+	_, err := generator.NewTempDir()
+	assert.ErrorIs(t, err, expected)
+}
+
+func TestDirCreatorWithin(t *testing.T) {
+	root := t.TempDir()
+	existing := filepath.Join(root, "owned-by-caller.txt")
+	require.NoError(t, ioutil.WriteFile(existing, []byte("keep me"), 0600))
+
+	generator := NewTempDirGeneratorWithCreator(DirCreatorWithin(root))
+	dir, err := generator.NewTempDir()
+	require.NoError(t, err)
+	assert.Equal(t, root, filepath.Dir(dir))
+
+	// only the temp dirs created within the caller-provided dir are removed
+	require.NoError(t, generator.Cleanup())
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+	assert.DirExists(t, root)
+	assert.FileExists(t, existing)
+
+	// the caller-provided dir must already exist
+	generator = NewTempDirGeneratorWithCreator(DirCreatorWithin(filepath.Join(root, "missing")))
+	_, err = generator.NewTempDir()
+	assert.Error(t, err)
+
+	generator = NewTempDirGeneratorWithCreator(DirCreatorWithin(existing))
+	_, err = generator.NewTempDir()
+	assert.Error(t, err)
+}
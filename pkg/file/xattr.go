@@ -0,0 +1,35 @@
+package file
+
+import (
+	"archive/tar"
+	"errors"
+	"strings"
+	"syscall"
+)
+
+// xattrPAXPrefix is the prefix of the PAX records holding extended attributes (including ACLs, which are stored as the
+// "system.posix_acl_access" and "system.posix_acl_default" attributes).
+const xattrPAXPrefix = "SCHILY.xattr."
+
+// xattrsFromHeader returns the extended attributes of the given tar entry (by attribute name), which is nil when there
+// are none.
+func xattrsFromHeader(header tar.Header) map[string]string {
+	var xattrs map[string]string
+	for key, value := range header.PAXRecords {
+		if !strings.HasPrefix(key, xattrPAXPrefix) {
+			continue
+		}
+		if xattrs == nil {
+			xattrs = make(map[string]string)
+		}
+		xattrs[strings.TrimPrefix(key, xattrPAXPrefix)] = value
+	}
+	return xattrs
+}
+
+// isXattrNotApplicable indicates if the given error from setting an extended attribute means the attribute cannot be
+// applied in this environment, either since the caller lacks the privileges (e.g. "security.*" or "trusted.*"
+// attributes as an unprivileged user) or since the filesystem does not support it.
+func isXattrNotApplicable(err error) bool {
+	return errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.ENOTSUP)
+}
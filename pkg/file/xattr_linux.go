@@ -0,0 +1,8 @@
+package file
+
+import "golang.org/x/sys/unix"
+
+// setXattr sets the given extended attribute on the given path (without following links).
+func setXattr(path, name string, value []byte) error {
+	return unix.Lsetxattr(path, name, value, 0)
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package file
+
+import "github.com/anchore/stereoscope/internal/log"
+
+// setXattr does nothing, since extended attributes from tars are only applied on linux.
+func setXattr(path, name string, _ []byte) error {
+	log.Debugf("not applying xattr=%q to path=%q (unsupported platform)", name, path)
+	return nil
+}
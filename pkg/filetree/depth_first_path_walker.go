@@ -6,6 +6,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/anchore/stereoscope/internal/log"
 	"github.com/anchore/stereoscope/pkg/file"
 	"github.com/anchore/stereoscope/pkg/filetree/filenode"
 )
@@ -39,7 +40,10 @@ type DepthFirstPathWalker struct {
 	tree         *FileTree
 	pathStack    file.PathStack
 	visitedPaths file.PathSet
-	conditions   WalkConditions
+	// realPaths are the resolved paths of the traversed paths, used to detect links to directories already being
+	// traversed
+	realPaths  map[file.Path]file.Path
+	conditions WalkConditions
 }
 
 func NewDepthFirstPathWalker(tree *FileTree, visitor FileNodeVisitor, conditions *WalkConditions) *DepthFirstPathWalker {
@@ -47,6 +51,7 @@ func NewDepthFirstPathWalker(tree *FileTree, visitor FileNodeVisitor, conditions
 		visitor:      visitor,
 		tree:         tree,
 		visitedPaths: file.NewPathSet(),
+		realPaths:    make(map[file.Path]file.Path),
 	}
 	if conditions != nil {
 		w.conditions = *conditions
@@ -69,6 +74,14 @@ func (w *DepthFirstPathWalker) Walk(from file.Path) (file.Path, *filenode.FileNo
 			FollowBasenameLinks:          true,
 			DoNotFollowDeadBasenameLinks: true,
 		})
+		cycle := errors.Is(err, ErrLinkCycleDetected)
+		if cycle {
+			// the link at the path is part of a cycle, thus visit the link itself (without descending through it)
+			log.Debugf("not traversing link cycle at path=%q: %+v", currentPath, err)
+			currentNode, err = w.tree.node(currentPath, linkResolutionStrategy{
+				FollowAncestorLinks: true,
+			})
+		}
 		if err != nil {
 			return "", nil, err
 		}
@@ -86,6 +99,14 @@ func (w *DepthFirstPathWalker) Walk(from file.Path) (file.Path, *filenode.FileNo
 		}
 		currentPath = currentPath.Normalize()
 
+		// a link to a directory that is already being traversed (e.g. /home/user/root -> /) forms a cycle, thus the
+		// link is visited without descending through it
+		if !cycle && currentNode.RealPath != currentPath && w.traversing(currentPath, currentNode.RealPath) {
+			log.Debugf("not traversing link cycle at path=%q to ancestor=%q", currentPath, currentNode.RealPath)
+			cycle = true
+		}
+		w.realPaths[currentPath] = currentNode.RealPath
+
 		// visit
 		if w.visitor != nil && !w.visitedPaths.Contains(currentPath) {
 			if w.conditions.ShouldVisit == nil || w.conditions.ShouldVisit != nil && w.conditions.ShouldVisit(currentPath, *currentNode) {
@@ -97,7 +118,7 @@ func (w *DepthFirstPathWalker) Walk(from file.Path) (file.Path, *filenode.FileNo
 			}
 		}
 
-		if w.conditions.ShouldContinueBranch != nil && !w.conditions.ShouldContinueBranch(currentPath, *currentNode) {
+		if cycle || w.conditions.ShouldContinueBranch != nil && !w.conditions.ShouldContinueBranch(currentPath, *currentNode) {
 			continue
 		}
 
@@ -115,6 +136,21 @@ func (w *DepthFirstPathWalker) Walk(from file.Path) (file.Path, *filenode.FileNo
 	return currentPath, currentNode, nil
 }
 
+// traversing indicates if the given real path is the resolved path of any ancestor of the given path (thus is already
+// being traversed).
+func (w *DepthFirstPathWalker) traversing(p file.Path, realPath file.Path) bool {
+	for _, ancestor := range p.ConstituentPaths() {
+		ancestorRealPath, ok := w.realPaths[ancestor]
+		if !ok {
+			ancestorRealPath = ancestor
+		}
+		if ancestorRealPath == realPath {
+			return true
+		}
+	}
+	return false
+}
+
 func (w *DepthFirstPathWalker) WalkAll() error {
 	_, _, err := w.Walk("/")
 	return err
@@ -5,6 +5,8 @@ import (
 	"github.com/anchore/stereoscope/pkg/file"
 	"github.com/anchore/stereoscope/pkg/filetree/filenode"
 	"github.com/go-test/deep"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"strings"
 	"testing"
 )
@@ -235,16 +237,12 @@ func TestDFS_WalkAll_ConditionalBranchPruning(t *testing.T) {
 func TestDFS_WalkAll_MaxDirDepthTerminatesTraversal(t *testing.T) {
 	tr := NewFileTree()
 
-	possiblePaths := make(map[string]*file.Reference)
-
-	// absolute symlink
-	_, err := tr.AddSymLink("/home/wagoodman", "/home")
+	// note: link cycles are not traversed (see TestDFS_WalkAll_LinkCycles), thus the max depth is reached with a tree
+	// that is really that deep
+	_, err := tr.AddFile(file.Path(strings.Repeat("/deep", maxDirDepth+1) + "/file.txt"))
 	if err != nil {
-		t.Fatalf("could not setup link: %+v", err)
+		t.Fatalf("could not setup file: %+v", err)
 	}
-	// since we are following base links on walk, we should NOT expect the symlink ref at the link destination
-	possiblePaths["/home/wagoodman"] = nil
-	possiblePaths["/home"] = nil
 
 	// start the test
 
@@ -288,3 +286,38 @@ func assertExpectedTraversal(t *testing.T, expected, actual map[string]*file.Ref
 		t.Errorf("   diff: %s", d)
 	}
 }
+
+func TestDFS_WalkAll_LinkCycles(t *testing.T) {
+	tr := NewFileTree()
+	for _, link := range [][2]file.Path{
+		// direct cycles
+		{"/self", "/self"},
+		{"/a", "/b"},
+		{"/b", "/a"},
+		// indirect cycle through ancestor links
+		{"/x", "/y/1"},
+		{"/y", "/x/2"},
+		// links to directories already being traversed
+		{"/home/wagoodman", "/home"},
+		{"/home/root", "/"},
+		{"/home/up", ".."},
+	} {
+		_, err := tr.AddSymLink(link[0], link[1])
+		require.NoError(t, err)
+	}
+	_, err := tr.AddFile("/home/file.txt")
+	require.NoError(t, err)
+
+	var visited []string
+	visitor := func(path file.Path, node filenode.FileNode) error {
+		visited = append(visited, string(path))
+		return nil
+	}
+
+	require.NoError(t, NewDepthFirstPathWalker(tr, visitor, nil).WalkAll())
+
+	// every link is visited (without descending through the cycle)
+	assert.ElementsMatch(t, []string{
+		"/", "/a", "/b", "/home", "/home/file.txt", "/home/root", "/home/up", "/home/wagoodman", "/self", "/x", "/y",
+	}, visited)
+}
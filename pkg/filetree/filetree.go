@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/anchore/stereoscope/internal"
+	"github.com/anchore/stereoscope/internal/log"
 	"github.com/anchore/stereoscope/pkg/file"
 	"github.com/anchore/stereoscope/pkg/filetree/filenode"
 	"github.com/anchore/stereoscope/pkg/tree"
@@ -18,6 +19,28 @@ import (
 var ErrRemovingRoot = errors.New("cannot remove the root path (`/`) from the FileTree")
 var ErrLinkCycleDetected = errors.New("cycle during symlink resolution")
 
+// maxLinkHops is the maximum number of links followed while resolving a single path (as with MAXSYMLINKS on linux),
+// which bounds the resolution of cycles through ancestor links (e.g. /a -> /b/x and /b -> /a/y) that are not otherwise
+// detected.
+const maxLinkHops = 40
+
+// ErrSymlinkCycle is returned when resolving a path through symlinks that form a cycle (e.g. /a -> /b -> /a), or that
+// take more than maxLinkHops links to resolve. This matches ErrLinkCycleDetected (see errors.Is).
+type ErrSymlinkCycle struct {
+	// Path is the link being resolved when the cycle was detected
+	Path file.Path
+	// Hops is the number of links followed before the cycle was detected
+	Hops int
+}
+
+func (e *ErrSymlinkCycle) Error() string {
+	return fmt.Sprintf("cycle during symlink resolution (path=%q, after %d links)", e.Path, e.Hops)
+}
+
+func (e *ErrSymlinkCycle) Is(target error) bool {
+	return target == ErrLinkCycleDetected
+}
+
 // FileTree represents a file/directory Tree
 type FileTree struct {
 	tree *tree.Tree
@@ -167,8 +190,10 @@ func (t *FileTree) node(p file.Path, strategy linkResolutionStrategy) (*filenode
 
 	var currentNode *filenode.FileNode
 	var err error
+	// the number of links followed is shared by all (recursive) link resolution for the path
+	var hops int
 	if strategy.FollowAncestorLinks {
-		currentNode, err = t.resolveAncestorLinks(normalizedPath)
+		currentNode, err = t.resolveAncestorLinks(normalizedPath, &hops)
 		if err != nil {
 			return currentNode, err
 		}
@@ -185,14 +210,14 @@ func (t *FileTree) node(p file.Path, strategy linkResolutionStrategy) (*filenode
 	}
 
 	if strategy.FollowBasenameLinks {
-		currentNode, err = t.resolveNodeLinks(currentNode, !strategy.DoNotFollowDeadBasenameLinks)
+		currentNode, err = t.resolveNodeLinks(currentNode, !strategy.DoNotFollowDeadBasenameLinks, &hops)
 	}
 	return currentNode, err
 }
 
 // return FileNode of the basename in the given path (no resolution is done at or past the basename). Note: it is
-// assumed that the given path has already been normalized.
-func (t *FileTree) resolveAncestorLinks(path file.Path) (*filenode.FileNode, error) {
+// assumed that the given path has already been normalized. The given hop count is the number of links followed so far.
+func (t *FileTree) resolveAncestorLinks(path file.Path, hops *int) (*filenode.FileNode, error) {
 	// performance optimization... see if there is a node at the path (as if it is a real path). If so,
 	// use it, otherwise, continue with ancestor resolution
 	currentNode, err := t.node(path, linkResolutionStrategy{})
@@ -248,7 +273,7 @@ func (t *FileTree) resolveAncestorLinks(path file.Path) (*filenode.FileNode, err
 		// links until the next Node is resolved (or not).
 		isLastPart := idx == len(pathParts)-1
 		if !isLastPart && currentNode.IsLink() {
-			currentNode, err = t.resolveNodeLinks(currentNode, true)
+			currentNode, err = t.resolveNodeLinks(currentNode, true, hops)
 			if err != nil {
 				// only expected to happen on cycles
 				return currentNode, err
@@ -265,8 +290,9 @@ func (t *FileTree) resolveAncestorLinks(path file.Path) (*filenode.FileNode, err
 }
 
 // followNode takes the given FileNode and resolves all links at the base of the real path for the node (this implies
-// that NO ancestors are considered).
-func (t *FileTree) resolveNodeLinks(n *filenode.FileNode, followDeadBasenameLinks bool) (*filenode.FileNode, error) {
+// that NO ancestors are considered). The given hop count is the number of links followed so far, which is bounded (see
+// maxLinkHops).
+func (t *FileTree) resolveNodeLinks(n *filenode.FileNode, followDeadBasenameLinks bool, hops *int) (*filenode.FileNode, error) {
 	if n == nil {
 		return nil, fmt.Errorf("cannot resolve links with nil Node given")
 	}
@@ -286,7 +312,7 @@ func (t *FileTree) resolveNodeLinks(n *filenode.FileNode, followDeadBasenameLink
 		}
 
 		if alreadySeen.Contains(string(currentNode.RealPath)) {
-			return nil, ErrLinkCycleDetected
+			return nil, &ErrSymlinkCycle{Path: currentNode.RealPath, Hops: *hops}
 		}
 
 		if !currentNode.IsLink() {
@@ -297,6 +323,10 @@ func (t *FileTree) resolveNodeLinks(n *filenode.FileNode, followDeadBasenameLink
 
 		// prepare for the next iteration
 		alreadySeen.Add(string(currentNode.RealPath))
+		*hops++
+		if *hops > maxLinkHops {
+			return nil, &ErrSymlinkCycle{Path: currentNode.RealPath, Hops: *hops}
+		}
 
 		var nextPath file.Path
 		if currentNode.LinkPath.IsAbsolutePath() {
@@ -319,7 +349,7 @@ func (t *FileTree) resolveNodeLinks(n *filenode.FileNode, followDeadBasenameLink
 		lastNode = currentNode
 
 		// get the next Node (based on the next path)
-		currentNode, err = t.resolveAncestorLinks(nextPath)
+		currentNode, err = t.resolveAncestorLinks(nextPath, hops)
 		if err != nil {
 			// only expected to occur upon cycle detection
 			return currentNode, err
@@ -690,8 +720,13 @@ func (t *FileTree) Walk(fn func(path file.Path, f filenode.FileNode) error, cond
 // merge takes the given Tree and combines it with the current Tree, preferring files in the other Tree if there
 // are path conflicts. This is the basis function for squashing (where the current Tree is the bottom Tree and the
 // given Tree is the top Tree).
-// nolint:gocognit,funlen
 func (t *FileTree) merge(upper *FileTree) error {
+	return t.mergeWithTracer(upper, log.DefaultTracer())
+}
+
+// mergeWithTracer merges the given Tree (see merge), logging each squash decision to the given tracer.
+// nolint:gocognit,funlen
+func (t *FileTree) mergeWithTracer(upper *FileTree, tracer log.Tracer) error {
 	conditions := tree.WalkConditions{
 		ShouldContinueBranch: func(n node.Node) bool {
 			p := file.Path(n.ID())
@@ -710,6 +745,7 @@ func (t *FileTree) merge(upper *FileTree) error {
 		upperNode := n.(*filenode.FileNode)
 		// opaque directories must be processed first
 		if upper.hasOpaqueDirectory(upperNode.RealPath) {
+			tracer.Tracef("squash: opaque directory=%q in upper layer removes all lower layer children", upperNode.RealPath)
 			err := t.RemoveChildPaths(upperNode.RealPath)
 			if err != nil {
 				return fmt.Errorf("filetree merge failed to remove child paths (upperPath=%s): %w", upperNode.RealPath, err)
@@ -722,6 +758,14 @@ func (t *FileTree) merge(upper *FileTree) error {
 				return fmt.Errorf("filetree merge failed to find original upperPath for whiteout (upperPath=%s): %w", upperNode.RealPath, err)
 			}
 
+			if tracer.Enabled() {
+				if t.HasPath(lowerPath) {
+					tracer.Tracef("squash: whiteout=%q in upper layer removes path=%q", upperNode.RealPath, lowerPath)
+				} else {
+					tracer.Tracef("squash: whiteout=%q in upper layer has no lower path=%q to remove", upperNode.RealPath, lowerPath)
+				}
+			}
+
 			err = t.RemovePath(lowerPath)
 			if err != nil {
 				return fmt.Errorf("filetree merge failed to remove upperPath (upperPath=%s): %w", lowerPath, err)
@@ -744,6 +788,10 @@ func (t *FileTree) merge(upper *FileTree) error {
 			}
 		}
 
+		if lowerNode != nil && (upperNode.FileType != file.TypeDir || lowerNode.FileType != file.TypeDir) {
+			tracer.Tracef("squash: path=%q (type=%q) from upper layer overwrites lower layer path (type=%q)", upperNode.RealPath, upperNode.FileType, lowerNode.FileType)
+		}
+
 		nodeCopy := *upperNode
 
 		// keep original file references if the upper tree does not have them (only for the same file types)
@@ -6,9 +6,11 @@ import (
 	"testing"
 
 	"github.com/anchore/stereoscope/internal"
+	"github.com/anchore/stereoscope/internal/log"
 	"github.com/anchore/stereoscope/pkg/file"
 	"github.com/anchore/stereoscope/pkg/filetree/filenode"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestFileTree_AddPath(t *testing.T) {
@@ -429,6 +431,50 @@ func TestFileTree_Merge_Whiteout(t *testing.T) {
 
 }
 
+type traceRecorder struct {
+	messages []string
+}
+
+func (l *traceRecorder) Errorf(string, ...interface{}) {}
+func (l *traceRecorder) Error(...interface{})          {}
+func (l *traceRecorder) Warnf(string, ...interface{})  {}
+func (l *traceRecorder) Warn(...interface{})           {}
+func (l *traceRecorder) Infof(string, ...interface{})  {}
+func (l *traceRecorder) Info(...interface{})           {}
+func (l *traceRecorder) Debug(...interface{})          {}
+func (l *traceRecorder) Debugf(format string, args ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, args...))
+}
+
+func TestFileTree_Merge_Trace(t *testing.T) {
+	original := log.Log
+	recorder := &traceRecorder{}
+	log.Log = recorder
+	log.SetTraceMode(true)
+	t.Cleanup(func() {
+		log.Log = original
+		log.SetTraceMode(false)
+	})
+
+	tr1 := NewFileTree()
+	tr1.AddFile("/etc/removed.txt")
+	tr1.AddFile("/etc/contested.txt")
+	tr1.AddFile("/opaque/lower.txt")
+
+	tr2 := NewFileTree()
+	tr2.AddFile("/etc/.wh.removed.txt")
+	tr2.AddFile("/etc/contested.txt")
+	tr2.AddFile("/opaque/.wh..wh..opq")
+
+	if err := tr1.merge(tr2); err != nil {
+		t.Fatalf("error on merge : %+v", err)
+	}
+
+	assert.Contains(t, recorder.messages, `[trace] squash: whiteout="/etc/.wh.removed.txt" in upper layer removes path="/etc/removed.txt"`)
+	assert.Contains(t, recorder.messages, `[trace] squash: path="/etc/contested.txt" (type='0') from upper layer overwrites lower layer path (type='0')`)
+	assert.Contains(t, recorder.messages, `[trace] squash: opaque directory="/opaque" in upper layer removes all lower layer children`)
+}
+
 func TestFileTree_Merge_DirOverride(t *testing.T) {
 	tr1 := NewFileTree()
 	tr1.AddFile("/home/wagoodman/awesome/place")
@@ -777,7 +823,7 @@ func TestFileTree_File_CycleDetection(t *testing.T) {
 
 	// the test.... do we stop when a cycle is detected?
 	exists, _, err := tr.File("/home/wagoodman", FollowBasenameLinks)
-	if err != ErrLinkCycleDetected {
+	if !errors.Is(err, ErrLinkCycleDetected) {
 		t.Fatalf("should have gotten an error on resolving a file")
 	}
 
@@ -787,6 +833,43 @@ func TestFileTree_File_CycleDetection(t *testing.T) {
 
 }
 
+func TestFileTree_File_SymlinkCycles(t *testing.T) {
+	tr := NewFileTree()
+	for _, link := range [][2]file.Path{
+		// direct cycles
+		{"/self", "/self"},
+		{"/a", "/b"},
+		{"/b", "/a"},
+		// indirect cycle through ancestor links, which is bounded by the number of links followed
+		{"/x", "/y/1"},
+		{"/y", "/x/2"},
+	} {
+		_, err := tr.AddSymLink(link[0], link[1])
+		require.NoError(t, err)
+	}
+
+	for _, p := range []file.Path{"/self", "/a", "/a/file.txt", "/x", "/x/file.txt", "/y/file.txt"} {
+		t.Run(string(p), func(t *testing.T) {
+			exists, ref, err := tr.File(p, FollowBasenameLinks)
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, ErrLinkCycleDetected))
+
+			var cycleErr *ErrSymlinkCycle
+			require.True(t, errors.As(err, &cycleErr))
+			assert.LessOrEqual(t, cycleErr.Hops, maxLinkHops+1)
+
+			assert.False(t, exists)
+			assert.Nil(t, ref)
+		})
+	}
+
+	// without following the basename link the link itself is found
+	exists, ref, err := tr.File("/a")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.NotNil(t, ref)
+}
+
 func TestFileTree_AllFiles(t *testing.T) {
 	tr := NewFileTree()
 
@@ -1,17 +1,29 @@
 package filetree
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/anchore/stereoscope/internal/log"
+)
 
 type UnionFileTree struct {
-	trees []*FileTree
+	trees  []*FileTree
+	tracer log.Tracer
 }
 
 func NewUnionFileTree() *UnionFileTree {
 	return &UnionFileTree{
-		trees: make([]*FileTree, 0),
+		trees:  make([]*FileTree, 0),
+		tracer: log.DefaultTracer(),
 	}
 }
 
+// SetTraceMode enables (or disables) trace logging of the decisions made while squashing, regardless of the default
+// trace mode.
+func (u *UnionFileTree) SetTraceMode(enabled bool) {
+	u.tracer = log.Tracer(enabled)
+}
+
 func (u *UnionFileTree) PushTree(t *FileTree) {
 	u.trees = append(u.trees, t)
 }
@@ -35,7 +47,7 @@ func (u *UnionFileTree) Squash() (*FileTree, error) {
 			continue
 		}
 
-		if err = squashedTree.merge(refTree); err != nil {
+		if err = squashedTree.mergeWithTracer(refTree, u.tracer); err != nil {
 			return nil, fmt.Errorf("unable to squash layer=%d : %w", layerIdx, err)
 		}
 	}
@@ -0,0 +1,182 @@
+package image
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	v1Types "github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/spf13/afero"
+)
+
+// HelmChartConfigMediaType is the manifest config media type of Helm charts stored as OCI artifacts.
+const HelmChartConfigMediaType = "application/vnd.cncf.helm.config.v1+json"
+
+// maxArtifactMetadataSize bounds the size of the index and manifest blobs read to determine if an OCI layout holds a
+// container image (layer blobs are never read).
+const maxArtifactMetadataSize = 4 * 1024 * 1024
+
+// ErrNotImage is returned when an OCI layout (or manifest) describes an artifact that is not a container image, such
+// as a Helm chart, which cannot be read as an image.
+type ErrNotImage struct {
+	// Location is the path (or reference) of the artifact.
+	Location string
+	// ConfigMediaType is the media type of the artifact manifest config.
+	ConfigMediaType string
+}
+
+func (e *ErrNotImage) Error() string {
+	kind := "an OCI artifact"
+	if normalizeMediaType(e.ConfigMediaType) == HelmChartConfigMediaType {
+		kind = "a Helm chart"
+	}
+	return fmt.Sprintf("%q is not a container image: found %s (config media type=%q)", e.Location, kind, e.ConfigMediaType)
+}
+
+// IsImageConfigMediaType indicates if the given manifest config media type describes a container image config (as
+// opposed to the config of another kind of OCI artifact, such as a Helm chart). An empty media type is assumed to be
+// an image config.
+func IsImageConfigMediaType(mediaType string) bool {
+	switch v1Types.MediaType(normalizeMediaType(mediaType)) {
+	case "", v1Types.OCIConfigJSON, v1Types.DockerConfigJSON:
+		return true
+	}
+	return false
+}
+
+func normalizeMediaType(mediaType string) string {
+	if idx := strings.Index(mediaType, ";"); idx >= 0 {
+		mediaType = mediaType[:idx]
+	}
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
+
+// checkOCIArchiveIsImage returns an *ErrNotImage if the OCI archive at the given path only holds artifacts that are
+// not container images. Archives that cannot be fully inspected (e.g. a missing or malformed index) are assumed to be
+// images, leaving any problem to be reported when the image is read.
+func checkOCIArchiveIsImage(fs afero.Fs, imgPath string, cfg detectSourceConfig) error {
+	f, err := fs.Open(imgPath)
+	if err != nil {
+		return fmt.Errorf("unable to open archive=%s: %w", imgPath, err)
+	}
+
+	archive, err := file.NewArchiveReader(f)
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("unable to read archive=%s: %w", imgPath, err)
+	}
+	defer archive.Close()
+
+	// note: the blobs may be in any order within the archive, so all small blobs are kept until the index is resolved
+	contents := make(map[string][]byte)
+	visitor := func(entry file.TarFileEntry) error {
+		if cfg.maxArchiveHeaders > 0 && entry.Sequence >= int64(cfg.maxArchiveHeaders) {
+			return file.ErrTarStopIteration
+		}
+		name := path.Clean(entry.Header.Name)
+		if (name != "index.json" && !strings.HasPrefix(name, "blobs/")) || entry.Header.Size > maxArtifactMetadataSize {
+			return nil
+		}
+		b, err := ioutil.ReadAll(entry.Reader)
+		if err != nil {
+			return err
+		}
+		contents[name] = b
+		return nil
+	}
+
+	if err := file.IterateTar(archive, visitor); err != nil {
+		log.Debugf("unable to inspect OCI archive=%q for artifacts: %+v", imgPath, err)
+		return nil
+	}
+
+	return checkOCILayoutIsImage(imgPath, func(p string) ([]byte, bool) {
+		b, ok := contents[p]
+		return b, ok
+	})
+}
+
+// checkOCIDirectoryIsImage returns an *ErrNotImage if the OCI directory at the given path only holds artifacts that
+// are not container images (see checkOCIArchiveIsImage).
+func checkOCIDirectoryIsImage(fs afero.Fs, dirPath string) error {
+	return checkOCILayoutIsImage(dirPath, func(p string) ([]byte, bool) {
+		info, err := fs.Stat(path.Join(dirPath, p))
+		if err != nil || info.Size() > maxArtifactMetadataSize {
+			return nil, false
+		}
+		b, err := afero.ReadFile(fs, path.Join(dirPath, p))
+		return b, err == nil
+	})
+}
+
+// checkOCILayoutIsImage resolves the manifests referenced by the layout index (with the given function to read files
+// relative to the layout root), returning an *ErrNotImage if there are manifests and none of them are images.
+func checkOCILayoutIsImage(location string, read func(string) ([]byte, bool)) error {
+	configMediaTypes, ok := manifestConfigMediaTypes(read, "index.json", 0)
+	if !ok || len(configMediaTypes) == 0 {
+		return nil
+	}
+
+	for _, mediaType := range configMediaTypes {
+		if IsImageConfigMediaType(mediaType) {
+			return nil
+		}
+	}
+
+	return &ErrNotImage{
+		Location:        location,
+		ConfigMediaType: configMediaTypes[0],
+	}
+}
+
+// manifestConfigMediaTypes returns the config media types of all manifests referenced by the index at the given path,
+// descending into nested indexes. False is returned if any part of the layout could not be read.
+func manifestConfigMediaTypes(read func(string) ([]byte, bool), indexPath string, depth int) ([]string, bool) {
+	// note: the nesting depth is bounded to guard against malicious layouts that refer to themselves
+	if depth > 4 {
+		return nil, false
+	}
+
+	b, ok := read(indexPath)
+	if !ok {
+		return nil, false
+	}
+
+	var index v1.IndexManifest
+	if err := json.Unmarshal(b, &index); err != nil {
+		log.Debugf("unable to parse OCI index=%q: %+v", indexPath, err)
+		return nil, false
+	}
+
+	var mediaTypes []string
+	for _, descriptor := range index.Manifests {
+		blobPath := path.Join("blobs", descriptor.Digest.Algorithm, descriptor.Digest.Hex)
+
+		if SourceFromMediaType(string(descriptor.MediaType)) == IndexMediaTypeKind {
+			nested, ok := manifestConfigMediaTypes(read, blobPath, depth+1)
+			if !ok {
+				return nil, false
+			}
+			mediaTypes = append(mediaTypes, nested...)
+			continue
+		}
+
+		b, ok := read(blobPath)
+		if !ok {
+			return nil, false
+		}
+
+		var manifest v1.Manifest
+		if err := json.Unmarshal(b, &manifest); err != nil {
+			log.Debugf("unable to parse OCI manifest=%q: %+v", descriptor.Digest, err)
+			return nil, false
+		}
+		mediaTypes = append(mediaTypes, string(manifest.Config.MediaType))
+	}
+	return mediaTypes, true
+}
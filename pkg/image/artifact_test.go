@@ -0,0 +1,68 @@
+package image
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const helmChartFixture = "test-fixtures/helm-chart-oci-archive.tar"
+
+func TestIsImageConfigMediaType(t *testing.T) {
+	tests := []struct {
+		mediaType string
+		expected  bool
+	}{
+		{mediaType: "application/vnd.oci.image.config.v1+json", expected: true},
+		{mediaType: "application/vnd.docker.container.image.v1+json", expected: true},
+		{mediaType: "Application/VND.OCI.Image.Config.v1+json; charset=utf-8", expected: true},
+		{mediaType: "", expected: true},
+		{mediaType: HelmChartConfigMediaType, expected: false},
+		{mediaType: "application/vnd.oci.empty.v1+json", expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.mediaType, func(t *testing.T) {
+			assert.Equal(t, test.expected, IsImageConfigMediaType(test.mediaType))
+		})
+	}
+}
+
+func TestDetectSourceFromPath_HelmChart(t *testing.T) {
+	extracted := t.TempDir()
+	f, err := os.Open(helmChartFixture)
+	require.NoError(t, err)
+	defer f.Close()
+	require.NoError(t, file.UntarToDirectory(f, extracted))
+
+	tests := []struct {
+		name     string
+		location string
+	}{
+		{
+			name:     "oci archive",
+			location: helmChartFixture,
+		},
+		{
+			name:     "oci directory",
+			location: extracted,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source, err := DetectSourceFromPath(test.location)
+			assert.Equal(t, UnknownSource, source)
+
+			var notImageErr *ErrNotImage
+			require.True(t, errors.As(err, &notImageErr), "unexpected error: %+v", err)
+			assert.Equal(t, test.location, notImageErr.Location)
+			assert.Equal(t, HelmChartConfigMediaType, notImageErr.ConfigMediaType)
+			assert.Contains(t, err.Error(), "is not a container image: found a Helm chart")
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package image
+
+import (
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// Healthcheck is the health probe declared by the image config (e.g. from a HEALTHCHECK Dockerfile instruction).
+type Healthcheck struct {
+	// Test is the probe to run, where the first element describes how: "CMD" (the remaining elements are the command
+	// and arguments), "CMD-SHELL" (the remaining element is run with the default shell), or "NONE" (health checks are
+	// disabled, e.g. one inherited from the base image). An empty test inherits the probe of the base image.
+	Test []string
+	// Interval is the time to wait between probes (zero inherits the default)
+	Interval time.Duration
+	// Timeout is the time to wait before considering a probe to have hung (zero inherits the default)
+	Timeout time.Duration
+	// StartPeriod is the time for the container to initialize before failed probes count towards the retries (zero
+	// inherits the default)
+	StartPeriod time.Duration
+	// Retries is the number of consecutive failed probes needed to consider the container unhealthy (zero inherits the
+	// default)
+	Retries int
+}
+
+// Disabled indicates that health checks are explicitly disabled (HEALTHCHECK NONE).
+func (h Healthcheck) Disabled() bool {
+	return len(h.Test) > 0 && strings.EqualFold(h.Test[0], "NONE")
+}
+
+// healthcheck returns the health probe declared within the given config, which is nil when none is declared.
+func healthcheck(config v1.Config) *Healthcheck {
+	if config.Healthcheck == nil {
+		return nil
+	}
+	return &Healthcheck{
+		Test:        config.Healthcheck.Test,
+		Interval:    config.Healthcheck.Interval,
+		Timeout:     config.Healthcheck.Timeout,
+		StartPeriod: config.Healthcheck.StartPeriod,
+		Retries:     config.Healthcheck.Retries,
+	}
+}
@@ -0,0 +1,70 @@
+package image
+
+import (
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImage_StopSignalAndHealthcheck(t *testing.T) {
+	tests := []struct {
+		name            string
+		config          v1.Config
+		wantStopSignal  string
+		wantHealthcheck *Healthcheck
+		wantDisabled    bool
+	}{
+		{
+			name: "not declared",
+		},
+		{
+			name: "declared",
+			config: v1.Config{
+				StopSignal: "SIGQUIT",
+				Healthcheck: &v1.HealthConfig{
+					Test:        []string{"CMD", "curl", "-f", "http://localhost/"},
+					Interval:    30 * time.Second,
+					Timeout:     5 * time.Second,
+					StartPeriod: 10 * time.Second,
+					Retries:     3,
+				},
+			},
+			wantStopSignal: "SIGQUIT",
+			wantHealthcheck: &Healthcheck{
+				Test:        []string{"CMD", "curl", "-f", "http://localhost/"},
+				Interval:    30 * time.Second,
+				Timeout:     5 * time.Second,
+				StartPeriod: 10 * time.Second,
+				Retries:     3,
+			},
+		},
+		{
+			name: "disabled",
+			config: v1.Config{
+				Healthcheck: &v1.HealthConfig{Test: []string{"NONE"}},
+			},
+			wantHealthcheck: &Healthcheck{Test: []string{"NONE"}},
+			wantDisabled:    true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v1Image, err := mutate.Config(newTestV1Image(t, []testEntry{testDir("/data")}), test.config)
+			require.NoError(t, err)
+
+			img := NewImage(v1Image, t.TempDir())
+			require.NoError(t, img.Read())
+
+			assert.Equal(t, test.wantStopSignal, img.Metadata.StopSignal)
+			assert.Equal(t, test.wantHealthcheck, img.Metadata.Healthcheck)
+			if test.wantHealthcheck != nil {
+				assert.Equal(t, test.wantDisabled, img.Metadata.Healthcheck.Disabled())
+			}
+		})
+	}
+}
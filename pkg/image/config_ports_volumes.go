@@ -0,0 +1,81 @@
+package image
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/anchore/stereoscope/internal/log"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// defaultPortProtocol is the protocol assumed for exposed ports that do not specify one (as with the docker daemon).
+const defaultPortProtocol = "tcp"
+
+// Port is a port exposed by the image config (e.g. "80/tcp").
+type Port struct {
+	Number uint16
+	// Protocol is the lowercase transport protocol (e.g. "tcp", "udp", or "sctp")
+	Protocol string
+}
+
+func (p Port) String() string {
+	return fmt.Sprintf("%d/%s", p.Number, p.Protocol)
+}
+
+// ParsePort parses an exposed port from the image config (e.g. "80/tcp", "53/udp", or "8080", which is assumed to
+// be tcp).
+func ParsePort(value string) (Port, error) {
+	parts := strings.SplitN(strings.TrimSpace(value), "/", 2)
+
+	number, err := strconv.ParseUint(parts[0], 10, 16)
+	if err != nil || number == 0 {
+		return Port{}, fmt.Errorf("invalid port number in exposed port=%q", value)
+	}
+
+	protocol := defaultPortProtocol
+	if len(parts) == 2 {
+		protocol = strings.ToLower(parts[1])
+		if protocol == "" {
+			return Port{}, fmt.Errorf("missing protocol in exposed port=%q", value)
+		}
+	}
+
+	return Port{
+		Number:   uint16(number),
+		Protocol: protocol,
+	}, nil
+}
+
+// exposedPorts returns the parsed exposed ports from the given config, sorted by port number and protocol. Ports that
+// cannot be parsed are skipped (the raw values remain available within the config).
+func exposedPorts(config v1.Config) []Port {
+	var ports []Port
+	for value := range config.ExposedPorts {
+		port, err := ParsePort(value)
+		if err != nil {
+			log.Debugf("skipping exposed port: %+v", err)
+			continue
+		}
+		ports = append(ports, port)
+	}
+
+	sort.Slice(ports, func(i, j int) bool {
+		if ports[i].Number != ports[j].Number {
+			return ports[i].Number < ports[j].Number
+		}
+		return ports[i].Protocol < ports[j].Protocol
+	})
+	return ports
+}
+
+// volumes returns the sorted volume paths declared within the given config.
+func volumes(config v1.Config) []string {
+	var paths []string
+	for volume := range config.Volumes {
+		paths = append(paths, volume)
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,71 @@
+package image
+
+import (
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePort(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Port
+		wantErr  bool
+	}{
+		{input: "80/tcp", expected: Port{Number: 80, Protocol: "tcp"}},
+		{input: "53/UDP", expected: Port{Number: 53, Protocol: "udp"}},
+		{input: "9000/sctp", expected: Port{Number: 9000, Protocol: "sctp"}},
+		{input: "8080", expected: Port{Number: 8080, Protocol: "tcp"}},
+		{input: "65536/tcp", wantErr: true},
+		{input: "0/tcp", wantErr: true},
+		{input: "8000-8010/tcp", wantErr: true},
+		{input: "http/tcp", wantErr: true},
+		{input: "80/", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			actual, err := ParsePort(test.input)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestImage_ExposedPortsAndVolumes(t *testing.T) {
+	v1Image := newTestV1Image(t, []testEntry{testDir("/data")})
+	v1Image, err := mutate.Config(v1Image, v1.Config{
+		ExposedPorts: map[string]struct{}{
+			"443/tcp":    {},
+			"53/udp":     {},
+			"53/tcp":     {},
+			"not-a-port": {},
+		},
+		Volumes: map[string]struct{}{
+			"/var/lib/data": {},
+			"/data":         {},
+		},
+	})
+	require.NoError(t, err)
+
+	img := NewImage(v1Image, t.TempDir())
+	require.NoError(t, img.Read())
+
+	assert.Equal(t, []Port{
+		{Number: 53, Protocol: "tcp"},
+		{Number: 53, Protocol: "udp"},
+		{Number: 443, Protocol: "tcp"},
+	}, img.Metadata.ExposedPorts)
+	assert.Equal(t, []string{"/data", "/var/lib/data"}, img.Metadata.Volumes)
+
+	// the raw values remain available, including any that could not be parsed
+	assert.Contains(t, img.Metadata.Config.Config.ExposedPorts, "not-a-port")
+	assert.Equal(t, "443/tcp", img.Metadata.ExposedPorts[2].String())
+}
@@ -15,7 +15,9 @@ func fetchFileContentsByPath(ft *filetree.FileTree, fileCatalog *FileCatalog, pa
 	if err != nil {
 		return nil, err
 	}
-	if !exists && fileReference == nil {
+	if !exists || fileReference == nil {
+		// note: paths may exist without a reference, e.g. the root of an empty tree (as with scratch images) or
+		// parent dirs without a tar entry
 		return nil, fmt.Errorf("could not find file path in Tree: %s", path)
 	}
 
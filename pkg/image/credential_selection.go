@@ -0,0 +1,56 @@
+package image
+
+import "sync"
+
+// CredentialSource identifies where the credentials used to access a registry came from.
+type CredentialSource string
+
+const (
+	// RegistryOptionsCredentialSource indicates credentials given explicitly with the RegistryOptions.
+	RegistryOptionsCredentialSource CredentialSource = "registry-options"
+	// CredentialHelperCredentialSource indicates credentials from a docker credential helper (credHelpers or credsStore).
+	CredentialHelperCredentialSource CredentialSource = "credential-helper"
+	// DockerConfigCredentialSource indicates credentials stored within the docker config file (auths).
+	DockerConfigCredentialSource CredentialSource = "docker-config"
+	// AnonymousCredentialSource indicates that no credentials were found, so the registry is accessed anonymously.
+	AnonymousCredentialSource CredentialSource = "anonymous"
+)
+
+// CredentialSelection describes which credentials were selected to access a registry. This never includes the
+// credentials themselves.
+type CredentialSelection struct {
+	// Registry is the registry host the credentials are for (e.g. "index.docker.io" or "localhost:5000").
+	Registry string
+	// Source is where the credentials came from.
+	Source CredentialSource
+	// Helper is the name of the docker credential helper (e.g. "desktop" or "ecr-login") when the source is a
+	// credential helper.
+	Helper string
+}
+
+var credentialSelection = struct {
+	lock    sync.RWMutex
+	handler func(CredentialSelection)
+}{}
+
+// SetCredentialSelectionHandler sets a function that is called whenever credentials are selected to access a registry,
+// by the registry provider and by the docker daemon provider when pulling (where the daemon is given the credentials
+// from the docker config). This is useful for auditing which credentials were used without logging any secrets. A nil
+// handler (the default) disables the notifications. Note: the handler may be called concurrently.
+func SetCredentialSelectionHandler(handler func(CredentialSelection)) {
+	credentialSelection.lock.Lock()
+	defer credentialSelection.lock.Unlock()
+
+	credentialSelection.handler = handler
+}
+
+// NotifyCredentialSelection calls the handler set with SetCredentialSelectionHandler (if any) with the given selection.
+func NotifyCredentialSelection(selection CredentialSelection) {
+	credentialSelection.lock.RLock()
+	handler := credentialSelection.handler
+	credentialSelection.lock.RUnlock()
+
+	if handler != nil {
+		handler(selection)
+	}
+}
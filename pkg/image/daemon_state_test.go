@@ -0,0 +1,54 @@
+package image
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDaemon is a docker daemon that only answers pings.
+func fakeDaemon(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("API-Version", "1.41")
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestResetDaemonState(t *testing.T) {
+	original, exists := os.LookupEnv("DOCKER_HOST")
+	t.Cleanup(func() {
+		if exists {
+			_ = os.Setenv("DOCKER_HOST", original)
+		} else {
+			_ = os.Unsetenv("DOCKER_HOST")
+		}
+		_ = ResetDaemonState()
+	})
+
+	// the daemon is unavailable...
+	unavailable := fakeDaemon(t)
+	unavailable.Close()
+	require.NoError(t, os.Setenv("DOCKER_HOST", "tcp://"+unavailable.Listener.Addr().String()))
+	require.NoError(t, ResetDaemonState())
+	assert.Equal(t, OciRegistrySource, DetermineImagePullSource("alpine:latest"))
+
+	// ...then becomes available, which is not noticed by the shared client
+	available := fakeDaemon(t)
+	require.NoError(t, os.Setenv("DOCKER_HOST", "tcp://"+available.Listener.Addr().String()))
+	assert.Equal(t, OciRegistrySource, DetermineImagePullSource("alpine:latest"))
+
+	require.NoError(t, ResetDaemonState())
+	assert.Equal(t, DockerDaemonSource, DetermineImagePullSource("alpine:latest"))
+
+	// ...then becomes unavailable again, which is noticed on the next ping
+	available.Close()
+	assert.Equal(t, OciRegistrySource, DetermineImagePullSource("alpine:latest"))
+}
@@ -0,0 +1,20 @@
+package image
+
+import "fmt"
+
+// WithDisplayReference labels the image with the given reference (e.g. a friendly tag for an image fetched by digest,
+// or vice versa), decoupling how the image is named from what was fetched. The reference is stored normalized (see
+// NormalizeReference) as Metadata.DisplayReference, which takes precedence as the image identity (see
+// Metadata.Identity), while the tags and repo digests still describe what was fetched. This may be given to NewImage or
+// Image.Read for an image from any provider (e.g. the docker daemon or a registry). An error is raised if the reference
+// does not parse.
+func WithDisplayReference(reference string) AdditionalMetadata {
+	return func(image *Image) error {
+		normalized, err := NormalizeReference(reference)
+		if err != nil {
+			return fmt.Errorf("invalid display reference: %w", err)
+		}
+		image.Metadata.DisplayReference = normalized
+		return nil
+	}
+}
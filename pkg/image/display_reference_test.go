@@ -0,0 +1,61 @@
+package image
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithDisplayReference(t *testing.T) {
+	tests := []struct {
+		name      string
+		reference string
+		want      string
+		wantErr   require.ErrorAssertionFunc
+	}{
+		{
+			name:      "friendly tag",
+			reference: "alpine:3.18",
+			want:      "docker.io/library/alpine:3.18",
+		},
+		{
+			name:      "digest",
+			reference: "registry.example.com/team/app@sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+			want:      "registry.example.com/team/app@sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		},
+		{
+			name:      "unparsable",
+			reference: "Not A Reference!",
+			wantErr:   require.Error,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.wantErr == nil {
+				test.wantErr = require.NoError
+			}
+
+			img := NewImage(newTestV1Image(t, []testEntry{testFile("etc/passwd", "root")}), t.TempDir(),
+				WithTags("registry.example.com/team/app:fetched"),
+				WithRepoDigests([]string{"registry.example.com/team/app@sha256:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"}),
+			)
+			err := img.Read(WithDisplayReference(test.reference))
+			test.wantErr(t, err)
+			if err != nil {
+				return
+			}
+
+			assert.Equal(t, test.want, img.Metadata.DisplayReference)
+			assert.Equal(t, test.want, img.Metadata.Identity())
+			// what was fetched is still described by the tags and repo digests
+			assert.Equal(t, "registry.example.com/team/app:fetched", img.Metadata.Tags[0].String())
+			assert.Len(t, img.Metadata.RepoDigests, 1)
+
+			doc, err := json.Marshal(img.Metadata)
+			require.NoError(t, err)
+			assert.Contains(t, string(doc), `"displayReference":"`+test.want+`"`)
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package image
+
+import (
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/filetree"
+)
+
+// distrolessShells are the shells searched for within the image squash.
+var distrolessShells = []string{
+	"/bin/sh",
+	"/bin/bash",
+	"/bin/ash",
+	"/bin/dash",
+	"/bin/zsh",
+	"/bin/busybox",
+	"/usr/bin/sh",
+	"/usr/bin/bash",
+}
+
+// distrolessPackageManagers are the package manager executables searched for within the image squash.
+var distrolessPackageManagers = []string{
+	"/usr/bin/apt",
+	"/usr/bin/apt-get",
+	"/usr/bin/dpkg",
+	"/usr/bin/rpm",
+	"/usr/bin/yum",
+	"/usr/bin/dnf",
+	"/usr/bin/microdnf",
+	"/usr/bin/zypper",
+	"/usr/bin/pacman",
+	"/sbin/apk",
+}
+
+// distrolessPackageDatabases are the package databases searched for within the image squash.
+var distrolessPackageDatabases = []string{
+	"/var/lib/dpkg/status",
+	"/var/lib/dpkg/status.d",
+	"/var/lib/rpm",
+	"/usr/lib/sysimage/rpm",
+	"/lib/apk/db/installed",
+	"/var/lib/pacman/local",
+}
+
+// DistrolessSignals is the evidence used to decide if an image is distroless (see Image.IsDistroless). Each field lists
+// the well-known paths that were found within the image squash (links are resolved).
+type DistrolessSignals struct {
+	// Shells are the shells found (e.g. "/bin/sh")
+	Shells []string
+	// PackageManagers are the package manager executables found (e.g. "/usr/bin/apt")
+	PackageManagers []string
+	// PackageDatabases are the package databases found (e.g. "/var/lib/dpkg/status"). Note: distroless images may
+	// still carry package metadata (e.g. "/var/lib/dpkg/status.d"), thus these do not affect the classification.
+	PackageDatabases []string
+}
+
+// IsDistroless indicates that neither a shell nor a package manager was found.
+func (s DistrolessSignals) IsDistroless() bool {
+	return len(s.Shells) == 0 && len(s.PackageManagers) == 0
+}
+
+// IsStatic indicates that there is no trace of a distro at all: no shell, package manager, or package database (as with
+// images holding a single statically linked binary).
+func (s DistrolessSignals) IsStatic() bool {
+	return s.IsDistroless() && len(s.PackageDatabases) == 0
+}
+
+// DistrolessSignals searches the image squash (the image must be read first) for well-known shells, package managers,
+// and package databases. This is a heuristic meant as a hint for which analyzers to run, not a guarantee (e.g. a shell
+// at an unusual path is not found).
+func (i *Image) DistrolessSignals() DistrolessSignals {
+	tree := i.SquashedTree()
+	return DistrolessSignals{
+		Shells:           existingPaths(tree, distrolessShells),
+		PackageManagers:  existingPaths(tree, distrolessPackageManagers),
+		PackageDatabases: existingPaths(tree, distrolessPackageDatabases),
+	}
+}
+
+// IsDistroless heuristically determines that the image has neither a shell nor a package manager (see
+// DistrolessSignals for the evidence used).
+func (i *Image) IsDistroless() bool {
+	return i.DistrolessSignals().IsDistroless()
+}
+
+// existingPaths returns the given paths that exist within the given tree (following links, dead links do not exist).
+func existingPaths(tree *filetree.FileTree, paths []string) []string {
+	var found []string
+	for _, p := range paths {
+		if tree.HasPath(file.Path(p), filetree.FollowBasenameLinks) {
+			found = append(found, p)
+		}
+	}
+	return found
+}
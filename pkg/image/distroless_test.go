@@ -0,0 +1,66 @@
+package image
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImage_DistrolessSignals(t *testing.T) {
+	tests := []struct {
+		name           string
+		layers         [][]testEntry
+		want           DistrolessSignals
+		wantDistroless bool
+		wantStatic     bool
+	}{
+		{
+			name: "debian",
+			layers: [][]testEntry{{
+				testFile("usr/bin/bash", "bash"),
+				testSymlink("bin", "usr/bin"),
+				testSymlink("usr/bin/sh", "bash"),
+				testFile("usr/bin/apt", "apt"),
+				testFile("var/lib/dpkg/status", "Package: base-files"),
+			}},
+			want: DistrolessSignals{
+				Shells:           []string{"/bin/sh", "/bin/bash", "/usr/bin/sh", "/usr/bin/bash"},
+				PackageManagers:  []string{"/usr/bin/apt"},
+				PackageDatabases: []string{"/var/lib/dpkg/status"},
+			},
+		},
+		{
+			name: "distroless",
+			layers: [][]testEntry{{
+				testFile("var/lib/dpkg/status.d/base", "Package: base-files"),
+				testFile("app/server", "binary"),
+				testSymlink("bin/sh", "/bin/busybox"),
+			}},
+			want: DistrolessSignals{
+				PackageDatabases: []string{"/var/lib/dpkg/status.d"},
+			},
+			wantDistroless: true,
+		},
+		{
+			name: "shell removed in a later layer",
+			layers: [][]testEntry{
+				{testFile("bin/sh", "sh"), testFile("app/server", "binary")},
+				{testFile("bin/.wh.sh", "")},
+			},
+			want:           DistrolessSignals{},
+			wantDistroless: true,
+			wantStatic:     true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			img := newTestImage(t, test.layers...)
+
+			signals := img.DistrolessSignals()
+			assert.Equal(t, test.want, signals)
+			assert.Equal(t, test.wantDistroless, img.IsDistroless())
+			assert.Equal(t, test.wantStatic, signals.IsStatic())
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package docker
+
+import (
+	"github.com/anchore/stereoscope/internal/docker"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+// CloseClient closes the docker client shared by all daemon providers, which is created afresh on next use (see
+// image.ResetDaemonState).
+func CloseClient() error {
+	return image.ResetDaemonState()
+}
+
+// SetAPIVersion pins the docker API version used to communicate with the docker daemon (e.g. "1.24" for legacy
+// daemons), taking precedence over the DOCKER_API_VERSION environment variable. By default the version is negotiated
+// with the daemon. An empty version removes the pin. This only applies to clients created after the call (see
+// CloseClient).
+func SetAPIVersion(version string) {
+	docker.SetAPIVersion(version)
+}
+
+// SetMaxAPIVersion caps the docker API version negotiated with the docker daemon. An empty version removes the cap.
+// This only applies to clients created after the call (see CloseClient).
+func SetMaxAPIVersion(version string) {
+	docker.SetMaxAPIVersion(version)
+}
@@ -0,0 +1,156 @@
+package docker
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// hasCompressedLayers indicates if any layer referenced by the given docker archive manifest entry is gzip compressed
+// (e.g. "<id>/layer.tar.gz"), determined by the file extension or the leading magic bytes of the layer file.
+func hasCompressedLayers(archivePath string, descriptor tarball.Descriptor) (bool, error) {
+	remaining := make(map[string]struct{})
+	for _, layerPath := range descriptor.Layers {
+		switch strings.ToLower(path.Ext(layerPath)) {
+		case ".gz", ".tgz":
+			return true, nil
+		}
+		remaining[layerPath] = struct{}{}
+	}
+	if len(remaining) == 0 {
+		return false, nil
+	}
+
+	f, err := file.OpenArchive(archivePath)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Errorf("unable to close tar file (%s): %w", archivePath, err)
+		}
+	}()
+
+	var compressed bool
+	err = file.IterateTar(f, func(entry file.TarFileEntry) error {
+		if _, ok := remaining[entry.Header.Name]; !ok {
+			return nil
+		}
+		delete(remaining, entry.Header.Name)
+
+		isGzip, err := file.IsGzipCompressed(entry.Reader)
+		if err != nil {
+			return fmt.Errorf("unable to read layer=%q: %w", entry.Header.Name, err)
+		}
+		if isGzip {
+			compressed = true
+			return file.ErrTarStopIteration
+		}
+		if len(remaining) == 0 {
+			return file.ErrTarStopIteration
+		}
+		return nil
+	})
+	return compressed, err
+}
+
+// archiveImage is a partial.UncompressedImageCore for a docker archive where each layer file may independently be an
+// uncompressed or gzip compressed tar (the tarball lib assumes all layers are compressed the same as the first layer).
+type archiveImage struct {
+	archivePath string
+	descriptor  tarball.Descriptor
+	rawConfig   []byte
+	diffIDs     []v1.Hash
+}
+
+var _ partial.UncompressedImageCore = (*archiveImage)(nil)
+
+// newArchiveImage creates an image for the given manifest entry within the docker archive at the given path.
+func newArchiveImage(archivePath string, descriptor tarball.Descriptor) (v1.Image, error) {
+	rawConfig, err := readFromArchive(archivePath, descriptor.Config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find docker config: %w", err)
+	}
+
+	config, err := v1.ParseConfigFile(bytes.NewReader(rawConfig))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse docker config: %w", err)
+	}
+
+	if len(config.RootFS.DiffIDs) != len(descriptor.Layers) {
+		return nil, fmt.Errorf("docker config has %d layers but the archive manifest has %d layers", len(config.RootFS.DiffIDs), len(descriptor.Layers))
+	}
+
+	return partial.UncompressedToImage(&archiveImage{
+		archivePath: archivePath,
+		descriptor:  descriptor,
+		rawConfig:   rawConfig,
+		diffIDs:     config.RootFS.DiffIDs,
+	})
+}
+
+func (i *archiveImage) RawConfigFile() ([]byte, error) {
+	return i.rawConfig, nil
+}
+
+func (i *archiveImage) MediaType() (types.MediaType, error) {
+	return types.DockerManifestSchema2, nil
+}
+
+func (i *archiveImage) LayerByDiffID(diffID v1.Hash) (partial.UncompressedLayer, error) {
+	for idx, candidate := range i.diffIDs {
+		if candidate == diffID {
+			return &archiveLayer{
+				archivePath: i.archivePath,
+				layerPath:   i.descriptor.Layers[idx],
+				diffID:      diffID,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("diff id %q not found", diffID)
+}
+
+// archiveLayer is a partial.UncompressedLayer for a layer file within a docker archive, which is transparently
+// decompressed when gzip compressed.
+type archiveLayer struct {
+	archivePath string
+	layerPath   string
+	diffID      v1.Hash
+}
+
+func (l *archiveLayer) DiffID() (v1.Hash, error) {
+	return l.diffID, nil
+}
+
+func (l *archiveLayer) Uncompressed() (io.ReadCloser, error) {
+	f, err := file.OpenArchive(l.archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := file.ReaderFromTar(f, l.layerPath)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	uncompressed, err := file.NewArchiveReader(reader)
+	if err != nil {
+		_ = reader.Close()
+		return nil, err
+	}
+	return uncompressed, nil
+}
+
+func (l *archiveLayer) MediaType() (types.MediaType, error) {
+	return types.DockerLayer, nil
+}
@@ -0,0 +1,80 @@
+package docker
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHasCompressedLayers(t *testing.T) {
+	tests := []struct {
+		name     string
+		fixture  string
+		expected bool
+	}{
+		{
+			name:     "gzipped layer by extension",
+			fixture:  "test-fixtures/docker-save-classic.tar",
+			expected: true,
+		},
+		{
+			name:     "gzipped layers by extension and magic bytes",
+			fixture:  "test-fixtures/docker-save-gzip-layers.tar",
+			expected: true,
+		},
+		{
+			name:     "uncompressed layers",
+			fixture:  "test-fixtures/docker-save-oci-layout.tar",
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manifest, err := extractManifest(test.fixture)
+			require.NoError(t, err)
+			require.Len(t, manifest.parsed, 1)
+
+			actual, err := hasCompressedLayers(test.fixture, manifest.parsed[0])
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestTarballImageProvider_Provide_GzipLayers(t *testing.T) {
+	// the first layer is an uncompressed "layer.tar", the second is a "layer.tar.gz", and the third is a gzipped
+	// "layer.tar" (only detectable by the magic bytes)
+	fixture := "test-fixtures/docker-save-gzip-layers.tar"
+
+	tmpDirGen := file.NewTempDirGenerator()
+	t.Cleanup(func() {
+		assert.NoError(t, tmpDirGen.Cleanup())
+	})
+
+	img, err := NewProviderFromTarball(fixture, &tmpDirGen, nil, nil).Provide()
+	require.NoError(t, err)
+	require.NoError(t, img.Read())
+
+	require.Len(t, img.Layers, 3)
+	for idx, layer := range img.Layers {
+		assert.Equal(t, img.Metadata.Config.RootFS.DiffIDs[idx].String(), layer.Metadata.Digest)
+	}
+	require.Len(t, img.Metadata.Tags, 1)
+	assert.Equal(t, "stereoscope-fixture-gzip-layers:latest", img.Metadata.Tags[0].String())
+
+	expected := map[string]string{
+		"/etc/hello.txt":  "hello from layer 3\n",
+		"/etc/second.txt": "hello from layer 2\n",
+	}
+	for path, contents := range expected {
+		reader, err := img.FileContentsFromSquash(file.Path(path))
+		require.NoError(t, err)
+		actual, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, contents, string(actual), path)
+	}
+}
@@ -5,18 +5,21 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math"
 	"os"
 	"path"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/anchore/stereoscope/pkg/file"
 
 	"github.com/docker/cli/cli/config/configfile"
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 
 	"github.com/anchore/stereoscope/internal/bus"
 	"github.com/anchore/stereoscope/internal/docker"
@@ -30,32 +33,64 @@ import (
 	"github.com/wagoodman/go-progress"
 )
 
+// apiClient is the subset of the docker client API that is used by the daemon provider.
+type apiClient interface {
+	ImageInspectWithRaw(ctx context.Context, image string) (types.ImageInspect, []byte, error)
+	ImagePull(ctx context.Context, ref string, options types.ImagePullOptions) (io.ReadCloser, error)
+	ImageSave(ctx context.Context, images []string) (io.ReadCloser, error)
+}
+
+// tarFile is the destination of the image tar saved from the docker daemon.
+type tarFile interface {
+	io.WriteCloser
+	Name() string
+}
+
 // DaemonImageProvider is a image.Provider capable of fetching and representing a docker image from the docker daemon API.
 type DaemonImageProvider struct {
-	imageStr  string
-	tmpDirGen *file.TempDirGenerator
+	imageStr      string
+	platform      string
+	tmpDirGen     *file.TempDirGenerator
+	getClient     func() (apiClient, error)
+	createTarFile func(name string) (tarFile, error)
 }
 
 // NewProviderFromDaemon creates a new provider instance for a specific image that will later be cached to the given directory.
 func NewProviderFromDaemon(imgStr string, tmpDirGen *file.TempDirGenerator) *DaemonImageProvider {
 	return &DaemonImageProvider{
-		imageStr:  imgStr,
-		tmpDirGen: tmpDirGen,
+		imageStr:      imgStr,
+		tmpDirGen:     tmpDirGen,
+		getClient:     sharedClient,
+		createTarFile: createTarFile,
 	}
 }
 
-func (p *DaemonImageProvider) trackSaveProgress() (*progress.TimedProgress, *progress.Writer, *progress.Stage, error) {
-	dockerClient, err := docker.GetClient()
-	if err != nil {
-		return nil, nil, nil, fmt.Errorf("unable to get docker client: %w", err)
-	}
+// NewProviderFromDaemonWithPlatform creates a new provider instance for a specific platform (e.g. "linux/arm64" or
+// "linux/arm/v7") of a multi-platform image. The platform is used when pulling the image, and when inspecting and
+// saving the image on daemons that support it (API version 1.48 and above, e.g. with the containerd image store). Older
+// daemons only hold a single platform of an image, in which case that platform is used (with a warning if it does not
+// match the requested platform).
+func NewProviderFromDaemonWithPlatform(imgStr string, tmpDirGen *file.TempDirGenerator, platform string) *DaemonImageProvider {
+	p := NewProviderFromDaemon(imgStr, tmpDirGen)
+	p.platform = platform
+	return p
+}
 
-	// fetch the expected image size to estimate and measure progress
-	inspect, _, err := dockerClient.ImageInspectWithRaw(context.Background(), p.imageStr)
+// createTarFile creates the file on disk for the image tar.
+func createTarFile(name string) (tarFile, error) {
+	return os.Create(name)
+}
+
+// sharedClient returns the process-wide docker client.
+func sharedClient() (apiClient, error) {
+	dockerClient, err := docker.GetClient()
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("unable to inspect image: %w", err)
+		return nil, err
 	}
+	return platformClient{Client: dockerClient}, nil
+}
 
+func (p *DaemonImageProvider) trackSaveProgress(inspect types.ImageInspect, disabled event.TypeSet) (*progress.TimedProgress, *progress.Writer, *event.Stage, *event.TerminableProgress) {
 	// docker image save clocks in at ~125MB/sec on my laptop... mileage may vary, of course :shrug:
 	mb := math.Pow(2, 20)
 	sec := float64(inspect.VirtualSize) / (mb * 125)
@@ -63,73 +98,157 @@ func (p *DaemonImageProvider) trackSaveProgress() (*progress.TimedProgress, *pro
 
 	estimateSaveProgress := progress.NewTimedProgress(approxSaveTime)
 	copyProgress := progress.NewSizedWriter(inspect.VirtualSize)
-	aggregateProgress := progress.NewAggregator(progress.NormalizeStrategy, estimateSaveProgress, copyProgress)
+
+	var progs []progress.Progressable
+	if approxSaveTime.Milliseconds() > 0 {
+		progs = append(progs, estimateSaveProgress)
+	} else {
+		// there is nothing to estimate (a zero duration cannot be normalized)
+		estimateSaveProgress.SetCompleted()
+	}
+	if inspect.VirtualSize <= 0 {
+		// some daemons do not report the image size (e.g. with the containerd image store), thus the number of bytes
+		// to save is unknown and the progress is indeterminate until the save completes
+		log.Debugf("docker daemon did not report a size for image=%q, save progress is indeterminate", p.imageStr)
+		copyProgress = progress.NewWriter()
+	}
+	progs = append(progs, copyProgress)
+
+	aggregateProgress := event.NewTerminableProgress(progress.NewAggregator(progress.NormalizeStrategy, progs...))
 
 	// let consumers know of a monitorable event (image save + copy stages)
-	stage := &progress.Stage{}
+	stage := &event.Stage{}
 
-	bus.Publish(partybus.Event{
+	bus.PublishUnless(partybus.Event{
 		Type:   event.FetchImage,
 		Source: p.imageStr,
 		Value: progress.StagedProgressable(&struct {
 			progress.Stager
-			*progress.Aggregator
+			event.StageCoder
+			*event.TerminableProgress
 		}{
-			Stager:     progress.Stager(stage),
-			Aggregator: aggregateProgress,
+			Stager:             progress.Stager(stage),
+			StageCoder:         event.StageCoder(stage),
+			TerminableProgress: aggregateProgress,
 		}),
-	})
+	}, disabled)
 
-	return estimateSaveProgress, copyProgress, stage, nil
+	return estimateSaveProgress, copyProgress, stage, aggregateProgress
 }
 
-// pull a docker image
-func (p *DaemonImageProvider) pull(ctx context.Context) error {
+// terminateProgress marks the given progress as ended, distinguishing a cancellation (the given context is done) from
+// a failure (an error is given).
+func terminateProgress(ctx context.Context, prog *event.TerminableProgress, err error) {
+	switch {
+	case ctx.Err() != nil:
+		prog.SetCancelled(ctx.Err())
+	case err != nil:
+		prog.SetFailed(err)
+	default:
+		prog.SetCompleted()
+	}
+}
+
+// contextReader is an io.Reader that stops reading once the given context is done.
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
+
+// reportingWriter gives the number of bytes written so far to the progress reporter.
+type reportingWriter struct {
+	image string
+	stage event.StageCode
+	total int64
+	n     int64
+}
+
+func (w *reportingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	bus.ReportProgress(w.image, w.stage, w.n, w.total)
+	return len(p), nil
+}
+
+// pull a docker image, returning stats about what was transferred
+func (p *DaemonImageProvider) pull(ctx context.Context) (_ *image.FetchStats, err error) {
 	log.Debugf("pulling docker image=%q", p.imageStr)
+	start := time.Now()
+
+	// the daemon pulls from the registry on our behalf, which is subject to the registry policy
+	if ref, err := name.ParseReference(p.imageStr); err == nil {
+		if err := image.CheckRegistryPermitted(ref.Context().RegistryStr()); err != nil {
+			return nil, err
+		}
+	}
 
 	// note: this will search the default config dir and allow for a DOCKER_CONFIG override
 	cfg, err := config.Load("")
 	if err != nil {
-		return fmt.Errorf("failed to load docker config: %w", err)
+		return nil, fmt.Errorf("failed to load docker config: %w", err)
 	}
 	log.Debugf("using docker config=%q", cfg.Filename)
 
 	var status = newPullStatus()
 	defer func() {
-		status.complete = true
+		status.terminate(ctx, err)
+		if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			bytes, layersCompleted, layersTotal := status.transferred()
+			err = image.NewErrFetchDeadlineExceeded(p.imageStr, image.FetchProgress{
+				Stage:           "pulling",
+				Bytes:           bytes,
+				Duration:        time.Since(start),
+				LayersCompleted: layersCompleted,
+				LayersTotal:     layersTotal,
+			})
+		}
 	}()
 
+	bus.ReportStage(p.imageStr, event.PullingStage, "pulling image")
+
 	// publish a pull event on the bus, allowing for read-only consumption of status
-	bus.Publish(partybus.Event{
+	bus.PublishUnless(partybus.Event{
 		Type:   event.PullDockerImage,
 		Source: p.imageStr,
 		Value:  status,
-	})
+	}, event.DisabledTypes(ctx))
 
-	dockerClient, err := docker.GetClient()
+	dockerClient, err := p.getClient()
 	if err != nil {
-		return fmt.Errorf("failed to load docker client: %w", err)
+		return nil, fmt.Errorf("failed to load docker client: %w", err)
 	}
 
 	options, err := newPullOptions(p.imageStr, cfg)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	options.Platform = p.platform
 
 	resp, err := dockerClient.ImagePull(ctx, p.imageStr, options)
 	if err != nil {
-		return fmt.Errorf("pull failed: %w", err)
+		return nil, fmt.Errorf("pull failed: %w", err)
 	}
+	defer func() {
+		if err := resp.Close(); err != nil {
+			log.Errorf("unable to close pull response: %w", err)
+		}
+	}()
 
 	var thePullEvent *pullEvent
-	decoder := json.NewDecoder(resp)
+	decoder := json.NewDecoder(contextReader{ctx: ctx, reader: resp})
 	for {
 		if err := decoder.Decode(&thePullEvent); err != nil {
 			if err == io.EOF {
 				break
 			}
 
-			return fmt.Errorf("failed to pull image: %w", err)
+			return nil, fmt.Errorf("failed to pull image: %w", err)
 		}
 
 		// check for the last two events indicating the pull is complete
@@ -138,57 +257,133 @@ func (p *DaemonImageProvider) pull(ctx context.Context) error {
 		}
 
 		status.onEvent(thePullEvent)
+
+		current, total := status.downloaded()
+		bus.ReportProgress(p.imageStr, event.PullingStage, current, total)
 	}
 
-	return nil
+	return &image.FetchStats{
+		PulledFromRegistry: true,
+		Duration:           time.Since(start),
+		Bytes:              status.downloadedBytes(),
+	}, nil
 }
 
 // Provide an image object that represents the cached docker image tar fetched from a docker daemon.
 func (p *DaemonImageProvider) Provide() (*image.Image, error) {
+	return p.ProvideContext(context.Background())
+}
+
+// ProvideContext is Provide where the given context can be used to cancel the pull and save of the image. Upon
+// cancellation the in-flight bus events are marked as cancelled and the partially saved image tar is removed. If the
+// context deadline passes then a *image.ErrFetchDeadlineExceeded is returned describing how far the fetch got.
+func (p *DaemonImageProvider) ProvideContext(ctx context.Context) (_ *image.Image, err error) {
+	start := time.Now()
+	fetchStats := &image.FetchStats{}
+	var copyProgress *progress.Writer
+	defer func() {
+		var deadlineErr *image.ErrFetchDeadlineExceeded
+		if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) || errors.As(err, &deadlineErr) {
+			return
+		}
+		// note: the pull reports its own progress, this is the save from the daemon (after any pull)
+		fetchProgress := image.FetchProgress{
+			Stage:    "saving",
+			Duration: time.Since(start),
+		}
+		if fetchStats != nil {
+			fetchProgress.Bytes += fetchStats.Bytes
+		}
+		if copyProgress != nil {
+			fetchProgress.Bytes += copyProgress.Current()
+		}
+		err = image.NewErrFetchDeadlineExceeded(p.imageStr, fetchProgress)
+	}()
+
+	var requestedPlatform *v1.Platform
+	if p.platform != "" {
+		parsed, err := parsePlatform(p.platform)
+		if err != nil {
+			return nil, err
+		}
+		requestedPlatform = &parsed
+	}
+
 	imageTempDir, err := p.tmpDirGen.NewTempDir()
 	if err != nil {
 		return nil, err
 	}
 
 	// create a file within the temp dir
-	tempTarFile, err := os.Create(path.Join(imageTempDir, "image.tar"))
+	tempTarFile, err := p.createTarFile(path.Join(imageTempDir, "image.tar"))
 	if err != nil {
 		return nil, fmt.Errorf("unable to create temp file for image: %w", err)
 	}
 	defer func() {
-		err := tempTarFile.Close()
-		if err != nil {
-			log.Errorf("unable to close temp file (%s): %w", tempTarFile.Name(), err)
+		if closeErr := tempTarFile.Close(); closeErr != nil {
+			log.Errorf("unable to close temp file (%s): %w", tempTarFile.Name(), closeErr)
+		}
+		if err == nil {
+			return
+		}
+		// don't leave a partial image tar behind
+		if removeErr := os.Remove(tempTarFile.Name()); removeErr != nil && !os.IsNotExist(removeErr) {
+			log.Errorf("unable to remove temp file (%s): %w", tempTarFile.Name(), removeErr)
 		}
 	}()
 
 	// obtain a Docker client
-	dockerClient, err := docker.GetClient()
+	dockerClient, err := p.getClient()
 	if err != nil {
 		return nil, fmt.Errorf("unable to create a docker client: %w", err)
 	}
 
+	// only select the platform on daemons that support it, otherwise use the platform the daemon has
+	platform := p.selectPlatform(ctx, dockerClient, requestedPlatform)
+
 	// check if the image exists locally
-	inspectResult, _, err := dockerClient.ImageInspectWithRaw(context.Background(), p.imageStr)
+	inspectResult, err := inspectImage(ctx, dockerClient, p.imageStr, platform)
+
+	// note: the API version is negotiated with the daemon upon the first request
+	if versioned, ok := dockerClient.(interface{ ClientVersion() string }); ok {
+		log.Debugf("using docker API version=%q", versioned.ClientVersion())
+	}
 
 	if err != nil {
-		if client.IsErrNotFound(err) {
-			if err = p.pull(context.Background()); err != nil {
-				return nil, err
-			}
-		} else {
+		if !client.IsErrNotFound(err) {
 			return nil, fmt.Errorf("unable to inspect existing image: %w", err)
 		}
+
+		if fetchStats, err = p.pull(ctx); err != nil {
+			return nil, err
+		}
+
+		// the image is now local, inspect again to get the tags and digests of what was pulled
+		inspectResult, err = inspectImage(ctx, dockerClient, p.imageStr, platform)
+		if err != nil {
+			return nil, fmt.Errorf("unable to inspect pulled image: %w", err)
+		}
 	}
 
-	// save the image from the docker daemon to a tar file
-	estimateSaveProgress, copyProgress, stage, err := p.trackSaveProgress()
-	if err != nil {
-		return nil, fmt.Errorf("unable to trace image save progress: %w", err)
+	if requestedPlatform != nil && platform == nil && !matchesPlatform(inspectResult, *requestedPlatform) {
+		log.Warnf("docker daemon image=%q has platform=%q which does not match the requested platform=%q", p.imageStr, inspectResult.Os+"/"+inspectResult.Architecture, p.platform)
 	}
 
-	stage.Current = "requesting image from Docker"
-	readCloser, err := dockerClient.ImageSave(context.Background(), []string{p.imageStr})
+	// a digest-pinned reference must resolve to an image with the same repo digest
+	if err = verifyRepoDigest(p.imageStr, inspectResult.RepoDigests); err != nil {
+		return nil, err
+	}
+
+	// save the image from the docker daemon to a tar file
+	estimateSaveProgress, saveCopyProgress, stage, saveProgress := p.trackSaveProgress(inspectResult, event.DisabledTypes(ctx))
+	copyProgress = saveCopyProgress
+	defer func() {
+		terminateProgress(ctx, saveProgress, err)
+	}()
+
+	stage.Set(event.SavingStage, "requesting image from Docker")
+	bus.ReportStage(p.imageStr, event.SavingStage, "requesting image from Docker")
+	readCloser, err := saveImage(ctx, dockerClient, saveReference(p.imageStr, inspectResult), platform)
 	if err != nil {
 		return nil, fmt.Errorf("unable to save image tar: %w", err)
 	}
@@ -204,8 +399,14 @@ func (p *DaemonImageProvider) Provide() (*image.Image, error) {
 
 	// save the image contents to the temp file
 	// note: this is the same image that will be used to querying image content during analysis
-	stage.Current = "saving image to disk"
-	nBytes, err := io.Copy(io.MultiWriter(tempTarFile, copyProgress), readCloser)
+	stage.Set(event.SavingStage, "saving image to disk")
+	bus.ReportStage(p.imageStr, event.SavingStage, "saving image to disk")
+	saveReporter := &reportingWriter{image: p.imageStr, stage: event.SavingStage, total: inspectResult.VirtualSize}
+	nBytes, err := file.Copy(io.MultiWriter(tempTarFile, copyProgress, saveReporter), contextReader{ctx: ctx, reader: readCloser})
+	if errors.Is(err, syscall.ENOSPC) {
+		// note: the partial image tar is removed upon return
+		return nil, &image.ErrNoSpace{Path: tempTarFile.Name(), BytesWritten: nBytes, Err: err}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("unable to save image to tar: %w", err)
 	}
@@ -214,13 +415,136 @@ func (p *DaemonImageProvider) Provide() (*image.Image, error) {
 	}
 
 	// use the existing tarball provider to process what was pulled from the docker daemon
-	return NewProviderFromTarball(tempTarFile.Name(), p.tmpDirGen, inspectResult.RepoTags, inspectResult.RepoDigests).Provide()
+	tarballProvider := NewProviderFromTarball(tempTarFile.Name(), p.tmpDirGen, inspectResult.RepoTags, inspectResult.RepoDigests)
+	tarballProvider.additionalMetadata = append(tarballProvider.additionalMetadata, image.WithFetchStats(fetchStats))
+	if inspectResult.Variant != "" {
+		tarballProvider.additionalMetadata = append(tarballProvider.additionalMetadata, image.WithPlatformVariant(inspectResult.Variant))
+	}
+	if len(inspectResult.RepoTags) == 0 && len(inspectResult.RepoDigests) == 0 {
+		// untagged (dangling) images are identified by ID alone
+		log.Debugf("docker daemon image=%q is untagged, identifying the image by ID=%q", p.imageStr, inspectResult.ID)
+		tarballProvider.additionalMetadata = append(tarballProvider.additionalMetadata, image.WithDangling())
+	}
+	return tarballProvider.Provide()
 }
 
-func newPullOptions(image string, cfg *configfile.ConfigFile) (types.ImagePullOptions, error) {
+// Exists indicates if the image is held by the docker daemon, without pulling or saving the image. An image that is
+// not held by the daemon is reported as not existing (false without an error), even if it could be pulled. An error is
+// returned when the daemon cannot be asked (e.g. the daemon is not running).
+func (p *DaemonImageProvider) Exists(ctx context.Context) (bool, error) {
+	var requestedPlatform *v1.Platform
+	if p.platform != "" {
+		parsed, err := parsePlatform(p.platform)
+		if err != nil {
+			return false, err
+		}
+		requestedPlatform = &parsed
+	}
+
+	dockerClient, err := p.getClient()
+	if err != nil {
+		return false, fmt.Errorf("unable to create a docker client: %w", err)
+	}
+
+	_, err = inspectImage(ctx, dockerClient, p.imageStr, p.selectPlatform(ctx, dockerClient, requestedPlatform))
+	switch {
+	case err == nil:
+		return true, nil
+	case client.IsErrNotFound(err):
+		return false, nil
+	default:
+		return false, fmt.Errorf("unable to inspect image: %w", err)
+	}
+}
+
+// selectPlatform returns the platform to request from the daemon, which is nil when no platform is requested or when
+// the daemon does not support selecting a platform (only a single platform of an image is held by such daemons).
+func (p *DaemonImageProvider) selectPlatform(ctx context.Context, dockerClient apiClient, requested *v1.Platform) *v1.Platform {
+	if requested == nil {
+		return nil
+	}
+	if selector, ok := dockerClient.(platformAPIClient); ok && selector.supportsPlatform(ctx) {
+		return requested
+	}
+	log.Debugf("docker daemon does not support selecting a platform (requires API version %s), using the default platform for image=%q", minPlatformAPIVersion, p.imageStr)
+	return nil
+}
+
+// inspectImage inspects the given image, selecting the given platform (if any).
+func inspectImage(ctx context.Context, dockerClient apiClient, imageStr string, platform *v1.Platform) (types.ImageInspect, error) {
+	if platform != nil {
+		if selector, ok := dockerClient.(platformAPIClient); ok {
+			return selector.imageInspectWithPlatform(ctx, imageStr, *platform)
+		}
+	}
+	inspect, _, err := dockerClient.ImageInspectWithRaw(ctx, imageStr)
+	return inspect, err
+}
+
+// saveImage saves the given image as a docker archive, selecting the given platform (if any).
+func saveImage(ctx context.Context, dockerClient apiClient, imageStr string, platform *v1.Platform) (io.ReadCloser, error) {
+	if platform != nil {
+		if selector, ok := dockerClient.(platformAPIClient); ok {
+			return selector.imageSaveWithPlatform(ctx, []string{imageStr}, *platform)
+		}
+	}
+	return dockerClient.ImageSave(ctx, []string{imageStr})
+}
+
+// saveReference returns the reference to save the inspected image by, which is the image ID found by inspect. The
+// daemon resolves tag, digest, and ID references differently between the inspect and save calls (e.g. a digest
+// reference for an image that was since re-tagged), so the ID ensures that the saved image is the inspected image.
+func saveReference(imageStr string, inspect types.ImageInspect) string {
+	if inspect.ID == "" {
+		return imageStr
+	}
+	return inspect.ID
+}
+
+// matchesPlatform indicates if the inspected image has the given platform. The variant is only compared when requested
+// and reported by inspect (older daemons do not report the variant), see image.NormalizePlatformVariant.
+func matchesPlatform(inspect types.ImageInspect, platform v1.Platform) bool {
+	if !strings.EqualFold(inspect.Os, platform.OS) || !strings.EqualFold(inspect.Architecture, platform.Architecture) {
+		return false
+	}
+	if platform.Variant == "" || inspect.Variant == "" {
+		return true
+	}
+	return image.NormalizePlatformVariant(inspect.Architecture, inspect.Variant) == image.NormalizePlatformVariant(platform.Architecture, platform.Variant)
+}
+
+// verifyRepoDigest ensures that when the given image reference is pinned to a digest (e.g. repo@sha256:...) that the
+// image resolved by the daemon has a matching repo digest. References that are not digest-pinned are not checked.
+func verifyRepoDigest(imageStr string, repoDigests []string) error {
+	ref, err := name.ParseReference(imageStr)
+	if err != nil {
+		// this may be an image ID or other daemon-specific identifier, which we cannot (and need not) verify
+		return nil
+	}
+
+	digestRef, ok := ref.(name.Digest)
+	if !ok {
+		return nil
+	}
+
+	for _, repoDigest := range repoDigests {
+		candidate, err := name.NewDigest(repoDigest)
+		if err != nil {
+			log.Debugf("unable to parse repo digest=%q: %+v", repoDigest, err)
+			continue
+		}
+		if candidate.DigestStr() == digestRef.DigestStr() && candidate.Context().Name() == digestRef.Context().Name() {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("image resolved by the docker daemon does not have the requested digest=%q (found repo digests: %+v)", digestRef.DigestStr(), repoDigests)
+}
+
+func newPullOptions(imageStr string, cfg *configfile.ConfigFile) (types.ImagePullOptions, error) {
 	var options types.ImagePullOptions
 
-	ref, err := name.ParseReference(image)
+	ref, err := name.ParseReference(imageStr)
 	if err != nil {
 		return options, err
 	}
@@ -232,6 +556,11 @@ func newPullOptions(image string, cfg *configfile.ConfigFile) (types.ImagePullOp
 		return options, fmt.Errorf("failed to fetch registry auth (hostname=%s): %w", hostname, err)
 	}
 
+	selection := image.CredentialSelection{
+		Registry: hostname,
+		Source:   image.AnonymousCredentialSource,
+	}
+
 	if creds.Username != "" {
 		log.Debugf("using docker credentials for %q", hostname)
 
@@ -239,11 +568,29 @@ func newPullOptions(image string, cfg *configfile.ConfigFile) (types.ImagePullOp
 		if err != nil {
 			return options, err
 		}
+
+		selection.Source = image.DockerConfigCredentialSource
+		if helper := credentialHelper(cfg, hostname); helper != "" {
+			selection.Source = image.CredentialHelperCredentialSource
+			selection.Helper = helper
+		}
 	}
 
+	image.NotifyCredentialSelection(selection)
 	return options, nil
 }
 
+// credentialHelper returns the name of the credential helper the docker config uses for the given registry (if any).
+func credentialHelper(cfg *configfile.ConfigFile, hostname string) string {
+	if helper, exists := cfg.CredentialHelpers[hostname]; exists {
+		return helper
+	}
+	return cfg.CredentialsStore
+}
+
+// encodeCredentials encodes the given credentials for the X-Registry-Auth header, which the daemon decodes as URL-safe
+// base64 JSON (as the docker CLI encodes it). Note: standard base64 may produce "+" and "/" characters that the daemon
+// fails to decode, which is likely for long secrets (e.g. Harbor robot account tokens), leaving the pull unauthenticated.
 func encodeCredentials(username, password string) (string, error) {
 	buffer := &bytes.Buffer{}
 	encoder := json.NewEncoder(buffer)
@@ -257,5 +604,5 @@ func encodeCredentials(username, password string) (string, error) {
 		return "", err
 	}
 
-	return base64.StdEncoding.EncodeToString(buffer.Bytes()), nil
+	return base64.URLEncoding.EncodeToString(buffer.Bytes()), nil
 }
@@ -1,10 +1,97 @@
 package docker
 
 import (
-	"github.com/stretchr/testify/assert"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
+
+	"github.com/anchore/stereoscope/internal/bus"
+	"github.com/anchore/stereoscope/pkg/event"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/docker/cli/cli/config"
+	"github.com/docker/cli/cli/config/configfile"
+	configtypes "github.com/docker/cli/cli/config/types"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/errdefs"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wagoodman/go-partybus"
+	"github.com/wagoodman/go-progress"
 )
 
+// fakeAPIClient is a stand-in for the docker daemon API, where each call is delegated to the configured function.
+type fakeAPIClient struct {
+	inspect     func(image string) (types.ImageInspect, error)
+	pull        func(ref string) (io.ReadCloser, error)
+	save        func(images []string) (io.ReadCloser, error)
+	pulled      []string
+	pullOptions []types.ImagePullOptions
+	saved       [][]string
+}
+
+func (f *fakeAPIClient) ImageInspectWithRaw(_ context.Context, image string) (types.ImageInspect, []byte, error) {
+	inspect, err := f.inspect(image)
+	return inspect, nil, err
+}
+
+func (f *fakeAPIClient) ImagePull(_ context.Context, ref string, options types.ImagePullOptions) (io.ReadCloser, error) {
+	f.pulled = append(f.pulled, ref)
+	f.pullOptions = append(f.pullOptions, options)
+	return f.pull(ref)
+}
+
+func (f *fakeAPIClient) ImageSave(_ context.Context, images []string) (io.ReadCloser, error) {
+	f.saved = append(f.saved, images)
+	return f.save(images)
+}
+
+func newFakeDaemonProvider(t *testing.T, imageStr string, fake *fakeAPIClient) *DaemonImageProvider {
+	t.Helper()
+
+	tmpDirGen := file.NewTempDirGenerator()
+	t.Cleanup(func() {
+		assert.NoError(t, tmpDirGen.Cleanup())
+	})
+
+	p := NewProviderFromDaemon(imageStr, &tmpDirGen)
+	p.getClient = func() (apiClient, error) {
+		return fake, nil
+	}
+	return p
+}
+
+// dockerArchive returns the contents of a "docker save" tar for a random image.
+func dockerArchive(t *testing.T) []byte {
+	t.Helper()
+
+	img, err := random.Image(64, 2)
+	require.NoError(t, err)
+
+	tag, err := name.NewTag("stereoscope-test:latest")
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, tarball.Write(tag, img, buf))
+	return buf.Bytes()
+}
+
 func TestEncodeCredentials(t *testing.T) {
 	// regression test for https://github.com/anchore/grype/issues/254
 	// the JSON encoded credentials should NOT escape characters
@@ -20,3 +107,912 @@ func TestEncodeCredentials(t *testing.T) {
 
 	assert.Equal(t, expected, actual, "unexpected output")
 }
+
+// harborRobotUsername is a Harbor robot account username, which includes "$" and "+" characters.
+const harborRobotUsername = "robot$my-project+ci-scanner"
+
+// harborRobotToken is a Harbor robot account secret, whose standard base64 encoding includes "+" and "/" characters.
+const harborRobotToken = "eyJhbGciOiJSUzI1NiIsInR5cCI6IkpXVCJ9.c2VjcmV0Pz8/Pj4+fn5+.T0s/Pz8+Pj4-_~~~"
+
+func TestEncodeCredentials_HarborRobotAccount(t *testing.T) {
+	encoded, err := encodeCredentials(harborRobotUsername, harborRobotToken)
+	require.NoError(t, err)
+
+	// the daemon decodes the credentials as URL-safe base64
+	assert.NotContains(t, encoded, "+")
+	assert.NotContains(t, encoded, "/")
+	decoded, err := base64.URLEncoding.DecodeString(encoded)
+	require.NoError(t, err)
+
+	var actual map[string]string
+	require.NoError(t, json.Unmarshal(decoded, &actual))
+	assert.Equal(t, harborRobotUsername, actual["username"])
+	assert.Equal(t, harborRobotToken, actual["password"])
+}
+
+func TestNewPullOptions_HarborRobotAccount(t *testing.T) {
+	// the docker config file stores the credentials as standard base64 of "username:secret"
+	configDir := t.TempDir()
+	auth := base64.StdEncoding.EncodeToString([]byte(harborRobotUsername + ":" + harborRobotToken))
+	contents := fmt.Sprintf(`{"auths": {"harbor.example.com": {"auth": %q}}}`, auth)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(configDir, "config.json"), []byte(contents), 0600))
+
+	cfg, err := config.Load(configDir)
+	require.NoError(t, err)
+
+	options, err := newPullOptions("harbor.example.com/my-project/app:latest", cfg)
+	require.NoError(t, err)
+
+	decoded, err := base64.URLEncoding.DecodeString(options.RegistryAuth)
+	require.NoError(t, err)
+
+	var actual map[string]string
+	require.NoError(t, json.Unmarshal(decoded, &actual))
+	assert.Equal(t, harborRobotUsername, actual["username"])
+	assert.Equal(t, harborRobotToken, actual["password"])
+}
+
+func TestNewPullOptions_CredentialSelection(t *testing.T) {
+	tests := []struct {
+		name     string
+		auths    map[string]configtypes.AuthConfig
+		expected image.CredentialSelection
+	}{
+		{
+			name: "docker config",
+			auths: map[string]configtypes.AuthConfig{
+				"localhost:5000": {Username: "user", Password: "secret"},
+			},
+			expected: image.CredentialSelection{
+				Registry: "localhost:5000",
+				Source:   image.DockerConfigCredentialSource,
+			},
+		},
+		{
+			name: "anonymous",
+			auths: map[string]configtypes.AuthConfig{
+				"other:5000": {Username: "user", Password: "secret"},
+			},
+			expected: image.CredentialSelection{
+				Registry: "localhost:5000",
+				Source:   image.AnonymousCredentialSource,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var selections []image.CredentialSelection
+			image.SetCredentialSelectionHandler(func(selection image.CredentialSelection) {
+				selections = append(selections, selection)
+			})
+			t.Cleanup(func() {
+				image.SetCredentialSelectionHandler(nil)
+			})
+
+			cfg := configfile.New(filepath.Join(t.TempDir(), "config.json"))
+			cfg.AuthConfigs = test.auths
+
+			_, err := newPullOptions("localhost:5000/some/image:latest", cfg)
+			require.NoError(t, err)
+			assert.Equal(t, []image.CredentialSelection{test.expected}, selections)
+		})
+	}
+}
+
+func TestVerifyRepoDigest(t *testing.T) {
+	const digest = "sha256:8f1c7d2b9a4c1e9e2c3a2f1b0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f2e1d"
+	const otherDigest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+
+	tests := []struct {
+		name        string
+		imageStr    string
+		repoDigests []string
+		wantErr     bool
+	}{
+		{
+			name:     "tag reference is not verified",
+			imageStr: "alpine:3.12",
+		},
+		{
+			name:     "image ID is not verified",
+			imageStr: "sha256:" + strings.Repeat("a", 64),
+		},
+		{
+			name:        "matching digest",
+			imageStr:    "alpine@" + digest,
+			repoDigests: []string{"alpine@" + otherDigest, "alpine@" + digest},
+		},
+		{
+			name:        "matching digest with fully qualified reference",
+			imageStr:    "docker.io/library/alpine@" + digest,
+			repoDigests: []string{"alpine@" + digest},
+		},
+		{
+			name:        "mismatched digest",
+			imageStr:    "alpine@" + digest,
+			repoDigests: []string{"alpine@" + otherDigest},
+			wantErr:     true,
+		},
+		{
+			name:        "matching digest for another repo",
+			imageStr:    "alpine@" + digest,
+			repoDigests: []string{"busybox@" + digest},
+			wantErr:     true,
+		},
+		{
+			name:     "no repo digests",
+			imageStr: "alpine@" + digest,
+			wantErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := verifyRepoDigest(test.imageStr, test.repoDigests)
+			if test.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDaemonImageProvider_Provide_PullByDigest(t *testing.T) {
+	const imageStr = "stereoscope-test@sha256:8f1c7d2b9a4c1e9e2c3a2f1b0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f2e1d"
+
+	archive := dockerArchive(t)
+	pulled := false
+	fake := &fakeAPIClient{
+		inspect: func(image string) (types.ImageInspect, error) {
+			if !pulled {
+				return types.ImageInspect{}, errdefs.NotFound(fmt.Errorf("no such image: %s", image))
+			}
+			return types.ImageInspect{
+				ID:          "sha256:" + strings.Repeat("b", 64),
+				RepoDigests: []string{imageStr},
+				VirtualSize: int64(len(archive)),
+			}, nil
+		},
+		pull: func(ref string) (io.ReadCloser, error) {
+			pulled = true
+			return ioutil.NopCloser(strings.NewReader(`{"status":"Status: Downloaded newer image"}`)), nil
+		},
+		save: func(images []string) (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(archive)), nil
+		},
+	}
+
+	img, err := newFakeDaemonProvider(t, imageStr, fake).Provide()
+	require.NoError(t, err)
+	require.NoError(t, img.Read())
+
+	assert.Equal(t, []string{imageStr}, fake.pulled, "expected a pull by digest")
+	assert.Equal(t, [][]string{{"sha256:" + strings.Repeat("b", 64)}}, fake.saved, "expected a save by ID")
+	assert.Equal(t, []string{imageStr}, img.Metadata.RepoDigests)
+}
+
+// limitedTarFile is a tar file on a filesystem with the given number of bytes of free space.
+type limitedTarFile struct {
+	*os.File
+	free int64
+}
+
+func (f *limitedTarFile) Write(p []byte) (int, error) {
+	if int64(len(p)) <= f.free {
+		f.free -= int64(len(p))
+		return f.File.Write(p)
+	}
+	n, err := f.File.Write(p[:f.free])
+	f.free -= int64(n)
+	if err != nil {
+		return n, err
+	}
+	return n, &os.PathError{Op: "write", Path: f.Name(), Err: syscall.ENOSPC}
+}
+
+func TestDaemonImageProvider_Provide_NoSpace(t *testing.T) {
+	const free = 1000
+
+	archive := dockerArchive(t)
+	require.Greater(t, len(archive), free)
+	fake := &fakeAPIClient{
+		inspect: func(image string) (types.ImageInspect, error) {
+			return types.ImageInspect{
+				ID:          "sha256:" + strings.Repeat("b", 64),
+				RepoTags:    []string{"stereoscope-test:latest"},
+				VirtualSize: int64(len(archive)),
+			}, nil
+		},
+		save: func(images []string) (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(archive)), nil
+		},
+	}
+
+	var tarPath string
+	p := newFakeDaemonProvider(t, "stereoscope-test:latest", fake)
+	p.createTarFile = func(name string) (tarFile, error) {
+		tarPath = name
+		f, err := os.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		return &limitedTarFile{File: f, free: free}, nil
+	}
+
+	_, err := p.Provide()
+	require.Error(t, err)
+
+	var noSpaceErr *image.ErrNoSpace
+	require.True(t, errors.As(err, &noSpaceErr), "expected ErrNoSpace, got: %+v", err)
+	assert.True(t, errors.Is(err, syscall.ENOSPC))
+	assert.Equal(t, tarPath, noSpaceErr.Path)
+	assert.Equal(t, int64(free), noSpaceErr.BytesWritten)
+
+	_, statErr := os.Stat(tarPath)
+	assert.True(t, os.IsNotExist(statErr), "expected the partial image tar to be removed")
+}
+
+func TestDaemonImageProvider_Provide_DisplayReference(t *testing.T) {
+	const imageStr = "stereoscope-test@sha256:8f1c7d2b9a4c1e9e2c3a2f1b0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f2e1d"
+
+	archive := dockerArchive(t)
+	fake := &fakeAPIClient{
+		inspect: func(image string) (types.ImageInspect, error) {
+			return types.ImageInspect{
+				ID:          "sha256:" + strings.Repeat("b", 64),
+				RepoDigests: []string{imageStr},
+				VirtualSize: int64(len(archive)),
+			}, nil
+		},
+		save: func(images []string) (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(archive)), nil
+		},
+	}
+
+	img, err := newFakeDaemonProvider(t, imageStr, fake).Provide()
+	require.NoError(t, err)
+	require.NoError(t, img.Read(image.WithDisplayReference("stereoscope-test:friendly")))
+
+	assert.Equal(t, "docker.io/library/stereoscope-test:friendly", img.Metadata.Identity())
+	assert.Equal(t, []string{imageStr}, img.Metadata.RepoDigests)
+}
+
+func TestDaemonImageProvider_Provide_ReferenceForms(t *testing.T) {
+	var (
+		id         = "sha256:" + strings.Repeat("c", 64)
+		tag        = "stereoscope-test:latest"
+		repoDigest = "stereoscope-test@sha256:" + strings.Repeat("d", 64)
+	)
+
+	tests := []struct {
+		name     string
+		imageStr string
+	}{
+		{
+			name:     "tag",
+			imageStr: tag,
+		},
+		{
+			name:     "digest",
+			imageStr: repoDigest,
+		},
+		{
+			name:     "ID",
+			imageStr: id,
+		},
+		{
+			name:     "short ID",
+			imageStr: strings.Repeat("c", 12),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			archive := dockerArchive(t)
+			fake := &fakeAPIClient{
+				inspect: func(image string) (types.ImageInspect, error) {
+					return types.ImageInspect{
+						ID:          id,
+						RepoTags:    []string{tag},
+						RepoDigests: []string{repoDigest},
+						VirtualSize: int64(len(archive)),
+					}, nil
+				},
+				save: func(images []string) (io.ReadCloser, error) {
+					// the daemon only knows the image by ID at this point (e.g. the tag was since moved)
+					if len(images) != 1 || images[0] != id {
+						return nil, errdefs.NotFound(fmt.Errorf("no such image: %s", images))
+					}
+					return ioutil.NopCloser(bytes.NewReader(archive)), nil
+				},
+			}
+
+			img, err := newFakeDaemonProvider(t, test.imageStr, fake).Provide()
+			require.NoError(t, err)
+			require.NoError(t, img.Read())
+
+			assert.Equal(t, [][]string{{id}}, fake.saved)
+			assert.Equal(t, []string{repoDigest}, img.Metadata.RepoDigests)
+			assert.Contains(t, img.Metadata.Tags[0].String(), "stereoscope-test:latest")
+		})
+	}
+}
+
+func TestDaemonImageProvider_Provide_DigestMismatch(t *testing.T) {
+	const imageStr = "stereoscope-test@sha256:8f1c7d2b9a4c1e9e2c3a2f1b0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f2e1d"
+
+	fake := &fakeAPIClient{
+		inspect: func(image string) (types.ImageInspect, error) {
+			return types.ImageInspect{
+				RepoDigests: []string{"stereoscope-test@sha256:" + strings.Repeat("1", 64)},
+			}, nil
+		},
+		save: func(images []string) (io.ReadCloser, error) {
+			t.Fatal("save should not be called for a mismatched digest")
+			return nil, nil
+		},
+	}
+
+	_, err := newFakeDaemonProvider(t, imageStr, fake).Provide()
+	assert.Error(t, err)
+	assert.Empty(t, fake.pulled)
+}
+
+func TestDaemonImageProvider_Provide_FetchStats(t *testing.T) {
+	const imageStr = "stereoscope-test:latest"
+
+	pullEvents := strings.Join([]string{
+		`{"status":"Pulling from stereoscope-test","id":"latest"}`,
+		`{"status":"Pulling fs layer","id":"aaaa"}`,
+		`{"status":"Already exists","id":"bbbb"}`,
+		`{"status":"Downloading","id":"aaaa","progressDetail":{"current":50,"total":100}}`,
+		`{"status":"Download complete","id":"aaaa"}`,
+		`{"status":"Pull complete","id":"aaaa"}`,
+		`{"status":"Status: Downloaded newer image for stereoscope-test:latest"}`,
+	}, "\n")
+
+	tests := []struct {
+		name          string
+		present       bool
+		wantPulled    bool
+		wantBytes     int64
+		wantPullCalls int
+	}{
+		{
+			name:          "image already present in the daemon",
+			present:       true,
+			wantPulled:    false,
+			wantBytes:     0,
+			wantPullCalls: 0,
+		},
+		{
+			name:          "image pulled by the daemon",
+			present:       false,
+			wantPulled:    true,
+			wantBytes:     100,
+			wantPullCalls: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			archive := dockerArchive(t)
+			present := test.present
+			fake := &fakeAPIClient{
+				inspect: func(image string) (types.ImageInspect, error) {
+					if !present {
+						return types.ImageInspect{}, errdefs.NotFound(fmt.Errorf("no such image: %s", image))
+					}
+					return types.ImageInspect{
+						RepoTags:    []string{imageStr},
+						VirtualSize: int64(len(archive)),
+					}, nil
+				},
+				pull: func(ref string) (io.ReadCloser, error) {
+					present = true
+					return ioutil.NopCloser(strings.NewReader(pullEvents)), nil
+				},
+				save: func(images []string) (io.ReadCloser, error) {
+					return ioutil.NopCloser(bytes.NewReader(archive)), nil
+				},
+			}
+
+			img, err := newFakeDaemonProvider(t, imageStr, fake).Provide()
+			require.NoError(t, err)
+			require.NoError(t, img.Read())
+
+			assert.Len(t, fake.pulled, test.wantPullCalls)
+			require.NotNil(t, img.Metadata.FetchStats)
+			assert.Equal(t, test.wantPulled, img.Metadata.FetchStats.PulledFromRegistry)
+			assert.Equal(t, test.wantBytes, img.Metadata.FetchStats.Bytes)
+			if !test.wantPulled {
+				assert.Zero(t, img.Metadata.FetchStats.Duration)
+			}
+		})
+	}
+}
+
+// cancellingReader returns the first chunk of the underlying reader and then cancels the context (simulating a user
+// cancelling mid-save).
+type cancellingReader struct {
+	reader io.Reader
+	cancel context.CancelFunc
+	read   bool
+}
+
+func (r *cancellingReader) Read(p []byte) (int, error) {
+	if r.read {
+		r.cancel()
+	}
+	r.read = true
+	if len(p) > 512 {
+		p = p[:512]
+	}
+	return r.reader.Read(p)
+}
+
+func TestDaemonImageProvider_ProvideContext_CancelledDuringSave(t *testing.T) {
+	const imageStr = "stereoscope-test:latest"
+
+	publisher := &recordingPublisher{}
+	bus.SetPublisher(publisher)
+	t.Cleanup(func() {
+		bus.SetPublisher(nil)
+	})
+
+	// capture the temp dirs created by the provider
+	tempRoot := t.TempDir()
+	original, isSet := os.LookupEnv("TMPDIR")
+	require.NoError(t, os.Setenv("TMPDIR", tempRoot))
+	t.Cleanup(func() {
+		if isSet {
+			_ = os.Setenv("TMPDIR", original)
+		} else {
+			_ = os.Unsetenv("TMPDIR")
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	archive := dockerArchive(t)
+	fake := &fakeAPIClient{
+		inspect: func(image string) (types.ImageInspect, error) {
+			return types.ImageInspect{
+				RepoTags:    []string{imageStr},
+				VirtualSize: int64(len(archive)),
+			}, nil
+		},
+		save: func(images []string) (io.ReadCloser, error) {
+			return ioutil.NopCloser(&cancellingReader{reader: bytes.NewReader(archive), cancel: cancel}), nil
+		},
+	}
+
+	_, err := newFakeDaemonProvider(t, imageStr, fake).ProvideContext(ctx)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.Canceled), "unexpected error: %+v", err)
+
+	// the fetch event is marked as cancelled (not left in progress)
+	require.Len(t, publisher.events, 1)
+	require.Equal(t, event.FetchImage, publisher.events[0].Type)
+	prog, ok := publisher.events[0].Value.(progress.StagedProgressable)
+	require.True(t, ok)
+	assert.True(t, errors.Is(prog.Error(), event.ErrCancelled), "unexpected progress error: %+v", prog.Error())
+
+	// the partially saved image tar is removed
+	var tars []string
+	require.NoError(t, filepath.Walk(tempRoot, func(p string, info os.FileInfo, err error) error {
+		if err == nil && strings.HasSuffix(p, ".tar") {
+			tars = append(tars, p)
+		}
+		return err
+	}))
+	assert.Empty(t, tars)
+}
+
+func TestDaemonImageProvider_ProvideContext_CancelledDuringPull(t *testing.T) {
+	const imageStr = "stereoscope-test:latest"
+
+	publisher := &recordingPublisher{}
+	bus.SetPublisher(publisher)
+	t.Cleanup(func() {
+		bus.SetPublisher(nil)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fake := &fakeAPIClient{
+		inspect: func(image string) (types.ImageInspect, error) {
+			return types.ImageInspect{}, errdefs.NotFound(fmt.Errorf("no such image: %s", image))
+		},
+		pull: func(ref string) (io.ReadCloser, error) {
+			events := strings.NewReader(`{"status":"Pulling from stereoscope-test","id":"latest"}` + "\n" + `{"status":"Pulling fs layer","id":"aaaa"}`)
+			return ioutil.NopCloser(&cancellingReader{reader: events, cancel: cancel}), nil
+		},
+	}
+
+	_, err := newFakeDaemonProvider(t, imageStr, fake).ProvideContext(ctx)
+	require.Error(t, err)
+
+	// the pull event is marked as complete and cancelled (there is no save event)
+	require.Len(t, publisher.events, 1)
+	require.Equal(t, event.PullDockerImage, publisher.events[0].Type)
+	status, ok := publisher.events[0].Value.(*PullStatus)
+	require.True(t, ok)
+	assert.True(t, status.Complete())
+	assert.True(t, status.Cancelled())
+}
+
+// stallingReader returns up to the given number of bytes from the underlying reader and then stalls until the context
+// is done (simulating a slow transfer that exceeds a deadline).
+type stallingReader struct {
+	ctx    context.Context
+	reader io.Reader
+	limit  int
+}
+
+func (r *stallingReader) Read(p []byte) (int, error) {
+	if r.limit <= 0 {
+		<-r.ctx.Done()
+		return 0, r.ctx.Err()
+	}
+	if len(p) > r.limit {
+		p = p[:r.limit]
+	}
+	n, err := r.reader.Read(p)
+	r.limit -= n
+	if err == io.EOF {
+		r.limit = 0
+		err = nil
+	}
+	return n, err
+}
+
+func TestDaemonImageProvider_ProvideContext_DeadlineExceeded(t *testing.T) {
+	const imageStr = "stereoscope-test:latest"
+
+	pullEvents := strings.Join([]string{
+		`{"status":"Pulling from stereoscope-test","id":"latest"}`,
+		`{"status":"Already exists","id":"aaaa"}`,
+		`{"status":"Downloading","id":"bbbb","progressDetail":{"current":30,"total":100}}`,
+		`{"status":"Pulling fs layer","id":"cccc"}`,
+	}, "\n") + "\n"
+
+	tests := []struct {
+		name     string
+		present  bool
+		expected image.FetchProgress
+	}{
+		{
+			name:    "during pull",
+			present: false,
+			expected: image.FetchProgress{
+				Stage:           "pulling",
+				Bytes:           30,
+				LayersCompleted: 1,
+				LayersTotal:     3,
+			},
+		},
+		{
+			name:    "during save",
+			present: true,
+			expected: image.FetchProgress{
+				Stage: "saving",
+				Bytes: 512,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			archive := dockerArchive(t)
+			fake := &fakeAPIClient{
+				inspect: func(image string) (types.ImageInspect, error) {
+					if !test.present {
+						return types.ImageInspect{}, errdefs.NotFound(fmt.Errorf("no such image: %s", image))
+					}
+					return types.ImageInspect{
+						RepoTags:    []string{imageStr},
+						VirtualSize: int64(len(archive)),
+					}, nil
+				},
+				pull: func(ref string) (io.ReadCloser, error) {
+					return ioutil.NopCloser(&stallingReader{ctx: ctx, reader: strings.NewReader(pullEvents), limit: len(pullEvents)}), nil
+				},
+				save: func(images []string) (io.ReadCloser, error) {
+					return ioutil.NopCloser(&stallingReader{ctx: ctx, reader: bytes.NewReader(archive), limit: 512}), nil
+				},
+			}
+
+			_, err := newFakeDaemonProvider(t, imageStr, fake).ProvideContext(ctx)
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, context.DeadlineExceeded), "unexpected error: %+v", err)
+
+			var deadlineErr *image.ErrFetchDeadlineExceeded
+			require.True(t, errors.As(err, &deadlineErr), "unexpected error: %+v", err)
+			assert.Equal(t, imageStr, deadlineErr.Image)
+
+			actual := deadlineErr.Progress()
+			assert.Greater(t, int64(actual.Duration), int64(0))
+			actual.Duration = 0
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+// recordingPublisher is a partybus.Publisher that keeps all published events.
+type recordingPublisher struct {
+	events []partybus.Event
+}
+
+func (r *recordingPublisher) Publish(e partybus.Event) {
+	r.events = append(r.events, e)
+}
+
+// recordingReporter is an event.ProgressReporter that records every stage and the last progress of each stage.
+type recordingReporter struct {
+	lock     sync.Mutex
+	stages   []string
+	progress map[event.StageCode][2]int64
+}
+
+func (r *recordingReporter) OnStage(_ string, _ event.StageCode, description string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.stages = append(r.stages, description)
+}
+
+func (r *recordingReporter) OnProgress(_ string, stage event.StageCode, current, total int64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.progress == nil {
+		r.progress = make(map[event.StageCode][2]int64)
+	}
+	r.progress[stage] = [2]int64{current, total}
+}
+
+func TestDaemonImageProvider_Provide_ProgressReporter(t *testing.T) {
+	const imageStr = "stereoscope-test:latest"
+	pullEvents := strings.Join([]string{
+		`{"status":"Pulling from stereoscope-test","id":"latest"}`,
+		`{"status":"Pulling fs layer","id":"aaaa"}`,
+		`{"status":"Downloading","id":"aaaa","progressDetail":{"current":50,"total":100}}`,
+		`{"status":"Download complete","id":"aaaa"}`,
+		`{"status":"Pull complete","id":"aaaa"}`,
+	}, "\n")
+
+	archive := dockerArchive(t)
+	var present bool
+	fake := &fakeAPIClient{
+		inspect: func(image string) (types.ImageInspect, error) {
+			if !present {
+				return types.ImageInspect{}, errdefs.NotFound(fmt.Errorf("no such image: %s", image))
+			}
+			return types.ImageInspect{
+				RepoTags:    []string{imageStr},
+				VirtualSize: int64(len(archive)),
+			}, nil
+		},
+		pull: func(ref string) (io.ReadCloser, error) {
+			present = true
+			return ioutil.NopCloser(strings.NewReader(pullEvents)), nil
+		},
+		save: func(images []string) (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(archive)), nil
+		},
+	}
+
+	reporter := &recordingReporter{}
+	bus.SetProgressReporter(reporter)
+	t.Cleanup(func() {
+		bus.SetProgressReporter(nil)
+	})
+
+	img, err := newFakeDaemonProvider(t, imageStr, fake).Provide()
+	require.NoError(t, err)
+	require.NoError(t, img.Read())
+
+	assert.Equal(t, []string{
+		"pulling image",
+		"requesting image from Docker",
+		"saving image to disk",
+		"reading layers",
+		"squashing layers",
+	}, reporter.stages)
+
+	assert.Equal(t, [2]int64{100, 100}, reporter.progress[event.PullingStage])
+	assert.Equal(t, [2]int64{int64(len(archive)), int64(len(archive))}, reporter.progress[event.SavingStage])
+	assert.Equal(t, [2]int64{4, 4}, reporter.progress[event.SquashingStage])
+}
+
+func TestDaemonImageProvider_Provide_Untagged(t *testing.T) {
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+
+	id, err := img.ConfigName()
+	require.NoError(t, err)
+	imageStr := id.Hex[:12]
+
+	// a dangling image is saved without any repo tags
+	ref, err := name.NewDigest("stereoscope-test@" + id.String())
+	require.NoError(t, err)
+	buf := &bytes.Buffer{}
+	require.NoError(t, tarball.MultiRefWrite(map[name.Reference]v1.Image{ref: img}, buf))
+	archive := buf.Bytes()
+
+	fake := &fakeAPIClient{
+		inspect: func(image string) (types.ImageInspect, error) {
+			return types.ImageInspect{
+				ID:          id.String(),
+				VirtualSize: int64(len(archive)),
+			}, nil
+		},
+		save: func(images []string) (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(archive)), nil
+		},
+	}
+
+	result, err := newFakeDaemonProvider(t, imageStr, fake).Provide()
+	require.NoError(t, err)
+	require.NoError(t, result.Read())
+
+	assert.True(t, result.Metadata.Dangling)
+	assert.Empty(t, result.Metadata.Tags)
+	assert.Empty(t, result.Metadata.RepoDigests)
+	assert.Equal(t, id.String(), result.Metadata.ID)
+	assert.Equal(t, id.String(), result.Metadata.Identity())
+}
+
+func TestDaemonImageProvider_trackSaveProgress(t *testing.T) {
+	tests := []struct {
+		name        string
+		virtualSize int64
+		wantSize    int64
+	}{
+		{name: "known size", virtualSize: 512 * 1024 * 1024, wantSize: 200},
+		{name: "zero size", virtualSize: 0, wantSize: 100},
+		{name: "size too small to estimate", virtualSize: 16, wantSize: 100},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := NewProviderFromDaemon("stereoscope-test:latest", nil)
+
+			_, copyProgress, _, aggregate := p.trackSaveProgress(types.ImageInspect{VirtualSize: test.virtualSize}, nil)
+
+			_, err := copyProgress.Write(make([]byte, 8))
+			require.NoError(t, err)
+
+			assert.Equal(t, test.wantSize, aggregate.Size())
+			assert.GreaterOrEqual(t, aggregate.Current(), int64(0))
+			assert.LessOrEqual(t, aggregate.Current(), aggregate.Size())
+
+			aggregate.SetCompleted()
+			assert.Equal(t, aggregate.Size(), aggregate.Current())
+		})
+	}
+}
+
+func TestDaemonImageProvider_Provide_ZeroVirtualSize(t *testing.T) {
+	const imageStr = "stereoscope-test:latest"
+
+	archive := dockerArchive(t)
+	fake := &fakeAPIClient{
+		inspect: func(image string) (types.ImageInspect, error) {
+			// e.g. the containerd image store does not report a size
+			return types.ImageInspect{RepoTags: []string{imageStr}}, nil
+		},
+		save: func(images []string) (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(archive)), nil
+		},
+	}
+
+	reporter := &recordingReporter{}
+	bus.SetProgressReporter(reporter)
+	t.Cleanup(func() {
+		bus.SetProgressReporter(nil)
+	})
+
+	img, err := newFakeDaemonProvider(t, imageStr, fake).Provide()
+	require.NoError(t, err)
+	require.NoError(t, img.Read())
+
+	// the total is unknown
+	assert.Equal(t, [2]int64{int64(len(archive)), 0}, reporter.progress[event.SavingStage])
+}
+
+func TestDaemonImageProvider_Exists(t *testing.T) {
+	tests := []struct {
+		name    string
+		inspect func(image string) (types.ImageInspect, error)
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "held by the daemon",
+			inspect: func(image string) (types.ImageInspect, error) {
+				return types.ImageInspect{ID: "sha256:" + strings.Repeat("a", 64)}, nil
+			},
+			want: true,
+		},
+		{
+			name: "not held by the daemon",
+			inspect: func(image string) (types.ImageInspect, error) {
+				return types.ImageInspect{}, errdefs.NotFound(fmt.Errorf("no such image: %s", image))
+			},
+		},
+		{
+			name: "daemon error",
+			inspect: func(image string) (types.ImageInspect, error) {
+				return types.ImageInspect{}, errors.New("cannot connect to the docker daemon")
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := &fakeAPIClient{inspect: test.inspect}
+
+			actual, err := newFakeDaemonProvider(t, "stereoscope-test:latest", fake).Exists(context.Background())
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, actual)
+			assert.Empty(t, fake.pulled)
+			assert.Empty(t, fake.saved)
+		})
+	}
+}
+
+func Test_matchesPlatform(t *testing.T) {
+	tests := []struct {
+		name     string
+		inspect  types.ImageInspect
+		platform v1.Platform
+		want     bool
+	}{
+		{
+			name:     "same variant",
+			inspect:  types.ImageInspect{Os: "linux", Architecture: "arm", Variant: "v7"},
+			platform: v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+			want:     true,
+		},
+		{
+			name:     "variant mismatch",
+			inspect:  types.ImageInspect{Os: "linux", Architecture: "arm", Variant: "v6"},
+			platform: v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+		},
+		{
+			name:     "default variant",
+			inspect:  types.ImageInspect{Os: "linux", Architecture: "arm64", Variant: "v8"},
+			platform: v1.Platform{OS: "linux", Architecture: "arm64"},
+			want:     true,
+		},
+		{
+			name:     "variant not reported by the daemon",
+			inspect:  types.ImageInspect{Os: "linux", Architecture: "arm"},
+			platform: v1.Platform{OS: "linux", Architecture: "arm", Variant: "v6"},
+			want:     true,
+		},
+		{
+			name:     "architecture mismatch",
+			inspect:  types.ImageInspect{Os: "linux", Architecture: "amd64"},
+			platform: v1.Platform{OS: "linux", Architecture: "arm64"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, matchesPlatform(test.inspect, test.platform))
+		})
+	}
+}
@@ -4,12 +4,13 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
 	"io/ioutil"
-	"os"
+	"path"
+	"strings"
 
 	"github.com/anchore/stereoscope/internal/log"
 	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	"github.com/google/go-containerregistry/pkg/v1/types"
@@ -43,9 +44,20 @@ func (m dockerManifest) allTags() (tags []string) {
 	return tags
 }
 
+// isOCILayout indicates if the manifest refers to content-addressable blobs (e.g. "blobs/sha256/<hex>"), which is
+// the case for archives saved by newer docker daemons (v25+) that write an OCI image layout alongside the manifest.
+func (m dockerManifest) isOCILayout() bool {
+	for _, entry := range m.parsed {
+		if !strings.HasPrefix(path.Clean(entry.Config), "blobs/") {
+			return false
+		}
+	}
+	return len(m.parsed) > 0
+}
+
 // extractManifest is helper function for extracting and parsing a docker image manifest (V2) from a docker image tar.
 func extractManifest(tarPath string) (*dockerManifest, error) {
-	f, err := os.Open(tarPath)
+	f, err := file.OpenArchive(tarPath)
 	if err != nil {
 		return nil, err
 	}
@@ -53,7 +65,7 @@ func extractManifest(tarPath string) (*dockerManifest, error) {
 	defer func() {
 		err := f.Close()
 		if err != nil {
-			log.Errorf("unable to close tar file (%s): %w", f.Name(), err)
+			log.Errorf("unable to close tar file (%s): %w", tarPath, err)
 		}
 	}()
 
@@ -70,49 +82,129 @@ func extractManifest(tarPath string) (*dockerManifest, error) {
 }
 
 // generateOCIManifest takes a docker manifest and a path to the tar and generates an OCI manifest derived from the given arguments and the docker config.
-func generateOCIManifest(tarPath string, manifest *dockerManifest) (*v1.Manifest, []byte, error) {
-	f, err := os.Open(tarPath)
-	if err != nil {
-		return nil, nil, err
+// If the docker config contents are already known they may be given, otherwise the config is read from the tar.
+func generateOCIManifest(tarPath string, manifest *dockerManifest, configContents []byte) (*v1.Manifest, []byte, error) {
+	if len(manifest.parsed) != 1 {
+		return nil, nil, ErrMultipleManifests
 	}
 
-	defer func() {
-		err := f.Close()
+	if configContents == nil {
+		var err error
+		configContents, err = readFromArchive(tarPath, manifest.parsed[0].Config)
 		if err != nil {
-			log.Errorf("unable to close tar file (%s): %w", f.Name(), err)
+			return nil, nil, fmt.Errorf("unable to find docker config: %w", err)
 		}
-	}()
+	}
 
-	if len(manifest.parsed) != 1 {
-		return nil, nil, ErrMultipleManifests
+	var layerSizes = make([]int64, len(manifest.parsed[0].Layers))
+	for idx, layerTarPath := range manifest.parsed[0].Layers {
+		layerMetadata, err := metadataFromArchive(tarPath, layerTarPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to find layer tar: %w", err)
+		}
+		layerSizes[idx] = layerMetadata.Size
 	}
 
-	configReader, err := file.ReaderFromTar(f, manifest.parsed[0].Config)
+	theManifest, err := assembleOCIManifest(configContents, layerSizes)
+
+	return theManifest, configContents, err
+}
+
+// extractOCILayoutManifest finds the original OCI manifest for the image with the given config digest within a docker
+// archive that contains an OCI image layout (index.json + blobs). Nested indexes are searched and blobs that are not
+// present in the archive (e.g. for other platforms) are skipped. The raw manifest is returned as-is so that the
+// manifest digest is preserved.
+func extractOCILayoutManifest(tarPath string, configDigest v1.Hash) (*v1.Manifest, []byte, error) {
+	rawIndex, err := readFromArchive(tarPath, "index.json")
 	if err != nil {
-		return nil, nil, fmt.Errorf("unable to find docker config: %w", err)
+		return nil, nil, fmt.Errorf("unable to find OCI index: %w", err)
 	}
 
-	configContents, err := ioutil.ReadAll(configReader)
+	manifest, rawManifest, err := findOCILayoutManifest(tarPath, rawIndex, configDigest)
 	if err != nil {
-		return nil, nil, fmt.Errorf("unable to read docker config: %w", err)
+		return nil, nil, err
 	}
+	if manifest == nil {
+		return nil, nil, fmt.Errorf("no OCI manifest found for config=%q", configDigest)
+	}
+	return manifest, rawManifest, nil
+}
 
-	var layerSizes = make([]int64, len(manifest.parsed[0].Layers))
-	for idx, layerTarPath := range manifest.parsed[0].Layers {
-		_, err = f.Seek(0, io.SeekStart)
+func findOCILayoutManifest(tarPath string, rawIndex []byte, configDigest v1.Hash) (*v1.Manifest, []byte, error) {
+	index, err := v1.ParseIndexManifest(bytes.NewReader(rawIndex))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to parse OCI index: %w", err)
+	}
+
+	for _, descriptor := range index.Manifests {
+		kind := image.SourceFromMediaType(string(descriptor.MediaType))
+		if kind != image.IndexMediaTypeKind && !kind.IsManifest() {
+			continue
+		}
+
+		blobPath := path.Join("blobs", descriptor.Digest.Algorithm, descriptor.Digest.Hex)
+		contents, err := readFromArchive(tarPath, blobPath)
 		if err != nil {
-			return nil, nil, fmt.Errorf("unable to reset tar reader: %w", err)
+			log.Debugf("skipping OCI descriptor=%q: %+v", descriptor.Digest, err)
+			continue
+		}
+
+		if kind == image.IndexMediaTypeKind {
+			manifest, rawManifest, err := findOCILayoutManifest(tarPath, contents, configDigest)
+			if err != nil || manifest != nil {
+				return manifest, rawManifest, err
+			}
+			continue
 		}
-		layerMetadata, err := file.MetadataFromTar(f, layerTarPath)
+
+		manifest, err := v1.ParseManifest(bytes.NewReader(contents))
 		if err != nil {
-			return nil, nil, fmt.Errorf("unable to find layer tar: %w", err)
+			return nil, nil, fmt.Errorf("unable to parse OCI manifest=%q: %w", descriptor.Digest, err)
+		}
+		if manifest.Config.Digest == configDigest {
+			return manifest, contents, nil
 		}
-		layerSizes[idx] = layerMetadata.Size
 	}
+	return nil, nil, nil
+}
 
-	theManifest, err := assembleOCIManifest(configContents, layerSizes)
+// readFromArchive reads the full contents of a single entry from the (possibly compressed) archive at the given path.
+func readFromArchive(archivePath, entryPath string) ([]byte, error) {
+	f, err := file.OpenArchive(archivePath)
+	if err != nil {
+		return nil, err
+	}
 
-	return theManifest, configContents, err
+	defer func() {
+		err := f.Close()
+		if err != nil {
+			log.Errorf("unable to close tar file (%s): %w", archivePath, err)
+		}
+	}()
+
+	reader, err := file.ReaderFromTar(f, entryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(reader)
+}
+
+// metadataFromArchive reads the tar header metadata for a single entry from the (possibly compressed) archive at the given path.
+func metadataFromArchive(archivePath, entryPath string) (file.Metadata, error) {
+	f, err := file.OpenArchive(archivePath)
+	if err != nil {
+		return file.Metadata{}, err
+	}
+
+	defer func() {
+		err := f.Close()
+		if err != nil {
+			log.Errorf("unable to close tar file (%s): %w", archivePath, err)
+		}
+	}()
+
+	return file.MetadataFromTar(f, entryPath)
 }
 
 // assembleOCIManifest takes the docker manifest and config file content to populate a v1.Manifest (OCI).
@@ -0,0 +1,178 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/anchore/stereoscope/internal/docker"
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/versions"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// minPlatformAPIVersion is the first docker API version that accepts a platform when inspecting and saving images
+// (for multi-platform images stored locally, e.g. with the containerd image store).
+const minPlatformAPIVersion = "1.48"
+
+// platformAPIClient is implemented by docker API clients that can select the platform of a multi-platform image when
+// inspecting and saving it.
+type platformAPIClient interface {
+	// supportsPlatform indicates if the daemon accepts a platform when inspecting and saving images.
+	supportsPlatform(ctx context.Context) bool
+	imageInspectWithPlatform(ctx context.Context, image string, platform v1.Platform) (types.ImageInspect, error)
+	imageSaveWithPlatform(ctx context.Context, images []string, platform v1.Platform) (io.ReadCloser, error)
+}
+
+// platformClient adds platform selection to the image inspect and save calls of the docker client, which the docker
+// client library does not support (the requests are made directly against the daemon API).
+type platformClient struct {
+	*client.Client
+}
+
+func (c platformClient) supportsPlatform(ctx context.Context) bool {
+	// note: the platform requests are made with the minimum API version that supports them (the version negotiated by
+	// the docker client library is capped at an older version), as long as a lower version has not been configured
+	if limit := docker.APIVersionLimit(); limit != "" && versions.LessThan(limit, minPlatformAPIVersion) {
+		return false
+	}
+
+	ping, err := c.Ping(ctx)
+	if err != nil {
+		log.Debugf("unable to ping docker daemon to check for platform support: %+v", err)
+		return false
+	}
+	return ping.APIVersion != "" && !versions.LessThan(ping.APIVersion, minPlatformAPIVersion)
+}
+
+func (c platformClient) imageInspectWithPlatform(ctx context.Context, image string, platform v1.Platform) (types.ImageInspect, error) {
+	var inspect types.ImageInspect
+
+	query, err := platformQuery(platform)
+	if err != nil {
+		return inspect, err
+	}
+
+	resp, err := c.get(ctx, "/images/"+url.PathEscape(image)+"/json", query)
+	if err != nil {
+		return inspect, err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(&inspect); err != nil {
+		return inspect, fmt.Errorf("unable to decode image inspect response: %w", err)
+	}
+	return inspect, nil
+}
+
+func (c platformClient) imageSaveWithPlatform(ctx context.Context, images []string, platform v1.Platform) (io.ReadCloser, error) {
+	query, err := platformQuery(platform)
+	if err != nil {
+		return nil, err
+	}
+	query["names"] = images
+
+	resp, err := c.get(ctx, "/images/get", query)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// get makes a GET request to the given path of the (versioned) daemon API, returning an error for any unsuccessful
+// response.
+func (c platformClient) get(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	hostURL, err := client.ParseHostURL(c.DaemonHost())
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := c.HTTPClient()
+
+	scheme, host := "http", hostURL.Host
+	switch hostURL.Scheme {
+	case "unix", "npipe":
+		// note: the transport dials the socket regardless of the host in the URL
+		host = "docker"
+	default:
+		if transport, ok := httpClient.Transport.(*http.Transport); ok && transport.TLSClientConfig != nil {
+			scheme = "https"
+		}
+	}
+
+	requestURL := url.URL{
+		Scheme:   scheme,
+		Host:     host,
+		Path:     hostURL.Path + "/v" + minPlatformAPIVersion + path,
+		RawQuery: query.Encode(),
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Host = host
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("docker daemon request failed: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+
+		var apiErr types.ErrorResponse
+		message := strings.TrimSpace(string(body))
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Message != "" {
+			message = apiErr.Message
+		}
+
+		err := fmt.Errorf("docker daemon request failed (status=%d): %s", resp.StatusCode, message)
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, errdefs.NotFound(err)
+		}
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// platformQuery encodes the given platform as the daemon API expects (a JSON encoded OCI platform).
+func platformQuery(platform v1.Platform) (url.Values, error) {
+	encoded, err := json.Marshal(platform)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode platform: %w", err)
+	}
+	return url.Values{"platform": []string{string(encoded)}}, nil
+}
+
+// parsePlatform parses a platform of the form "os/arch[/variant]" (e.g. "linux/arm64/v8").
+func parsePlatform(platform string) (v1.Platform, error) {
+	parts := strings.Split(platform, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return v1.Platform{}, fmt.Errorf("invalid platform=%q (expected os/arch[/variant])", platform)
+	}
+	for _, part := range parts {
+		if part == "" {
+			return v1.Platform{}, fmt.Errorf("invalid platform=%q (expected os/arch[/variant])", platform)
+		}
+	}
+
+	p := v1.Platform{
+		OS:           strings.ToLower(parts[0]),
+		Architecture: strings.ToLower(parts[1]),
+	}
+	if len(parts) == 3 {
+		p.Variant = strings.ToLower(parts[2])
+	}
+	return p, nil
+}
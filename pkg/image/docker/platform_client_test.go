@@ -0,0 +1,248 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parsePlatform(t *testing.T) {
+	tests := []struct {
+		platform string
+		expected v1.Platform
+		wantErr  bool
+	}{
+		{
+			platform: "linux/amd64",
+			expected: v1.Platform{OS: "linux", Architecture: "amd64"},
+		},
+		{
+			platform: "linux/arm/v7",
+			expected: v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+		},
+		{
+			platform: "Linux/ARM64",
+			expected: v1.Platform{OS: "linux", Architecture: "arm64"},
+		},
+		{
+			platform: "linux",
+			wantErr:  true,
+		},
+		{
+			platform: "linux//v7",
+			wantErr:  true,
+		},
+		{
+			platform: "linux/arm/v7/extra",
+			wantErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.platform, func(t *testing.T) {
+			actual, err := parsePlatform(test.platform)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+// newTestPlatformClient returns a platform client for a fake daemon which reports the given API version.
+func newTestPlatformClient(t *testing.T, apiVersion string, handler http.HandlerFunc) platformClient {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("API-Version", apiVersion)
+		if strings.HasSuffix(r.URL.Path, "/_ping") {
+			_, _ = w.Write([]byte("OK"))
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	dockerClient, err := client.NewClientWithOpts(client.WithHost("tcp://"+strings.TrimPrefix(server.URL, "http://")), client.WithAPIVersionNegotiation())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, dockerClient.Close())
+	})
+
+	return platformClient{Client: dockerClient}
+}
+
+func TestPlatformClient(t *testing.T) {
+	platform := v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}
+
+	var requests []*http.Request
+	c := newTestPlatformClient(t, minPlatformAPIVersion, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		switch r.URL.Path {
+		case "/v1.48/images/stereoscope-test:latest/json":
+			_ = json.NewEncoder(w).Encode(types.ImageInspect{ID: "sha256:abc", Os: "linux", Architecture: "arm64"})
+		case "/v1.48/images/get":
+			_, _ = w.Write([]byte("archive"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"no such image"}`))
+		}
+	})
+
+	require.True(t, c.supportsPlatform(context.Background()))
+
+	inspect, err := c.imageInspectWithPlatform(context.Background(), "stereoscope-test:latest", platform)
+	require.NoError(t, err)
+	assert.Equal(t, "sha256:abc", inspect.ID)
+
+	reader, err := c.imageSaveWithPlatform(context.Background(), []string{"stereoscope-test:latest"}, platform)
+	require.NoError(t, err)
+	contents, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	assert.Equal(t, "archive", string(contents))
+
+	require.Len(t, requests, 2)
+	for _, r := range requests {
+		assert.JSONEq(t, `{"os":"linux","architecture":"arm64","variant":"v8"}`, r.URL.Query().Get("platform"))
+	}
+	assert.Equal(t, []string{"stereoscope-test:latest"}, requests[1].URL.Query()["names"])
+
+	_, err = c.imageInspectWithPlatform(context.Background(), "missing:latest", platform)
+	require.Error(t, err)
+	assert.True(t, errdefs.IsNotFound(err), "unexpected error: %+v", err)
+	assert.Contains(t, err.Error(), "no such image")
+}
+
+func TestPlatformClient_OlderDaemon(t *testing.T) {
+	c := newTestPlatformClient(t, "1.41", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request: %s", r.URL)
+	})
+
+	assert.False(t, c.supportsPlatform(context.Background()))
+}
+
+// fakePlatformAPIClient is a fakeAPIClient for a daemon that may support selecting a platform.
+type fakePlatformAPIClient struct {
+	*fakeAPIClient
+	supported bool
+	inspected []v1.Platform
+	saved     []v1.Platform
+}
+
+func (f *fakePlatformAPIClient) supportsPlatform(context.Context) bool {
+	return f.supported
+}
+
+func (f *fakePlatformAPIClient) imageInspectWithPlatform(_ context.Context, image string, platform v1.Platform) (types.ImageInspect, error) {
+	f.inspected = append(f.inspected, platform)
+	return f.inspect(image)
+}
+
+func (f *fakePlatformAPIClient) imageSaveWithPlatform(_ context.Context, images []string, platform v1.Platform) (io.ReadCloser, error) {
+	f.saved = append(f.saved, platform)
+	return f.save(images)
+}
+
+func TestDaemonImageProvider_Provide_Platform(t *testing.T) {
+	archive := dockerArchive(t)
+	imageStr := "stereoscope-test:latest"
+	platform := v1.Platform{OS: "linux", Architecture: "arm64"}
+
+	tests := []struct {
+		name             string
+		supported        bool
+		expectedPlatform []v1.Platform
+	}{
+		{
+			name:             "daemon selects the platform",
+			supported:        true,
+			expectedPlatform: []v1.Platform{platform},
+		},
+		{
+			name:      "older daemons use the image they have",
+			supported: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var pulled bool
+			fake := &fakePlatformAPIClient{
+				supported: test.supported,
+				fakeAPIClient: &fakeAPIClient{
+					inspect: func(image string) (types.ImageInspect, error) {
+						if !pulled {
+							return types.ImageInspect{}, errdefs.NotFound(fmt.Errorf("no such image: %s", image))
+						}
+						return types.ImageInspect{
+							RepoTags:     []string{imageStr},
+							Os:           "linux",
+							Architecture: "arm64",
+							VirtualSize:  int64(len(archive)),
+						}, nil
+					},
+					pull: func(ref string) (io.ReadCloser, error) {
+						pulled = true
+						return ioutil.NopCloser(strings.NewReader("")), nil
+					},
+					save: func(images []string) (io.ReadCloser, error) {
+						return ioutil.NopCloser(strings.NewReader(string(archive))), nil
+					},
+				},
+			}
+
+			tmpDirGen := file.NewTempDirGenerator()
+			t.Cleanup(func() {
+				assert.NoError(t, tmpDirGen.Cleanup())
+			})
+
+			p := NewProviderFromDaemonWithPlatform(imageStr, &tmpDirGen, "linux/arm64")
+			p.getClient = func() (apiClient, error) {
+				return fake, nil
+			}
+
+			img, err := p.Provide()
+			require.NoError(t, err)
+			require.NoError(t, img.Read())
+
+			require.Len(t, fake.pullOptions, 1)
+			assert.Equal(t, "linux/arm64", fake.pullOptions[0].Platform)
+
+			if test.expectedPlatform == nil {
+				assert.Empty(t, fake.inspected)
+				assert.Empty(t, fake.saved)
+				assert.Len(t, fake.fakeAPIClient.saved, 1)
+				return
+			}
+			assert.Equal(t, []v1.Platform{platform, platform}, fake.inspected)
+			assert.Equal(t, test.expectedPlatform, fake.saved)
+		})
+	}
+}
+
+func TestDaemonImageProvider_Provide_InvalidPlatform(t *testing.T) {
+	tmpDirGen := file.NewTempDirGenerator()
+	t.Cleanup(func() {
+		assert.NoError(t, tmpDirGen.Cleanup())
+	})
+
+	_, err := NewProviderFromDaemonWithPlatform("stereoscope-test:latest", &tmpDirGen, "arm64").Provide()
+	assert.Error(t, err)
+}
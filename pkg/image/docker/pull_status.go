@@ -1,8 +1,13 @@
 package docker
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"sync"
 
+	"github.com/anchore/stereoscope/pkg/event"
+
 	"github.com/wagoodman/go-progress"
 )
 
@@ -56,6 +61,7 @@ type PullStatus struct {
 	layers           []LayerID
 	lock             sync.Mutex
 	complete         bool
+	err              error
 }
 
 func newPullStatus() *PullStatus {
@@ -66,10 +72,45 @@ func newPullStatus() *PullStatus {
 	}
 }
 
+// Complete indicates if the pull has ended (successfully or not).
 func (p *PullStatus) Complete() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
 	return p.complete
 }
 
+// Cancelled indicates if the pull ended due to cancellation (e.g. the context given to the fetch was cancelled).
+func (p *PullStatus) Cancelled() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return errors.Is(p.err, event.ErrCancelled)
+}
+
+// Err returns the reason the pull ended without success, or nil if the pull is in progress or was successful.
+func (p *PullStatus) Err() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.err
+}
+
+// terminate marks the pull as ended, recording a cancellation (when the given context is done) or failure (when an
+// error is given).
+func (p *PullStatus) terminate(ctx context.Context, err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.complete = true
+	switch {
+	case ctx.Err() != nil:
+		p.err = fmt.Errorf("%w: %v", event.ErrCancelled, ctx.Err())
+	case err != nil:
+		p.err = err
+	}
+}
+
 func (p *PullStatus) Layers() []LayerID {
 	p.lock.Lock()
 	defer p.lock.Unlock()
@@ -88,6 +129,49 @@ func (p *PullStatus) Current(layer LayerID) LayerState {
 	}
 }
 
+// downloaded returns the number of bytes downloaded so far along with the number of bytes to download (for the layers
+// known so far, which excludes layers that already existed on the host).
+func (p *PullStatus) downloaded() (current, total int64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, dl := range p.downloadProgress {
+		current += dl.N
+		total += dl.Total
+	}
+	return current, total
+}
+
+// downloadedBytes is the total size of all layers that needed to be downloaded (layers that already existed on the
+// host are not counted).
+func (p *PullStatus) downloadedBytes() int64 {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var total int64
+	for _, dl := range p.downloadProgress {
+		total += dl.Total
+	}
+	return total
+}
+
+// transferred returns the number of bytes downloaded so far along with the number of layers that have been pulled
+// (or already existed on the host) out of all known layers.
+func (p *PullStatus) transferred() (bytes int64, layersCompleted int, layersTotal int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, dl := range p.downloadProgress {
+		bytes += dl.N
+	}
+	for _, layer := range p.layers {
+		if p.phase[layer] >= AlreadyExistsPhase {
+			layersCompleted++
+		}
+	}
+	return bytes, layersCompleted, len(p.layers)
+}
+
 func (p *PullStatus) onEvent(event *pullEvent) {
 	p.lock.Lock()
 	defer p.lock.Unlock()
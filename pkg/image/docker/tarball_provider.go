@@ -1,8 +1,10 @@
 package docker
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 
 	"github.com/anchore/stereoscope/internal"
 	"github.com/anchore/stereoscope/internal/log"
@@ -20,21 +22,60 @@ type TarballImageProvider struct {
 	extraTags   []string
 	repoDigests []string
 	tmpDirGen   *file.TempDirGenerator
+	// manifest and rawConfig are optionally provided by the caller, in which case they are not derived from the archive
+	manifest  *v1.Manifest
+	rawConfig []byte
+	// additionalMetadata is applied after all metadata derived from the archive (e.g. details only known to the daemon)
+	additionalMetadata []image.AdditionalMetadata
+}
+
+// TarballOption is a functional option for the TarballImageProvider.
+type TarballOption func(*TarballImageProvider)
+
+// WithPreparsedManifest provides the image manifest for the image in the archive (e.g. from a prior registry fetch or
+// inspection), which skips generating a manifest from the archive contents (which requires inspecting every layer).
+// The manifest must refer to the same config as the archive, otherwise Provide will fail.
+func WithPreparsedManifest(manifest *v1.Manifest) TarballOption {
+	return func(p *TarballImageProvider) {
+		p.manifest = manifest
+	}
+}
+
+// WithPreparsedConfig provides the raw image config for the image in the archive, which skips reading the config from
+// the archive when generating a manifest. The config digest must match the archive config, otherwise Provide will fail.
+func WithPreparsedConfig(rawConfig []byte) TarballOption {
+	return func(p *TarballImageProvider) {
+		p.rawConfig = rawConfig
+	}
 }
 
 // NewProviderFromTarball creates a new provider instance for the specific image already at the given path.
-func NewProviderFromTarball(path string, tmpDirGen *file.TempDirGenerator, tags []string, repoDigests []string) *TarballImageProvider {
-	return &TarballImageProvider{
+func NewProviderFromTarball(path string, tmpDirGen *file.TempDirGenerator, tags []string, repoDigests []string, options ...TarballOption) *TarballImageProvider {
+	provider := &TarballImageProvider{
 		path:        path,
 		extraTags:   tags,
 		repoDigests: repoDigests,
 		tmpDirGen:   tmpDirGen,
 	}
+	for _, option := range options {
+		option(provider)
+	}
+	return provider
+}
+
+// opener provides a reader to the uncompressed docker image tar.
+func (p *TarballImageProvider) opener() (io.ReadCloser, error) {
+	return file.OpenArchive(p.path)
 }
 
 // Provide an image object that represents the docker image tar at the configured location on disk.
 func (p *TarballImageProvider) Provide() (*image.Image, error) {
-	img, err := tarball.ImageFromPath(p.path, nil)
+	theManifest, err := extractManifest(p.path)
+	if err != nil {
+		log.Warnf("could not extract manifest: %+v", err)
+	}
+
+	img, err := p.image(theManifest)
 	if err != nil {
 		// raise a more controlled error for when there are multiple images within the given tar (from https://github.com/anchore/grype/issues/215)
 		if err.Error() == "tarball must contain only a single image to be used with tarball.Image" {
@@ -43,17 +84,16 @@ func (p *TarballImageProvider) Provide() (*image.Image, error) {
 		return nil, fmt.Errorf("unable to provide image from tarball: %w", err)
 	}
 
+	if err = p.validatePreparsed(img); err != nil {
+		return nil, err
+	}
+
 	// make a best-effort to generate an OCI manifest and gets tags, but ultimately this should be considered optional
 	var rawOCIManifest []byte
-	var rawConfig []byte
-	var ociManifest *v1.Manifest
+	var rawConfig = p.rawConfig
+	var ociManifest = p.manifest
 	var metadata []image.AdditionalMetadata
 
-	theManifest, err := extractManifest(p.path)
-	if err != nil {
-		log.Warnf("could not extract manifest: %+v", err)
-	}
-
 	var tags = internal.NewStringSet()
 	for _, t := range p.extraTags {
 		tags.Add(t)
@@ -66,18 +106,33 @@ func (p *TarballImageProvider) Provide() (*image.Image, error) {
 			tags.Add(t)
 		}
 
-		ociManifest, rawConfig, err = generateOCIManifest(p.path, theManifest)
-		if err != nil {
-			log.Warnf("failed to generate OCI manifest from docker archive: %+v", err)
+		if ociManifest == nil && theManifest.isOCILayout() {
+			// newer docker daemons save an OCI image layout alongside the manifest.json, prefer the original manifest
+			ociManifest, rawOCIManifest = p.ociLayoutManifest(img)
+			if rawConfig == nil && ociManifest != nil {
+				rawConfig, err = img.RawConfigFile()
+				if err != nil {
+					log.Warnf("unable to read config from OCI layout docker archive: %+v", err)
+				}
+			}
 		}
 
-		// we may have the config available, use it
-		if rawConfig != nil {
-			metadata = append(metadata, image.WithConfig(rawConfig))
+		if ociManifest == nil {
+			ociManifest, rawConfig, err = generateOCIManifest(p.path, theManifest, p.rawConfig)
+			if err != nil {
+				log.Warnf("failed to generate OCI manifest from docker archive: %+v", err)
+			}
 		}
 	}
 
-	if ociManifest != nil {
+	// we may have the config available, use it
+	if rawConfig != nil {
+		metadata = append(metadata, image.WithConfig(rawConfig))
+	}
+
+	if rawOCIManifest != nil {
+		metadata = append(metadata, image.WithManifest(rawOCIManifest))
+	} else if ociManifest != nil {
 		rawOCIManifest, err = json.Marshal(&ociManifest)
 		if err != nil {
 			log.Warnf("failed to serialize OCI manifest: %+v", err)
@@ -91,6 +146,7 @@ func (p *TarballImageProvider) Provide() (*image.Image, error) {
 	}
 
 	metadata = append(metadata, image.WithRepoDigests(p.repoDigests))
+	metadata = append(metadata, p.additionalMetadata...)
 
 	contentTempDir, err := p.tmpDirGen.NewTempDir()
 	if err != nil {
@@ -99,3 +155,77 @@ func (p *TarballImageProvider) Provide() (*image.Image, error) {
 
 	return image.NewImage(img, contentTempDir, metadata...), nil
 }
+
+// image returns the image within the archive. Archives with gzip compressed layer files (e.g. "<id>/layer.tar.gz") are
+// read layer by layer, decompressing each layer file as needed, since the tarball lib assumes that every layer is
+// compressed (or not) the same as the first layer.
+func (p *TarballImageProvider) image(theManifest *dockerManifest) (v1.Image, error) {
+	if theManifest != nil && len(theManifest.parsed) == 1 {
+		compressed, err := hasCompressedLayers(p.path, theManifest.parsed[0])
+		switch {
+		case err != nil:
+			log.Debugf("unable to determine if docker archive layers are compressed: %+v", err)
+		case compressed:
+			log.Debugf("docker archive has gzip compressed layers: %q", p.path)
+			return newArchiveImage(p.path, theManifest.parsed[0])
+		}
+	}
+
+	// note: the archive may be gzip compressed, which the tarball lib does not account for
+	return tarball.Image(p.opener, nil)
+}
+
+// ociLayoutManifest makes a best-effort attempt to find the original manifest for the given image within the OCI image
+// layout of the archive, returning nothing if the manifest cannot be found.
+func (p *TarballImageProvider) ociLayoutManifest(img v1.Image) (*v1.Manifest, []byte) {
+	configDigest, err := img.ConfigName()
+	if err != nil {
+		log.Warnf("unable to determine config digest from tarball: %+v", err)
+		return nil, nil
+	}
+
+	manifest, rawManifest, err := extractOCILayoutManifest(p.path, configDigest)
+	if err != nil {
+		log.Debugf("unable to use OCI layout manifest from docker archive (generating one instead): %+v", err)
+		return nil, nil
+	}
+	return manifest, rawManifest
+}
+
+// validatePreparsed ensures that any caller-provided manifest and config describe the image within the archive.
+func (p *TarballImageProvider) validatePreparsed(img v1.Image) error {
+	if p.manifest == nil && p.rawConfig == nil {
+		return nil
+	}
+
+	configDigest, err := img.ConfigName()
+	if err != nil {
+		return fmt.Errorf("unable to determine config digest from tarball: %w", err)
+	}
+
+	if p.rawConfig != nil {
+		givenDigest, _, err := v1.SHA256(bytes.NewReader(p.rawConfig))
+		if err != nil {
+			return fmt.Errorf("unable to digest the given config: %w", err)
+		}
+		if givenDigest != configDigest {
+			return fmt.Errorf("given config digest=%q does not match the tarball config digest=%q", givenDigest, configDigest)
+		}
+	}
+
+	if p.manifest != nil {
+		if p.manifest.Config.Digest != configDigest {
+			return fmt.Errorf("given manifest config digest=%q does not match the tarball config digest=%q", p.manifest.Config.Digest, configDigest)
+		}
+
+		config, err := img.ConfigFile()
+		if err != nil {
+			return fmt.Errorf("unable to read config from tarball: %w", err)
+		}
+		if len(p.manifest.Layers) != len(config.RootFS.DiffIDs) {
+			return fmt.Errorf("given manifest has %d layers but the tarball has %d layers", len(p.manifest.Layers), len(config.RootFS.DiffIDs))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package docker
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	v1Types "github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTarballImageProvider_Provide_Preparsed(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "image.tar")
+	require.NoError(t, ioutil.WriteFile(archivePath, dockerArchive(t), 0644))
+
+	theManifest, err := extractManifest(archivePath)
+	require.NoError(t, err)
+	ociManifest, rawConfig, err := generateOCIManifest(archivePath, theManifest, nil)
+	require.NoError(t, err)
+
+	wrongConfigManifest := *ociManifest
+	wrongConfigManifest.Config.Digest = v1.Hash{Algorithm: "sha256", Hex: "8f1c7d2b9a4c1e9e2c3a2f1b0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f2e1d"}
+
+	wrongLayersManifest := *ociManifest
+	wrongLayersManifest.Layers = ociManifest.Layers[:1]
+
+	tests := []struct {
+		name    string
+		options []TarballOption
+		wantErr bool
+	}{
+		{
+			name: "nothing preparsed",
+		},
+		{
+			name:    "preparsed config",
+			options: []TarballOption{WithPreparsedConfig(rawConfig)},
+		},
+		{
+			name:    "preparsed manifest",
+			options: []TarballOption{WithPreparsedManifest(ociManifest)},
+		},
+		{
+			name:    "preparsed manifest and config",
+			options: []TarballOption{WithPreparsedManifest(ociManifest), WithPreparsedConfig(rawConfig)},
+		},
+		{
+			name:    "mismatched config",
+			options: []TarballOption{WithPreparsedConfig([]byte(`{"architecture":"amd64"}`))},
+			wantErr: true,
+		},
+		{
+			name:    "manifest for another config",
+			options: []TarballOption{WithPreparsedManifest(&wrongConfigManifest)},
+			wantErr: true,
+		},
+		{
+			name:    "manifest with different layers",
+			options: []TarballOption{WithPreparsedManifest(&wrongLayersManifest)},
+			wantErr: true,
+		},
+	}
+
+	expectedManifest, err := json.Marshal(ociManifest)
+	require.NoError(t, err)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tmpDirGen := file.NewTempDirGenerator()
+			t.Cleanup(func() {
+				assert.NoError(t, tmpDirGen.Cleanup())
+			})
+
+			img, err := NewProviderFromTarball(archivePath, &tmpDirGen, nil, nil, test.options...).Provide()
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, img.Read())
+
+			assert.Equal(t, rawConfig, img.Metadata.RawConfig)
+			assert.JSONEq(t, string(expectedManifest), string(img.Metadata.RawManifest))
+			require.Len(t, img.Metadata.Tags, 1)
+			assert.Equal(t, "stereoscope-test:latest", img.Metadata.Tags[0].String())
+		})
+	}
+}
+
+func TestTarballImageProvider_Provide_DockerSaveFormats(t *testing.T) {
+	tests := []struct {
+		name              string
+		fixture           string
+		expectedMediaType v1Types.MediaType
+		// expectedManifestDigest is only known for archives that carry the original manifest
+		expectedManifestDigest string
+		expectedImageID        string
+	}{
+		{
+			name:              "classic docker save",
+			fixture:           "test-fixtures/docker-save-classic.tar",
+			expectedMediaType: image.MediaTypeDockerManifest,
+			// as listed by "docker images --no-trunc" (and the config file name within the archive)
+			expectedImageID: "sha256:765bbc0ca2afeb95564d95777fb69ca83c29966e798edb820c445f5caf7aa3ba",
+		},
+		{
+			name:                   "docker save with OCI layout",
+			fixture:                "test-fixtures/docker-save-oci-layout.tar",
+			expectedMediaType:      image.MediaTypeOCIManifest,
+			expectedManifestDigest: "sha256:d7dc112678dfb4ac3366944c57e1336e035b0a37224a26788b52056fe04faac0",
+			expectedImageID:        "sha256:765bbc0ca2afeb95564d95777fb69ca83c29966e798edb820c445f5caf7aa3ba",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source, err := image.DetectSourceFromPath(test.fixture)
+			require.NoError(t, err)
+			require.Equal(t, image.DockerTarballSource, source)
+
+			tmpDirGen := file.NewTempDirGenerator()
+			t.Cleanup(func() {
+				assert.NoError(t, tmpDirGen.Cleanup())
+			})
+
+			img, err := NewProviderFromTarball(test.fixture, &tmpDirGen, nil, nil).Provide()
+			require.NoError(t, err)
+			require.NoError(t, img.Read())
+
+			manifest, err := v1.ParseManifest(bytes.NewReader(img.Metadata.RawManifest))
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedMediaType, manifest.MediaType)
+			assert.NotEmpty(t, img.Metadata.RawConfig)
+			if test.expectedManifestDigest != "" {
+				assert.Equal(t, test.expectedManifestDigest, img.Metadata.ManifestDigest)
+			}
+			assert.Equal(t, test.expectedImageID, img.Metadata.ConfigDigest)
+			assert.Equal(t, test.expectedImageID, img.Metadata.ID)
+
+			normalized, err := img.Manifest()
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedMediaType, normalized.MediaType)
+			assert.Equal(t, img.Metadata.ID, normalized.Config.Digest.String())
+			assert.Len(t, normalized.Layers, len(img.Layers))
+
+			require.Len(t, img.Metadata.Tags, 1)
+			assert.Equal(t, "stereoscope-fixture-docker-save:latest", img.Metadata.Tags[0].String())
+
+			contents, err := img.FileContentsFromSquash("/etc/hello.txt")
+			require.NoError(t, err)
+			actual, err := ioutil.ReadAll(contents)
+			require.NoError(t, err)
+			assert.Equal(t, "hello from docker save\n", string(actual))
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package image
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	passwdPath = "/etc/passwd"
+	groupPath  = "/etc/group"
+)
+
+// ErrUserNotFound is returned from Image.EffectiveUser when a named user (or group) within the config User does not
+// exist within the passwd (or group) file of the image.
+type ErrUserNotFound struct {
+	// Name is the user or group name that was not found
+	Name string
+	// Path is the file that was searched (/etc/passwd or /etc/group)
+	Path string
+}
+
+func (e *ErrUserNotFound) Error() string {
+	return fmt.Sprintf("unable to find %q within %s", e.Name, e.Path)
+}
+
+// passwdEntry is a single user from an /etc/passwd file.
+type passwdEntry struct {
+	name string
+	uid  int
+	gid  int
+}
+
+// groupEntry is a single group from an /etc/group file.
+type groupEntry struct {
+	name string
+	gid  int
+}
+
+// EffectiveWorkingDir returns the working directory the container would run in: the config WorkingDir, or "/" when
+// none is set (the image must be read first).
+func (i *Image) EffectiveWorkingDir() string {
+	workingDir := i.Metadata.Config.Config.WorkingDir
+	if workingDir == "" {
+		return "/"
+	}
+	return path.Clean("/" + workingDir)
+}
+
+// EffectiveUser returns the uid, gid, and user name the container would run as, resolving the config User against the
+// /etc/passwd and /etc/group files within the image squash (the image must be read first). The User may be a name or
+// uid, optionally followed by a group name or gid (e.g. "nginx", "1000", "nginx:www-data", "1000:1000"), where an empty
+// User is root. As with container runtimes, a uid or gid does not need to exist within the passwd or group file, and the
+// gid is the primary group of the user (or 0 if the user is not within the passwd file) unless a group is given. A
+// *ErrUserNotFound is returned when a named user or group does not exist (including when there are no such files). The
+// name is the user name from the passwd file, which is empty for a uid that is not within the passwd file.
+func (i *Image) EffectiveUser() (uid, gid int, name string, err error) {
+	userSpec, groupSpec := i.Metadata.Config.Config.User, ""
+	if idx := strings.Index(userSpec, ":"); idx >= 0 {
+		userSpec, groupSpec = userSpec[:idx], userSpec[idx+1:]
+	}
+	if userSpec == "" {
+		userSpec = "0"
+	}
+
+	users, err := i.passwdEntries()
+	if err != nil {
+		return 0, 0, "", err
+	}
+
+	if id, isID, err := parseID(userSpec); err != nil {
+		return 0, 0, "", fmt.Errorf("invalid user=%q: %w", userSpec, err)
+	} else if isID {
+		uid = id
+		for _, u := range users {
+			if u.uid == uid {
+				gid, name = u.gid, u.name
+				break
+			}
+		}
+	} else {
+		found := false
+		for _, u := range users {
+			if u.name == userSpec {
+				uid, gid, name, found = u.uid, u.gid, u.name, true
+				break
+			}
+		}
+		if !found {
+			return 0, 0, "", &ErrUserNotFound{Name: userSpec, Path: passwdPath}
+		}
+	}
+
+	if groupSpec == "" {
+		return uid, gid, name, nil
+	}
+
+	if id, isID, err := parseID(groupSpec); err != nil {
+		return 0, 0, "", fmt.Errorf("invalid group=%q: %w", groupSpec, err)
+	} else if isID {
+		return uid, id, name, nil
+	}
+
+	groups, err := i.groupEntries()
+	if err != nil {
+		return 0, 0, "", err
+	}
+	for _, g := range groups {
+		if g.name == groupSpec {
+			return uid, g.gid, name, nil
+		}
+	}
+	return 0, 0, "", &ErrUserNotFound{Name: groupSpec, Path: groupPath}
+}
+
+// parseID parses a numeric uid or gid, indicating if the value is numeric at all (otherwise it is a name).
+func parseID(value string) (int, bool, error) {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return 0, false, nil
+		}
+	}
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 0, true, err
+	}
+	return int(id), true, nil
+}
+
+// passwdEntries returns the users from the passwd file within the image squash (none if there is no passwd file).
+func (i *Image) passwdEntries() ([]passwdEntry, error) {
+	lines, err := i.colonSeparatedLines(passwdPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []passwdEntry
+	for _, fields := range lines {
+		// name:password:uid:gid:gecos:home:shell
+		if len(fields) < 4 {
+			continue
+		}
+		uid, uidErr := strconv.Atoi(fields[2])
+		gid, gidErr := strconv.Atoi(fields[3])
+		if uidErr != nil || gidErr != nil {
+			continue
+		}
+		entries = append(entries, passwdEntry{name: fields[0], uid: uid, gid: gid})
+	}
+	return entries, nil
+}
+
+// groupEntries returns the groups from the group file within the image squash (none if there is no group file).
+func (i *Image) groupEntries() ([]groupEntry, error) {
+	lines, err := i.colonSeparatedLines(groupPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []groupEntry
+	for _, fields := range lines {
+		// name:password:gid:members
+		if len(fields) < 3 {
+			continue
+		}
+		gid, err := strconv.Atoi(fields[2])
+		if err != nil {
+			continue
+		}
+		entries = append(entries, groupEntry{name: fields[0], gid: gid})
+	}
+	return entries, nil
+}
+
+// colonSeparatedLines returns the fields of each (non-empty, non-comment) line within the given file of the image
+// squash, where a missing file has no lines.
+func (i *Image) colonSeparatedLines(p string) ([][]string, error) {
+	contents, err := i.ReadFile(p)
+	if err != nil {
+		var notFound *ErrPathNotFound
+		if errors.As(err, &notFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var lines [][]string
+	scanner := bufio.NewScanner(strings.NewReader(string(contents)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, strings.Split(line, ":"))
+	}
+	return lines, scanner.Err()
+}
@@ -0,0 +1,162 @@
+package image
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImage_EffectiveUser(t *testing.T) {
+	passwd := `root:x:0:0:root:/root:/bin/bash
+# a comment
+nginx:x:101:101:nginx user:/nonexistent:/bin/false
+app:x:1000:1001::/home/app:/bin/sh
+`
+	group := `root:x:0:
+nginx:x:101:
+www-data:x:33:nginx
+`
+	etc := []testEntry{testDir("/etc"), testFile("/etc/passwd", passwd), testFile("/etc/group", group)}
+
+	tests := []struct {
+		name         string
+		user         string
+		layers       [][]testEntry
+		expectedUID  int
+		expectedGID  int
+		expectedName string
+		expectedErr  *ErrUserNotFound
+	}{
+		{
+			name:         "no user is root",
+			user:         "",
+			layers:       [][]testEntry{etc},
+			expectedName: "root",
+		},
+		{
+			name:         "named user",
+			user:         "app",
+			layers:       [][]testEntry{etc},
+			expectedUID:  1000,
+			expectedGID:  1001,
+			expectedName: "app",
+		},
+		{
+			name:         "numeric user",
+			user:         "101",
+			layers:       [][]testEntry{etc},
+			expectedUID:  101,
+			expectedGID:  101,
+			expectedName: "nginx",
+		},
+		{
+			name:        "numeric user not within passwd",
+			user:        "5000",
+			layers:      [][]testEntry{etc},
+			expectedUID: 5000,
+			expectedGID: 0,
+		},
+		{
+			name:         "named user and group",
+			user:         "nginx:www-data",
+			layers:       [][]testEntry{etc},
+			expectedUID:  101,
+			expectedGID:  33,
+			expectedName: "nginx",
+		},
+		{
+			name:         "named user and numeric group",
+			user:         "app:2000",
+			layers:       [][]testEntry{etc},
+			expectedUID:  1000,
+			expectedGID:  2000,
+			expectedName: "app",
+		},
+		{
+			name:        "numeric user and group without passwd or group files",
+			user:        "1000:1000",
+			layers:      [][]testEntry{{testDir("/etc")}},
+			expectedUID: 1000,
+			expectedGID: 1000,
+		},
+		{
+			name:        "missing named user",
+			user:        "postgres",
+			layers:      [][]testEntry{etc},
+			expectedErr: &ErrUserNotFound{Name: "postgres", Path: "/etc/passwd"},
+		},
+		{
+			name:        "missing named group",
+			user:        "app:docker",
+			layers:      [][]testEntry{etc},
+			expectedErr: &ErrUserNotFound{Name: "docker", Path: "/etc/group"},
+		},
+		{
+			name:        "named user without passwd file",
+			user:        "app",
+			layers:      [][]testEntry{{testDir("/etc")}},
+			expectedErr: &ErrUserNotFound{Name: "app", Path: "/etc/passwd"},
+		},
+		{
+			name: "passwd from the squash",
+			user: "app",
+			layers: [][]testEntry{
+				etc,
+				{testFile("/etc/passwd", "app:x:2000:2000::/home/app:/bin/sh\n")},
+			},
+			expectedUID:  2000,
+			expectedGID:  2000,
+			expectedName: "app",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			img := newTestImage(t, test.layers...)
+			img.Metadata.Config.Config.User = test.user
+
+			uid, gid, name, err := img.EffectiveUser()
+			if test.expectedErr != nil {
+				var notFound *ErrUserNotFound
+				require.True(t, errors.As(err, &notFound), "expected ErrUserNotFound, got %+v", err)
+				assert.Equal(t, test.expectedErr, notFound)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedUID, uid)
+			assert.Equal(t, test.expectedGID, gid)
+			assert.Equal(t, test.expectedName, name)
+		})
+	}
+}
+
+func TestImage_EffectiveUser_InvalidID(t *testing.T) {
+	img := newTestImage(t, []testEntry{testDir("/etc")})
+	img.Metadata.Config.Config.User = "99999999999"
+
+	_, _, _, err := img.EffectiveUser()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid user")
+}
+
+func TestImage_EffectiveWorkingDir(t *testing.T) {
+	tests := []struct {
+		workingDir string
+		expected   string
+	}{
+		{workingDir: "", expected: "/"},
+		{workingDir: "/app", expected: "/app"},
+		{workingDir: "/app/", expected: "/app"},
+		{workingDir: "app/../srv", expected: "/srv"},
+	}
+
+	img := newTestImage(t, []testEntry{testDir("/etc")})
+	for _, test := range tests {
+		t.Run(test.workingDir, func(t *testing.T) {
+			img.Metadata.Config.Config.WorkingDir = test.workingDir
+			assert.Equal(t, test.expected, img.EffectiveWorkingDir())
+		})
+	}
+}
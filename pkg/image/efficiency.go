@@ -0,0 +1,132 @@
+package image
+
+import (
+	"sort"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+// LayerEfficiency describes how compactly a single layer is stored.
+type LayerEfficiency struct {
+	// Index is the index of the layer within the image (in build order)
+	Index int
+	// Digest is the digest of the layer
+	Digest string
+	// Size is the size in bytes of the layer content (see LayerMetadata.Size)
+	Size int64
+	// CompressedSize is the size in bytes of the layer blob (see LayerMetadata.CompressedSize)
+	CompressedSize int64
+	// CompressionRatio is the compressed size relative to the content size (e.g. 0.25 means the blob is a quarter of
+	// the content size), which is zero when either size is unknown
+	CompressionRatio float64
+}
+
+// WastedPath is a path whose content is stored more than once across layers, where all but the content visible in
+// the squash is wasted (e.g. a file that is added then removed or replaced by a later layer).
+type WastedPath struct {
+	// Path is the absolute path within the image
+	Path string
+	// Occurrences is the number of layers that hold content for the path
+	Occurrences int
+	// WastedBytes is the size of the content that is not visible in the squash
+	WastedBytes int64
+}
+
+// Efficiency describes how much of the image content is useful, see Image.Efficiency.
+type Efficiency struct {
+	// Layers is the compression information for each layer (in build order)
+	Layers []LayerEfficiency
+	// Analyzed indicates that the duplicate analysis was run (see WithEfficiencyAnalysis), otherwise the fields below
+	// are empty
+	Analyzed bool
+	// WastedBytes is the total size of content that is not visible in the squash
+	WastedBytes int64
+	// WastedPaths are the paths with wasted content, with the most wasteful paths first
+	WastedPaths []WastedPath
+	// Score is the fraction of the image content size that is visible in the squash (1 means nothing is wasted)
+	Score float64
+}
+
+// WithEfficiencyAnalysis enables the duplicate analysis of Image.Efficiency, which finds content that is added by a
+// layer but overwritten or deleted by a later layer. This retains the path history of the image (see
+// WithPathHistory), thus is not enabled by default.
+func WithEfficiencyAnalysis() AdditionalMetadata {
+	return WithPathHistory()
+}
+
+// Efficiency returns the compression ratio of each layer and, when the image was read with WithEfficiencyAnalysis,
+// the content that is stored within the image but not visible in the squash. This reuses what was gathered while the
+// image was read (no layer content is read again).
+func (i *Image) Efficiency() Efficiency {
+	var result Efficiency
+	var totalSize int64
+	for idx, layer := range i.Layers {
+		m := layer.Metadata
+		entry := LayerEfficiency{
+			Index:          idx,
+			Digest:         m.Digest,
+			Size:           m.Size,
+			CompressedSize: m.CompressedSize,
+		}
+		if m.Size > 0 && m.CompressedSize > 0 {
+			entry.CompressionRatio = float64(m.CompressedSize) / float64(m.Size)
+		}
+		result.Layers = append(result.Layers, entry)
+		totalSize += m.Size
+	}
+
+	if i.pathHistory == nil {
+		return result
+	}
+	result.Analyzed = true
+
+	for p, changes := range i.pathHistory {
+		wasted := WastedPath{Path: string(p)}
+		for idx, change := range changes {
+			if change.Action == PathDeleted {
+				continue
+			}
+			wasted.Occurrences++
+			// the last change that holds content is visible in the squash (unless a later change deletes it)
+			if idx == len(changes)-1 {
+				continue
+			}
+			wasted.WastedBytes += i.layerContentSize(change.LayerIndex, p)
+		}
+		if wasted.WastedBytes == 0 {
+			continue
+		}
+		result.WastedBytes += wasted.WastedBytes
+		result.WastedPaths = append(result.WastedPaths, wasted)
+	}
+
+	sort.Slice(result.WastedPaths, func(a, b int) bool {
+		if result.WastedPaths[a].WastedBytes != result.WastedPaths[b].WastedBytes {
+			return result.WastedPaths[a].WastedBytes > result.WastedPaths[b].WastedBytes
+		}
+		return result.WastedPaths[a].Path < result.WastedPaths[b].Path
+	})
+
+	result.Score = 1
+	if totalSize > 0 {
+		result.Score = 1 - float64(result.WastedBytes)/float64(totalSize)
+	}
+	return result
+}
+
+// layerContentSize returns the size of the content for the given path within the layer at the given index.
+func (i *Image) layerContentSize(layerIdx int, p file.Path) int64 {
+	tree := i.Layers[layerIdx].Tree
+	if tree == nil {
+		return 0
+	}
+	_, ref, err := tree.File(p)
+	if err != nil || ref == nil {
+		return 0
+	}
+	entry, err := i.FileCatalog.Get(*ref)
+	if err != nil {
+		return 0
+	}
+	return entry.Metadata.Size
+}
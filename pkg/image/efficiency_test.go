@@ -0,0 +1,66 @@
+package image
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImage_Efficiency(t *testing.T) {
+	big := strings.Repeat("a", 1000)
+	v1Img := newTestV1Image(t,
+		[]testEntry{
+			testFile("tmp/cache.tar", big),
+			testFile("etc/config", "v1"),
+			testFile("etc/keep", "keep"),
+		},
+		[]testEntry{
+			testFile("tmp/.wh.cache.tar", ""),
+			testFile("etc/config", "v2"),
+		},
+	)
+	rawManifest, err := v1Img.RawManifest()
+	require.NoError(t, err)
+
+	img := NewImage(v1Img, t.TempDir(), WithManifest(rawManifest), WithEfficiencyAnalysis())
+	require.NoError(t, img.Read())
+
+	efficiency := img.Efficiency()
+
+	require.Len(t, efficiency.Layers, 2)
+	for idx, layer := range efficiency.Layers {
+		assert.Equal(t, idx, layer.Index)
+		assert.Equal(t, img.Layers[idx].Metadata.Digest, layer.Digest)
+		assert.Positive(t, layer.CompressedSize)
+		assert.InDelta(t, float64(layer.CompressedSize)/float64(layer.Size), layer.CompressionRatio, 0.0001)
+	}
+	// the repetitive content compresses well
+	assert.Less(t, efficiency.Layers[0].CompressionRatio, 1.0)
+
+	assert.True(t, efficiency.Analyzed)
+	assert.Equal(t, []WastedPath{
+		{Path: "/tmp/cache.tar", Occurrences: 1, WastedBytes: 1000},
+		{Path: "/etc/config", Occurrences: 2, WastedBytes: 2},
+	}, efficiency.WastedPaths)
+	assert.Equal(t, int64(1002), efficiency.WastedBytes)
+	assert.InDelta(t, 1-1002.0/float64(img.Metadata.Size), efficiency.Score, 0.0001)
+}
+
+func TestImage_Efficiency_NotAnalyzed(t *testing.T) {
+	img := newTestImage(t,
+		[]testEntry{testFile("etc/config", "v1")},
+		[]testEntry{testFile("etc/config", "v2")},
+	)
+
+	efficiency := img.Efficiency()
+
+	require.Len(t, efficiency.Layers, 2)
+	// without a manifest the blob sizes are unknown
+	assert.Zero(t, efficiency.Layers[0].CompressedSize)
+	assert.Zero(t, efficiency.Layers[0].CompressionRatio)
+	assert.False(t, efficiency.Analyzed)
+	assert.Empty(t, efficiency.WastedPaths)
+	assert.Zero(t, efficiency.WastedBytes)
+}
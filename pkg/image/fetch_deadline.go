@@ -0,0 +1,53 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// FetchProgress describes how far fetching an image got before it was interrupted.
+type FetchProgress struct {
+	// Stage is the part of the fetch that was interrupted (e.g. "pulling", "saving", or "reading layers").
+	Stage string
+	// Bytes is the number of bytes transferred before the interruption. For the docker daemon this includes the bytes
+	// downloaded by the daemon while pulling along with the bytes of the image tar saved from the daemon.
+	Bytes int64
+	// Duration is how long the fetch ran before the interruption.
+	Duration time.Duration
+	// LayersCompleted is the number of layers that were completely transferred (or already present).
+	LayersCompleted int
+	// LayersTotal is the number of layers to transfer (zero if layers are not tracked for the stage or the number of
+	// layers was not yet known).
+	LayersTotal int
+}
+
+// ErrFetchDeadlineExceeded is returned when the deadline of the context given to fetch an image passes before the
+// fetch completes, describing how far the fetch got. This error wraps context.DeadlineExceeded.
+type ErrFetchDeadlineExceeded struct {
+	// Image is the user image reference being fetched
+	Image    string
+	progress FetchProgress
+}
+
+// NewErrFetchDeadlineExceeded creates an error for the given image fetch that was interrupted with the given progress.
+func NewErrFetchDeadlineExceeded(image string, progress FetchProgress) *ErrFetchDeadlineExceeded {
+	return &ErrFetchDeadlineExceeded{
+		Image:    image,
+		progress: progress,
+	}
+}
+
+func (e *ErrFetchDeadlineExceeded) Error() string {
+	return fmt.Sprintf("deadline exceeded while fetching image=%q (stage=%q bytes=%d layers=%d/%d elapsed=%s)",
+		e.Image, e.progress.Stage, e.progress.Bytes, e.progress.LayersCompleted, e.progress.LayersTotal, e.progress.Duration)
+}
+
+func (e *ErrFetchDeadlineExceeded) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// Progress returns how far the fetch got before the deadline passed.
+func (e *ErrFetchDeadlineExceeded) Progress() FetchProgress {
+	return e.progress
+}
@@ -0,0 +1,59 @@
+package image
+
+import "time"
+
+// FetchStats describes how the image content was obtained by the provider.
+type FetchStats struct {
+	// PulledFromRegistry indicates that the image content was transferred from a registry over the network, as opposed
+	// to already being present on the host (e.g. in the docker daemon image store).
+	PulledFromRegistry bool
+	// Duration is how long the pull took (zero if nothing was pulled).
+	Duration time.Duration
+	// Bytes is the number of bytes transferred during the pull (zero if nothing was pulled).
+	Bytes int64
+}
+
+// WithFetchStats records how the image was obtained, for providers where the stats are final once the image is
+// provided (e.g. the docker daemon provider).
+func WithFetchStats(stats *FetchStats) AdditionalMetadata {
+	return func(image *Image) error {
+		image.Metadata.FetchStats = stats
+		return nil
+	}
+}
+
+// WithFetchStatsRecorder records how the image was obtained, for providers that lazily fetch content while the image is
+// read (e.g. registry layers), where the given function returns a copy of the stats recorded so far and must be safe
+// to call while the stats are being recorded. Metadata.FetchStats is a copy taken once Image.Read returns, while
+// Image.CurrentFetchStats reports the stats recorded so far.
+func WithFetchStatsRecorder(snapshot func() FetchStats) AdditionalMetadata {
+	return func(image *Image) error {
+		image.fetchStatsSnapshot = snapshot
+		image.refreshFetchStats()
+		return nil
+	}
+}
+
+// CurrentFetchStats returns a copy of how the image was obtained so far (nil if the image was not fetched from a daemon
+// or registry), which unlike Metadata.FetchStats is safe to call while content is still being fetched (e.g. after an
+// interrupted Image.Read).
+func (i *Image) CurrentFetchStats() *FetchStats {
+	if i.fetchStatsSnapshot != nil {
+		stats := i.fetchStatsSnapshot()
+		return &stats
+	}
+	if i.Metadata.FetchStats == nil {
+		return nil
+	}
+	stats := *i.Metadata.FetchStats
+	return &stats
+}
+
+// refreshFetchStats replaces Metadata.FetchStats with a copy of the stats recorded so far (see WithFetchStatsRecorder).
+func (i *Image) refreshFetchStats() {
+	if i.fetchStatsSnapshot == nil {
+		return
+	}
+	stats := i.fetchStatsSnapshot()
+	i.Metadata.FetchStats = &stats
+}
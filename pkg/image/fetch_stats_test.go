@@ -0,0 +1,73 @@
+package image
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// liveStats are fetch stats that keep updating (as with layers fetched while the image is read).
+type liveStats struct {
+	lock  sync.Mutex
+	stats FetchStats
+}
+
+func (s *liveStats) add(n int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.stats.Bytes += n
+	s.stats.Duration += time.Millisecond
+}
+
+func (s *liveStats) snapshot() FetchStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.stats
+}
+
+func TestWithFetchStatsRecorder(t *testing.T) {
+	live := &liveStats{stats: FetchStats{PulledFromRegistry: true}}
+	img := NewImage(newTestV1Image(t, []testEntry{testFile("/file.txt", "contents")}), t.TempDir(), WithFetchStatsRecorder(live.snapshot))
+
+	live.add(10)
+	require.NoError(t, img.Read())
+
+	// the stats are copied once the image is read...
+	require.NotNil(t, img.Metadata.FetchStats)
+	assert.Equal(t, FetchStats{PulledFromRegistry: true, Bytes: 10, Duration: time.Millisecond}, *img.Metadata.FetchStats)
+
+	// ...thus are not written to by requests still in flight, which are only reported by the current stats
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			live.add(1)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		assert.GreaterOrEqual(t, img.CurrentFetchStats().Bytes, int64(10))
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(10), img.Metadata.FetchStats.Bytes)
+	assert.Equal(t, int64(110), img.CurrentFetchStats().Bytes)
+}
+
+func TestImage_CurrentFetchStats(t *testing.T) {
+	img := NewImage(nil, t.TempDir())
+	assert.Nil(t, img.CurrentFetchStats())
+
+	stats := &FetchStats{Bytes: 10}
+	require.NoError(t, WithFetchStats(stats)(img))
+
+	current := img.CurrentFetchStats()
+	require.NotNil(t, current)
+	assert.Equal(t, int64(10), current.Bytes)
+	assert.NotSame(t, stats, current)
+}
@@ -14,6 +14,10 @@ var ErrFileNotFound = fmt.Errorf("could not find file")
 type FileCatalog struct {
 	catalog    map[file.ID]FileCatalogEntry
 	byMIMEType map[string][]file.ID
+	// maxFileReadSize is the maximum number of bytes that may be read from any single file (see SetMaxFileReadSize)
+	maxFileReadSize int64
+	// maxEntries is the maximum number of files that may be cataloged (see SetMaxFileEntries)
+	maxEntries int64
 }
 
 // FileCatalogEntry represents all stored metadata for a single file reference.
@@ -32,6 +36,14 @@ func NewFileCatalog() FileCatalog {
 	}
 }
 
+// checkEntryLimit returns ErrTooManyFiles if cataloging another file would exceed the maximum number of entries.
+func (c *FileCatalog) checkEntryLimit() error {
+	if c.maxEntries > 0 && int64(len(c.catalog)) >= c.maxEntries {
+		return fmt.Errorf("%w (limit=%d)", ErrTooManyFiles, c.maxEntries)
+	}
+	return nil
+}
+
 // Add creates a new FileCatalogEntry for the given file reference and metadata, cataloged by the ID of the
 // file reference (overwriting any existing entries without warning).
 func (c *FileCatalog) Add(f file.Reference, m file.Metadata, l *Layer, opener file.Opener) {
@@ -82,7 +94,8 @@ func (c *FileCatalog) GetByMIMEType(mType string) ([]FileCatalogEntry, error) {
 }
 
 // FetchContents reads the file contents for the given file reference from the underlying image/layer blob. An error
-// is returned if there is no file at the given path and layer or the read operation cannot continue.
+// is returned if there is no file at the given path and layer or the read operation cannot continue. If a maximum
+// file read size is configured then a *file.ErrFileTooLarge is returned for files that exceed the limit.
 func (c *FileCatalog) FileContents(f file.Reference) (io.ReadCloser, error) {
 	catalogEntry, ok := c.catalog[f.ID()]
 	if !ok {
@@ -93,5 +106,14 @@ func (c *FileCatalog) FileContents(f file.Reference) (io.ReadCloser, error) {
 		return nil, fmt.Errorf("no contents available for file: %+v", f.RealPath)
 	}
 
-	return catalogEntry.Contents(), nil
+	if c.maxFileReadSize > 0 && catalogEntry.Metadata.Size > c.maxFileReadSize {
+		return nil, &file.ErrFileTooLarge{
+			Path:  string(f.RealPath),
+			Size:  catalogEntry.Metadata.Size,
+			Limit: c.maxFileReadSize,
+		}
+	}
+
+	// note: the size from the tar header is not trusted, the limit is enforced while reading as well
+	return file.NewSizeLimitedReadCloser(catalogEntry.Contents(), string(f.RealPath), c.maxFileReadSize), nil
 }
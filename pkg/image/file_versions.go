@@ -0,0 +1,84 @@
+package image
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+// FileVersion is a single layer's version of a file (see Image.FileVersions).
+type FileVersion struct {
+	// LayerIndex is the index of the layer within the image (in build order)
+	LayerIndex int
+	// LayerDigest is the digest of the layer
+	LayerDigest string
+	// Digest is the digest of the file content within the layer (e.g. "sha256:..."), which is empty for anything other
+	// than a regular file (or when the content cannot be read)
+	Digest string
+	// Size is the size in bytes of the file content within the layer
+	Size int64
+}
+
+// WithFileVersions retains the entries of every layer for each path (not only the entry visible in the squash), which
+// is needed for Image.FileVersions. This retains the path history of the image (see WithPathHistory), thus is not
+// enabled by default.
+func WithFileVersions() AdditionalMetadata {
+	return WithPathHistory()
+}
+
+// FileVersions returns each layer's version of the file at the given path (in build order), such as a file that is
+// replaced by a later layer with different contents. Layers that delete the path are not included, though a version
+// that is later deleted is. Links are not followed. The file content of each version is read to compute the digest.
+// Nil is returned if the path never appears within any layer or the image was not read with WithFileVersions.
+func (i *Image) FileVersions(path string) []FileVersion {
+	p := file.Path(file.DirSeparator + path).Normalize()
+
+	var versions []FileVersion
+	for _, change := range i.PathHistory(string(p)) {
+		if change.Action == PathDeleted {
+			continue
+		}
+		version := FileVersion{
+			LayerIndex:  change.LayerIndex,
+			LayerDigest: change.LayerDigest,
+		}
+
+		tree := i.Layers[change.LayerIndex].Tree
+		if tree == nil {
+			versions = append(versions, version)
+			continue
+		}
+		if _, ref, err := tree.File(p); err == nil && ref != nil {
+			if entry, err := i.FileCatalog.Get(*ref); err == nil {
+				version.Size = entry.Metadata.Size
+				if entry.Metadata.TypeFlag == tar.TypeReg || entry.Metadata.TypeFlag == tar.TypeRegA {
+					digest, err := i.fileDigest(*ref)
+					if err != nil {
+						log.Warnf("unable to digest path=%q within layer=%q: %+v", p, change.LayerDigest, err)
+					}
+					version.Digest = digest
+				}
+			}
+		}
+		versions = append(versions, version)
+	}
+	return versions
+}
+
+// fileDigest returns the sha256 digest of the contents of the given file.
+func (i *Image) fileDigest(ref file.Reference) (string, error) {
+	reader, err := i.FileCatalog.FileContents(ref)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	hasher := sha256.New()
+	if _, err := file.Copy(hasher, reader); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("sha256:%x", hasher.Sum(nil)), nil
+}
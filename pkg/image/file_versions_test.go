@@ -0,0 +1,70 @@
+package image
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImage_FileVersions(t *testing.T) {
+	digest := func(contents string) string {
+		return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(contents)))
+	}
+
+	img := NewImage(newTestV1Image(t,
+		[]testEntry{
+			testDir("etc"),
+			testFile("etc/shadow", "root:*:18000::::::"),
+			testFile("etc/hosts", "localhost"),
+		},
+		[]testEntry{
+			testFile("etc/shadow", "root:$6$changed:18000::::::"),
+			testFile("etc/.wh.hosts", ""),
+		},
+		[]testEntry{
+			testFile("etc/shadow", "root:*:18000::::::"),
+		},
+	), t.TempDir(), WithFileVersions())
+	require.NoError(t, img.Read())
+
+	t.Run("versions across layers", func(t *testing.T) {
+		versions := img.FileVersions("/etc/shadow")
+		require.Len(t, versions, 3)
+		for idx, version := range versions {
+			assert.Equal(t, idx, version.LayerIndex)
+			assert.Equal(t, img.Layers[idx].Metadata.Digest, version.LayerDigest)
+		}
+		assert.Equal(t, digest("root:*:18000::::::"), versions[0].Digest)
+		assert.Equal(t, digest("root:$6$changed:18000::::::"), versions[1].Digest)
+		assert.Equal(t, versions[0].Digest, versions[2].Digest)
+		assert.Equal(t, int64(len("root:$6$changed:18000::::::")), versions[1].Size)
+	})
+
+	t.Run("deleting layers are not versions", func(t *testing.T) {
+		versions := img.FileVersions("etc/hosts")
+		require.Len(t, versions, 1)
+		assert.Equal(t, 0, versions[0].LayerIndex)
+		assert.Equal(t, digest("localhost"), versions[0].Digest)
+	})
+
+	t.Run("directories have no digest", func(t *testing.T) {
+		versions := img.FileVersions("/etc")
+		require.Len(t, versions, 1)
+		assert.Empty(t, versions[0].Digest)
+	})
+
+	t.Run("never appears", func(t *testing.T) {
+		assert.Empty(t, img.FileVersions("/etc/passwd"))
+	})
+}
+
+func TestImage_FileVersions_NotRetained(t *testing.T) {
+	img := newTestImage(t,
+		[]testEntry{testFile("etc/shadow", "v1")},
+		[]testEntry{testFile("etc/shadow", "v2")},
+	)
+	assert.Empty(t, img.FileVersions("/etc/shadow"))
+}
@@ -0,0 +1,31 @@
+package image
+
+import (
+	"bytes"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// WithForeignLayers indicates if foreign layers (layers that are not distributed by the registry, as with Windows base
+// images, which instead declare URLs to external locations) should be fetched when the image is read. When not
+// allowed, the contents of foreign layers are skipped: the layer is marked as skipped within the layer metadata and has
+// an empty file tree. By default foreign layers are fetched.
+func WithForeignLayers(allowed bool) AdditionalMetadata {
+	return func(image *Image) error {
+		image.skipForeignLayers = !allowed
+		return nil
+	}
+}
+
+// foreignLayerURLs returns the external URLs declared for the given layer within the image manifest (if any).
+func foreignLayerURLs(imgMetadata Metadata, idx int) []string {
+	if len(imgMetadata.RawManifest) == 0 {
+		return nil
+	}
+
+	manifest, err := v1.ParseManifest(bytes.NewReader(imgMetadata.RawManifest))
+	if err != nil || idx >= len(manifest.Layers) {
+		return nil
+	}
+	return manifest.Layers[idx].URLs
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"io"
+	"sort"
 
 	"github.com/anchore/stereoscope/internal/bus"
 	"github.com/anchore/stereoscope/internal/log"
@@ -24,12 +25,33 @@ type Image struct {
 	contentCacheDir string
 	// Metadata contains select image attributes
 	Metadata Metadata
-	// Layers contains the rich layer objects in build order
+	// Layers contains the rich layer objects in build order (base-first, matching the image manifest order), where
+	// Layers[i].Metadata.Index == i (see BaseLayer and TopLayer)
 	Layers []*Layer
 	// FileCatalog contains all file metadata for all files in all layers
 	FileCatalog FileCatalog
 
 	overrideMetadata []AdditionalMetadata
+	// lowMemory indicates that only the image squash tree is retained (see SetLowMemoryMode)
+	lowMemory bool
+	// tracer logs how the image is read and squashed (see WithTraceMode)
+	tracer log.Tracer
+	// skipForeignLayers indicates that the contents of foreign layers are not fetched (see WithForeignLayers)
+	skipForeignLayers bool
+	// layerCache is the shared cache to read layers into (see WithLayerCache)
+	layerCache *LayerCache
+	// pathHistory is the ordered set of layer changes for each path, only tracked when requested (see WithPathHistory)
+	pathHistory map[file.Path][]PathChange
+	// layerSelection is the subset of layers to read (see WithLayerIndices), all layers are read when nil
+	layerSelection *layerSelection
+	// sparseExtraction is the subset of entries to extract from each layer (see WithSparseExtraction)
+	sparseExtraction *sparseExtraction
+	// squashedDigest is the digest of the image squash tree, computed upon request (see SquashedDigest)
+	squashedDigest string
+	// disabledEvents are the event types not published while reading the image (see WithDisabledEvents)
+	disabledEvents event.TypeSet
+	// fetchStatsSnapshot returns a copy of the stats recorded while content is fetched (see WithFetchStatsRecorder)
+	fetchStatsSnapshot func() FetchStats
 }
 
 type AdditionalMetadata func(*Image) error
@@ -68,6 +90,7 @@ func WithConfig(config []byte) AdditionalMetadata {
 	return func(image *Image) error {
 		image.Metadata.RawConfig = config
 		image.Metadata.ID = fmt.Sprintf("sha256:%x", sha256.Sum256(config))
+		image.Metadata.ConfigDigest = image.Metadata.ID
 		return nil
 	}
 }
@@ -83,6 +106,28 @@ func WithRepoDigests(digests []string) AdditionalMetadata {
 	}
 }
 
+// WithDangling marks the image as untagged within the docker daemon, where the image ID is its only identity.
+func WithDangling() AdditionalMetadata {
+	return func(image *Image) error {
+		image.Metadata.Dangling = true
+		return nil
+	}
+}
+
+// WithDisabledEvents suppresses publishing the given event types (e.g. event.ReadLayer) while reading the image, in
+// addition to any event types disabled globally (see stereoscope.SetDisabledEvents).
+func WithDisabledEvents(types ...partybus.EventType) AdditionalMetadata {
+	return func(image *Image) error {
+		if image.disabledEvents == nil {
+			image.disabledEvents = event.NewTypeSet()
+		}
+		for _, t := range types {
+			image.disabledEvents[t] = struct{}{}
+		}
+		return nil
+	}
+}
+
 // NewImage provides a new, unread image object.
 func NewImage(image v1.Image, contentCacheDir string, additionalMetadata ...AdditionalMetadata) *Image {
 	imgObj := &Image{
@@ -103,42 +148,69 @@ func (i *Image) IDs() []string {
 	return ids
 }
 
-func (i *Image) trackReadProgress(metadata Metadata) *progress.Manual {
+func (i *Image) trackReadProgress(metadata Metadata) (*progress.Manual, *event.Stage) {
 	prog := &progress.Manual{
 		// x2 for read and squash of each layer
 		Total: int64(len(metadata.Config.RootFS.DiffIDs) * 2),
 	}
 
-	bus.Publish(partybus.Event{
+	stage := &event.Stage{}
+	stage.Set(event.ExtractingStage, "reading layers")
+	bus.ReportStage(i.progressName(), event.ExtractingStage, "reading layers")
+
+	bus.PublishUnless(partybus.Event{
 		Type:   event.ReadImage,
 		Source: metadata,
-		Value:  progress.Progressable(prog),
-	})
+		Value: progress.StagedProgressable(&struct {
+			progress.Stager
+			event.StageCoder
+			*progress.Manual
+		}{
+			Stager:     progress.Stager(stage),
+			StageCoder: event.StageCoder(stage),
+			Manual:     prog,
+		}),
+	}, i.disabledEvents)
+
+	return prog, stage
+}
 
-	return prog
+// progressName is how the image is identified to the progress reporter (see Metadata.Identity).
+func (i *Image) progressName() string {
+	return i.Metadata.Identity()
 }
 
-func (i *Image) applyOverrideMetadata() error {
-	for _, optionFn := range i.overrideMetadata {
-		if err := optionFn(i); err != nil {
-			return fmt.Errorf("unable to override metadata option: %w", err)
+// applyOverrideMetadata applies the options given to NewImage, followed by the given options.
+func (i *Image) applyOverrideMetadata(options ...AdditionalMetadata) error {
+	for _, optionSet := range [][]AdditionalMetadata{i.overrideMetadata, options} {
+		for _, optionFn := range optionSet {
+			if err := optionFn(i); err != nil {
+				return fmt.Errorf("unable to override metadata option: %w", err)
+			}
 		}
 	}
 	return nil
 }
 
 // Read parses information from the underlying image tar into this struct. This includes image metadata, layer
-// metadata, layer file trees, and layer squash trees (which implies the image squash tree).
-func (i *Image) Read() error {
+// metadata, layer file trees, and layer squash trees (which implies the image squash tree). Any given options are
+// applied after the options given to NewImage.
+func (i *Image) Read(options ...AdditionalMetadata) error {
 	var layers = make([]*Layer, 0)
 	var err error
+	// note: layers may be fetched while the image is read, thus the stats are only final afterwards
+	defer i.refreshFetchStats()
+	i.lowMemory = isLowMemoryMode()
+	i.tracer = log.DefaultTracer()
+	i.FileCatalog.maxFileReadSize = currentMaxFileReadSize()
+	i.FileCatalog.maxEntries = currentMaxFileEntries()
 	i.Metadata, err = readImageMetadata(i.image)
 	if err != nil {
 		return err
 	}
 
 	// override any metadata with what the user has provided manually
-	if err = i.applyOverrideMetadata(); err != nil {
+	if err = i.applyOverrideMetadata(options...); err != nil {
 		return err
 	}
 
@@ -152,25 +224,59 @@ func (i *Image) Read() error {
 		return err
 	}
 
+	if len(v1Layers) == 0 {
+		// e.g. a "FROM scratch" image with only metadata instructions, which has a valid (empty) squash tree
+		log.Debugf("image has no layers: %+v", i.Metadata.ID)
+	}
+
+	var diffIDs []string
+	for _, diffID := range i.Metadata.Config.RootFS.DiffIDs {
+		diffIDs = append(diffIDs, diffID.String())
+	}
+	selected, err := i.layerSelection.resolve(diffIDs)
+	if err != nil {
+		return err
+	}
+
 	// let consumers know of a monitorable event (image save + copy stages)
-	readProg := i.trackReadProgress(i.Metadata)
+	readProg, stage := i.trackReadProgress(i.Metadata)
 
 	for idx, v1Layer := range v1Layers {
 		layer := NewLayer(v1Layer)
+		layer.skipForeign = i.skipForeignLayers
+		layer.unselected = selected != nil && !selected[idx]
+		layer.cache = i.layerCache
+		layer.sparse = i.sparseExtraction
+		layer.tracer = i.tracer
+		layer.disabledEvents = i.disabledEvents
 		err := layer.Read(&i.FileCatalog, i.Metadata, idx, i.contentCacheDir)
 		if err != nil {
+			readProg.Err = err
 			return err
 		}
+		i.recordPathHistory(layer, idx)
+		if i.lowMemory {
+			// the file catalog retains the location of each file within the cached layer tar, the index is not needed
+			layer.indexedContent = nil
+		}
 		i.Metadata.Size += layer.Metadata.Size
+		i.Metadata.Layers = append(i.Metadata.Layers, layer.Metadata)
 		layers = append(layers, layer)
 
 		readProg.N++
+		bus.ReportProgress(i.progressName(), event.ExtractingStage, readProg.N, readProg.Total)
 	}
 
 	i.Layers = layers
 
 	// in order to resolve symlinks all squashed trees must be available
-	return i.squash(readProg)
+	stage.Set(event.SquashingStage, "squashing layers")
+	bus.ReportStage(i.progressName(), event.SquashingStage, "squashing layers")
+	if err := i.squash(readProg); err != nil {
+		readProg.Err = err
+		return err
+	}
+	return nil
 }
 
 // squash generates a squash tree for each layer in the image. For instance, layer 2 squash =
@@ -185,7 +291,10 @@ func (i *Image) squash(prog *progress.Manual) error {
 			continue
 		}
 
+		i.tracer.Tracef("squashing layer=%d digest=%q onto the squash of layers 0-%d", idx, layer.Metadata.Digest, idx-1)
+
 		var unionTree = filetree.NewUnionFileTree()
+		unionTree.SetTraceMode(i.tracer.Enabled())
 		unionTree.PushTree(lastSquashTree)
 		unionTree.PushTree(layer.Tree)
 
@@ -194,18 +303,45 @@ func (i *Image) squash(prog *progress.Manual) error {
 			return fmt.Errorf("failed to squash tree %d: %w", idx, err)
 		}
 
+		if i.lowMemory && idx > 1 {
+			// only the image squash tree (and the first layer tree, which is its own squash) is retained
+			i.Layers[idx-1].SquashedTree = nil
+		}
+
 		layer.SquashedTree = squashedTree
 		lastSquashTree = squashedTree
 
 		prog.N++
+		bus.ReportProgress(i.progressName(), event.SquashingStage, prog.N, prog.Total)
 	}
 
 	prog.SetCompleted()
+	bus.ReportProgress(i.progressName(), event.SquashingStage, prog.Total, prog.Total)
 
 	return nil
 }
 
-// SquashedTree returns the pre-computed image squash file tree.
+// BaseLayer returns the first layer of the image (index 0 within the manifest), upon which all other layers are applied.
+// Nil is returned for images without layers (e.g. scratch images) or images that have not been read.
+func (i *Image) BaseLayer() *Layer {
+	if len(i.Layers) == 0 {
+		return nil
+	}
+	return i.Layers[0]
+}
+
+// TopLayer returns the last layer of the image (the highest index within the manifest), which is applied last and thus
+// takes precedence within the image squash. Nil is returned for images without layers (e.g. scratch images) or images
+// that have not been read.
+func (i *Image) TopLayer() *Layer {
+	if len(i.Layers) == 0 {
+		return nil
+	}
+	return i.Layers[len(i.Layers)-1]
+}
+
+// SquashedTree returns the pre-computed image squash file tree. Images without any layers (e.g. scratch images) have
+// an empty squash tree.
 func (i *Image) SquashedTree() *filetree.FileTree {
 	layerCount := len(i.Layers)
 
@@ -217,6 +353,21 @@ func (i *Image) SquashedTree() *filetree.FileTree {
 	return topLayer.SquashedTree
 }
 
+// ListPaths returns the sorted set of all paths within the image squash tree (including the root and any implied
+// parent directories). Paths removed by whiteouts in later layers are not included, nor are the whiteout markers
+// themselves. No file contents are read, making this a cheap way to check for path existence or to diff images.
+func (i *Image) ListPaths() []string {
+	var paths []string
+	for _, p := range i.SquashedTree().AllRealPaths() {
+		if p.IsWhiteout() {
+			continue
+		}
+		paths = append(paths, string(p))
+	}
+	sort.Strings(paths)
+	return paths
+}
+
 // FileContentsFromSquash fetches file contents for a single path, relative to the image squash tree.
 // If the path does not exist an error is returned.
 func (i *Image) FileContentsFromSquash(path file.Path) (io.ReadCloser, error) {
@@ -246,6 +397,9 @@ func (i *Image) FileContentsByRef(ref file.Reference) (io.ReadCloser, error) {
 // the layer squash of the given layer index argument.
 // If the given file reference is not a link type, or is a unresolvable (dead) link, then the given file reference is returned.
 func (i *Image) ResolveLinkByLayerSquash(ref file.Reference, layer int, options ...filetree.LinkResolutionOption) (*file.Reference, error) {
+	if i.Layers[layer].SquashedTree == nil {
+		return nil, ErrLayerSquashUnavailable
+	}
 	allOptions := append([]filetree.LinkResolutionOption{filetree.FollowBasenameLinks}, options...)
 	_, resolvedRef, err := i.Layers[layer].SquashedTree.File(ref.RealPath, allOptions...)
 	return resolvedRef, err
@@ -255,6 +409,6 @@ func (i *Image) ResolveLinkByLayerSquash(ref file.Reference, layer int, options
 // If the given file reference is not a link type, or is a unresolvable (dead) link, then the given file reference is returned.
 func (i *Image) ResolveLinkByImageSquash(ref file.Reference, options ...filetree.LinkResolutionOption) (*file.Reference, error) {
 	allOptions := append([]filetree.LinkResolutionOption{filetree.FollowBasenameLinks}, options...)
-	_, resolvedRef, err := i.Layers[len(i.Layers)-1].SquashedTree.File(ref.RealPath, allOptions...)
+	_, resolvedRef, err := i.SquashedTree().File(ref.RealPath, allOptions...)
 	return resolvedRef, err
 }
@@ -0,0 +1,315 @@
+package image
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/filetree"
+)
+
+// basic interface assertion
+var _ fs.ReadDirFS = (*imageFS)(nil)
+var _ fs.ReadFileFS = (*imageFS)(nil)
+var _ fs.StatFS = (*imageFS)(nil)
+var _ fs.ReadDirFile = (*imageFSFile)(nil)
+var _ fs.DirEntry = (*imageFSFileInfo)(nil)
+
+var errNotDir = errors.New("not a directory")
+var errIsDir = errors.New("is a directory")
+
+// FS returns a read-only io/fs view of the image squash tree (the image must be read first), allowing for the use
+// of fs.WalkDir, fs.Glob, fs.Sub, etc. Paths follow the io/fs conventions: they are unrooted and slash separated,
+// where "." is the image root (e.g. "etc/passwd" refers to "/etc/passwd").
+//
+// Symlinks and hardlinks are resolved within the image (never against the host) when opening, stating, or reading
+// a path, just as os.Open and os.Stat would. Directory listings (ReadDir) report links as-is with fs.ModeSymlink set
+// (as os.ReadDir would), thus fs.WalkDir does not descend into linked directories. Paths with dead links or link
+// cycles do not exist. The returned value additionally provides Lstat and ReadLink methods for inspecting links
+// without resolving them.
+func (i *Image) FS() fs.FS {
+	return &imageFS{
+		tree:    i.SquashedTree(),
+		catalog: &i.FileCatalog,
+	}
+}
+
+// ReadDir returns the immediate children of the directory at the given path within the image squash tree (the image
+// must be read first), sorted by filename. The path may be absolute (e.g. "/etc") or relative to the image root. Links
+// to directories are resolved, however, the entries themselves are reported as-is (see FS). Paths removed by whiteouts
+// are not listed. An error wrapping fs.ErrNotExist is returned if the path does not exist or is not a directory.
+func (i *Image) ReadDir(dirPath string) ([]fs.DirEntry, error) {
+	name := strings.TrimPrefix(path.Clean(file.DirSeparator+dirPath), file.DirSeparator)
+	if name == "" {
+		name = "."
+	}
+
+	entries, err := i.FS().(*imageFS).ReadDir(name)
+	if errors.Is(err, errNotDir) {
+		return nil, &fs.PathError{Op: "readdir", Path: dirPath, Err: fs.ErrNotExist}
+	}
+	return entries, err
+}
+
+// imageFS implements io/fs interfaces relative to a squashed file tree.
+type imageFS struct {
+	tree    *filetree.FileTree
+	catalog *FileCatalog
+}
+
+// lookup finds the file at the given io/fs path, following any links at the basename when requested.
+func (f *imageFS) lookup(op, name string, followLinks bool) (*imageFSFileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	var options []filetree.LinkResolutionOption
+	if followLinks {
+		options = append(options, filetree.FollowBasenameLinks)
+	}
+
+	exists, ref, err := f.tree.File(toImagePath(name), options...)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	if !exists {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	info := &imageFSFileInfo{
+		name: path.Base(name),
+	}
+
+	if ref == nil {
+		// this is a directory that is implied by the paths of other files (e.g. the root), but has no tar entry
+		info.metadata = file.Metadata{
+			IsDir: true,
+			Mode:  fs.ModeDir | 0755,
+		}
+		return info, nil
+	}
+
+	entry, err := f.catalog.Get(*ref)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	info.ref = ref
+	info.metadata = entry.Metadata
+
+	return info, nil
+}
+
+// Open opens the named file (resolving any links).
+func (f *imageFS) Open(name string) (fs.File, error) {
+	info, err := f.lookup("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return &imageFSFile{
+		fs:   f,
+		name: name,
+		info: info,
+	}, nil
+}
+
+// Stat returns a FileInfo describing the named file (resolving any links).
+func (f *imageFS) Stat(name string) (fs.FileInfo, error) {
+	info, err := f.lookup("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Lstat returns a FileInfo describing the named file without following a link at the basename.
+func (f *imageFS) Lstat(name string) (fs.FileInfo, error) {
+	info, err := f.lookup("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// ReadLink returns the (unresolved) destination of the named symlink or hardlink.
+func (f *imageFS) ReadLink(name string) (string, error) {
+	info, err := f.lookup("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	if info.metadata.Linkname == "" {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return info.metadata.Linkname, nil
+}
+
+// ReadFile reads the named file (resolving any links) and returns its contents.
+func (f *imageFS) ReadFile(name string) ([]byte, error) {
+	fh, err := f.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	return ioutil.ReadAll(fh)
+}
+
+// ReadDir reads the named directory (resolving any links) and returns a list of directory entries sorted by filename.
+// Note: the entries themselves are not resolved, thus links are reported as links.
+func (f *imageFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	info, err := f.lookup("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errNotDir}
+	}
+
+	children, err := f.tree.ListPaths(toImagePath(name))
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(children))
+	for _, child := range children {
+		if child.IsWhiteout() {
+			// whiteouts in the lowest layer have nothing to remove, but are not files within the image either
+			continue
+		}
+		childInfo, err := f.lookup("readdir", path.Join(name, child.Basename()), false)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, childInfo)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+// toImagePath converts an io/fs path to an absolute path within the image.
+func toImagePath(name string) file.Path {
+	if name == "." {
+		return file.DirSeparator
+	}
+	return file.Path(file.DirSeparator + name)
+}
+
+// imageFSFile is an open file (or directory) from an imageFS.
+type imageFSFile struct {
+	fs      *imageFS
+	name    string
+	info    *imageFSFileInfo
+	reader  io.ReadCloser
+	entries []fs.DirEntry
+	offset  int
+	closed  bool
+}
+
+func (f *imageFSFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// Read reads file contents, which are only fetched on the first read.
+func (f *imageFSFile) Read(b []byte) (int, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrClosed}
+	}
+	if f.info.IsDir() {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: errIsDir}
+	}
+	if f.reader == nil {
+		reader, err := f.fs.catalog.FileContents(*f.info.ref)
+		if err != nil {
+			return 0, &fs.PathError{Op: "read", Path: f.name, Err: err}
+		}
+		f.reader = reader
+	}
+	return f.reader.Read(b)
+}
+
+// ReadDir reads the directory contents with the semantics described by fs.ReadDirFile.
+func (f *imageFSFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if f.entries == nil {
+		entries, err := f.fs.ReadDir(f.name)
+		if err != nil {
+			return nil, err
+		}
+		f.entries = entries
+	}
+
+	remaining := f.entries[f.offset:]
+	if n <= 0 {
+		f.offset = len(f.entries)
+		return remaining, nil
+	}
+
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+	f.offset += n
+	return remaining[:n], nil
+}
+
+func (f *imageFSFile) Close() error {
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	if f.reader != nil {
+		return f.reader.Close()
+	}
+	return nil
+}
+
+// imageFSFileInfo describes a single file within an imageFS. The underlying file.Metadata is available via Sys().
+type imageFSFileInfo struct {
+	name     string
+	ref      *file.Reference
+	metadata file.Metadata
+}
+
+func (i *imageFSFileInfo) Name() string {
+	return i.name
+}
+
+func (i *imageFSFileInfo) Size() int64 {
+	return i.metadata.Size
+}
+
+func (i *imageFSFileInfo) Mode() fs.FileMode {
+	return i.metadata.Mode
+}
+
+// ModTime is not tracked for files within an image, thus the zero time is always returned.
+func (i *imageFSFileInfo) ModTime() time.Time {
+	return time.Time{}
+}
+
+func (i *imageFSFileInfo) IsDir() bool {
+	return i.metadata.IsDir
+}
+
+// Sys returns the file.Metadata for the file.
+func (i *imageFSFileInfo) Sys() interface{} {
+	return i.metadata
+}
+
+func (i *imageFSFileInfo) Type() fs.FileMode {
+	return i.Mode().Type()
+}
+
+func (i *imageFSFileInfo) Info() (fs.FileInfo, error) {
+	return i, nil
+}
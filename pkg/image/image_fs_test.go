@@ -0,0 +1,196 @@
+package image
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fsTestImage(t *testing.T) *Image {
+	return newTestImage(t,
+		[]testEntry{
+			testDir("etc/"),
+			testFile("etc/hostname", "stereoscope\n"),
+			testFile("etc/removed", "gone\n"),
+			testDir("usr/"),
+			testDir("usr/lib/"),
+			testFile("usr/lib/os-release", "ID=test\n"),
+		},
+		[]testEntry{
+			testFile("etc/.wh.removed", ""),
+			testSymlink("etc/os-release", "../usr/lib/os-release"),
+			testSymlink("lib", "usr/lib"),
+			testSymlink("dead", "/nowhere"),
+			testFile("implied/dir/file.txt", "implied\n"),
+		},
+	)
+}
+
+func TestImage_FS(t *testing.T) {
+	// note: the standard conformance checks require every listed entry to be openable, thus there can be no dead links
+	img := newTestImage(t,
+		[]testEntry{
+			testDir("etc/"),
+			testFile("etc/hostname", "stereoscope\n"),
+			testDir("usr/"),
+			testDir("usr/lib/"),
+			testFile("usr/lib/os-release", "ID=test\n"),
+		},
+		[]testEntry{
+			testSymlink("etc/os-release", "../usr/lib/os-release"),
+			testSymlink("lib", "usr/lib"),
+			testFile("implied/dir/file.txt", "implied\n"),
+		},
+	)
+
+	err := fstest.TestFS(img.FS(),
+		"etc/hostname",
+		"etc/os-release",
+		"usr/lib/os-release",
+		"implied/dir/file.txt",
+	)
+	assert.NoError(t, err)
+}
+
+func TestImage_FS_ReadFile(t *testing.T) {
+	img := fsTestImage(t)
+
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+		wantErr  error
+	}{
+		{
+			name:     "regular file",
+			path:     "etc/hostname",
+			expected: "stereoscope\n",
+		},
+		{
+			name:     "through basename symlink",
+			path:     "etc/os-release",
+			expected: "ID=test\n",
+		},
+		{
+			name:     "through ancestor symlink",
+			path:     "lib/os-release",
+			expected: "ID=test\n",
+		},
+		{
+			name:    "deleted in upper layer",
+			path:    "etc/removed",
+			wantErr: fs.ErrNotExist,
+		},
+		{
+			name:    "dead link",
+			path:    "dead",
+			wantErr: fs.ErrNotExist,
+		},
+		{
+			name:    "rooted path",
+			path:    "/etc/hostname",
+			wantErr: fs.ErrInvalid,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contents, err := fs.ReadFile(img.FS(), test.path)
+			if test.wantErr != nil {
+				assert.ErrorIs(t, err, test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, string(contents))
+		})
+	}
+}
+
+func TestImage_FS_ReadDir(t *testing.T) {
+	img := fsTestImage(t)
+
+	entries, err := fs.ReadDir(img.FS(), ".")
+	require.NoError(t, err)
+
+	types := make(map[string]fs.FileMode)
+	for _, entry := range entries {
+		types[entry.Name()] = entry.Type()
+	}
+
+	assert.Equal(t, map[string]fs.FileMode{
+		"dead":    fs.ModeSymlink,
+		"etc":     fs.ModeDir,
+		"implied": fs.ModeDir,
+		"lib":     fs.ModeSymlink,
+		"usr":     fs.ModeDir,
+	}, types)
+}
+
+func TestImage_ReadDir(t *testing.T) {
+	img := fsTestImage(t)
+
+	names := func(entries []fs.DirEntry) []string {
+		var result []string
+		for _, entry := range entries {
+			result = append(result, entry.Name())
+		}
+		return result
+	}
+
+	for _, dir := range []string{"/etc", "etc", "/etc/", "/lib/../etc"} {
+		entries, err := img.ReadDir(dir)
+		require.NoError(t, err, dir)
+		assert.Equal(t, []string{"hostname", "os-release"}, names(entries), dir)
+	}
+
+	entries, err := img.ReadDir("/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dead", "etc", "implied", "lib", "usr"}, names(entries))
+
+	// links to directories are resolved
+	entries, err = img.ReadDir("/lib")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "os-release", entries[0].Name())
+	assert.True(t, entries[0].Type().IsRegular())
+
+	for _, missing := range []string{"/etc/removed", "/etc/hostname", "/nowhere", "/dead"} {
+		_, err := img.ReadDir(missing)
+		assert.ErrorIs(t, err, fs.ErrNotExist, missing)
+	}
+}
+
+func TestImage_FS_WalkDir(t *testing.T) {
+	img := fsTestImage(t)
+
+	var files []string
+	err := fs.WalkDir(img.FS(), ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	// note: linked directories are not descended into
+	assert.Equal(t, []string{"etc/hostname", "implied/dir/file.txt", "usr/lib/os-release"}, files)
+}
+
+func TestImage_FS_Stat(t *testing.T) {
+	img := fsTestImage(t)
+
+	info, err := fs.Stat(img.FS(), "lib")
+	require.NoError(t, err)
+	assert.True(t, info.IsDir(), "stat must resolve links")
+
+	info, err = fs.Stat(img.FS(), "etc/os-release")
+	require.NoError(t, err)
+	assert.Equal(t, int64(len("ID=test\n")), info.Size())
+	assert.True(t, info.Mode().IsRegular())
+}
@@ -1,15 +1,25 @@
 package image
 
 import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/anchore/stereoscope/internal/log"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	v1Types "github.com/google/go-containerregistry/pkg/v1/types"
 )
 
-// Metadata represents container image metadata.
+// Metadata represents container image metadata. Note: the JSON representation of the metadata is a stable summary
+// (see MarshalJSON) and not a direct encoding of these fields.
 type Metadata struct {
-	// ID is the sha256 of this image config json (not manifest)
+	// ID is the sha256 of this image config json (not manifest), which is the same as ConfigDigest
 	ID string
+	// ConfigDigest is the digest of the raw config blob (the "image ID" as shown by "docker images"), which is the same
+	// regardless of the source the image was read from: for docker archives this is the name of the config file and
+	// for registry and OCI sources this is the digest of the config descriptor within the manifest. Unlike the manifest
+	// digest, this does not change when the image is pushed to another registry or the layers are compressed differently.
+	ConfigDigest string
 	// Size in bytes of all the image layer content sizes (does not include config / manifest / index metadata sizes)
 	Size      int64
 	Config    v1.ConfigFile
@@ -20,6 +30,46 @@ type Metadata struct {
 	ManifestDigest string
 	RawConfig      []byte
 	RepoDigests    []string
+	// FetchStats describes how the image was obtained (nil if the image was not fetched from a daemon or registry)
+	FetchStats *FetchStats
+	// Layers is the metadata for each layer in build order (populated once the image has been read)
+	Layers []LayerMetadata
+	// ExposedPorts are the ports exposed by the image config, sorted by port number (the raw values are available
+	// within Config.Config.ExposedPorts, including any that could not be parsed)
+	ExposedPorts []Port
+	// Volumes are the sorted volume paths declared by the image config
+	Volumes []string
+	// OCILabels are the standard "org.opencontainers.image.*" provenance labels of the image config
+	OCILabels OCILabels
+	// StopSignal is the signal sent to stop the container, as declared by the image config (e.g. "SIGTERM"), which is
+	// empty when not declared (the runtime default applies)
+	StopSignal string
+	// Healthcheck is the health probe declared by the image config, which is nil when not declared
+	Healthcheck *Healthcheck
+	// Variant is the CPU variant of the image platform (e.g. "v7" for linux/arm/v7), as declared within the image config
+	// or otherwise by the index entry the image was selected from, which is empty when unknown
+	Variant string
+	// DisplayReference is how the image is labelled when it differs from what was fetched (see WithDisplayReference),
+	// which is empty when not given
+	DisplayReference string
+	// Dangling indicates the image is untagged within the docker daemon (no tags or repo digests), thus the image can
+	// only be identified by ID (see Identity)
+	Dangling bool
+}
+
+// Identity returns the most meaningful name for the image: the display reference (when given), otherwise the first
+// tag, otherwise the first repo digest, otherwise the image ID (as with dangling images).
+func (m Metadata) Identity() string {
+	if m.DisplayReference != "" {
+		return m.DisplayReference
+	}
+	if len(m.Tags) > 0 {
+		return m.Tags[0].String()
+	}
+	if len(m.RepoDigests) > 0 {
+		return m.RepoDigests[0]
+	}
+	return m.ID
 }
 
 // readImageMetadata extracts the most pertinent information from the underlying image tar.
@@ -44,10 +94,24 @@ func readImageMetadata(img v1.Image) (Metadata, error) {
 		return Metadata{}, err
 	}
 
+	// note: the config digest is computed from the raw config (rather than taken from the config descriptor) such that
+	// it is the same for all sources
+	configDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(rawConfig))
+	if configDigest != id.String() {
+		log.Debugf("image config digest=%q does not match the config name=%q", configDigest, id.String())
+	}
+
 	return Metadata{
-		ID:        id.String(),
-		Config:    *config,
-		MediaType: mediaType,
-		RawConfig: rawConfig,
+		ID:           configDigest,
+		ConfigDigest: configDigest,
+		Config:       *config,
+		MediaType:    mediaType,
+		RawConfig:    rawConfig,
+		Variant:      configVariant(rawConfig),
+		ExposedPorts: exposedPorts(config.Config),
+		Volumes:      volumes(config.Config),
+		OCILabels:    ociLabels(config.Config),
+		StopSignal:   config.Config.StopSignal,
+		Healthcheck:  healthcheck(config.Config),
 	}, nil
 }
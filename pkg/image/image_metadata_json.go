@@ -0,0 +1,128 @@
+package image
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// metadataJSON is the stable JSON representation of image Metadata. Fields are only ever added to this shape (never
+// renamed or removed), and optional fields are omitted when empty. For example:
+//
+//	{
+//	  "id": "sha256:...",
+//	  "manifestDigest": "sha256:...",
+//	  "mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+//	  "tags": ["docker.io/library/alpine:latest"],
+//	  "repoDigests": ["docker.io/library/alpine@sha256:..."],
+//	  "size": 5577102,
+//	  "platform": {"os": "linux", "architecture": "amd64"},
+//	  "config": {"created": "2022-01-01T00:00:00Z", "cmd": ["/bin/sh"], "env": ["PATH=/bin"], "exposedPorts": ["80/tcp"]},
+//	  "layers": [
+//	    {"index": 0, "digest": "sha256:...", "diffID": "sha256:...", "compressedDigest": "sha256:...", "mediaType": "...", "size": 5577102}
+//	  ]
+//	}
+type metadataJSON struct {
+	ID               string             `json:"id"`
+	ManifestDigest   string             `json:"manifestDigest,omitempty"`
+	MediaType        string             `json:"mediaType,omitempty"`
+	Tags             []string           `json:"tags"`
+	RepoDigests      []string           `json:"repoDigests"`
+	Size             int64              `json:"size"`
+	Platform         platformJSON       `json:"platform"`
+	Config           configSummaryJSON  `json:"config"`
+	Layers           []layerSummaryJSON `json:"layers"`
+	Dangling         bool               `json:"dangling,omitempty"`
+	DisplayReference string             `json:"displayReference,omitempty"`
+}
+
+// platformJSON describes the platform the image was built for.
+type platformJSON struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	OSVersion    string `json:"osVersion,omitempty"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// configSummaryJSON is a summary of the image config (the raw config is not included).
+type configSummaryJSON struct {
+	Created    string            `json:"created,omitempty"`
+	Author     string            `json:"author,omitempty"`
+	User       string            `json:"user,omitempty"`
+	WorkingDir string            `json:"workingDir,omitempty"`
+	Entrypoint []string          `json:"entrypoint,omitempty"`
+	Cmd        []string          `json:"cmd,omitempty"`
+	Env        []string          `json:"env,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	// ExposedPorts are formatted as "<number>/<protocol>" (e.g. "80/tcp")
+	ExposedPorts []string `json:"exposedPorts,omitempty"`
+	Volumes      []string `json:"volumes,omitempty"`
+}
+
+// layerSummaryJSON describes a single layer (in build order).
+type layerSummaryJSON struct {
+	Index            uint   `json:"index"`
+	Digest           string `json:"digest"`
+	DiffID           string `json:"diffID"`
+	CompressedDigest string `json:"compressedDigest,omitempty"`
+	MediaType        string `json:"mediaType,omitempty"`
+	Size             int64  `json:"size"`
+}
+
+// MarshalJSON encodes the metadata with a stable JSON shape (see metadataJSON), which is independent of the Go
+// representation of the metadata (e.g. the raw manifest and config are not included).
+func (m Metadata) MarshalJSON() ([]byte, error) {
+	doc := metadataJSON{
+		ID:             m.ID,
+		ManifestDigest: m.ManifestDigest,
+		MediaType:      string(m.MediaType),
+		Tags:           make([]string, 0, len(m.Tags)),
+		RepoDigests:    make([]string, 0, len(m.RepoDigests)),
+		Size:           m.Size,
+		Platform: platformJSON{
+			OS:           m.Config.OS,
+			Architecture: m.Config.Architecture,
+			OSVersion:    m.Config.OSVersion,
+			Variant:      m.Variant,
+		},
+		Config: configSummaryJSON{
+			Author:     m.Config.Author,
+			User:       m.Config.Config.User,
+			WorkingDir: m.Config.Config.WorkingDir,
+			Entrypoint: m.Config.Config.Entrypoint,
+			Cmd:        m.Config.Config.Cmd,
+			Env:        m.Config.Config.Env,
+			Labels:     m.Config.Config.Labels,
+		},
+		Layers:           make([]layerSummaryJSON, 0, len(m.Layers)),
+		Dangling:         m.Dangling,
+		DisplayReference: m.DisplayReference,
+	}
+
+	if !m.Config.Created.IsZero() {
+		doc.Config.Created = m.Config.Created.UTC().Format(time.RFC3339Nano)
+	}
+
+	for _, tag := range m.Tags {
+		doc.Tags = append(doc.Tags, tag.String())
+	}
+
+	doc.RepoDigests = append(doc.RepoDigests, m.RepoDigests...)
+
+	for _, port := range m.ExposedPorts {
+		doc.Config.ExposedPorts = append(doc.Config.ExposedPorts, port.String())
+	}
+	doc.Config.Volumes = m.Volumes
+
+	for _, layer := range m.Layers {
+		doc.Layers = append(doc.Layers, layerSummaryJSON{
+			Index:            layer.Index,
+			Digest:           layer.Digest,
+			DiffID:           layer.DiffID,
+			CompressedDigest: layer.CompressedDigest,
+			MediaType:        string(layer.MediaType),
+			Size:             layer.Size,
+		})
+	}
+
+	return json.Marshal(doc)
+}
@@ -0,0 +1,117 @@
+package image
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadata_MarshalJSON(t *testing.T) {
+	tag, err := name.NewTag("alpine:latest")
+	require.NoError(t, err)
+
+	metadata := Metadata{
+		ID:   "sha256:config",
+		Size: 42,
+		Config: v1.ConfigFile{
+			Architecture: "arm64",
+			OS:           "linux",
+			Created:      v1.Time{Time: time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)},
+			Config: v1.Config{
+				User:   "nobody",
+				Cmd:    []string{"/bin/sh"},
+				Env:    []string{"PATH=/bin"},
+				Labels: map[string]string{"maintainer": "someone"},
+			},
+		},
+		MediaType:      types.DockerManifestSchema2,
+		Tags:           []name.Tag{tag},
+		RawManifest:    []byte("{}"),
+		ManifestDigest: "sha256:manifest",
+		RawConfig:      []byte("{}"),
+		RepoDigests:    []string{"index.docker.io/library/alpine@sha256:manifest"},
+		FetchStats:     &FetchStats{PulledFromRegistry: true},
+		Layers: []LayerMetadata{
+			{
+				Index:            0,
+				Digest:           "sha256:diff",
+				DiffID:           "sha256:diff",
+				CompressedDigest: "sha256:compressed",
+				MediaType:        types.DockerLayer,
+				Size:             42,
+			},
+		},
+		ExposedPorts: []Port{{Number: 80, Protocol: "tcp"}},
+		Volumes:      []string{"/data"},
+	}
+
+	expected := `{
+		"id": "sha256:config",
+		"manifestDigest": "sha256:manifest",
+		"mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+		"tags": ["alpine:latest"],
+		"repoDigests": ["index.docker.io/library/alpine@sha256:manifest"],
+		"size": 42,
+		"platform": {"os": "linux", "architecture": "arm64"},
+		"config": {
+			"created": "2022-01-02T03:04:05Z",
+			"user": "nobody",
+			"cmd": ["/bin/sh"],
+			"env": ["PATH=/bin"],
+			"labels": {"maintainer": "someone"},
+			"exposedPorts": ["80/tcp"],
+			"volumes": ["/data"]
+		},
+		"layers": [
+			{
+				"index": 0,
+				"digest": "sha256:diff",
+				"diffID": "sha256:diff",
+				"compressedDigest": "sha256:compressed",
+				"mediaType": "application/vnd.docker.image.rootfs.diff.tar.gzip",
+				"size": 42
+			}
+		]
+	}`
+
+	actual, err := json.Marshal(metadata)
+	require.NoError(t, err)
+	assert.JSONEq(t, expected, string(actual))
+
+	// the representation is the same when the metadata is referenced (e.g. as a struct field)
+	actual, err = json.Marshal(&metadata)
+	require.NoError(t, err)
+	assert.JSONEq(t, expected, string(actual))
+}
+
+func TestMetadata_MarshalJSON_Empty(t *testing.T) {
+	actual, err := json.Marshal(Metadata{})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"id": "",
+		"tags": [],
+		"repoDigests": [],
+		"size": 0,
+		"platform": {"os": "", "architecture": ""},
+		"config": {},
+		"layers": []
+	}`, string(actual))
+}
+
+func TestImage_MetadataLayers(t *testing.T) {
+	img := newTestImage(t,
+		[]testEntry{testFile("/a.txt", "a")},
+		[]testEntry{testFile("/b.txt", "bb")},
+	)
+
+	require.Len(t, img.Metadata.Layers, 2)
+	for idx, layer := range img.Layers {
+		assert.Equal(t, layer.Metadata, img.Metadata.Layers[idx])
+	}
+}
@@ -9,6 +9,8 @@ import (
 
 	"github.com/go-test/deep"
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestImageAdditionalMetadata(t *testing.T) {
@@ -68,8 +70,9 @@ func TestImageAdditionalMetadata(t *testing.T) {
 			},
 			image: Image{
 				Metadata: Metadata{
-					RawConfig: []byte("some bytes"),
-					ID:        fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("some bytes"))),
+					RawConfig:    []byte("some bytes"),
+					ID:           fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("some bytes"))),
+					ConfigDigest: fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("some bytes"))),
 				},
 			},
 		},
@@ -118,3 +121,67 @@ func TestImage_SquashedTree(t *testing.T) {
 		}
 	})
 }
+
+func TestImage_ListPaths(t *testing.T) {
+	img := newTestImage(t,
+		[]testEntry{
+			testDir("etc/"),
+			testFile("etc/removed", "gone"),
+			testFile("etc/kept", "here"),
+			testFile("usr/bin/tool", "bin"),
+			testFile(".wh.ignored", ""),
+		},
+		[]testEntry{
+			testFile("etc/.wh.removed", ""),
+			testSymlink("bin", "/usr/bin"),
+		},
+	)
+
+	expected := []string{
+		"/",
+		"/bin",
+		"/etc",
+		"/etc/kept",
+		"/usr",
+		"/usr/bin",
+		"/usr/bin/tool",
+	}
+
+	assert.Equal(t, expected, img.ListPaths())
+}
+
+func TestImage_LayerOrder(t *testing.T) {
+	img := newTestImage(t,
+		[]testEntry{testFile("base.txt", "base"), testFile("shared.txt", "from base")},
+		[]testEntry{testFile("middle.txt", "middle")},
+		[]testEntry{testFile("top.txt", "top"), testFile("shared.txt", "from top")},
+	)
+
+	manifest, err := img.image.Manifest()
+	require.NoError(t, err)
+	require.Len(t, img.Layers, 3)
+	require.Len(t, manifest.Layers, 3)
+
+	// layers are base-first, matching the manifest and the config rootfs order
+	for idx, layer := range img.Layers {
+		assert.Equal(t, uint(idx), layer.Metadata.Index)
+		assert.Equal(t, manifest.Layers[idx].Digest.String(), layer.Metadata.CompressedDigest)
+		assert.Equal(t, img.Metadata.Config.RootFS.DiffIDs[idx].String(), layer.Metadata.DiffID)
+	}
+
+	assert.Same(t, img.Layers[0], img.BaseLayer())
+	assert.Same(t, img.Layers[2], img.TopLayer())
+	assert.True(t, img.BaseLayer().Tree.HasPath("/base.txt"))
+	assert.True(t, img.TopLayer().Tree.HasPath("/top.txt"))
+
+	// the top layer is applied last, thus takes precedence within the squash
+	contents, err := img.ReadFile("/shared.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "from top", string(contents))
+}
+
+func TestImage_BaseAndTopLayer_NoLayers(t *testing.T) {
+	img := newTestImage(t)
+	assert.Nil(t, img.BaseLayer())
+	assert.Nil(t, img.TopLayer())
+}
@@ -28,20 +28,46 @@ type Layer struct {
 	// Tree is a filetree that represents the structure of the layer tar contents ("diff tree")
 	Tree *filetree.FileTree
 	// SquashedTree is a filetree that represents the combination of this layers diff tree and all diff trees
-	// in lower layers relative to this one.
+	// in lower layers relative to this one. Note: this is nil for intermediate layers in low memory mode (see
+	// SetLowMemoryMode).
 	SquashedTree *filetree.FileTree
 	// fileCatalog contains all file metadata for all files in all layers (not just this layer)
 	fileCatalog *FileCatalog
+	// tarPath is the location of the cached and unzipped layer tar
+	tarPath string
+	// skipForeign indicates that the contents of the layer should not be fetched if it is a foreign layer
+	skipForeign bool
+	// unselected indicates that the contents of the layer should not be fetched (see WithLayerIndices)
+	unselected bool
+	// cache is the shared layer cache to read the layer into (if any), instead of the image content cache
+	cache *LayerCache
+	// sparse is the subset of entries to extract (see WithSparseExtraction), all entries are extracted when nil
+	sparse *sparseExtraction
+	// tracer logs each tar entry indexed within the layer (see WithTraceMode)
+	tracer log.Tracer
+	// skippedSize is the size of the content not extracted from the layer (see WithSparseExtraction)
+	skippedSize int64
+	// disabledEvents are the event types not published while reading the layer (see WithDisabledEvents)
+	disabledEvents event.TypeSet
 }
 
 // NewLayer provides a new, unread layer object.
 func NewLayer(layer v1.Layer) *Layer {
 	return &Layer{
-		layer: layer,
+		layer:  layer,
+		tracer: log.DefaultTracer(),
 	}
 }
 
 func (l *Layer) uncompressedTarCache(uncompressedLayersCacheDir string) (string, error) {
+	if l.sparse != nil {
+		return l.sparseTarCache(uncompressedLayersCacheDir)
+	}
+
+	if l.cache != nil {
+		return l.cache.tar(l.Metadata.Digest, l.writeUncompressedTar)
+	}
+
 	if uncompressedLayersCacheDir == "" {
 		return "", fmt.Errorf("no cache directory given")
 	}
@@ -52,21 +78,50 @@ func (l *Layer) uncompressedTarCache(uncompressedLayersCacheDir string) (string,
 		return tarPath, nil
 	}
 
-	rawReader, err := l.layer.Uncompressed()
+	if err := l.writeUncompressedTar(tarPath); err != nil {
+		return "", err
+	}
+	return tarPath, nil
+}
+
+// sparseTarCache writes the tar of only the entries retained by the sparse extraction to the image content cache.
+func (l *Layer) sparseTarCache(uncompressedLayersCacheDir string) (string, error) {
+	if uncompressedLayersCacheDir == "" {
+		return "", fmt.Errorf("no cache directory given")
+	}
+
+	tarPath := path.Join(uncompressedLayersCacheDir, l.Metadata.Digest+"-sparse.tar")
+	skipped, err := l.sparse.writeLayerTar(l.layer, tarPath)
 	if err != nil {
 		return "", err
 	}
+	l.skippedSize = skipped
+	return tarPath, nil
+}
 
-	fh, err := os.Create(tarPath)
+// writeUncompressedTar writes the uncompressed layer tar to the given path.
+func (l *Layer) writeUncompressedTar(tarPath string) error {
+	return writeUncompressedLayerTar(l.layer, tarPath)
+}
+
+// writeUncompressedLayerTar writes the uncompressed tar of the given layer to the given path.
+func writeUncompressedLayerTar(layer v1.Layer, tarPath string) error {
+	rawReader, err := layer.Uncompressed()
 	if err != nil {
-		return "", fmt.Errorf("unable to create layer cache dir=%q : %w", tarPath, err)
+		return err
 	}
+	defer rawReader.Close()
 
-	if _, err := io.Copy(fh, rawReader); err != nil {
-		return "", fmt.Errorf("unable to populate layer cache dir=%q : %w", tarPath, err)
+	fh, err := os.Create(tarPath)
+	if err != nil {
+		return fmt.Errorf("unable to create layer cache dir=%q : %w", tarPath, err)
 	}
+	defer fh.Close()
 
-	return tarPath, nil
+	if _, err := file.Copy(fh, rawReader); err != nil {
+		return fmt.Errorf("unable to populate layer cache dir=%q : %w", tarPath, err)
+	}
+	return nil
 }
 
 // Read parses information from the underlying layer tar into this struct. This includes layer metadata, the layer
@@ -85,17 +140,31 @@ func (l *Layer) Read(catalog *FileCatalog, imgMetadata Metadata, idx int, uncomp
 		l.Metadata.Digest,
 		l.Metadata.MediaType)
 
-	monitor := trackReadProgress(l.Metadata)
+	if l.unselected {
+		log.Debugf("skipping unselected layer=%q", l.Metadata.Digest)
+		l.Metadata.Skipped = true
+		return nil
+	}
+
+	if l.skipForeign && l.Metadata.IsForeign() {
+		log.Warnf("skipping foreign layer=%q (urls=%+v)", l.Metadata.Digest, l.Metadata.URLs)
+		l.Metadata.Skipped = true
+		return nil
+	}
+
+	monitor := trackReadProgress(l.Metadata, l.disabledEvents)
 
 	tarFilePath, err := l.uncompressedTarCache(uncompressedLayersCacheDir)
 	if err != nil {
 		return err
 	}
+	l.tarPath = tarFilePath
 
 	l.indexedContent, err = file.NewTarIndex(tarFilePath, l.indexer(monitor))
 	if err != nil {
 		return fmt.Errorf("failed to read layer=%q tar : %w", l.Metadata.Digest, err)
 	}
+	l.Metadata.Size += l.skippedSize
 
 	monitor.SetCompleted()
 
@@ -111,6 +180,9 @@ func (l *Layer) FileContents(path file.Path) (io.ReadCloser, error) {
 // FileContentsFromSquash reads the file contents for the given path from the underlying layer blob, relative to the layers squashed file tree.
 // An error is returned if there is no file at the given path and layer or the read operation cannot continue.
 func (l *Layer) FileContentsFromSquash(path file.Path) (io.ReadCloser, error) {
+	if l.SquashedTree == nil {
+		return nil, ErrLayerSquashUnavailable
+	}
 	return fetchFileContentsByPath(l.SquashedTree, l.fileCatalog, path)
 }
 
@@ -129,6 +201,9 @@ func (l *Layer) FilesByMIMEType(mimeTypes ...string) ([]file.Reference, error) {
 
 // FilesByMIMETypeFromSquash returns file references for files that match at least one of the given MIME types relative to the squashed file tree representation.
 func (l *Layer) FilesByMIMETypeFromSquash(mimeTypes ...string) ([]file.Reference, error) {
+	if l.SquashedTree == nil {
+		return nil, ErrLayerSquashUnavailable
+	}
 	var refs []file.Reference
 	for _, ty := range mimeTypes {
 		refsForType, err := fetchFilesByMIMEType(l.SquashedTree, l.fileCatalog, ty)
@@ -142,6 +217,11 @@ func (l *Layer) FilesByMIMETypeFromSquash(mimeTypes ...string) ([]file.Reference
 
 func (l *Layer) indexer(monitor *progress.Manual) file.TarIndexVisitor {
 	return func(index file.TarIndexEntry) error {
+		// note: the entry limit is checked before the entry is added to the tree, bounding the size of the trees
+		if err := l.fileCatalog.checkEntryLimit(); err != nil {
+			return err
+		}
+
 		var err error
 		var entry = index.ToTarFileEntry()
 
@@ -163,6 +243,14 @@ func (l *Layer) indexer(monitor *progress.Manual) file.TarIndexVisitor {
 		//
 		// In summary: the set of all FileTrees can have NON-leaf nodes that don't exist in the FileCatalog, but
 		// the FileCatalog should NEVER have entries that don't appear in one (or more) FileTree(s).
+		if l.tracer.Enabled() {
+			l.tracer.Tracef("layer=%q entry: path=%q type=%q link=%q size=%d sequence=%d",
+				l.Metadata.Digest, metadata.Path, metadata.TypeFlag, metadata.Linkname, metadata.Size, entry.Sequence)
+			if l.Tree.HasPath(file.Path(metadata.Path)) {
+				l.tracer.Tracef("layer=%q path=%q appears more than once within the layer, the later entry wins", l.Metadata.Digest, metadata.Path)
+			}
+		}
+
 		var fileReference *file.Reference
 		switch metadata.TypeFlag {
 		case tar.TypeSymlink:
@@ -191,21 +279,21 @@ func (l *Layer) indexer(monitor *progress.Manual) file.TarIndexVisitor {
 		}
 
 		l.Metadata.Size += metadata.Size
-		l.fileCatalog.Add(*fileReference, metadata, l, index.Open)
+		l.fileCatalog.Add(*fileReference, metadata, l, index.Opener())
 
 		monitor.N++
 		return nil
 	}
 }
 
-func trackReadProgress(metadata LayerMetadata) *progress.Manual {
+func trackReadProgress(metadata LayerMetadata, disabled event.TypeSet) *progress.Manual {
 	p := &progress.Manual{}
 
-	bus.Publish(partybus.Event{
+	bus.PublishUnless(partybus.Event{
 		Type:   event.ReadLayer,
 		Source: metadata,
 		Value:  progress.Monitorable(p),
-	})
+	}, disabled)
 
 	return p
 }
@@ -0,0 +1,68 @@
+package image
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrLayerNotFound is returned when there is no layer within the image with the requested digest.
+type ErrLayerNotFound struct {
+	Digest string
+}
+
+func (e *ErrLayerNotFound) Error() string {
+	return fmt.Sprintf("no layer found with digest=%q", e.Digest)
+}
+
+// OpenLayerBlob returns the contents of the layer blob with the given digest (as referenced by the image manifest),
+// as stored by the image source (typically compressed). The caller is responsible for closing the returned reader.
+func (i *Image) OpenLayerBlob(digest string) (io.ReadCloser, error) {
+	digest = normalizeLayerDigest(digest)
+	for _, layer := range i.Layers {
+		if layer.Metadata.CompressedDigest != digest {
+			continue
+		}
+		if layer.Metadata.CompressedDigest == layer.Metadata.DiffID {
+			// the layer is stored uncompressed (e.g. within a docker-archive)
+			return layer.openTar()
+		}
+		return layer.layer.Compressed()
+	}
+	return nil, &ErrLayerNotFound{Digest: digest}
+}
+
+// OpenLayerTar returns the uncompressed layer tar for the layer with the given diff ID (as referenced by the image
+// config rootfs). The caller is responsible for closing the returned reader.
+func (i *Image) OpenLayerTar(diffID string) (io.ReadCloser, error) {
+	diffID = normalizeLayerDigest(diffID)
+	for _, layer := range i.Layers {
+		if layer.Metadata.DiffID == diffID {
+			return layer.openTar()
+		}
+	}
+	return nil, &ErrLayerNotFound{Digest: diffID}
+}
+
+// openTar returns the uncompressed layer tar, preferring the cached tar (when available) over the image source.
+func (l *Layer) openTar() (io.ReadCloser, error) {
+	if l.tarPath != "" {
+		fh, err := os.Open(l.tarPath)
+		if err == nil {
+			return fh, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("unable to open layer=%q tar: %w", l.Metadata.Digest, err)
+		}
+	}
+	return l.layer.Uncompressed()
+}
+
+// normalizeLayerDigest assumes the sha256 algorithm for digests without an algorithm prefix.
+func normalizeLayerDigest(digest string) string {
+	if digest != "" && !strings.Contains(digest, ":") {
+		return "sha256:" + digest
+	}
+	return digest
+}
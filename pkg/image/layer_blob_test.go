@@ -0,0 +1,58 @@
+package image
+
+import (
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImage_OpenLayerBlob(t *testing.T) {
+	entries := []testEntry{testDir("/etc"), testFile("/etc/hosts", "127.0.0.1 localhost\n")}
+	expected := testLayerTar(t, entries)
+	img := newTestImage(t, entries)
+	require.Len(t, img.Layers, 1)
+	metadata := img.Layers[0].Metadata
+
+	for _, digest := range []string{metadata.CompressedDigest, strings.TrimPrefix(metadata.CompressedDigest, "sha256:")} {
+		reader, err := img.OpenLayerBlob(digest)
+		require.NoError(t, err)
+
+		// the blob is stored compressed
+		uncompressed, err := gzip.NewReader(reader)
+		require.NoError(t, err)
+		actual, err := ioutil.ReadAll(uncompressed)
+		require.NoError(t, err)
+		require.NoError(t, reader.Close())
+		assert.Equal(t, expected, actual)
+	}
+
+	_, err := img.OpenLayerBlob(metadata.DiffID)
+	var notFound *ErrLayerNotFound
+	require.True(t, errors.As(err, &notFound), "unexpected error: %+v", err)
+	assert.Equal(t, metadata.DiffID, notFound.Digest)
+}
+
+func TestImage_OpenLayerTar(t *testing.T) {
+	first := []testEntry{testDir("/etc"), testFile("/etc/hosts", "127.0.0.1 localhost\n")}
+	second := []testEntry{testFile("/etc/hostname", "stereoscope\n")}
+	img := newTestImage(t, first, second)
+	require.Len(t, img.Layers, 2)
+
+	for idx, entries := range [][]testEntry{first, second} {
+		reader, err := img.OpenLayerTar(img.Layers[idx].Metadata.DiffID)
+		require.NoError(t, err)
+		actual, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		require.NoError(t, reader.Close())
+		assert.Equal(t, testLayerTar(t, entries), actual)
+	}
+
+	_, err := img.OpenLayerTar("sha256:0000000000000000000000000000000000000000000000000000000000000000")
+	var notFound *ErrLayerNotFound
+	assert.True(t, errors.As(err, &notFound), "unexpected error: %+v", err)
+}
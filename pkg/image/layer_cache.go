@@ -0,0 +1,113 @@
+package image
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/anchore/stereoscope/internal/log"
+)
+
+// LayerCache is a directory of uncompressed layer tars that can be shared between images, so that layers common to
+// several images (e.g. from a shared base image) are only fetched and extracted once. A LayerCache is safe for
+// concurrent use by images being read in parallel.
+type LayerCache struct {
+	dir   string
+	lock  sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// NewLayerCache creates a layer cache within the given (existing) directory. The caller is responsible for removing
+// the directory once all images using the cache are no longer needed.
+func NewLayerCache(dir string) *LayerCache {
+	return &LayerCache{
+		dir:   dir,
+		locks: make(map[string]*sync.Mutex),
+	}
+}
+
+// WithLayerCache reads the image layers into the given shared layer cache instead of the image content cache.
+func WithLayerCache(cache *LayerCache) AdditionalMetadata {
+	return func(image *Image) error {
+		image.layerCache = cache
+		return nil
+	}
+}
+
+// Prefetch fetches and uncompresses every layer of the given image into the cache without reading the image (no file
+// trees or catalogs are built), so that images read later with the same cache (see WithLayerCache) skip fetching the
+// cached layers. Foreign layers are skipped unless allowed for the image (see WithForeignLayers).
+func (c *LayerCache) Prefetch(img *Image) error {
+	// apply the options given to NewImage (e.g. whether foreign layers are allowed)
+	if err := img.applyOverrideMetadata(); err != nil {
+		return err
+	}
+
+	layers, err := img.image.Layers()
+	if err != nil {
+		return err
+	}
+
+	for idx, layer := range layers {
+		diffID, err := layer.DiffID()
+		if err != nil {
+			return fmt.Errorf("unable to determine diff id of layer=%d: %w", idx, err)
+		}
+
+		if img.skipForeignLayers {
+			mediaType, err := layer.MediaType()
+			if err != nil {
+				return fmt.Errorf("unable to determine media type of layer=%q: %w", diffID, err)
+			}
+			if !mediaType.IsDistributable() {
+				log.Debugf("skipping prefetch of foreign layer=%q", diffID)
+				continue
+			}
+		}
+
+		_, err = c.tar(diffID.String(), func(path string) error {
+			return writeUncompressedLayerTar(layer, path)
+		})
+		if err != nil {
+			return fmt.Errorf("unable to prefetch layer=%q: %w", diffID, err)
+		}
+	}
+	return nil
+}
+
+// digestLock returns the lock guarding the cached tar for the given layer digest.
+func (c *LayerCache) digestLock(digest string) *sync.Mutex {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	lock, exists := c.locks[digest]
+	if !exists {
+		lock = &sync.Mutex{}
+		c.locks[digest] = lock
+	}
+	return lock
+}
+
+// tar returns the path to the cached tar for the given layer digest, populating the cache with the given writer if the
+// layer has not yet been cached. The tar is only visible within the cache once it has been completely written.
+func (c *LayerCache) tar(digest string, write func(path string) error) (string, error) {
+	lock := c.digestLock(digest)
+	lock.Lock()
+	defer lock.Unlock()
+
+	tarPath := filepath.Join(c.dir, digest+".tar")
+	if _, err := os.Stat(tarPath); err == nil {
+		return tarPath, nil
+	}
+
+	partialPath := tarPath + ".partial"
+	if err := write(partialPath); err != nil {
+		_ = os.Remove(partialPath)
+		return "", err
+	}
+	if err := os.Rename(partialPath, tarPath); err != nil {
+		return "", fmt.Errorf("unable to populate layer cache=%q : %w", tarPath, err)
+	}
+	return tarPath, nil
+}
@@ -0,0 +1,115 @@
+package image
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayerCache_SharedBetweenImages(t *testing.T) {
+	raw := testLayerTar(t, []testEntry{testDir("/etc"), testFile("/etc/hosts", "127.0.0.1 localhost\n")})
+
+	var opened int32
+	baseLayer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		atomic.AddInt32(&opened, 1)
+		return ioutil.NopCloser(bytes.NewReader(raw)), nil
+	})
+	require.NoError(t, err)
+
+	// the digests of the layer are computed up front, so only reads of the layer for the cache are counted
+	_, err = baseLayer.DiffID()
+	require.NoError(t, err)
+	_, err = baseLayer.Digest()
+	require.NoError(t, err)
+	atomic.StoreInt32(&opened, 0)
+
+	cache := NewLayerCache(t.TempDir())
+
+	var images []*Image
+	for _, contents := range []string{"first", "second", "third"} {
+		topLayer, err := tarball.LayerFromOpener(func(contents string) tarball.Opener {
+			return func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(bytes.NewReader(testLayerTar(t, []testEntry{testFile("/etc/hostname", contents)}))), nil
+			}
+		}(contents))
+		require.NoError(t, err)
+
+		var v1Image v1.Image
+		v1Image, err = mutate.AppendLayers(empty.Image, baseLayer, topLayer)
+		require.NoError(t, err)
+		images = append(images, NewImage(v1Image, t.TempDir()))
+	}
+
+	var wg sync.WaitGroup
+	for _, img := range images {
+		wg.Add(1)
+		go func(img *Image) {
+			defer wg.Done()
+			assert.NoError(t, img.Read(WithLayerCache(cache)))
+		}(img)
+	}
+	wg.Wait()
+
+	// the shared base layer is only extracted once
+	assert.Equal(t, int32(1), atomic.LoadInt32(&opened))
+	for _, img := range images {
+		require.Len(t, img.Layers, 2)
+		assert.Equal(t, images[0].Layers[0].tarPath, img.Layers[0].tarPath)
+
+		reader, err := img.FileContentsFromSquash("/etc/hosts")
+		require.NoError(t, err)
+		actual, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, "127.0.0.1 localhost\n", string(actual))
+	}
+	assert.NotEqual(t, images[0].Layers[1].tarPath, images[1].Layers[1].tarPath)
+}
+
+func TestLayerCache_Prefetch(t *testing.T) {
+	raw := testLayerTar(t, []testEntry{testDir("/etc"), testFile("/etc/hosts", "127.0.0.1 localhost\n")})
+
+	var opened int32
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		atomic.AddInt32(&opened, 1)
+		return ioutil.NopCloser(bytes.NewReader(raw)), nil
+	})
+	require.NoError(t, err)
+
+	// the digests of the layer are computed up front, so only reads of the layer for the cache are counted
+	diffID, err := layer.DiffID()
+	require.NoError(t, err)
+	_, err = layer.Digest()
+	require.NoError(t, err)
+	atomic.StoreInt32(&opened, 0)
+
+	v1Image, err := mutate.AppendLayers(empty.Image, layer)
+	require.NoError(t, err)
+
+	cache := NewLayerCache(t.TempDir())
+	require.NoError(t, cache.Prefetch(NewImage(v1Image, t.TempDir())))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&opened))
+	assert.FileExists(t, filepath.Join(cache.dir, diffID.String()+".tar"))
+
+	// prefetching again (or reading the image) is served from the cache
+	require.NoError(t, cache.Prefetch(NewImage(v1Image, t.TempDir())))
+	img := NewImage(v1Image, t.TempDir())
+	require.NoError(t, img.Read(WithLayerCache(cache)))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&opened))
+
+	reader, err := img.FileContentsFromSquash("/etc/hosts")
+	require.NoError(t, err)
+	actual, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1 localhost\n", string(actual))
+}
@@ -0,0 +1,31 @@
+package image
+
+import (
+	"github.com/anchore/stereoscope/internal/log"
+)
+
+// LayerCommands returns the command that created each layer (the "created_by" value from the image config history),
+// keyed by the layer diff ID. History entries for instructions that did not produce a layer (marked as
+// "empty_layer", e.g. ENV or CMD) are skipped so that the remaining entries align with the layers in order. Layers
+// without a corresponding history entry (or with an empty command) are not included.
+func (i *Image) LayerCommands() map[string]string {
+	diffIDs := i.Metadata.Config.RootFS.DiffIDs
+	commands := make(map[string]string)
+
+	var idx int
+	for _, history := range i.Metadata.Config.History {
+		if history.EmptyLayer {
+			continue
+		}
+		if idx >= len(diffIDs) {
+			log.Debugf("image history has more layer entries than layers (%d), ignoring the remaining history", len(diffIDs))
+			break
+		}
+		if history.CreatedBy != "" {
+			commands[diffIDs[idx].String()] = history.CreatedBy
+		}
+		idx++
+	}
+
+	return commands
+}
@@ -0,0 +1,87 @@
+package image
+
+import (
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImage_LayerCommands(t *testing.T) {
+	first := v1.Hash{Algorithm: "sha256", Hex: "1111111111111111111111111111111111111111111111111111111111111111"}
+	second := v1.Hash{Algorithm: "sha256", Hex: "2222222222222222222222222222222222222222222222222222222222222222"}
+
+	tests := []struct {
+		name     string
+		diffIDs  []v1.Hash
+		history  []v1.History
+		expected map[string]string
+	}{
+		{
+			name:    "empty layers are skipped",
+			diffIDs: []v1.Hash{first, second},
+			history: []v1.History{
+				{CreatedBy: "/bin/sh -c #(nop) ADD file:abc in / "},
+				{CreatedBy: `/bin/sh -c #(nop)  CMD ["/bin/sh"]`, EmptyLayer: true},
+				{CreatedBy: "ENV PATH=/usr/local/bin", EmptyLayer: true},
+				{CreatedBy: "RUN apk add curl"},
+				{CreatedBy: "ENTRYPOINT [\"curl\"]", EmptyLayer: true},
+			},
+			expected: map[string]string{
+				first.String():  "/bin/sh -c #(nop) ADD file:abc in / ",
+				second.String(): "RUN apk add curl",
+			},
+		},
+		{
+			name:    "missing history",
+			diffIDs: []v1.Hash{first, second},
+			history: []v1.History{
+				{CreatedBy: "ADD rootfs.tar /"},
+			},
+			expected: map[string]string{
+				first.String(): "ADD rootfs.tar /",
+			},
+		},
+		{
+			name:    "extra history",
+			diffIDs: []v1.Hash{first},
+			history: []v1.History{
+				{CreatedBy: "ADD rootfs.tar /"},
+				{CreatedBy: "RUN something"},
+			},
+			expected: map[string]string{
+				first.String(): "ADD rootfs.tar /",
+			},
+		},
+		{
+			name:    "layer without a command",
+			diffIDs: []v1.Hash{first, second},
+			history: []v1.History{
+				{Comment: "imported"},
+				{CreatedBy: "RUN something"},
+			},
+			expected: map[string]string{
+				second.String(): "RUN something",
+			},
+		},
+		{
+			name:     "no layers",
+			history:  []v1.History{{CreatedBy: "CMD [\"/app\"]", EmptyLayer: true}},
+			expected: map[string]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			img := &Image{
+				Metadata: Metadata{
+					Config: v1.ConfigFile{
+						RootFS:  v1.RootFS{Type: "layers", DiffIDs: test.diffIDs},
+						History: test.history,
+					},
+				},
+			}
+			assert.Equal(t, test.expected, img.LayerCommands())
+		})
+	}
+}
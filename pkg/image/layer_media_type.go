@@ -0,0 +1,66 @@
+package image
+
+import (
+	"strings"
+)
+
+const (
+	// dockerLayerMediaTypePrefix is shared by all docker layer media types (e.g. "...rootfs.diff.tar.gzip" and
+	// "...rootfs.foreign.diff.tar.gzip")
+	dockerLayerMediaTypePrefix = "application/vnd.docker.image.rootfs."
+	// ociLayerMediaTypePrefix is shared by all OCI layer media types (e.g. "...layer.v1.tar+gzip" and
+	// "...layer.nondistributable.v1.tar+zstd")
+	ociLayerMediaTypePrefix = "application/vnd.oci.image.layer."
+)
+
+const (
+	UnknownLayerCompression LayerCompression = iota
+	UncompressedLayerCompression
+	GzipLayerCompression
+	ZstdLayerCompression
+)
+
+var layerCompressionStr = [...]string{
+	"Unknown",
+	"Uncompressed",
+	"Gzip",
+	"Zstd",
+}
+
+// LayerCompression is the normalized compression of a layer blob, which is the same for the docker and OCI variants of
+// a layer media type (e.g. "application/vnd.docker.image.rootfs.diff.tar.gzip" and
+// "application/vnd.oci.image.layer.v1.tar+gzip" are both GzipLayerCompression).
+type LayerCompression uint8
+
+// LayerCompressionFromMediaType classifies the given docker or OCI layer media type (including foreign and
+// non-distributable layers) by the compression of the layer blob. Media type parameters and casing are ignored. Media
+// types that do not describe a layer, or use an unknown compression, are UnknownLayerCompression.
+func LayerCompressionFromMediaType(mediaType string) LayerCompression {
+	if idx := strings.Index(mediaType, ";"); idx >= 0 {
+		mediaType = mediaType[:idx]
+	}
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+	if !strings.HasPrefix(mediaType, dockerLayerMediaTypePrefix) && !strings.HasPrefix(mediaType, ociLayerMediaTypePrefix) {
+		return UnknownLayerCompression
+	}
+
+	// note: docker suffixes the compression with a "." while OCI uses a "+" (e.g. ".tar.gzip" vs ".tar+gzip")
+	switch {
+	case strings.HasSuffix(mediaType, ".tar"):
+		return UncompressedLayerCompression
+	case strings.HasSuffix(mediaType, ".tar.gzip"), strings.HasSuffix(mediaType, ".tar+gzip"):
+		return GzipLayerCompression
+	case strings.HasSuffix(mediaType, ".tar.zstd"), strings.HasSuffix(mediaType, ".tar+zstd"):
+		return ZstdLayerCompression
+	}
+	return UnknownLayerCompression
+}
+
+// String returns a convenient display string for the layer compression.
+func (c LayerCompression) String() string {
+	if int(c) >= len(layerCompressionStr) {
+		return layerCompressionStr[UnknownLayerCompression]
+	}
+	return layerCompressionStr[c]
+}
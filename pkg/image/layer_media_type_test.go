@@ -0,0 +1,100 @@
+package image
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayerCompressionFromMediaType(t *testing.T) {
+	tests := []struct {
+		mediaType string
+		expected  LayerCompression
+	}{
+		// gzip
+		{
+			mediaType: "application/vnd.docker.image.rootfs.diff.tar.gzip",
+			expected:  GzipLayerCompression,
+		},
+		{
+			mediaType: "application/vnd.docker.image.rootfs.foreign.diff.tar.gzip",
+			expected:  GzipLayerCompression,
+		},
+		{
+			mediaType: "application/vnd.oci.image.layer.v1.tar+gzip",
+			expected:  GzipLayerCompression,
+		},
+		{
+			mediaType: "application/vnd.oci.image.layer.nondistributable.v1.tar+gzip",
+			expected:  GzipLayerCompression,
+		},
+		// zstd
+		{
+			mediaType: "application/vnd.docker.image.rootfs.diff.tar.zstd",
+			expected:  ZstdLayerCompression,
+		},
+		{
+			mediaType: "application/vnd.oci.image.layer.v1.tar+zstd",
+			expected:  ZstdLayerCompression,
+		},
+		{
+			mediaType: "application/vnd.oci.image.layer.nondistributable.v1.tar+zstd",
+			expected:  ZstdLayerCompression,
+		},
+		{
+			mediaType: "Application/VND.OCI.Image.Layer.v1.tar+zstd; charset=binary",
+			expected:  ZstdLayerCompression,
+		},
+		// uncompressed
+		{
+			mediaType: "application/vnd.docker.image.rootfs.diff.tar",
+			expected:  UncompressedLayerCompression,
+		},
+		{
+			mediaType: "application/vnd.oci.image.layer.v1.tar",
+			expected:  UncompressedLayerCompression,
+		},
+		{
+			mediaType: "application/vnd.oci.image.layer.nondistributable.v1.tar",
+			expected:  UncompressedLayerCompression,
+		},
+		// unknown
+		{
+			mediaType: "application/vnd.oci.image.layer.v1.tar+bzip2",
+			expected:  UnknownLayerCompression,
+		},
+		{
+			mediaType: "application/vnd.oci.image.manifest.v1+json",
+			expected:  UnknownLayerCompression,
+		},
+		{
+			mediaType: "application/x-tar",
+			expected:  UnknownLayerCompression,
+		},
+		{
+			mediaType: "",
+			expected:  UnknownLayerCompression,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.mediaType, func(t *testing.T) {
+			assert.Equal(t, test.expected, LayerCompressionFromMediaType(test.mediaType))
+		})
+	}
+}
+
+func TestLayerCompression_String(t *testing.T) {
+	assert.Equal(t, "Gzip", GzipLayerCompression.String())
+	assert.Equal(t, "Zstd", ZstdLayerCompression.String())
+	assert.Equal(t, "Uncompressed", UncompressedLayerCompression.String())
+	assert.Equal(t, "Unknown", LayerCompression(42).String())
+}
+
+func TestImage_LayerCompression(t *testing.T) {
+	img := newTestImage(t, []testEntry{testFile("etc/passwd", "root")})
+
+	require.Len(t, img.Layers, 1)
+	assert.Equal(t, "application/vnd.docker.image.rootfs.diff.tar.gzip", string(img.Layers[0].Metadata.MediaType))
+	assert.Equal(t, GzipLayerCompression, img.Layers[0].Metadata.Compression)
+}
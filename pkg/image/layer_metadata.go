@@ -1,18 +1,45 @@
 package image
 
 import (
+	"bytes"
+
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	v1Types "github.com/google/go-containerregistry/pkg/v1/types"
 )
 
 // Metadata represents container layer metadata.
 type LayerMetadata struct {
+	// Index is the position of the layer within the image manifest (and the image config rootfs diff IDs), which is
+	// base-first: 0 is the base layer and the last index is the top layer (the last layer applied).
 	Index uint
-	// Digest is the sha256 digest of the layer contents (the docker "diff id")
-	Digest    string
+	// Digest is the sha256 digest of the layer contents (the docker "diff id", the same as DiffID)
+	Digest string
+	// DiffID is the digest of the uncompressed layer contents (as referenced in the image config rootfs)
+	DiffID string
+	// CompressedDigest is the digest of the layer blob as referenced in the image manifest (which is the same as the
+	// diff ID for layers that are not compressed, e.g. layers within a docker-archive)
+	CompressedDigest string
+	// MediaType is the raw layer media type (as declared by the image manifest)
 	MediaType v1Types.MediaType
+	// Compression is the normalized compression of the layer blob derived from the media type, which is the same for
+	// the docker and OCI variants of a media type (see LayerCompressionFromMediaType)
+	Compression LayerCompression
 	// Size in bytes of the layer content size
 	Size int64
+	// CompressedSize is the size in bytes of the layer blob as referenced in the image manifest (zero when unknown,
+	// e.g. for images without a distribution manifest, such as those from a docker-archive)
+	CompressedSize int64
+	// URLs are the external locations of the layer blob declared by the image manifest (only for foreign layers)
+	URLs []string
+	// Skipped indicates that the layer contents were not fetched (e.g. a foreign layer that is not allowed to be
+	// fetched, see WithForeignLayers, or a layer that was not selected, see WithLayerIndices), in which case the layer
+	// file tree is empty.
+	Skipped bool
+}
+
+// IsForeign indicates if the layer is not distributed with the image, but is instead fetched from external URLs.
+func (m LayerMetadata) IsForeign() bool {
+	return !m.MediaType.IsDistributable()
 }
 
 // newLayerMetadata aggregates pertinent layer metadata information.
@@ -22,11 +49,56 @@ func newLayerMetadata(imgMetadata Metadata, layer v1.Layer, idx int) (LayerMetad
 		return LayerMetadata{}, err
 	}
 
+	compressedDigest, err := compressedLayerDigest(imgMetadata, layer, idx)
+	if err != nil {
+		return LayerMetadata{}, err
+	}
+
 	// digest = diff-id = a digest of the uncompressed layer content
 	diffIDHash := imgMetadata.Config.RootFS.DiffIDs[idx]
 	return LayerMetadata{
-		Index:     uint(idx),
-		Digest:    diffIDHash.String(),
-		MediaType: mediaType,
+		Index:            uint(idx),
+		Digest:           diffIDHash.String(),
+		DiffID:           diffIDHash.String(),
+		CompressedDigest: compressedDigest,
+		CompressedSize:   compressedLayerSize(imgMetadata, idx),
+		MediaType:        mediaType,
+		Compression:      LayerCompressionFromMediaType(string(mediaType)),
+		URLs:             foreignLayerURLs(imgMetadata, idx),
 	}, nil
 }
+
+// compressedLayerDigest returns the digest of the layer blob, preferring the image manifest (when available) over the
+// layer itself, since some layers can only provide a digest by compressing the layer contents.
+func compressedLayerDigest(imgMetadata Metadata, layer v1.Layer, idx int) (string, error) {
+	if descriptor := manifestLayer(imgMetadata, idx); descriptor != nil {
+		return descriptor.Digest.String(), nil
+	}
+
+	digest, err := layer.Digest()
+	if err != nil {
+		return "", err
+	}
+	return digest.String(), nil
+}
+
+// compressedLayerSize returns the size of the layer blob from the image manifest, or zero if there is no manifest (the
+// layer itself is not asked, since some layers can only provide a size by compressing the layer contents).
+func compressedLayerSize(imgMetadata Metadata, idx int) int64 {
+	if descriptor := manifestLayer(imgMetadata, idx); descriptor != nil {
+		return descriptor.Size
+	}
+	return 0
+}
+
+// manifestLayer returns the descriptor of the layer at the given index from the image manifest (if available).
+func manifestLayer(imgMetadata Metadata, idx int) *v1.Descriptor {
+	if len(imgMetadata.RawManifest) == 0 {
+		return nil
+	}
+	manifest, err := v1.ParseManifest(bytes.NewReader(imgMetadata.RawManifest))
+	if err != nil || len(manifest.Layers) != len(imgMetadata.Config.RootFS.DiffIDs) {
+		return nil
+	}
+	return &manifest.Layers[idx]
+}
@@ -0,0 +1,73 @@
+package image
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayerMetadata_Digests(t *testing.T) {
+	img := newTestV1Image(t,
+		[]testEntry{testFile("first.txt", "first")},
+		[]testEntry{testFile("second.txt", "second")},
+	)
+
+	config, err := img.ConfigFile()
+	require.NoError(t, err)
+	layers, err := img.Layers()
+	require.NoError(t, err)
+
+	var compressedDigests []string
+	for _, layer := range layers {
+		digest, err := layer.Digest()
+		require.NoError(t, err)
+		compressedDigests = append(compressedDigests, digest.String())
+	}
+
+	// a manifest that references the layers by diff ID (as is generated for a docker-archive)
+	manifest, err := img.Manifest()
+	require.NoError(t, err)
+	uncompressedManifest := manifest.DeepCopy()
+	for idx := range uncompressedManifest.Layers {
+		uncompressedManifest.Layers[idx].Digest = config.RootFS.DiffIDs[idx]
+	}
+	rawUncompressedManifest, err := json.Marshal(uncompressedManifest)
+	require.NoError(t, err)
+
+	var diffIDs []string
+	for _, diffID := range config.RootFS.DiffIDs {
+		diffIDs = append(diffIDs, diffID.String())
+	}
+
+	tests := []struct {
+		name                      string
+		metadata                  []AdditionalMetadata
+		expectedCompressedDigests []string
+	}{
+		{
+			name:                      "digests from the layers",
+			expectedCompressedDigests: compressedDigests,
+		},
+		{
+			name:                      "digests from the manifest",
+			metadata:                  []AdditionalMetadata{WithManifest(rawUncompressedManifest)},
+			expectedCompressedDigests: diffIDs,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := NewImage(img, t.TempDir(), test.metadata...)
+			require.NoError(t, result.Read())
+			require.Len(t, result.Layers, 2)
+
+			for idx, layer := range result.Layers {
+				assert.Equal(t, diffIDs[idx], layer.Metadata.DiffID)
+				assert.Equal(t, layer.Metadata.DiffID, layer.Metadata.Digest)
+				assert.Equal(t, test.expectedCompressedDigests[idx], layer.Metadata.CompressedDigest)
+			}
+		})
+	}
+}
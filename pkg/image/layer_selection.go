@@ -0,0 +1,75 @@
+package image
+
+import (
+	"fmt"
+)
+
+// layerSelection describes the subset of layers to read (see WithLayerIndices and WithLayerDiffIDs).
+type layerSelection struct {
+	indices []int
+	diffIDs []string
+}
+
+// WithLayerIndices reads only the layers at the given indices (in build order, where 0 is the base layer), for example
+// to analyze only what an application added on top of a base image. Negative indices count from the top layer (-1 is
+// the top layer). The layers that are not selected are not fetched nor extracted: they are marked as skipped within
+// the layer metadata and have empty file trees. The image squash is thus the merge of only the selected layers, where
+// whiteouts within the selected layers have no effect on the content of skipped layers (which is absent) and whiteouts
+// within skipped layers are ignored. Image.Read fails if an index is out of range. This may be combined with
+// WithLayerDiffIDs, in which case layers selected by either are read.
+func WithLayerIndices(indices ...int) AdditionalMetadata {
+	return func(image *Image) error {
+		if image.layerSelection == nil {
+			image.layerSelection = &layerSelection{}
+		}
+		image.layerSelection.indices = append(image.layerSelection.indices, indices...)
+		return nil
+	}
+}
+
+// WithLayerDiffIDs reads only the layers with the given diff IDs (e.g. "sha256:..."), see WithLayerIndices. Image.Read
+// fails if a diff ID is not found within the image.
+func WithLayerDiffIDs(diffIDs ...string) AdditionalMetadata {
+	return func(image *Image) error {
+		if image.layerSelection == nil {
+			image.layerSelection = &layerSelection{}
+		}
+		image.layerSelection.diffIDs = append(image.layerSelection.diffIDs, diffIDs...)
+		return nil
+	}
+}
+
+// resolve returns the set of selected layer indices for an image with the given layer diff IDs (in build order). A nil
+// set is returned when no selection was made (all layers are read).
+func (s *layerSelection) resolve(diffIDs []string) (map[int]bool, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	selected := make(map[int]bool)
+	for _, idx := range s.indices {
+		resolved := idx
+		if resolved < 0 {
+			resolved += len(diffIDs)
+		}
+		if resolved < 0 || resolved >= len(diffIDs) {
+			return nil, fmt.Errorf("selected layer index=%d is out of range (the image has %d layers)", idx, len(diffIDs))
+		}
+		selected[resolved] = true
+	}
+
+	for _, diffID := range s.diffIDs {
+		var found bool
+		for idx, candidate := range diffIDs {
+			if candidate == diffID {
+				selected[idx] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("selected layer diffID=%q is not within the image", diffID)
+		}
+	}
+
+	return selected, nil
+}
@@ -0,0 +1,90 @@
+package image
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImage_Read_LayerSelection(t *testing.T) {
+	layers := [][]testEntry{
+		{testFile("base/os", "base"), testFile("etc/config", "base")},
+		{testFile("app/bin", "app"), testFile("etc/.wh.config", "")},
+		{testFile("app/data", "data"), testFile("app/.wh.bin", "")},
+	}
+
+	diffIDs := func(t *testing.T) []string {
+		img := newTestImage(t, layers...)
+		var result []string
+		for _, layer := range img.Metadata.Layers {
+			result = append(result, layer.DiffID)
+		}
+		return result
+	}(t)
+
+	tests := []struct {
+		name        string
+		options     []AdditionalMetadata
+		wantSkipped []bool
+		wantPaths   []string
+		wantErr     string
+	}{
+		{
+			name:        "top layers by negative index",
+			options:     []AdditionalMetadata{WithLayerIndices(-2, -1)},
+			wantSkipped: []bool{true, false, false},
+			wantPaths:   []string{"/", "/app", "/app/data", "/etc"},
+		},
+		{
+			name:        "middle layer by index",
+			options:     []AdditionalMetadata{WithLayerIndices(1)},
+			wantSkipped: []bool{true, false, true},
+			wantPaths:   []string{"/", "/app", "/app/bin", "/etc"},
+		},
+		{
+			name:        "by index and diff ID",
+			options:     []AdditionalMetadata{WithLayerIndices(0), WithLayerDiffIDs(diffIDs[2])},
+			wantSkipped: []bool{false, true, false},
+			wantPaths:   []string{"/", "/app", "/app/data", "/base", "/base/os", "/etc", "/etc/config"},
+		},
+		{
+			name:    "index out of range",
+			options: []AdditionalMetadata{WithLayerIndices(3)},
+			wantErr: "out of range",
+		},
+		{
+			name:    "negative index out of range",
+			options: []AdditionalMetadata{WithLayerIndices(-4)},
+			wantErr: "out of range",
+		},
+		{
+			name:    "unknown diff ID",
+			options: []AdditionalMetadata{WithLayerDiffIDs("sha256:0000000000000000000000000000000000000000000000000000000000000000")},
+			wantErr: "is not within the image",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			img := NewImage(newTestV1Image(t, layers...), t.TempDir(), test.options...)
+			err := img.Read()
+			if test.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			var skipped []bool
+			for _, layer := range img.Layers {
+				skipped = append(skipped, layer.Metadata.Skipped)
+				if layer.Metadata.Skipped {
+					assert.Empty(t, layer.Tree.AllFiles())
+				}
+			}
+			assert.Equal(t, test.wantSkipped, skipped)
+			assert.Equal(t, test.wantPaths, img.ListPaths())
+		})
+	}
+}
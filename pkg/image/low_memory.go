@@ -0,0 +1,49 @@
+package image
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrLayerSquashUnavailable is returned when accessing the squashed tree of an intermediate layer, which is not
+// retained in low memory mode.
+var ErrLayerSquashUnavailable = fmt.Errorf("layer squash tree is not available in low memory mode")
+
+var lowMemoryMode int32
+
+// SetLowMemoryMode sets whether low memory mode is the default for images read afterwards (disabled unless set). The
+// default is overridden for a single image with WithLowMemoryMode (or for a single call with WithReadOptions), which is
+// preferred over changing the default for the whole process. In either mode file contents remain on disk (in the
+// uncompressed layer cache) and are only read on access, however, by default a squashed tree is kept in memory for
+// every layer, which scales with the number of files multiplied by the number of layers. In low memory mode only the
+// image squash tree (and each layer's own tree) is retained, which bounds memory usage to roughly the number of files
+// in the image.
+//
+// The tradeoff is that the squashed trees of intermediate layers are not available: Layer.SquashedTree is nil for
+// these layers and the squash-relative Layer functions (and Image.ResolveLinkByLayerSquash) return
+// ErrLayerSquashUnavailable. Reading and querying the image squash is unaffected.
+func SetLowMemoryMode(enabled bool) {
+	var value int32
+	if enabled {
+		value = 1
+	}
+	atomic.StoreInt32(&lowMemoryMode, value)
+}
+
+func isLowMemoryMode() bool {
+	return atomic.LoadInt32(&lowMemoryMode) == 1
+}
+
+// WithLowMemoryMode enables (or disables) low memory mode for a single image (see SetLowMemoryMode), regardless of the
+// process-wide default.
+func WithLowMemoryMode(enabled bool) AdditionalMetadata {
+	return func(image *Image) error {
+		image.lowMemory = enabled
+		return nil
+	}
+}
+
+// WithLowMemory enables low memory mode for a single image (see WithLowMemoryMode).
+func WithLowMemory() AdditionalMetadata {
+	return WithLowMemoryMode(true)
+}
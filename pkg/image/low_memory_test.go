@@ -0,0 +1,135 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"runtime"
+	"testing"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// retainedHeap reads the image and returns the approximate number of heap bytes retained by the read image.
+func retainedHeap(t *testing.T, img *Image) uint64 {
+	t.Helper()
+
+	var stats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	before := stats.HeapAlloc
+
+	require.NoError(t, img.Read())
+
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	runtime.KeepAlive(img)
+
+	if stats.HeapAlloc < before {
+		return 0
+	}
+	return stats.HeapAlloc - before
+}
+
+func TestLowMemoryMode(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping large image test in short mode")
+	}
+
+	var layers [][]testEntry
+	for l := 0; l < 5; l++ {
+		var entries []testEntry
+		for f := 0; f < 5000; f++ {
+			entries = append(entries, testFile(fmt.Sprintf("/dir-%d/layer-%d-file-%d.txt", f%50, l, f), "contents"))
+		}
+		layers = append(layers, entries)
+	}
+	layers = append(layers, []testEntry{testFile("/etc/top.txt", "top layer")})
+	v1Image := newTestV1Image(t, layers...)
+
+	normal := NewImage(v1Image, t.TempDir())
+	normalHeap := retainedHeap(t, normal)
+
+	SetLowMemoryMode(true)
+	t.Cleanup(func() {
+		SetLowMemoryMode(false)
+	})
+
+	lowMemory := NewImage(v1Image, t.TempDir())
+	lowMemoryHeap := retainedHeap(t, lowMemory)
+
+	t.Logf("retained heap: normal=%dKiB low-memory=%dKiB", normalHeap/1024, lowMemoryHeap/1024)
+	// the intermediate squash trees alone account for well over a third of the retained heap
+	assert.Less(t, lowMemoryHeap, normalHeap*2/3)
+
+	// the image squash is unaffected...
+	assert.Len(t, lowMemory.SquashedTree().AllFiles(), len(normal.SquashedTree().AllFiles()))
+	reader, err := lowMemory.FileContentsFromSquash("/etc/top.txt")
+	require.NoError(t, err)
+	contents, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "top layer", string(contents))
+
+	// ...as is the first layer (which is its own squash) and the top layer (which is the image squash)...
+	_, err = lowMemory.Layers[0].FileContentsFromSquash("/dir-0/layer-0-file-0.txt")
+	assert.NoError(t, err)
+	_, err = lowMemory.Layers[5].FileContentsFromSquash("/dir-0/layer-0-file-0.txt")
+	assert.NoError(t, err)
+
+	// ...however intermediate layer squashes are not available
+	_, err = lowMemory.Layers[2].FileContentsFromSquash("/dir-0/layer-0-file-0.txt")
+	assert.ErrorIs(t, err, ErrLayerSquashUnavailable)
+	_, err = lowMemory.Layers[2].FilesByMIMETypeFromSquash("text/plain")
+	assert.ErrorIs(t, err, ErrLayerSquashUnavailable)
+	_, err = lowMemory.ResolveLinkByLayerSquash(*file.NewFileReference("/etc/top.txt"), 2)
+	assert.ErrorIs(t, err, ErrLayerSquashUnavailable)
+}
+
+func TestWithLowMemory(t *testing.T) {
+	img := NewImage(newTestV1Image(t,
+		[]testEntry{testFile("/first.txt", "first")},
+		[]testEntry{testFile("/second.txt", "second")},
+		[]testEntry{testFile("/third.txt", "third")},
+	), t.TempDir(), WithLowMemory())
+	require.NoError(t, img.Read())
+
+	assert.NotNil(t, img.Layers[0].SquashedTree)
+	assert.Nil(t, img.Layers[1].SquashedTree)
+	assert.NotNil(t, img.Layers[2].SquashedTree)
+
+	reader, err := img.FileContentsFromSquash("/first.txt")
+	require.NoError(t, err)
+	contents, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "first", string(contents))
+}
+
+func TestWithLowMemoryMode_OverridesDefault(t *testing.T) {
+	v1Image := newTestV1Image(t,
+		[]testEntry{testFile("/first.txt", "first")},
+		[]testEntry{testFile("/second.txt", "second")},
+		[]testEntry{testFile("/third.txt", "third")},
+	)
+
+	SetLowMemoryMode(true)
+	t.Cleanup(func() {
+		SetLowMemoryMode(false)
+	})
+
+	// the default applies unless overridden...
+	img := NewImage(v1Image, t.TempDir())
+	require.NoError(t, img.Read())
+	assert.Nil(t, img.Layers[1].SquashedTree)
+
+	// ...by the options for the image...
+	img = NewImage(v1Image, t.TempDir(), WithLowMemoryMode(false))
+	require.NoError(t, img.Read())
+	assert.NotNil(t, img.Layers[1].SquashedTree)
+
+	// ...or the options for the call
+	img = NewImage(v1Image, t.TempDir())
+	require.NoError(t, img.Read(ReadOptions(WithReadOptions(context.Background(), WithLowMemoryMode(false)))...))
+	assert.NotNil(t, img.Layers[1].SquashedTree)
+}
@@ -0,0 +1,79 @@
+package image
+
+import (
+	"bytes"
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	v1Types "github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Manifest is a normalized view of an image manifest, the same regardless of the source the image was read from (the
+// raw manifest is available as Metadata.RawManifest).
+type Manifest struct {
+	// SchemaVersion is the manifest schema version (always 2 for docker v2 schema 2 and OCI manifests)
+	SchemaVersion int64
+	// MediaType is the manifest media type (e.g. "application/vnd.oci.image.manifest.v1+json"), which is taken from the
+	// image when the manifest does not declare it (as is optional with OCI manifests)
+	MediaType v1Types.MediaType
+	// Config is the descriptor of the image config
+	Config v1.Descriptor
+	// Layers are the descriptors of the layer blobs, in build order (base-first, as with Image.Layers)
+	Layers []v1.Descriptor
+	// Annotations are the manifest annotations (OCI manifests only), which is nil when there are none
+	Annotations map[string]string
+}
+
+// Manifest returns the normalized manifest of the image. The manifest is populated for all sources, though what the
+// descriptors describe differs by source:
+//
+// For registry, OCI directory, and OCI archive sources this is the manifest as stored, thus layer descriptors are of the
+// layer blobs (typically gzip compressed, with the digest and size of the compressed blob, and an OCI or docker layer
+// media type) and Metadata.ManifestDigest is the digest of this manifest.
+//
+// For docker archive and docker daemon sources this is the original manifest when the archive includes an OCI image
+// layout (as saved by docker 25+ and the buildkit docker exporter). Otherwise the manifest is generated from the
+// manifest.json of the archive: a docker v2 schema 2 manifest where the layer descriptors are of the uncompressed layer
+// tars (the digest is the layer diff ID, the size is the uncompressed size, and the media type is
+// "application/vnd.docker.image.rootfs.diff.tar.gzip" regardless), and there are no annotations. Note: the digest of a
+// generated manifest (Metadata.ManifestDigest) does not match the manifest the image was pushed or pulled with.
+func (i *Image) Manifest() (*Manifest, error) {
+	manifest, err := i.parsedManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	normalized := Manifest{
+		SchemaVersion: manifest.SchemaVersion,
+		MediaType:     manifest.MediaType,
+		Config:        manifest.Config,
+		Layers:        append([]v1.Descriptor{}, manifest.Layers...),
+		Annotations:   manifest.Annotations,
+	}
+	if normalized.MediaType == "" {
+		normalized.MediaType = i.Metadata.MediaType
+	}
+	return &normalized, nil
+}
+
+// parsedManifest returns the raw manifest of the image parsed, falling back to the manifest from the image itself when
+// no raw manifest was provided.
+func (i *Image) parsedManifest() (*v1.Manifest, error) {
+	if len(i.Metadata.RawManifest) > 0 {
+		manifest, err := v1.ParseManifest(bytes.NewReader(i.Metadata.RawManifest))
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse image manifest: %w", err)
+		}
+		return manifest, nil
+	}
+
+	if i.image == nil {
+		return nil, fmt.Errorf("no image manifest available")
+	}
+
+	manifest, err := i.image.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read image manifest: %w", err)
+	}
+	return manifest, nil
+}
@@ -0,0 +1,78 @@
+package image
+
+import (
+	"encoding/json"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImage_Manifest(t *testing.T) {
+	layers := [][]testEntry{
+		{testFile("etc/os-release", "ID=alpine")},
+		{testFile("app/main.go", "package main")},
+	}
+
+	t.Run("from the image", func(t *testing.T) {
+		img := newTestImage(t, layers...)
+
+		manifest, err := img.Manifest()
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(2), manifest.SchemaVersion)
+		assert.Equal(t, types.DockerManifestSchema2, manifest.MediaType)
+		assert.Equal(t, img.Metadata.ID, manifest.Config.Digest.String())
+		require.Len(t, manifest.Layers, 2)
+		for idx, layer := range img.Layers {
+			digest, err := layer.layer.Digest()
+			require.NoError(t, err)
+			assert.Equal(t, digest, manifest.Layers[idx].Digest)
+		}
+	})
+
+	t.Run("from the raw manifest", func(t *testing.T) {
+		raw, err := json.Marshal(v1.Manifest{
+			SchemaVersion: 2,
+			Config: v1.Descriptor{
+				MediaType: types.OCIConfigJSON,
+				Size:      100,
+				Digest:    v1.Hash{Algorithm: "sha256", Hex: "1111111111111111111111111111111111111111111111111111111111111111"},
+			},
+			Layers: []v1.Descriptor{
+				{
+					MediaType: types.OCILayer,
+					Size:      200,
+					Digest:    v1.Hash{Algorithm: "sha256", Hex: "2222222222222222222222222222222222222222222222222222222222222222"},
+				},
+			},
+			Annotations: map[string]string{"org.opencontainers.image.created": "2024-01-01T00:00:00Z"},
+		})
+		require.NoError(t, err)
+
+		img := NewImage(newTestV1Image(t, layers...), t.TempDir(), WithManifest(raw))
+		require.NoError(t, img.Read())
+
+		manifest, err := img.Manifest()
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(2), manifest.SchemaVersion)
+		// the media type is optional within OCI manifests, thus is taken from the image
+		assert.Equal(t, img.Metadata.MediaType, manifest.MediaType)
+		assert.Equal(t, types.OCIConfigJSON, manifest.Config.MediaType)
+		require.Len(t, manifest.Layers, 1)
+		assert.Equal(t, int64(200), manifest.Layers[0].Size)
+		assert.Equal(t, types.OCILayer, manifest.Layers[0].MediaType)
+		assert.Equal(t, "2024-01-01T00:00:00Z", manifest.Annotations["org.opencontainers.image.created"])
+	})
+
+	t.Run("invalid raw manifest", func(t *testing.T) {
+		img := NewImage(newTestV1Image(t, layers...), t.TempDir(), WithManifest([]byte("{")))
+		require.NoError(t, img.Read())
+
+		_, err := img.Manifest()
+		assert.Error(t, err)
+	})
+}
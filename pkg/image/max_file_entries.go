@@ -0,0 +1,39 @@
+package image
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// DefaultMaxFileEntries is the default maximum number of filesystem entries within an image (see SetMaxFileEntries),
+// which is well beyond the entry count of legitimate images (even large distro images hold a few hundred thousand).
+const DefaultMaxFileEntries = 10000000
+
+// ErrTooManyFiles is returned when reading an image that holds more filesystem entries than allowed (see
+// SetMaxFileEntries).
+var ErrTooManyFiles = errors.New("image has too many files")
+
+var maxFileEntries int64 = DefaultMaxFileEntries
+
+// SetMaxFileEntries sets the default maximum number of filesystem entries (tar entries across all layers) that images
+// read afterwards may contain, protecting against images crafted with millions of tiny entries to exhaust memory while
+// building the file trees. Reading an image that exceeds the limit stops as soon as the limit is passed and raises
+// ErrTooManyFiles. A count <= 0 disables the limit. The default is overridden for a single image with
+// WithMaxFileEntries (or for a single call with WithReadOptions). Note: this is independent of the file read size
+// limit (see SetMaxFileReadSize).
+func SetMaxFileEntries(count int64) {
+	atomic.StoreInt64(&maxFileEntries, count)
+}
+
+func currentMaxFileEntries() int64 {
+	return atomic.LoadInt64(&maxFileEntries)
+}
+
+// WithMaxFileEntries sets the maximum number of filesystem entries for a single image, regardless of the process-wide
+// default (see SetMaxFileEntries). A count <= 0 disables the limit.
+func WithMaxFileEntries(count int64) AdditionalMetadata {
+	return func(image *Image) error {
+		image.FileCatalog.maxEntries = count
+		return nil
+	}
+}
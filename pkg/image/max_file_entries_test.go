@@ -0,0 +1,85 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// entryBomb returns the entries of a layer packed with the given number of tiny files.
+func entryBomb(prefix string, count int) []testEntry {
+	entries := make([]testEntry, 0, count)
+	for i := 0; i < count; i++ {
+		entries = append(entries, testFile(fmt.Sprintf("%s/%d", prefix, i), ""))
+	}
+	return entries
+}
+
+func TestImage_MaxFileEntries(t *testing.T) {
+	tests := []struct {
+		name        string
+		globalLimit int64
+		options     []AdditionalMetadata
+		callOptions []AdditionalMetadata
+		wantErr     bool
+	}{
+		{
+			name:        "default limit",
+			globalLimit: DefaultMaxFileEntries,
+		},
+		{
+			name:        "global limit",
+			globalLimit: 5000,
+			wantErr:     true,
+		},
+		{
+			name:        "limit spans layers",
+			globalLimit: 15000,
+			wantErr:     true,
+		},
+		{
+			name:        "under the limit",
+			globalLimit: 20000,
+		},
+		{
+			name:    "image limit",
+			options: []AdditionalMetadata{WithMaxFileEntries(5000)},
+			wantErr: true,
+		},
+		{
+			name:        "image limit overrides the global limit",
+			globalLimit: 5000,
+			options:     []AdditionalMetadata{WithMaxFileEntries(0)},
+		},
+		{
+			name:        "call limit overrides the image limit",
+			options:     []AdditionalMetadata{WithMaxFileEntries(0)},
+			callOptions: []AdditionalMetadata{WithMaxFileEntries(5000)},
+			wantErr:     true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			SetMaxFileEntries(test.globalLimit)
+			t.Cleanup(func() {
+				SetMaxFileEntries(DefaultMaxFileEntries)
+			})
+
+			img := NewImage(newTestV1Image(t, entryBomb("/a", 10000), entryBomb("/b", 10000)), t.TempDir(), test.options...)
+			err := img.Read(ReadOptions(WithReadOptions(context.Background(), test.callOptions...))...)
+			if !test.wantErr {
+				require.NoError(t, err)
+				assert.Len(t, img.FileCatalog.catalog, 20000)
+				return
+			}
+
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, ErrTooManyFiles), "unexpected error: %+v", err)
+		})
+	}
+}
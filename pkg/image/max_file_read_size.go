@@ -0,0 +1,28 @@
+package image
+
+import "sync/atomic"
+
+var maxFileReadSize int64
+
+// SetMaxFileReadSize sets the default maximum number of bytes that may be read from the contents of any single file
+// within images read afterwards, protecting callers that read file contents into memory (e.g. to parse package
+// manifests) from unexpectedly large files. Opening (or reading) a file that exceeds the limit raises a
+// *file.ErrFileTooLarge. A size <= 0 disables the limit (the default). The default is overridden for a single image
+// with WithMaxFileReadSize (or for a single call with WithReadOptions). Note: this limit applies to each file
+// individually, not the image.
+func SetMaxFileReadSize(size int64) {
+	atomic.StoreInt64(&maxFileReadSize, size)
+}
+
+func currentMaxFileReadSize() int64 {
+	return atomic.LoadInt64(&maxFileReadSize)
+}
+
+// WithMaxFileReadSize sets the maximum file read size for a single image, regardless of the process-wide default (see
+// SetMaxFileReadSize). A size <= 0 disables the limit.
+func WithMaxFileReadSize(size int64) AdditionalMetadata {
+	return func(image *Image) error {
+		image.FileCatalog.maxFileReadSize = size
+		return nil
+	}
+}
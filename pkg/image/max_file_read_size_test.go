@@ -0,0 +1,93 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSparseFileImage returns an image with a single layer holding a 4 GB sparse file (/big.bin) and a small file.
+func newSparseFileImage(t *testing.T) v1.Image {
+	t.Helper()
+
+	layer, err := tarball.LayerFromFile("test-fixtures/sparse-file-layer.tar")
+	require.NoError(t, err)
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	require.NoError(t, err)
+	return img
+}
+
+func TestImage_MaxFileReadSize(t *testing.T) {
+	tests := []struct {
+		name            string
+		globalLimit     int64
+		options         []AdditionalMetadata
+		callOptions     []AdditionalMetadata
+		wantBigTooLarge bool
+	}{
+		{
+			name: "no limit",
+		},
+		{
+			name:            "global limit",
+			globalLimit:     file.MB,
+			wantBigTooLarge: true,
+		},
+		{
+			name:            "image limit",
+			options:         []AdditionalMetadata{WithMaxFileReadSize(file.MB)},
+			wantBigTooLarge: true,
+		},
+		{
+			name:        "image limit overrides the global limit",
+			globalLimit: file.MB,
+			options:     []AdditionalMetadata{WithMaxFileReadSize(0)},
+		},
+		{
+			name:            "call limit",
+			callOptions:     []AdditionalMetadata{WithMaxFileReadSize(file.MB)},
+			wantBigTooLarge: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			SetMaxFileReadSize(test.globalLimit)
+			t.Cleanup(func() {
+				SetMaxFileReadSize(0)
+			})
+
+			img := NewImage(newSparseFileImage(t), t.TempDir(), test.options...)
+			require.NoError(t, img.Read(ReadOptions(WithReadOptions(context.Background(), test.callOptions...))...))
+
+			// files under the limit are always readable
+			reader, err := img.FileContentsFromSquash("/small.txt")
+			require.NoError(t, err)
+			contents, err := ioutil.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, "a small file\n", string(contents))
+
+			reader, err = img.FileContentsFromSquash("/big.bin")
+			if !test.wantBigTooLarge {
+				require.NoError(t, err)
+				assert.NoError(t, reader.Close())
+				return
+			}
+
+			var tooLarge *file.ErrFileTooLarge
+			require.True(t, errors.As(err, &tooLarge), "unexpected error: %+v", err)
+			assert.Equal(t, "/big.bin", tooLarge.Path)
+			assert.Equal(t, int64(4*file.GB), tooLarge.Size)
+			assert.Equal(t, int64(file.MB), tooLarge.Limit)
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package image
+
+import (
+	"strings"
+
+	v1Types "github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Known manifest media types.
+const (
+	MediaTypeOCIIndex           = v1Types.OCIImageIndex
+	MediaTypeOCIManifest        = v1Types.OCIManifestSchema1
+	MediaTypeDockerManifestList = v1Types.DockerManifestList
+	MediaTypeDockerManifest     = v1Types.DockerManifestSchema2
+	// MediaTypeDockerManifestSchema1 and MediaTypeDockerManifestSchema1Signed are legacy manifests (not supported)
+	MediaTypeDockerManifestSchema1       = v1Types.DockerManifestSchema1
+	MediaTypeDockerManifestSchema1Signed = v1Types.DockerManifestSchema1Signed
+)
+
+const (
+	UnsupportedMediaTypeKind MediaTypeKind = iota
+	IndexMediaTypeKind
+	DockerManifestMediaTypeKind
+	OCIManifestMediaTypeKind
+)
+
+var mediaTypeKindStr = [...]string{
+	"Unsupported",
+	"Index",
+	"DockerManifest",
+	"OCIManifest",
+}
+
+// MediaTypeKind is a classification of a manifest media type.
+type MediaTypeKind uint8
+
+// SourceFromMediaType classifies the given manifest media type as an index (an OCI image index or a docker manifest
+// list), a docker (V2 schema 2) manifest, an OCI manifest, or an unsupported artifact. Media type parameters and
+// casing are ignored.
+func SourceFromMediaType(mediaType string) MediaTypeKind {
+	if idx := strings.Index(mediaType, ";"); idx >= 0 {
+		mediaType = mediaType[:idx]
+	}
+
+	switch v1Types.MediaType(strings.ToLower(strings.TrimSpace(mediaType))) {
+	case MediaTypeOCIIndex, MediaTypeDockerManifestList:
+		return IndexMediaTypeKind
+	case MediaTypeDockerManifest:
+		return DockerManifestMediaTypeKind
+	case MediaTypeOCIManifest:
+		return OCIManifestMediaTypeKind
+	}
+	return UnsupportedMediaTypeKind
+}
+
+// IsManifest indicates if the kind describes a single image manifest (docker or OCI).
+func (k MediaTypeKind) IsManifest() bool {
+	return k == DockerManifestMediaTypeKind || k == OCIManifestMediaTypeKind
+}
+
+// String returns a convenient display string for the media type kind.
+func (k MediaTypeKind) String() string {
+	if int(k) >= len(mediaTypeKindStr) {
+		return mediaTypeKindStr[UnsupportedMediaTypeKind]
+	}
+	return mediaTypeKindStr[k]
+}
@@ -0,0 +1,54 @@
+package image
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSourceFromMediaType(t *testing.T) {
+	tests := []struct {
+		mediaType string
+		expected  MediaTypeKind
+	}{
+		{
+			mediaType: "application/vnd.oci.image.index.v1+json",
+			expected:  IndexMediaTypeKind,
+		},
+		{
+			mediaType: "application/vnd.docker.distribution.manifest.list.v2+json",
+			expected:  IndexMediaTypeKind,
+		},
+		{
+			mediaType: "application/vnd.docker.distribution.manifest.v2+json",
+			expected:  DockerManifestMediaTypeKind,
+		},
+		{
+			mediaType: "application/vnd.oci.image.manifest.v1+json",
+			expected:  OCIManifestMediaTypeKind,
+		},
+		{
+			mediaType: "Application/VND.OCI.Image.Manifest.v1+json; charset=utf-8",
+			expected:  OCIManifestMediaTypeKind,
+		},
+		{
+			mediaType: "application/vnd.docker.distribution.manifest.v1+prettyjws",
+			expected:  UnsupportedMediaTypeKind,
+		},
+		{
+			mediaType: "application/vnd.in-toto+json",
+			expected:  UnsupportedMediaTypeKind,
+		},
+		{
+			mediaType: "",
+			expected:  UnsupportedMediaTypeKind,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.mediaType, func(t *testing.T) {
+			actual := SourceFromMediaType(test.mediaType)
+			assert.Equal(t, test.expected, actual, "got %s", actual)
+		})
+	}
+}
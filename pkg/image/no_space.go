@@ -0,0 +1,26 @@
+package image
+
+import (
+	"fmt"
+)
+
+// ErrNoSpace is returned when the image content cannot be written to disk since the filesystem is full (e.g. while
+// saving an image from the docker daemon), describing where the content was written and how much was written before
+// running out of space. Any partially written file has been removed. This error wraps the underlying write error (thus
+// errors.Is(err, syscall.ENOSPC) holds).
+type ErrNoSpace struct {
+	// Path is the file that was being written
+	Path string
+	// BytesWritten is the number of bytes written before running out of space
+	BytesWritten int64
+	// Err is the underlying write error
+	Err error
+}
+
+func (e *ErrNoSpace) Error() string {
+	return fmt.Sprintf("no space left on device while writing path=%q (after %d bytes), the partial file was removed: %v", e.Path, e.BytesWritten, e.Err)
+}
+
+func (e *ErrNoSpace) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,193 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/anchore/stereoscope/internal/bus"
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/event"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/wagoodman/go-partybus"
+	"github.com/wagoodman/go-progress"
+)
+
+// defaultArchiveName is the name of a downloaded archive when the URL path does not have a usable basename.
+const defaultArchiveName = "image.tar"
+
+// IsArchiveURL indicates if the given location is a http(s) URL (e.g. "https://example.com/image.tar"), which refers to
+// an image archive to download rather than an image within a registry.
+func IsArchiveURL(location string) bool {
+	u, err := url.Parse(location)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	scheme := strings.ToLower(u.Scheme)
+	return scheme == "http" || scheme == "https"
+}
+
+// DownloadArchive downloads the image archive (a docker-archive or oci-archive) at the given http(s) URL into a new temp
+// dir (cleaned up with the given generator), returning the path to the downloaded archive. Requests are made with the
+// same transport as registry requests (TLS verification, extra headers, and resuming interrupted downloads), however,
+// registry credentials are never sent. The basename of the URL path is kept so that extension hints still apply.
+func DownloadArchive(ctx context.Context, archiveURL string, tmpDirGen *file.TempDirGenerator, registryOptions *image.RegistryOptions) (_ string, err error) {
+	if registryOptions == nil {
+		registryOptions = &image.RegistryOptions{}
+	}
+
+	u, err := url.Parse(archiveURL)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse archive URL=%q: %w", archiveURL, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("unable to create request for archive URL=%q: %w", archiveURL, err)
+	}
+
+	log.Debugf("downloading image archive=%q", archiveURL)
+	prog, stage := trackDownloadProgress(archiveURL, event.DisabledTypes(ctx))
+	defer func() {
+		if err != nil {
+			prog.Err = err
+		}
+	}()
+
+	stage.Set(event.PullingStage, "downloading image archive")
+	bus.ReportStage(archiveURL, event.PullingStage, "downloading image archive")
+
+	resp, err := (&http.Client{Transport: prepareTransport(registryOptions)}).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unable to download archive=%q: %w", archiveURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unable to download archive=%q: unexpected status %q", archiveURL, resp.Status)
+	}
+
+	// note: the content length is -1 when unknown, in which case the progress is indeterminate
+	prog.Total = resp.ContentLength
+
+	tempDir, err := tmpDirGen.NewTempDir()
+	if err != nil {
+		return "", err
+	}
+
+	archivePath := filepath.Join(tempDir, archiveName(u))
+	fh, err := os.Create(archivePath)
+	if err != nil {
+		return "", fmt.Errorf("unable to create file for archive: %w", err)
+	}
+	defer func() {
+		if closeErr := fh.Close(); closeErr != nil {
+			log.Errorf("unable to close archive file (%s): %w", archivePath, closeErr)
+		}
+		if err == nil {
+			return
+		}
+		// don't leave a partial archive behind
+		if removeErr := os.Remove(archivePath); removeErr != nil && !os.IsNotExist(removeErr) {
+			log.Errorf("unable to remove archive file (%s): %w", archivePath, removeErr)
+		}
+	}()
+
+	n, err := file.Copy(io.MultiWriter(fh, &downloadWriter{image: archiveURL, prog: prog}), resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("unable to download archive=%q: %w", archiveURL, err)
+	}
+	if resp.ContentLength >= 0 && n != resp.ContentLength {
+		return "", fmt.Errorf("unable to download archive=%q: expected %d bytes but got %d bytes", archiveURL, resp.ContentLength, n)
+	}
+
+	prog.SetCompleted()
+	return archivePath, nil
+}
+
+// ArchiveExists indicates if the image archive at the given http(s) URL exists, with a HEAD request made with the same
+// transport as DownloadArchive (thus nothing is downloaded). A missing archive (404 or 410) is reported as false without
+// an error, whereas any other unexpected status is an error.
+func ArchiveExists(ctx context.Context, archiveURL string, registryOptions *image.RegistryOptions) (bool, error) {
+	if registryOptions == nil {
+		registryOptions = &image.RegistryOptions{}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, archiveURL, nil)
+	if err != nil {
+		return false, fmt.Errorf("unable to create request for archive URL=%q: %w", archiveURL, err)
+	}
+
+	resp, err := (&http.Client{Transport: prepareTransport(registryOptions)}).Do(req)
+	if err != nil {
+		return false, fmt.Errorf("unable to check for archive=%q: %w", archiveURL, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound, http.StatusGone:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unable to check for archive=%q: unexpected status %q", archiveURL, resp.Status)
+	}
+}
+
+// archiveName returns the basename of the given URL path, or a default name if there is none.
+func archiveName(u *url.URL) string {
+	name := path.Base(u.Path)
+	if name == "" || name == "." || name == "/" {
+		return defaultArchiveName
+	}
+	return name
+}
+
+// trackDownloadProgress publishes the fetch event for the archive download (unless the event type is disabled, either
+// globally or by the given types).
+func trackDownloadProgress(archiveURL string, disabled event.TypeSet) (*progress.Manual, *event.Stage) {
+	prog := &progress.Manual{
+		Total: -1,
+	}
+	stage := &event.Stage{}
+
+	bus.PublishUnless(partybus.Event{
+		Type:   event.FetchImage,
+		Source: archiveURL,
+		Value: progress.StagedProgressable(&struct {
+			progress.Stager
+			event.StageCoder
+			*progress.Manual
+		}{
+			Stager:     progress.Stager(stage),
+			StageCoder: event.StageCoder(stage),
+			Manual:     prog,
+		}),
+	}, disabled)
+
+	return prog, stage
+}
+
+// downloadWriter records the number of bytes downloaded so far, giving them to the progress reporter.
+type downloadWriter struct {
+	image string
+	prog  *progress.Manual
+}
+
+func (w *downloadWriter) Write(p []byte) (int, error) {
+	w.prog.N += int64(len(p))
+	// note: the reported total is zero when unknown
+	var total int64
+	if w.prog.Total > 0 {
+		total = w.prog.Total
+	}
+	bus.ReportProgress(w.image, event.PullingStage, w.prog.N, total)
+	return len(p), nil
+}
@@ -0,0 +1,89 @@
+package oci
+
+import (
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsArchiveURL(t *testing.T) {
+	tests := []struct {
+		location string
+		want     bool
+	}{
+		{location: "https://example.com/image.tar", want: true},
+		{location: "HTTP://example.com/image.oci", want: true},
+		{location: "http://localhost:8080/image.tar.gz", want: true},
+		{location: "docker.io/library/alpine:latest", want: false},
+		{location: "localhost:5000/alpine:latest", want: false},
+		{location: "/tmp/image.tar", want: false},
+		{location: "https://", want: false},
+		{location: "ftp://example.com/image.tar", want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.location, func(t *testing.T) {
+			assert.Equal(t, test.want, IsArchiveURL(test.location))
+		})
+	}
+}
+
+func TestDownloadArchive(t *testing.T) {
+	content := make([]byte, 64*1024)
+	rand.New(rand.NewSource(42)).Read(content)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/image.oci", "/":
+			_, _ = w.Write(content)
+		case "/interrupted.tar":
+			// note: the resuming transport would continue this download if ranges were supported
+			interruptedResponse(t, w, http.StatusOK, content)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name     string
+		path     string
+		wantName string
+		wantErr  bool
+	}{
+		{name: "keeps the basename", path: "/image.oci", wantName: "image.oci"},
+		{name: "default basename", path: "/", wantName: defaultArchiveName},
+		{name: "not found", path: "/missing.tar", wantErr: true},
+		{name: "interrupted", path: "/interrupted.tar", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tmpDirGen := file.NewTempDirGenerator()
+			t.Cleanup(func() {
+				assert.NoError(t, tmpDirGen.Cleanup())
+			})
+
+			actual, err := DownloadArchive(context.Background(), server.URL+test.path, &tmpDirGen, &image.RegistryOptions{})
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.wantName, filepath.Base(actual))
+
+			downloaded, err := ioutil.ReadFile(actual)
+			require.NoError(t, err)
+			assert.Equal(t, content, downloaded)
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// BlobFetcher retrieves image content from a content-addressed store, decoupling how an image is assembled (see
+// BlobFetcherImageProvider) from how the content is transferred (e.g. the OCI distribution API is not required).
+type BlobFetcher interface {
+	// FetchManifest returns the raw manifest (or index) for the given reference. References within an index are always
+	// digests (e.g. "sha256:..."), while the reference given to the provider may be anything the store understands
+	// (e.g. a tag).
+	FetchManifest(ctx context.Context, reference string) ([]byte, error)
+	// FetchBlob returns the content of the blob with the given digest as stored (thus layers may be compressed).
+	FetchBlob(ctx context.Context, digest v1.Hash) (io.ReadCloser, error)
+}
+
+// fetchManifest fetches the manifest for the given reference, verifying the content when the reference is a digest.
+func fetchManifest(ctx context.Context, fetcher BlobFetcher, reference string) ([]byte, types.MediaType, error) {
+	raw, err := fetcher.FetchManifest(ctx, reference)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to fetch manifest=%q: %w", reference, err)
+	}
+
+	if expected, err := v1.NewHash(reference); err == nil {
+		if err := verifyDigest(raw, expected); err != nil {
+			return nil, "", fmt.Errorf("manifest=%q: %w", reference, err)
+		}
+	}
+
+	mediaType, err := manifestMediaType(raw)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to parse manifest=%q: %w", reference, err)
+	}
+	return raw, mediaType, nil
+}
+
+// manifestMediaType returns the media type declared within the given manifest (or index). The media type is optional
+// for OCI manifests, in which case the presence of the "manifests" field indicates an index.
+func manifestMediaType(raw []byte) (types.MediaType, error) {
+	var probe struct {
+		MediaType types.MediaType   `json:"mediaType"`
+		Manifests []json.RawMessage `json:"manifests"`
+	}
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return "", err
+	}
+
+	switch {
+	case probe.MediaType != "":
+		return probe.MediaType, nil
+	case probe.Manifests != nil:
+		return types.OCIImageIndex, nil
+	default:
+		return types.OCIManifestSchema1, nil
+	}
+}
+
+// verifyDigest ensures the given content matches the expected digest.
+func verifyDigest(content []byte, expected v1.Hash) error {
+	actual, _, err := v1.SHA256(bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		return fmt.Errorf("content digest=%q does not match expected digest=%q", actual, expected)
+	}
+	return nil
+}
+
+// fetcherManifest is a manifest fetched with a BlobFetcher, which is read as either an image or an index depending on
+// the media type (see resolvableManifest).
+type fetcherManifest struct {
+	ctx       context.Context
+	fetcher   BlobFetcher
+	raw       []byte
+	mediaType types.MediaType
+}
+
+func (m *fetcherManifest) Image() (v1.Image, error) {
+	return newFetcherImage(m.ctx, m.fetcher, m.raw, m.mediaType)
+}
+
+func (m *fetcherManifest) ImageIndex() (v1.ImageIndex, error) {
+	return &fetcherIndex{ctx: m.ctx, fetcher: m.fetcher, raw: m.raw, mediaType: m.mediaType}, nil
+}
+
+// verifyingReader is a io.ReadCloser that hashes the content as it is read, returning an error instead of io.EOF when
+// the content does not match the expected digest or size.
+type verifyingReader struct {
+	io.ReadCloser
+	hasher   hash.Hash
+	expected v1.Hash
+	size     int64
+	read     int64
+}
+
+func newVerifyingReader(reader io.ReadCloser, expected v1.Hash, size int64) (io.ReadCloser, error) {
+	hasher, err := v1.Hasher(expected.Algorithm)
+	if err != nil {
+		_ = reader.Close()
+		return nil, fmt.Errorf("unable to verify blob=%q: %w", expected, err)
+	}
+	return &verifyingReader{
+		ReadCloser: reader,
+		hasher:     hasher,
+		expected:   expected,
+		size:       size,
+	}, nil
+}
+
+func (r *verifyingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hasher.Write(p[:n])
+	r.read += int64(n)
+
+	if r.read > r.size {
+		return n, fmt.Errorf("blob=%q exceeds the expected size=%d", r.expected, r.size)
+	}
+	if !errors.Is(err, io.EOF) {
+		return n, err
+	}
+
+	if r.read != r.size {
+		return n, fmt.Errorf("blob=%q size=%d does not match expected size=%d", r.expected, r.read, r.size)
+	}
+	actual := v1.Hash{Algorithm: r.expected.Algorithm, Hex: hex.EncodeToString(r.hasher.Sum(nil))}
+	if actual != r.expected {
+		return n, fmt.Errorf("content digest=%q does not match expected digest=%q", actual, r.expected)
+	}
+	return n, err
+}
+
+// fetcherIndex is a v1.ImageIndex where all manifests are fetched with a BlobFetcher.
+type fetcherIndex struct {
+	ctx       context.Context
+	fetcher   BlobFetcher
+	raw       []byte
+	mediaType types.MediaType
+}
+
+func (i *fetcherIndex) MediaType() (types.MediaType, error) {
+	return i.mediaType, nil
+}
+
+func (i *fetcherIndex) Digest() (v1.Hash, error) {
+	h, _, err := v1.SHA256(bytes.NewReader(i.raw))
+	return h, err
+}
+
+func (i *fetcherIndex) Size() (int64, error) {
+	return int64(len(i.raw)), nil
+}
+
+func (i *fetcherIndex) RawManifest() ([]byte, error) {
+	return i.raw, nil
+}
+
+func (i *fetcherIndex) IndexManifest() (*v1.IndexManifest, error) {
+	return v1.ParseIndexManifest(bytes.NewReader(i.raw))
+}
+
+func (i *fetcherIndex) Image(digest v1.Hash) (v1.Image, error) {
+	raw, mediaType, err := fetchManifest(i.ctx, i.fetcher, digest.String())
+	if err != nil {
+		return nil, err
+	}
+	return newFetcherImage(i.ctx, i.fetcher, raw, mediaType)
+}
+
+func (i *fetcherIndex) ImageIndex(digest v1.Hash) (v1.ImageIndex, error) {
+	raw, mediaType, err := fetchManifest(i.ctx, i.fetcher, digest.String())
+	if err != nil {
+		return nil, err
+	}
+	return &fetcherIndex{ctx: i.ctx, fetcher: i.fetcher, raw: raw, mediaType: mediaType}, nil
+}
+
+// fetcherImage is the core of a v1.Image (see partial.CompressedImageCore) where the config and layer blobs are
+// fetched with a BlobFetcher.
+type fetcherImage struct {
+	ctx       context.Context
+	fetcher   BlobFetcher
+	raw       []byte
+	mediaType types.MediaType
+	manifest  *v1.Manifest
+
+	configOnce sync.Once
+	config     []byte
+	configErr  error
+}
+
+// newFetcherImage creates a v1.Image from the given raw manifest. Note: layer blobs may be stored with any compression,
+// which is handled when the image is assembled (see assembleImage).
+func newFetcherImage(ctx context.Context, fetcher BlobFetcher, raw []byte, mediaType types.MediaType) (v1.Image, error) {
+	manifest, err := v1.ParseManifest(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse image manifest: %w", err)
+	}
+
+	return partial.CompressedToImage(&fetcherImage{
+		ctx:       ctx,
+		fetcher:   fetcher,
+		raw:       raw,
+		mediaType: mediaType,
+		manifest:  manifest,
+	})
+}
+
+func (i *fetcherImage) MediaType() (types.MediaType, error) {
+	return i.mediaType, nil
+}
+
+func (i *fetcherImage) RawManifest() ([]byte, error) {
+	return i.raw, nil
+}
+
+// RawConfigFile fetches the config blob (only once), verifying the content against the manifest.
+func (i *fetcherImage) RawConfigFile() ([]byte, error) {
+	i.configOnce.Do(func() {
+		i.config, i.configErr = i.fetchConfig()
+	})
+	return i.config, i.configErr
+}
+
+func (i *fetcherImage) fetchConfig() ([]byte, error) {
+	digest := i.manifest.Config.Digest
+	reader, err := i.fetcher.FetchBlob(i.ctx, digest)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch config=%q: %w", digest, err)
+	}
+	defer reader.Close()
+
+	config, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config=%q: %w", digest, err)
+	}
+	if err := verifyDigest(config, digest); err != nil {
+		return nil, fmt.Errorf("config=%q: %w", digest, err)
+	}
+	return config, nil
+}
+
+func (i *fetcherImage) LayerByDigest(digest v1.Hash) (partial.CompressedLayer, error) {
+	for idx, descriptor := range i.manifest.Layers {
+		if descriptor.Digest == digest {
+			return &fetcherLayer{image: i, index: idx, descriptor: descriptor}, nil
+		}
+	}
+	return nil, fmt.Errorf("layer=%q is not within the image manifest", digest)
+}
+
+// fetcherLayer is a layer blob (see partial.CompressedLayer) fetched with a BlobFetcher.
+type fetcherLayer struct {
+	image      *fetcherImage
+	index      int
+	descriptor v1.Descriptor
+}
+
+func (l *fetcherLayer) Digest() (v1.Hash, error) {
+	return l.descriptor.Digest, nil
+}
+
+func (l *fetcherLayer) Size() (int64, error) {
+	return l.descriptor.Size, nil
+}
+
+func (l *fetcherLayer) MediaType() (types.MediaType, error) {
+	return l.descriptor.MediaType, nil
+}
+
+// Compressed fetches the layer blob, where the content is verified against the digest and size of the layer descriptor
+// as it is read (the mismatch is reported at the end of the content instead of io.EOF).
+func (l *fetcherLayer) Compressed() (io.ReadCloser, error) {
+	reader, err := l.image.fetcher.FetchBlob(l.image.ctx, l.descriptor.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch layer=%q: %w", l.descriptor.Digest, err)
+	}
+	return newVerifyingReader(reader, l.descriptor.Digest, l.descriptor.Size)
+}
+
+// DiffID returns the diff ID from the image config, avoiding the need to decompress the layer (the GCR lib assumes
+// gzip compression when computing the diff ID from the blob).
+func (l *fetcherLayer) DiffID() (v1.Hash, error) {
+	raw, err := l.image.RawConfigFile()
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	config, err := v1.ParseConfigFile(bytes.NewReader(raw))
+	if err != nil {
+		return v1.Hash{}, fmt.Errorf("unable to parse config: %w", err)
+	}
+	if l.index >= len(config.RootFS.DiffIDs) {
+		return v1.Hash{}, fmt.Errorf("no diff ID for layer=%q within the image config", l.descriptor.Digest)
+	}
+	return config.RootFS.DiffIDs[l.index], nil
+}
@@ -0,0 +1,59 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// BlobFetcherImageProvider is an image.Provider for an image assembled from manifests and blobs retrieved with a
+// BlobFetcher (e.g. from a content-addressed store that does not implement the OCI distribution API).
+type BlobFetcherImageProvider struct {
+	reference string
+	fetcher   BlobFetcher
+	tmpDirGen *file.TempDirGenerator
+	platform  *v1.Platform
+	config    providerConfig
+}
+
+// NewProviderFromBlobFetcher creates a new provider instance for the image with the given reference (as understood by
+// the fetcher). When the reference resolves to an index, the given platform is selected (see RegistryOptions.Platform),
+// otherwise the only image within the index is selected, falling back to linux/amd64 (as with the registry provider).
+func NewProviderFromBlobFetcher(reference string, fetcher BlobFetcher, tmpDirGen *file.TempDirGenerator, platform *v1.Platform, options ...ProviderOption) *BlobFetcherImageProvider {
+	return &BlobFetcherImageProvider{
+		reference: reference,
+		fetcher:   fetcher,
+		tmpDirGen: tmpDirGen,
+		platform:  platform,
+		config:    newProviderConfig(options...),
+	}
+}
+
+// Provide an image object that represents the image assembled from the fetched blobs.
+func (p *BlobFetcherImageProvider) Provide() (*image.Image, error) {
+	return p.ProvideContext(context.Background())
+}
+
+// ProvideContext is Provide where the given context is given to the fetcher for all requests, including the layer
+// fetches made while the image is read (thus the context must remain valid until Image.Read returns).
+func (p *BlobFetcherImageProvider) ProvideContext(ctx context.Context) (*image.Image, error) {
+	log.Debugf("fetching image=%q with blob fetcher", p.reference)
+
+	raw, mediaType, err := fetchManifest(ctx, p.fetcher, p.reference)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &fetcherManifest{ctx: ctx, fetcher: p.fetcher, raw: raw, mediaType: mediaType}
+	img, platform, err := selectImage(mediaType, root, p.platform)
+	if err != nil {
+		return nil, fmt.Errorf("unable to select image for reference=%q: %w", p.reference, err)
+	}
+
+	// note: the manifest digest is derived from the raw manifest content
+	return assembleImage(img, p.reference, platform, p.config.requireLayers, p.tmpDirGen)
+}
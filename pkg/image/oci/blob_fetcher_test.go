@@ -0,0 +1,287 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryFetcher is an example BlobFetcher backed by an in-memory content-addressed store, where manifests may
+// additionally be named by tag.
+type memoryFetcher struct {
+	manifests map[string][]byte
+	blobs     map[v1.Hash][]byte
+}
+
+func newMemoryFetcher() *memoryFetcher {
+	return &memoryFetcher{
+		manifests: make(map[string][]byte),
+		blobs:     make(map[v1.Hash][]byte),
+	}
+}
+
+func (f *memoryFetcher) FetchManifest(_ context.Context, reference string) ([]byte, error) {
+	raw, ok := f.manifests[reference]
+	if !ok {
+		return nil, fmt.Errorf("manifest not found: %s", reference)
+	}
+	return raw, nil
+}
+
+func (f *memoryFetcher) FetchBlob(_ context.Context, digest v1.Hash) (io.ReadCloser, error) {
+	blob, ok := f.blobs[digest]
+	if !ok {
+		return nil, fmt.Errorf("blob not found: %s", digest)
+	}
+	return ioutil.NopCloser(bytes.NewReader(blob)), nil
+}
+
+// addImage stores the manifest, config, and layers of the given image, returning the manifest digest.
+func (f *memoryFetcher) addImage(t *testing.T, img v1.Image) v1.Hash {
+	t.Helper()
+
+	raw, err := img.RawManifest()
+	require.NoError(t, err)
+	digest, err := img.Digest()
+	require.NoError(t, err)
+	f.manifests[digest.String()] = raw
+
+	config, err := img.RawConfigFile()
+	require.NoError(t, err)
+	configName, err := img.ConfigName()
+	require.NoError(t, err)
+	f.blobs[configName] = config
+
+	layers, err := img.Layers()
+	require.NoError(t, err)
+	for _, layer := range layers {
+		layerDigest, err := layer.Digest()
+		require.NoError(t, err)
+		reader, err := layer.Compressed()
+		require.NoError(t, err)
+		blob, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		require.NoError(t, reader.Close())
+		f.blobs[layerDigest] = blob
+	}
+	return digest
+}
+
+// addIndex stores the given index along with all of its images, returning the index digest.
+func (f *memoryFetcher) addIndex(t *testing.T, index v1.ImageIndex) v1.Hash {
+	t.Helper()
+
+	indexManifest, err := index.IndexManifest()
+	require.NoError(t, err)
+	for _, descriptor := range indexManifest.Manifests {
+		img, err := index.Image(descriptor.Digest)
+		require.NoError(t, err)
+		f.addImage(t, img)
+	}
+
+	raw, err := index.RawManifest()
+	require.NoError(t, err)
+	digest, err := index.Digest()
+	require.NoError(t, err)
+	f.manifests[digest.String()] = raw
+	return digest
+}
+
+func TestBlobFetcherImageProvider_Provide(t *testing.T) {
+	img, err := random.Image(1024, 3)
+	require.NoError(t, err)
+
+	fetcher := newMemoryFetcher()
+	digest := fetcher.addImage(t, img)
+	// the store may name manifests by something other than a digest
+	fetcher.manifests["latest"] = fetcher.manifests[digest.String()]
+
+	for _, reference := range []string{digest.String(), "latest"} {
+		t.Run(reference, func(t *testing.T) {
+			tmpDirGen := file.NewTempDirGenerator()
+			t.Cleanup(func() {
+				assert.NoError(t, tmpDirGen.Cleanup())
+			})
+
+			provided, err := NewProviderFromBlobFetcher(reference, fetcher, &tmpDirGen, nil).Provide()
+			require.NoError(t, err)
+			require.NoError(t, provided.Read())
+
+			assert.Equal(t, digest.String(), provided.Metadata.ManifestDigest)
+
+			expectedLayers, err := img.Layers()
+			require.NoError(t, err)
+			require.Len(t, provided.Layers, len(expectedLayers))
+			for idx, layer := range expectedLayers {
+				diffID, err := layer.DiffID()
+				require.NoError(t, err)
+				assert.Equal(t, diffID.String(), provided.Layers[idx].Metadata.Digest)
+			}
+		})
+	}
+}
+
+func TestBlobFetcherImageProvider_Provide_Index(t *testing.T) {
+	platforms := []v1.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64"},
+	}
+
+	var digests []v1.Hash
+	var index v1.ImageIndex = empty.Index
+	for _, platform := range platforms {
+		platform := platform
+		img, err := random.Image(1024, 1)
+		require.NoError(t, err)
+		digest, err := img.Digest()
+		require.NoError(t, err)
+		digests = append(digests, digest)
+		index = mutate.AppendManifests(index, mutate.IndexAddendum{
+			Add:        img,
+			Descriptor: v1.Descriptor{Platform: &platform},
+		})
+	}
+
+	fetcher := newMemoryFetcher()
+	indexDigest := fetcher.addIndex(t, index)
+
+	tmpDirGen := file.NewTempDirGenerator()
+	t.Cleanup(func() {
+		assert.NoError(t, tmpDirGen.Cleanup())
+	})
+
+	provided, err := NewProviderFromBlobFetcher(indexDigest.String(), fetcher, &tmpDirGen, &platforms[1]).Provide()
+	require.NoError(t, err)
+	require.NoError(t, provided.Read())
+	assert.Equal(t, digests[1].String(), provided.Metadata.ManifestDigest)
+
+	// without a platform the default platform is selected (as with the registry provider)
+	provided, err = NewProviderFromBlobFetcher(indexDigest.String(), fetcher, &tmpDirGen, nil).Provide()
+	require.NoError(t, err)
+	require.NoError(t, provided.Read())
+	assert.Equal(t, digests[0].String(), provided.Metadata.ManifestDigest)
+
+	_, err = NewProviderFromBlobFetcher(indexDigest.String(), fetcher, &tmpDirGen, &v1.Platform{OS: "linux", Architecture: "s390x"}).Provide()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no image found for platform")
+}
+
+func firstLayerDigest(t *testing.T, img v1.Image) v1.Hash {
+	t.Helper()
+
+	layers, err := img.Layers()
+	require.NoError(t, err)
+	digest, err := layers[0].Digest()
+	require.NoError(t, err)
+	return digest
+}
+
+func Test_verifyingReader(t *testing.T) {
+	content := []byte("layer content")
+	digest, size, err := v1.SHA256(bytes.NewReader(content))
+	require.NoError(t, err)
+
+	reader, err := newVerifyingReader(ioutil.NopCloser(bytes.NewReader(content)), digest, size)
+	require.NoError(t, err)
+	actual, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, content, actual)
+
+	reader, err = newVerifyingReader(ioutil.NopCloser(bytes.NewReader(append(content, '!'))), digest, size)
+	require.NoError(t, err)
+	_, err = ioutil.ReadAll(reader)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "exceeds the expected size")
+
+	_, err = newVerifyingReader(ioutil.NopCloser(bytes.NewReader(content)), v1.Hash{Algorithm: "md5", Hex: "00"}, size)
+	assert.Error(t, err)
+}
+
+func TestBlobFetcherImageProvider_Provide_Verification(t *testing.T) {
+	img, err := random.Image(1024, 1)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		corrupt func(f *memoryFetcher, digest v1.Hash)
+		wantErr string
+	}{
+		{
+			name: "manifest digest mismatch",
+			corrupt: func(f *memoryFetcher, digest v1.Hash) {
+				f.manifests[digest.String()] = append(f.manifests[digest.String()], ' ')
+			},
+			wantErr: "does not match expected digest",
+		},
+		{
+			name: "config digest mismatch",
+			corrupt: func(f *memoryFetcher, _ v1.Hash) {
+				configName, err := img.ConfigName()
+				require.NoError(t, err)
+				f.blobs[configName] = append(f.blobs[configName], ' ')
+			},
+			wantErr: "does not match expected digest",
+		},
+		{
+			name: "layer digest mismatch",
+			corrupt: func(f *memoryFetcher, _ v1.Hash) {
+				layerDigest := firstLayerDigest(t, img)
+				// substitute content of the same size that still decompresses (the gzip header mtime is not checksummed)
+				substituted := make([]byte, len(f.blobs[layerDigest]))
+				copy(substituted, f.blobs[layerDigest])
+				substituted[4] ^= 0xff
+				f.blobs[layerDigest] = substituted
+			},
+			wantErr: "does not match expected digest",
+		},
+		{
+			name: "layer size mismatch",
+			corrupt: func(f *memoryFetcher, _ v1.Hash) {
+				layerDigest := firstLayerDigest(t, img)
+				f.blobs[layerDigest] = f.blobs[layerDigest][:len(f.blobs[layerDigest])-1]
+			},
+			wantErr: "does not match expected size",
+		},
+		{
+			name: "missing layer blob",
+			corrupt: func(f *memoryFetcher, _ v1.Hash) {
+				layers, err := img.Layers()
+				require.NoError(t, err)
+				layerDigest, err := layers[0].Digest()
+				require.NoError(t, err)
+				delete(f.blobs, layerDigest)
+			},
+			wantErr: "blob not found",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fetcher := newMemoryFetcher()
+			digest := fetcher.addImage(t, img)
+			test.corrupt(fetcher, digest)
+
+			tmpDirGen := file.NewTempDirGenerator()
+			t.Cleanup(func() {
+				assert.NoError(t, tmpDirGen.Cleanup())
+			})
+
+			provided, err := NewProviderFromBlobFetcher(digest.String(), fetcher, &tmpDirGen, nil).Provide()
+			if err == nil {
+				err = provided.Read()
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.wantErr)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package oci
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/anchore/stereoscope/internal/log"
+)
+
+// contentEncodingTransport is a http.RoundTripper that decodes manifest and blob responses with a HTTP content
+// encoding (e.g. "Content-Encoding: gzip" added by a proxy), since the registry client expects the raw content. The
+// net/http transport only decodes responses to requests where it asked for gzip itself, and never decodes deflate.
+// Note: this is independent of any layer compression (e.g. a gzip layer media type), which is part of the blob content.
+type contentEncodingTransport struct {
+	base http.RoundTripper
+}
+
+// newContentEncodingTransport creates a transport that transparently decodes gzip and deflate encoded registry
+// responses for manifests and blobs (which includes the image config).
+func newContentEncodingTransport(base http.RoundTripper) http.RoundTripper {
+	return &contentEncodingTransport{
+		base: base,
+	}
+}
+
+func (t *contentEncodingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	// note: HEAD responses (and other responses without content) have nothing to decode
+	if err != nil || req.Method != http.MethodGet || resp.Body == nil || resp.ContentLength == 0 || !(isManifestRequest(req) || isBlobRequest(req)) {
+		return resp, err
+	}
+
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	var decoded io.ReadCloser
+	switch encoding {
+	case "", "identity":
+		return resp, nil
+	case "gzip", "x-gzip":
+		gzipReader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			_ = resp.Body.Close()
+			return nil, fmt.Errorf("unable to decode gzip response for %q: %w", req.URL.Redacted(), err)
+		}
+		decoded = &decodedBody{Reader: gzipReader, decoder: gzipReader, body: resp.Body}
+	case "deflate":
+		// note: the HTTP deflate content coding is the zlib format (RFC 9110 section 8.4.1.2), not raw deflate
+		zlibReader, err := zlib.NewReader(resp.Body)
+		if err != nil {
+			_ = resp.Body.Close()
+			return nil, fmt.Errorf("unable to decode deflate response for %q: %w", req.URL.Redacted(), err)
+		}
+		decoded = &decodedBody{Reader: zlibReader, decoder: zlibReader, body: resp.Body}
+	default:
+		log.Warnf("unsupported content encoding=%q for %q", encoding, req.URL.Redacted())
+		return resp, nil
+	}
+
+	log.Debugf("decoding %s content encoding for %q", encoding, req.URL.Redacted())
+	resp.Body = decoded
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// isBlobRequest indicates if the given request fetches a blob (a config or layer) from a registry.
+func isBlobRequest(req *http.Request) bool {
+	return strings.HasPrefix(req.URL.Path, "/v2/") && strings.Contains(req.URL.Path, "/blobs/")
+}
+
+// decodedBody is a decoded response body, where closing the body closes both the decoder and the original body.
+type decodedBody struct {
+	io.Reader
+	decoder io.Closer
+	body    io.Closer
+}
+
+func (b *decodedBody) Close() error {
+	decoderErr := b.decoder.Close()
+	if err := b.body.Close(); err != nil {
+		return err
+	}
+	return decoderErr
+}
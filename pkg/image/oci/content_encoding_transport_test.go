@@ -0,0 +1,117 @@
+package oci
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encodingRegistry wraps a registry handler such that all manifest and blob GET responses are sent with the given HTTP
+// content encoding (as some proxies do), counting the encoded responses.
+func encodingRegistry(t *testing.T, encoding string, encoded *int32) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet || !(strings.Contains(r.URL.Path, "/manifests/") || strings.Contains(r.URL.Path, "/blobs/")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			recorder := httptest.NewRecorder()
+			next.ServeHTTP(recorder, r)
+
+			body := &bytes.Buffer{}
+			var writer io.WriteCloser
+			switch encoding {
+			case "gzip":
+				writer = gzip.NewWriter(body)
+			case "deflate":
+				writer = zlib.NewWriter(body)
+			}
+			_, err := writer.Write(recorder.Body.Bytes())
+			require.NoError(t, err)
+			require.NoError(t, writer.Close())
+
+			for key, values := range recorder.Header() {
+				w.Header()[key] = values
+			}
+			w.Header().Del("Content-Length")
+			w.Header().Set("Content-Encoding", encoding)
+			w.WriteHeader(recorder.Code)
+			_, _ = w.Write(body.Bytes())
+			atomic.AddInt32(encoded, 1)
+		})
+	}
+}
+
+func TestRegistryImageProvider_Provide_ContentEncoding(t *testing.T) {
+	for _, encoding := range []string{"gzip", "deflate"} {
+		t.Run(encoding, func(t *testing.T) {
+			var encoded, pushed int32
+			imageStr := pushRandomImage(t, func(next http.Handler) http.Handler {
+				wrapped := encodingRegistry(t, encoding, &encoded)(next)
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if atomic.LoadInt32(&pushed) == 0 {
+						next.ServeHTTP(w, r)
+						return
+					}
+					wrapped.ServeHTTP(w, r)
+				})
+			})
+			atomic.StoreInt32(&pushed, 1)
+
+			tmpDirGen := file.NewTempDirGenerator()
+			t.Cleanup(func() {
+				assert.NoError(t, tmpDirGen.Cleanup())
+			})
+
+			provider := NewProviderFromRegistry(imageStr, &tmpDirGen, &image.RegistryOptions{
+				InsecureUseHTTP: true,
+				// note: when the client asks for an encoding itself the net/http transport does not decode responses
+				ExtraHeaders: map[string]string{"Accept-Encoding": "gzip, deflate"},
+			})
+			img, err := provider.Provide()
+			require.NoError(t, err)
+			require.NoError(t, img.Read())
+
+			assert.NotEmpty(t, img.Metadata.Config.RootFS.DiffIDs)
+			assert.Len(t, img.Layers, 2)
+			assert.Greater(t, atomic.LoadInt32(&encoded), int32(0))
+		})
+	}
+}
+
+func TestContentEncodingTransport_IgnoresOtherRequests(t *testing.T) {
+	payload := []byte("not encoded by the transport")
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Header:        http.Header{"Content-Encoding": []string{"gzip"}},
+			Body:          ioutil.NopCloser(bytes.NewReader(payload)),
+			ContentLength: int64(len(payload)),
+		}, nil
+	})
+
+	transport := newContentEncodingTransport(base)
+	for _, target := range []string{"http://example.com/archive.tar.gz", "http://example.com/token"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		resp, err := transport.RoundTrip(req)
+		require.NoError(t, err)
+
+		contents, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, payload, contents)
+		assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	}
+}
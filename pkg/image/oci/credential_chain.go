@@ -0,0 +1,173 @@
+package oci
+
+import (
+	"os"
+	"sync"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/docker/cli/cli/config"
+	"github.com/docker/cli/cli/config/configfile"
+	"github.com/docker/cli/cli/config/credentials"
+	"github.com/docker/cli/cli/config/types"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// dockerConfigLock serializes loading the docker config, which is not safe for concurrent use.
+var dockerConfigLock sync.Mutex
+
+// credentialSource is a single tier of the registry credential resolution order. A nil authenticator indicates that
+// the source has no credentials for the target registry. The name of the credential helper used is returned (if any).
+type credentialSource struct {
+	name    string
+	source  image.CredentialSource
+	resolve func(target authn.Resource) (authn.Authenticator, string, error)
+}
+
+// credentialChain is an authn.Keychain that resolves registry credentials from each source in order, using the first
+// source with credentials for the target registry. The order is:
+//  1. explicit credentials from the RegistryOptions
+//  2. the credential helper configured in the docker config (credHelpers or credsStore)
+//  3. the credentials stored within the docker config file (auths)
+//  4. anonymous access
+//
+// A source that fails to resolve credentials is logged and skipped.
+type credentialChain struct {
+	sources []credentialSource
+}
+
+var _ authn.Keychain = (*credentialChain)(nil)
+
+func newCredentialChain(registryOptions *image.RegistryOptions) *credentialChain {
+	return &credentialChain{
+		sources: []credentialSource{
+			{
+				name:   "registry options",
+				source: image.RegistryOptionsCredentialSource,
+				resolve: func(target authn.Resource) (authn.Authenticator, string, error) {
+					if registryOptions == nil {
+						return nil, "", nil
+					}
+					return registryOptions.Authenticator(target.RegistryStr()), "", nil
+				},
+			},
+			{
+				name:    "docker credential helper",
+				source:  image.CredentialHelperCredentialSource,
+				resolve: resolveFromCredentialHelper,
+			},
+			{
+				name:    "docker config",
+				source:  image.DockerConfigCredentialSource,
+				resolve: resolveFromDockerConfig,
+			},
+		},
+	}
+}
+
+// Resolve returns the authenticator from the first credential source with credentials for the given target, falling
+// back to anonymous access. The selected source is reported with image.NotifyCredentialSelection.
+func (c *credentialChain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	for _, source := range c.sources {
+		authenticator, helper, err := source.resolve(target)
+		if err != nil {
+			log.Warnf("unable to resolve registry credentials from %s for %q: %+v", source.name, target.RegistryStr(), err)
+			continue
+		}
+		if authenticator == nil || authenticator == authn.Anonymous {
+			continue
+		}
+
+		log.Debugf("using registry credentials from %s for %q", source.name, target.RegistryStr())
+		image.NotifyCredentialSelection(image.CredentialSelection{
+			Registry: target.RegistryStr(),
+			Source:   source.source,
+			Helper:   helper,
+		})
+		return authenticator, nil
+	}
+
+	log.Debugf("no registry credentials found for %q, using anonymous access", target.RegistryStr())
+	image.NotifyCredentialSelection(image.CredentialSelection{
+		Registry: target.RegistryStr(),
+		Source:   image.AnonymousCredentialSource,
+	})
+	return authn.Anonymous, nil
+}
+
+// resolveFromCredentialHelper returns credentials from the credential helper configured for the target registry (or
+// the default credential store) within the docker config, along with the name of the helper.
+func resolveFromCredentialHelper(target authn.Resource) (authn.Authenticator, string, error) {
+	cf, key, err := loadDockerConfig(target)
+	if err != nil {
+		return nil, "", err
+	}
+
+	helper, exists := cf.CredentialHelpers[key]
+	if !exists {
+		helper = cf.CredentialsStore
+	}
+	if helper == "" {
+		return nil, "", nil
+	}
+
+	cfg, err := credentials.NewNativeStore(cf, helper).Get(key)
+	if err != nil {
+		return nil, "", err
+	}
+	// note: the native store merges in any entry from the docker config file, only the credentials from the helper
+	// itself are considered here (the docker config file is the next source in the chain)
+	return authenticatorFromAuthConfig(types.AuthConfig{
+		Username:      cfg.Username,
+		Password:      cfg.Password,
+		IdentityToken: cfg.IdentityToken,
+	}), helper, nil
+}
+
+// resolveFromDockerConfig returns the credentials stored directly within the docker config file.
+func resolveFromDockerConfig(target authn.Resource) (authn.Authenticator, string, error) {
+	cf, key, err := loadDockerConfig(target)
+	if err != nil {
+		return nil, "", err
+	}
+
+	cfg, err := credentials.NewFileStore(cf).Get(key)
+	if err != nil {
+		return nil, "", err
+	}
+	return authenticatorFromAuthConfig(cfg), "", nil
+}
+
+// loadDockerConfig loads the docker config (honoring DOCKER_CONFIG) and returns the key for the target registry within
+// the config (where docker hub credentials are stored under a legacy key).
+func loadDockerConfig(target authn.Resource) (*configfile.ConfigFile, string, error) {
+	dockerConfigLock.Lock()
+	defer dockerConfigLock.Unlock()
+
+	cf, err := config.Load(os.Getenv("DOCKER_CONFIG"))
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := target.RegistryStr()
+	if key == name.DefaultRegistry {
+		key = authn.DefaultAuthKey
+	}
+	return cf, key, nil
+}
+
+// authenticatorFromAuthConfig returns an authenticator for the given docker auth config, or nil if the config has no
+// credentials.
+func authenticatorFromAuthConfig(cfg types.AuthConfig) authn.Authenticator {
+	if cfg.Username == "" && cfg.Password == "" && cfg.Auth == "" && cfg.IdentityToken == "" && cfg.RegistryToken == "" {
+		return nil
+	}
+	return authn.FromConfig(authn.AuthConfig{
+		Username:      cfg.Username,
+		Password:      cfg.Password,
+		Auth:          cfg.Auth,
+		IdentityToken: cfg.IdentityToken,
+		RegistryToken: cfg.RegistryToken,
+	})
+}
@@ -0,0 +1,204 @@
+package oci
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setEnv sets the environment variable for the duration of the test.
+func setEnv(t *testing.T, key, value string) {
+	t.Helper()
+
+	original, exists := os.LookupEnv(key)
+	t.Cleanup(func() {
+		if exists {
+			_ = os.Setenv(key, original)
+		} else {
+			_ = os.Unsetenv(key)
+		}
+	})
+	require.NoError(t, os.Setenv(key, value))
+}
+
+// writeDockerConfig writes a docker config with the given auths and credential helpers, returning the config dir.
+func writeDockerConfig(t *testing.T, auths map[string]string, credHelpers map[string]string) string {
+	t.Helper()
+
+	var authEntries, helperEntries []string
+	for registry, auth := range auths {
+		authEntries = append(authEntries, fmt.Sprintf(`%q: {"auth": %q}`, registry, auth))
+	}
+	for registry, helper := range credHelpers {
+		helperEntries = append(helperEntries, fmt.Sprintf(`%q: %q`, registry, helper))
+	}
+
+	dir := t.TempDir()
+	contents := fmt.Sprintf(`{"auths": {%s}, "credHelpers": {%s}}`, strings.Join(authEntries, ","), strings.Join(helperEntries, ","))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "config.json"), []byte(contents), 0600))
+	return dir
+}
+
+// installCredentialHelper installs a "docker-credential-<name>" helper onto the PATH that returns the given secret for
+// the given registry (and no credentials otherwise).
+func installCredentialHelper(t *testing.T, helperName, registry, username, secret string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	script := fmt.Sprintf(`#!/bin/sh
+read server
+if [ "$server" = %q ]; then
+  echo '{"ServerURL": "%s", "Username": "%s", "Secret": "%s"}'
+  exit 0
+fi
+echo "credentials not found in native keychain"
+exit 1
+`, registry, registry, username, secret)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "docker-credential-"+helperName), []byte(script), 0755))
+	setEnv(t, "PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestCredentialChain_Resolve(t *testing.T) {
+	// "user:config-pass"
+	configAuth := "dXNlcjpjb25maWctcGFzcw=="
+
+	tests := []struct {
+		name            string
+		registryOptions *image.RegistryOptions
+		auths           map[string]string
+		credHelpers     map[string]string
+		helperRegistry  string
+		helperSecret    string
+		expected        *authn.AuthConfig
+		expectedSource  image.CredentialSelection
+	}{
+		{
+			name: "explicit credentials take precedence",
+			registryOptions: &image.RegistryOptions{
+				Credentials: []image.RegistryCredentials{
+					{Authority: "localhost:5000", Username: "user", Password: "option-pass"},
+				},
+			},
+			auths:        map[string]string{"localhost:5000": configAuth},
+			credHelpers:  map[string]string{"localhost:5000": "stereoscope-test"},
+			helperSecret: "helper-pass",
+			expected:     &authn.AuthConfig{Username: "user", Password: "option-pass"},
+			expectedSource: image.CredentialSelection{
+				Registry: "localhost:5000",
+				Source:   image.RegistryOptionsCredentialSource,
+			},
+		},
+		{
+			name: "credential helper over docker config",
+			registryOptions: &image.RegistryOptions{
+				Credentials: []image.RegistryCredentials{
+					{Authority: "other:5000", Username: "user", Password: "option-pass"},
+				},
+			},
+			auths:        map[string]string{"localhost:5000": configAuth},
+			credHelpers:  map[string]string{"localhost:5000": "stereoscope-test"},
+			helperSecret: "helper-pass",
+			expected:     &authn.AuthConfig{Username: "user", Password: "helper-pass"},
+			expectedSource: image.CredentialSelection{
+				Registry: "localhost:5000",
+				Source:   image.CredentialHelperCredentialSource,
+				Helper:   "stereoscope-test",
+			},
+		},
+		{
+			name:           "credential helper without credentials falls back to docker config",
+			auths:          map[string]string{"localhost:5000": configAuth},
+			credHelpers:    map[string]string{"localhost:5000": "stereoscope-test"},
+			helperRegistry: "other:5000",
+			helperSecret:   "helper-pass",
+			expected:       &authn.AuthConfig{Username: "user", Password: "config-pass"},
+			expectedSource: image.CredentialSelection{
+				Registry: "localhost:5000",
+				Source:   image.DockerConfigCredentialSource,
+			},
+		},
+		{
+			name:        "failing credential helper falls back to docker config",
+			auths:       map[string]string{"localhost:5000": configAuth},
+			credHelpers: map[string]string{"localhost:5000": "does-not-exist"},
+			expected:    &authn.AuthConfig{Username: "user", Password: "config-pass"},
+			expectedSource: image.CredentialSelection{
+				Registry: "localhost:5000",
+				Source:   image.DockerConfigCredentialSource,
+			},
+		},
+		{
+			name:            "docker config",
+			registryOptions: &image.RegistryOptions{},
+			auths:           map[string]string{"localhost:5000": configAuth},
+			expected:        &authn.AuthConfig{Username: "user", Password: "config-pass"},
+			expectedSource: image.CredentialSelection{
+				Registry: "localhost:5000",
+				Source:   image.DockerConfigCredentialSource,
+			},
+		},
+		{
+			name:  "anonymous",
+			auths: map[string]string{"other:5000": configAuth},
+			expectedSource: image.CredentialSelection{
+				Registry: "localhost:5000",
+				Source:   image.AnonymousCredentialSource,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setEnv(t, "DOCKER_CONFIG", writeDockerConfig(t, test.auths, test.credHelpers))
+			if test.helperSecret != "" {
+				helperRegistry := test.helperRegistry
+				if helperRegistry == "" {
+					helperRegistry = "localhost:5000"
+				}
+				installCredentialHelper(t, "stereoscope-test", helperRegistry, "user", test.helperSecret)
+			}
+
+			var selections []image.CredentialSelection
+			image.SetCredentialSelectionHandler(func(selection image.CredentialSelection) {
+				selections = append(selections, selection)
+			})
+			t.Cleanup(func() {
+				image.SetCredentialSelectionHandler(nil)
+			})
+
+			repo, err := name.NewRepository("localhost:5000/some/image")
+			require.NoError(t, err)
+
+			authenticator, err := newCredentialChain(test.registryOptions).Resolve(repo)
+			require.NoError(t, err)
+			assert.Equal(t, []image.CredentialSelection{test.expectedSource}, selections)
+
+			if test.expected == nil {
+				assert.Equal(t, authn.Anonymous, authenticator)
+				return
+			}
+
+			actual, err := authenticator.Authorization()
+			require.NoError(t, err)
+			if actual.Auth != "" {
+				// expand the basic auth from the docker config for comparison
+				decoded, err := base64.StdEncoding.DecodeString(actual.Auth)
+				require.NoError(t, err)
+				parts := strings.SplitN(string(decoded), ":", 2)
+				actual.Username, actual.Password = parts[0], parts[1]
+			}
+			assert.Equal(t, test.expected.Username, actual.Username)
+			assert.Equal(t, test.expected.Password, actual.Password)
+		})
+	}
+}
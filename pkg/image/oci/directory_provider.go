@@ -2,68 +2,198 @@ package oci
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/anchore/stereoscope/internal/log"
 	"github.com/anchore/stereoscope/pkg/file"
 	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/layout"
 )
 
+const (
+	// dockerReferenceTypeAnnotation is set by buildkit on index entries that describe another manifest in the index
+	dockerReferenceTypeAnnotation = "vnd.docker.reference.type"
+	// attestationManifestType is the dockerReferenceTypeAnnotation value for attestation manifests
+	attestationManifestType = "attestation-manifest"
+	// refNameAnnotation is the tag (or full reference) of an image within the index of an OCI layout
+	refNameAnnotation = "org.opencontainers.image.ref.name"
+)
+
 // DirectoryImageProvider is an image.Provider for an OCI image (V1) for an existing tar on disk (from a buildah push <img> oci:<img> command).
 type DirectoryImageProvider struct {
 	path      string
+	tag       string
 	tmpDirGen *file.TempDirGenerator
+	config    providerConfig
 }
 
 // NewProviderFromPath creates a new provider instance for the specific image already at the given path.
-func NewProviderFromPath(path string, tmpDirGen *file.TempDirGenerator) *DirectoryImageProvider {
+func NewProviderFromPath(path string, tmpDirGen *file.TempDirGenerator, options ...ProviderOption) *DirectoryImageProvider {
 	return &DirectoryImageProvider{
 		path:      path,
 		tmpDirGen: tmpDirGen,
+		config:    newProviderConfig(options...),
 	}
 }
 
+// NewProviderFromPathWithTag creates a new provider instance for the image with the given tag within the layout at the
+// given path, for layouts holding several images (see image.ParseOCIDirectoryLocation). The image is the descriptor
+// within index.json annotated with the tag (either as the tag itself or as a reference with the tag, e.g.
+// "docker.io/library/alpine:mytag"), where a tagged index is searched for the image (skipping attestations).
+func NewProviderFromPathWithTag(path string, tmpDirGen *file.TempDirGenerator, tag string, options ...ProviderOption) *DirectoryImageProvider {
+	p := NewProviderFromPath(path, tmpDirGen, options...)
+	p.tag = tag
+	return p
+}
+
 // Provide an image object that represents the OCI image as a directory.
 func (p *DirectoryImageProvider) Provide() (*image.Image, error) {
-	pathObj, err := layout.FromPath(p.path)
+	layoutPath, err := canonicalLayout(p.path, p.tmpDirGen)
 	if err != nil {
-		return nil, fmt.Errorf("unable to read image from OCI directory path %q: %w", p.path, err)
+		return nil, fmt.Errorf("unable to locate OCI directory blobs: %w", err)
 	}
 
-	index, err := layout.ImageIndexFromPath(p.path)
+	index, err := layout.ImageIndexFromPath(layoutPath)
 	if err != nil {
 		return nil, fmt.Errorf("unable to parse OCI directory index: %w", err)
 	}
 
-	indexManifest, err := index.IndexManifest()
+	var candidates []indexedManifest
+	if p.tag != "" {
+		candidates, err = taggedImageManifests(index, p.tag)
+	} else {
+		candidates, err = imageManifests(index)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("unable to parse OCI directory indexManifest: %w", err)
 	}
 
 	// for now, lets only support one image indexManifest (it is not clear how to handle multiple manifests)
-	if len(indexManifest.Manifests) != 1 {
-		return nil, fmt.Errorf("unexpected number of OCI directory manifests (found %d)", len(indexManifest.Manifests))
+	if len(candidates) != 1 {
+		if p.tag != "" {
+			return nil, fmt.Errorf("unexpected number of OCI directory manifests with tag=%q (found %d)", p.tag, len(candidates))
+		}
+		return nil, fmt.Errorf("unexpected number of OCI directory manifests (found %d)", len(candidates))
 	}
 
-	manifest := indexManifest.Manifests[0]
-	img, err := pathObj.Image(manifest.Digest)
+	manifest := candidates[0].descriptor
+	img, err := candidates[0].index.Image(manifest.Digest)
 	if err != nil {
 		return nil, fmt.Errorf("unable to parse OCI directory as an image: %w", err)
 	}
 
-	var metadata = []image.AdditionalMetadata{
+	// note: layer blobs in the layout may not be gzip compressed (as the GCR lib assumes), which assembleImage handles
+	return assembleImage(img, p.path, manifest.Platform, p.config.requireLayers, p.tmpDirGen,
 		image.WithManifestDigest(manifest.Digest.String()),
+	)
+}
+
+// indexedManifest is an image manifest descriptor along with the index that it was found in.
+type indexedManifest struct {
+	descriptor v1.Descriptor
+	index      v1.ImageIndex
+}
+
+// imageManifests returns all runnable image manifests referenced by the given index, descending into nested indexes
+// (e.g. buildkit wraps the image in an index alongside any attestations). Attestation manifests are skipped.
+func imageManifests(index v1.ImageIndex) ([]indexedManifest, error) {
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, err
 	}
 
-	// make a best-effort attempt at getting the raw indexManifest
-	rawManifest, err := img.RawManifest()
-	if err == nil {
-		metadata = append(metadata, image.WithManifest(rawManifest))
+	var manifests []indexedManifest
+	for _, descriptor := range indexManifest.Manifests {
+		if isAttestation(descriptor) {
+			log.Debugf("skipping OCI attestation manifest=%q", descriptor.Digest)
+			continue
+		}
+
+		if image.SourceFromMediaType(string(descriptor.MediaType)) != image.IndexMediaTypeKind {
+			manifests = append(manifests, indexedManifest{descriptor: descriptor, index: index})
+			continue
+		}
+
+		nested, err := index.ImageIndex(descriptor.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read nested index=%q: %w", descriptor.Digest, err)
+		}
+
+		nestedManifests, err := imageManifests(nested)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, nestedManifests...)
 	}
 
-	contentTempDir, err := p.tmpDirGen.NewTempDir()
+	return manifests, nil
+}
+
+// taggedImageManifests returns the runnable image manifests referenced by the descriptors within the given index that
+// are annotated with the given tag (see imageManifests).
+func taggedImageManifests(index v1.ImageIndex, tag string) ([]indexedManifest, error) {
+	indexManifest, err := index.IndexManifest()
 	if err != nil {
 		return nil, err
 	}
 
-	return image.NewImage(img, contentTempDir, metadata...), nil
+	var tags []string
+	var manifests []indexedManifest
+	for _, descriptor := range indexManifest.Manifests {
+		refName, ok := descriptor.Annotations[refNameAnnotation]
+		if !ok {
+			continue
+		}
+		tags = append(tags, refName)
+		if !refNameHasTag(refName, tag) {
+			continue
+		}
+
+		if image.SourceFromMediaType(string(descriptor.MediaType)) != image.IndexMediaTypeKind {
+			manifests = append(manifests, indexedManifest{descriptor: descriptor, index: index})
+			continue
+		}
+
+		nested, err := index.ImageIndex(descriptor.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read nested index=%q: %w", descriptor.Digest, err)
+		}
+
+		nestedManifests, err := imageManifests(nested)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, nestedManifests...)
+	}
+
+	if len(manifests) == 0 {
+		return nil, fmt.Errorf("no manifest with tag=%q (tags within the index: %s)", tag, strings.Join(tags, ", "))
+	}
+	return manifests, nil
+}
+
+// refNameHasTag indicates if the given ref name annotation is the given tag, either as the tag itself (e.g. "mytag") or
+// as a reference with the tag (e.g. "docker.io/library/alpine:mytag").
+func refNameHasTag(refName, tag string) bool {
+	if refName == tag {
+		return true
+	}
+	if !strings.HasSuffix(refName, ":"+tag) {
+		return false
+	}
+	// the suffix must be the tag of the reference (not e.g. the port of a registry)
+	ref, err := name.NewTag(refName, name.WeakValidation)
+	return err == nil && ref.TagStr() == tag
+}
+
+// isAttestation indicates if the given descriptor refers to an attestation manifest (e.g. provenance or SBOM
+// attestations from "docker buildx build --provenance") instead of a runnable image.
+func isAttestation(descriptor v1.Descriptor) bool {
+	if descriptor.Annotations[dockerReferenceTypeAnnotation] == attestationManifestType {
+		return true
+	}
+	// buildkit additionally marks attestations with an unknown platform, which no runnable image can have
+	return descriptor.Platform != nil && descriptor.Platform.OS == "unknown" && descriptor.Platform.Architecture == "unknown"
 }
@@ -0,0 +1,379 @@
+package oci
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirectoryImageProvider_Provide_BuildxProvenance(t *testing.T) {
+	// this fixture mirrors the OCI layout written by "docker buildx build --provenance=true --output type=oci": the
+	// top-level index refers to a nested index, which holds both the image and an in-toto attestation manifest.
+	tmpDirGen := file.NewTempDirGenerator()
+	t.Cleanup(func() {
+		assert.NoError(t, tmpDirGen.Cleanup())
+	})
+
+	img, err := NewProviderFromPath("test-fixtures/buildx-provenance", &tmpDirGen).Provide()
+	require.NoError(t, err)
+	require.NoError(t, img.Read())
+
+	assert.Equal(t, "linux", img.Metadata.Config.OS)
+	assert.Equal(t, "amd64", img.Metadata.Config.Architecture)
+	require.Len(t, img.Layers, 1)
+
+	reader, err := img.FileContentsFromSquash("/etc/hello.txt")
+	require.NoError(t, err)
+	contents, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "hello from buildx\n", string(contents))
+}
+
+func TestDirectoryImageProvider_Provide_CompressedLayers(t *testing.T) {
+	// this fixture has a gzip, zstd, and an uncompressed layer blob (each labeled with the appropriate media type)
+	tmpDirGen := file.NewTempDirGenerator()
+	t.Cleanup(func() {
+		assert.NoError(t, tmpDirGen.Cleanup())
+	})
+
+	img, err := NewProviderFromPath("test-fixtures/compressed-layers", &tmpDirGen).Provide()
+	require.NoError(t, err)
+	require.NoError(t, img.Read())
+	require.Len(t, img.Layers, 3)
+
+	expected := map[string]string{
+		"/gzip.txt":         "from a gzip layer\n",
+		"/zstd.txt":         "from a zstd layer\n",
+		"/uncompressed.txt": "from an uncompressed layer\n",
+	}
+
+	for path, contents := range expected {
+		reader, err := img.FileContentsFromSquash(file.Path(path))
+		require.NoError(t, err)
+		actual, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, contents, string(actual), path)
+	}
+}
+
+func TestDirectoryImageProvider_Provide_NonCanonicalBlobs(t *testing.T) {
+	// this fixture references the manifest, config, and one layer by a layout-relative path within the descriptor
+	// "urls" (the remaining layer is at the canonical location)
+	tmpDirGen := file.NewTempDirGenerator()
+	t.Cleanup(func() {
+		assert.NoError(t, tmpDirGen.Cleanup())
+	})
+
+	img, err := NewProviderFromPath("test-fixtures/non-canonical-blobs", &tmpDirGen).Provide()
+	require.NoError(t, err)
+	require.NoError(t, img.Read())
+	require.Len(t, img.Layers, 2)
+
+	expected := map[string]string{
+		"/relative.txt":  "from a layer at a relative path\n",
+		"/canonical.txt": "from a layer at the canonical path\n",
+	}
+
+	for path, contents := range expected {
+		reader, err := img.FileContentsFromSquash(file.Path(path))
+		require.NoError(t, err)
+		actual, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, contents, string(actual), path)
+	}
+}
+
+func TestDirectoryImageProvider_Provide_Tag(t *testing.T) {
+	// the layout holds a buildkit export (an index tagged "latest") along with two more tagged images
+	layoutDir := t.TempDir()
+	latestDigest := buildkitLayout(t, layoutDir)
+
+	layoutPath, err := layout.FromPath(layoutDir)
+	require.NoError(t, err)
+
+	digests := map[string]string{"latest": latestDigest}
+	for tag, refName := range map[string]string{"one": "one", "two": "docker.io/library/app:two"} {
+		img, err := random.Image(512, 1)
+		require.NoError(t, err)
+		require.NoError(t, layoutPath.AppendImage(img, layout.WithAnnotations(map[string]string{
+			refNameAnnotation: refName,
+		})))
+		digest, err := img.Digest()
+		require.NoError(t, err)
+		digests[tag] = digest.String()
+	}
+
+	tmpDirGen := file.NewTempDirGenerator()
+	t.Cleanup(func() {
+		assert.NoError(t, tmpDirGen.Cleanup())
+	})
+
+	for tag, digest := range digests {
+		t.Run(tag, func(t *testing.T) {
+			path, parsedTag := image.ParseOCIDirectoryLocation(layoutDir + ":" + tag)
+			require.Equal(t, layoutDir, path)
+			require.Equal(t, tag, parsedTag)
+
+			img, err := NewProviderFromPathWithTag(path, &tmpDirGen, parsedTag).Provide()
+			require.NoError(t, err)
+			require.NoError(t, img.Read())
+			assert.Equal(t, digest, img.Metadata.ManifestDigest)
+		})
+	}
+
+	_, err = NewProviderFromPathWithTag(layoutDir, &tmpDirGen, "missing").Provide()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `no manifest with tag="missing"`)
+
+	// without a tag the layout is ambiguous
+	_, err = NewProviderFromPath(layoutDir, &tmpDirGen).Provide()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unexpected number of OCI directory manifests (found 3)")
+}
+
+func Test_refNameHasTag(t *testing.T) {
+	assert.True(t, refNameHasTag("mytag", "mytag"))
+	assert.True(t, refNameHasTag("docker.io/library/alpine:mytag", "mytag"))
+	assert.True(t, refNameHasTag("localhost:5000/alpine:mytag", "mytag"))
+	assert.False(t, refNameHasTag("docker.io/library/alpine:other", "mytag"))
+	// the port of a registry is not a tag
+	assert.False(t, refNameHasTag("localhost:5000/alpine", "5000"))
+	assert.False(t, refNameHasTag("mytag-2", "mytag"))
+}
+
+func Test_locateBlob(t *testing.T) {
+	root := "test-fixtures/non-canonical-blobs"
+	canonicalDigest := v1.Hash{Algorithm: "sha256", Hex: "77a638aaf34758c7653e12ac412c33e13ab2483cf164fc8ff4f9b87d341d945a"}
+	missingDigest := v1.Hash{Algorithm: "sha256", Hex: "0000000000000000000000000000000000000000000000000000000000000000"}
+
+	tests := []struct {
+		name              string
+		descriptor        v1.Descriptor
+		expectedLocation  string
+		expectedCanonical bool
+	}{
+		{
+			name:              "canonical location",
+			descriptor:        v1.Descriptor{Digest: canonicalDigest, URLs: []string{"layers/first.tar.gz"}},
+			expectedLocation:  "test-fixtures/non-canonical-blobs/blobs/sha256/" + canonicalDigest.Hex,
+			expectedCanonical: true,
+		},
+		{
+			name:             "relative path",
+			descriptor:       v1.Descriptor{Digest: missingDigest, URLs: []string{"layers/first.tar.gz"}},
+			expectedLocation: "test-fixtures/non-canonical-blobs/layers/first.tar.gz",
+		},
+		{
+			name:             "file URL skipping remote URLs",
+			descriptor:       v1.Descriptor{Digest: missingDigest, URLs: []string{"https://example.com/layers/first.tar.gz", "file:layers/first.tar.gz"}},
+			expectedLocation: "test-fixtures/non-canonical-blobs/layers/first.tar.gz",
+		},
+		{
+			name:       "path cannot escape the layout",
+			descriptor: v1.Descriptor{Digest: missingDigest, URLs: []string{"../non-canonical-blobs/layers/first.tar.gz"}},
+		},
+		{
+			name:       "missing blob",
+			descriptor: v1.Descriptor{Digest: missingDigest},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			location, canonical := locateBlob(root, test.descriptor)
+			assert.Equal(t, test.expectedLocation, location)
+			assert.Equal(t, test.expectedCanonical, canonical)
+		})
+	}
+}
+
+const (
+	// ociLayerZstd is the media type for zstd compressed OCI layers
+	ociLayerZstd types.MediaType = "application/vnd.oci.image.layer.v1.tar+zstd"
+	// dockerLayerZstd is the media type for zstd compressed docker layers
+	dockerLayerZstd types.MediaType = "application/vnd.docker.image.rootfs.diff.tar.zstd"
+)
+
+func Test_decompress(t *testing.T) {
+	payload := []byte("some layer tar")
+
+	gzipped := &bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(gzipped)
+	_, err := gzipWriter.Write(payload)
+	require.NoError(t, err)
+	require.NoError(t, gzipWriter.Close())
+
+	zstdWriter, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	zstdCompressed := zstdWriter.EncodeAll(payload, nil)
+
+	tests := []struct {
+		name      string
+		mediaType types.MediaType
+		blob      []byte
+	}{
+		{
+			name:      "gzip",
+			mediaType: types.OCILayer,
+			blob:      gzipped.Bytes(),
+		},
+		{
+			name:      "zstd",
+			mediaType: ociLayerZstd,
+			blob:      zstdCompressed,
+		},
+		{
+			name:      "uncompressed",
+			mediaType: types.OCIUncompressedLayer,
+			blob:      payload,
+		},
+		{
+			name:      "docker gzip",
+			mediaType: types.DockerLayer,
+			blob:      gzipped.Bytes(),
+		},
+		{
+			name:      "docker foreign gzip",
+			mediaType: types.DockerForeignLayer,
+			blob:      gzipped.Bytes(),
+		},
+		{
+			name:      "docker zstd",
+			mediaType: dockerLayerZstd,
+			blob:      zstdCompressed,
+		},
+		{
+			name:      "docker uncompressed",
+			mediaType: types.DockerUncompressedLayer,
+			blob:      payload,
+		},
+		{
+			name:      "OCI non-distributable zstd",
+			mediaType: "application/vnd.oci.image.layer.nondistributable.v1.tar+zstd",
+			blob:      zstdCompressed,
+		},
+		{
+			name:      "OCI non-distributable uncompressed",
+			mediaType: types.OCIUncompressedRestrictedLayer,
+			blob:      payload,
+		},
+		{
+			name:      "zstd with media type parameters and casing",
+			mediaType: "Application/VND.OCI.Image.Layer.v1.tar+zstd; charset=binary",
+			blob:      zstdCompressed,
+		},
+		{
+			name:      "zstd labeled as gzip",
+			mediaType: types.OCILayer,
+			blob:      zstdCompressed,
+		},
+		{
+			name:      "uncompressed labeled as gzip",
+			mediaType: types.DockerLayer,
+			blob:      payload,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader, err := decompress(test.mediaType, ioutil.NopCloser(bytes.NewReader(test.blob)), int64(len(test.blob)))
+			require.NoError(t, err)
+
+			actual, err := ioutil.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, payload, actual)
+			assert.NoError(t, reader.Close())
+		})
+	}
+}
+
+func Test_isAttestation(t *testing.T) {
+	tests := []struct {
+		name       string
+		descriptor v1.Descriptor
+		expected   bool
+	}{
+		{
+			name: "image manifest",
+			descriptor: v1.Descriptor{
+				Platform: &v1.Platform{OS: "linux", Architecture: "amd64"},
+			},
+			expected: false,
+		},
+		{
+			name:       "image manifest without platform",
+			descriptor: v1.Descriptor{},
+			expected:   false,
+		},
+		{
+			name: "attestation manifest by reference type",
+			descriptor: v1.Descriptor{
+				Annotations: map[string]string{
+					"vnd.docker.reference.type": "attestation-manifest",
+				},
+			},
+			expected: true,
+		},
+		{
+			name: "attestation manifest by unknown platform",
+			descriptor: v1.Descriptor{
+				Platform: &v1.Platform{OS: "unknown", Architecture: "unknown"},
+			},
+			expected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, isAttestation(test.descriptor))
+		})
+	}
+}
+
+func TestTarballImageProvider_Provide_HelmChart(t *testing.T) {
+	tmpDirGen := file.NewTempDirGenerator()
+	t.Cleanup(func() {
+		assert.NoError(t, tmpDirGen.Cleanup())
+	})
+
+	const fixture = "../test-fixtures/helm-chart-oci-archive.tar"
+	_, err := NewProviderFromTarball(fixture, &tmpDirGen).Provide()
+
+	var notImageErr *image.ErrNotImage
+	require.True(t, errors.As(err, &notImageErr), "unexpected error: %+v", err)
+	assert.Equal(t, fixture, notImageErr.Location)
+	assert.Equal(t, image.HelmChartConfigMediaType, notImageErr.ConfigMediaType)
+}
+
+func TestTarballImageProvider_Provide_DockerAndOCIArchive(t *testing.T) {
+	tmpDirGen := file.NewTempDirGenerator()
+	t.Cleanup(func() {
+		assert.NoError(t, tmpDirGen.Cleanup())
+	})
+
+	// the archive also holds a docker-archive manifest.json, which is ignored when read as an oci-archive
+	img, err := NewProviderFromTarball("../test-fixtures/docker-and-oci-archive.tar", &tmpDirGen).Provide()
+	require.NoError(t, err)
+	require.NoError(t, img.Read())
+
+	contents, err := img.FileContentsFromSquash("/etc/marker.txt")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, contents.Close())
+	})
+	actual, err := ioutil.ReadAll(contents)
+	require.NoError(t, err)
+	assert.Equal(t, "dual marker\n", string(actual))
+}
@@ -0,0 +1,66 @@
+package oci
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+// fetchRecorder is a http.RoundTripper that records the number of response bytes read and the time elapsed until the
+// last read. Since registry layers are fetched lazily, the stats keep updating while the image is read, thus the stats
+// are only shared as copies taken under the lock (see snapshot).
+type fetchRecorder struct {
+	base  http.RoundTripper
+	start time.Time
+	lock  sync.Mutex
+	stats *image.FetchStats
+}
+
+func newFetchRecorder(base http.RoundTripper) *fetchRecorder {
+	return &fetchRecorder{
+		base:  base,
+		start: time.Now(),
+		stats: &image.FetchStats{
+			PulledFromRegistry: true,
+		},
+	}
+}
+
+func (r *fetchRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &recordedBody{ReadCloser: resp.Body, recorder: r}
+	return resp, nil
+}
+
+func (r *fetchRecorder) record(n int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.stats.Bytes += int64(n)
+	r.stats.Duration = time.Since(r.start)
+}
+
+// snapshot returns a copy of the stats recorded so far.
+func (r *fetchRecorder) snapshot() image.FetchStats {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return *r.stats
+}
+
+type recordedBody struct {
+	io.ReadCloser
+	recorder *fetchRecorder
+}
+
+func (b *recordedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.recorder.record(n)
+	return n, err
+}
@@ -0,0 +1,125 @@
+package oci
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// harborRobotUsername is a Harbor robot account username, which includes "$" and "+" characters.
+	harborRobotUsername = "robot$my-project+ci-scanner"
+	// harborRobotToken is a Harbor robot account secret, whose standard base64 encoding includes "+" and "/" characters.
+	harborRobotToken = "eyJhbGciOiJSUzI1NiIsInR5cCI6IkpXVCJ9.c2VjcmV0Pz8/Pj4+fn5+.T0s/Pz8+Pj4-_~~~"
+)
+
+// harborTokenRegistry wraps a registry with bearer token auth (as Harbor does), where tokens are only issued to the
+// robot account credentials. The credentials and scopes of each token request are recorded.
+type harborTokenRegistry struct {
+	lock      sync.Mutex
+	enabled   bool
+	usernames []string
+	passwords []string
+	scopes    [][]string
+}
+
+func (r *harborTokenRegistry) wrap(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.lock.Lock()
+		enabled := r.enabled
+		r.lock.Unlock()
+
+		switch {
+		case !enabled:
+			handler.ServeHTTP(w, req)
+		case req.URL.Path == "/service/token":
+			username, password, _ := req.BasicAuth()
+			r.lock.Lock()
+			r.usernames = append(r.usernames, username)
+			r.passwords = append(r.passwords, password)
+			r.scopes = append(r.scopes, req.URL.Query()["scope"])
+			r.lock.Unlock()
+
+			if username != harborRobotUsername || password != harborRobotToken {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"token": "robot-token"}`))
+		case req.Header.Get("Authorization") != "Bearer robot-token":
+			w.Header().Set("WWW-Authenticate", `Bearer realm="http://`+req.Host+`/service/token",service="harbor-registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			handler.ServeHTTP(w, req)
+		}
+	})
+}
+
+func TestRegistryImageProvider_Provide_HarborRobotAccount(t *testing.T) {
+	tests := []struct {
+		name            string
+		registryOptions func(registry string) *image.RegistryOptions
+		dockerConfig    func(t *testing.T, registry string)
+	}{
+		{
+			name: "registry options",
+			registryOptions: func(registry string) *image.RegistryOptions {
+				return &image.RegistryOptions{
+					InsecureUseHTTP: true,
+					Credentials: []image.RegistryCredentials{
+						{Authority: registry, Username: harborRobotUsername, Password: harborRobotToken},
+					},
+				}
+			},
+		},
+		{
+			name: "docker config",
+			registryOptions: func(string) *image.RegistryOptions {
+				return &image.RegistryOptions{InsecureUseHTTP: true}
+			},
+			dockerConfig: func(t *testing.T, registry string) {
+				auth := base64.StdEncoding.EncodeToString([]byte(harborRobotUsername + ":" + harborRobotToken))
+				setEnv(t, "DOCKER_CONFIG", writeDockerConfig(t, map[string]string{registry: auth}, nil))
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setEnv(t, "DOCKER_CONFIG", t.TempDir())
+
+			reg := &harborTokenRegistry{}
+			imageStr := pushRandomImage(t, reg.wrap)
+			reg.lock.Lock()
+			reg.enabled = true
+			reg.lock.Unlock()
+
+			registry := strings.SplitN(imageStr, "/", 2)[0]
+			if test.dockerConfig != nil {
+				test.dockerConfig(t, registry)
+			}
+
+			tmpDirGen := file.NewTempDirGenerator()
+			t.Cleanup(func() {
+				assert.NoError(t, tmpDirGen.Cleanup())
+			})
+
+			img, err := NewProviderFromRegistry(imageStr, &tmpDirGen, test.registryOptions(registry)).Provide()
+			require.NoError(t, err)
+			require.NoError(t, img.Read())
+
+			require.NotEmpty(t, reg.usernames)
+			for idx := range reg.usernames {
+				assert.Equal(t, harborRobotUsername, reg.usernames[idx])
+				assert.Equal(t, harborRobotToken, reg.passwords[idx])
+				assert.Equal(t, []string{"repository:stereoscope/test:pull"}, reg.scopes[idx])
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package oci
+
+import (
+	"net/http"
+
+	"github.com/anchore/stereoscope/internal/log"
+)
+
+// managedHeaders are the headers set by the registry client itself, which must not be replaced by user headers.
+var managedHeaders = []string{"Authorization", "Accept"}
+
+// headerTransport is a http.RoundTripper that adds the configured headers to every request.
+type headerTransport struct {
+	base    http.RoundTripper
+	headers http.Header
+}
+
+// newHeaderTransport creates a transport that adds the given headers to every request, ignoring any headers that are
+// managed by the registry client (e.g. Authorization). Headers already present on a request are never replaced.
+func newHeaderTransport(base http.RoundTripper, headers map[string]string) http.RoundTripper {
+	allowed := make(http.Header)
+	for key, value := range headers {
+		if isManagedHeader(key) {
+			log.Warnf("ignoring extra registry header=%q since it is managed by stereoscope", key)
+			continue
+		}
+		allowed.Set(key, value)
+	}
+
+	if len(allowed) == 0 {
+		return base
+	}
+
+	return &headerTransport{
+		base:    base,
+		headers: allowed,
+	}
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// note: a http.RoundTripper must not modify the given request
+	req = req.Clone(req.Context())
+	for key, values := range t.headers {
+		if req.Header.Get(key) != "" {
+			continue
+		}
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+func isManagedHeader(key string) bool {
+	canonical := http.CanonicalHeaderKey(key)
+	for _, managed := range managedHeaders {
+		if canonical == managed {
+			return true
+		}
+	}
+	return false
+}
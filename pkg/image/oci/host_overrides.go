@@ -0,0 +1,57 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// newHostOverrideDialer creates a dial function that dials the overridden address for hosts with an override (keeping
+// the port of the given address unless the override has one), and otherwise dials the given address unchanged.
+func newHostOverrideDialer(overrides map[string]string, base dialFunc) dialFunc {
+	if base == nil {
+		base = (&net.Dialer{}).DialContext
+	}
+
+	normalized := make(map[string]string, len(overrides))
+	for host, address := range overrides {
+		normalized[strings.ToLower(host)] = address
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return base(ctx, network, addr)
+		}
+
+		override, ok := normalized[strings.ToLower(host)]
+		if !ok {
+			return base(ctx, network, addr)
+		}
+
+		if _, _, err := net.SplitHostPort(override); err != nil {
+			// the override has no port, thus the port of the request is kept
+			override = net.JoinHostPort(override, port)
+		}
+		return base(ctx, network, override)
+	}
+}
+
+// hostOverridesKey returns a canonical form of the given host overrides, such that transports with the same overrides
+// are shared.
+func hostOverridesKey(overrides map[string]string) string {
+	if len(overrides) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(overrides))
+	for host, address := range overrides {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", strings.ToLower(host), address))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
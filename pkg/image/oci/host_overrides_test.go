@@ -0,0 +1,111 @@
+package oci
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryImageProvider_Provide_HostOverrides(t *testing.T) {
+	// the registry is only reachable by address, and records the host requested
+	var lock sync.Mutex
+	var hosts []string
+	var requests int32
+	imageStr := pushRandomImage(t, func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			lock.Lock()
+			hosts = append(hosts, r.Host)
+			lock.Unlock()
+			handler.ServeHTTP(w, r)
+		})
+	})
+	registryAddress := strings.SplitN(imageStr, "/", 2)[0]
+	host, port, err := net.SplitHostPort(registryAddress)
+	require.NoError(t, err)
+
+	tmpDirGen := file.NewTempDirGenerator()
+	t.Cleanup(func() {
+		assert.NoError(t, tmpDirGen.Cleanup())
+	})
+
+	hostname := "registry.stereoscope.test"
+	registryOptions := &image.RegistryOptions{
+		InsecureUseHTTP: true,
+		HostOverrides: map[string]string{
+			"Registry.Stereoscope.Test": host,
+		},
+	}
+	t.Cleanup(sharedTransport(registryOptions).CloseIdleConnections)
+
+	hosts = nil
+	atomic.StoreInt32(&requests, 0)
+	img, err := NewProviderFromRegistry(net.JoinHostPort(hostname, port)+"/stereoscope/test:latest", &tmpDirGen, registryOptions).Provide()
+	require.NoError(t, err)
+	require.NoError(t, img.Read())
+
+	// the requests are sent to the overridden address, keeping the original hostname
+	require.Greater(t, atomic.LoadInt32(&requests), int32(0))
+	for _, h := range hosts {
+		assert.Equal(t, net.JoinHostPort(hostname, port), h)
+	}
+}
+
+func Test_newHostOverrideDialer(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(server.Close)
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	host, port, err := net.SplitHostPort(u.Host)
+	require.NoError(t, err)
+
+	var dialed []string
+	base := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
+	}
+
+	dial := newHostOverrideDialer(map[string]string{
+		"registry.example":       host,
+		"other.registry.example": u.Host,
+	}, base)
+
+	for _, addr := range []string{"registry.example:" + port, "REGISTRY.example:" + port, "other.registry.example:443"} {
+		conn, err := dial(context.Background(), "tcp", addr)
+		require.NoError(t, err, "addr=%q", addr)
+		require.NoError(t, conn.Close())
+	}
+
+	assert.Equal(t, []string{u.Host, u.Host, u.Host}, dialed)
+
+	// hosts without an override are dialed unchanged
+	dialed = nil
+	conn, err := dial(context.Background(), "tcp", u.Host)
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+	assert.Equal(t, []string{u.Host}, dialed)
+}
+
+func Test_hostOverridesKey(t *testing.T) {
+	assert.Equal(t, "", hostOverridesKey(nil))
+	assert.Equal(t, "a.example=10.0.0.1,b.example=10.0.0.2:5000", hostOverridesKey(map[string]string{
+		"B.example": "10.0.0.2:5000",
+		"a.example": "10.0.0.1",
+	}))
+
+	withOverrides := sharedTransport(&image.RegistryOptions{HostOverrides: map[string]string{"a.example": "10.0.0.1"}})
+	assert.Same(t, withOverrides, sharedTransport(&image.RegistryOptions{HostOverrides: map[string]string{"A.example": "10.0.0.1"}}))
+	assert.NotSame(t, withOverrides, sharedTransport(&image.RegistryOptions{}))
+}
@@ -0,0 +1,101 @@
+package oci
+
+import (
+	"fmt"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// defaultPlatform is selected from multi-platform indexes when no platform is requested (as with the registry client).
+var defaultPlatform = v1.Platform{OS: "linux", Architecture: "amd64"}
+
+// resolvableManifest is a manifest that can be read as either an image or an index, depending on the media type (e.g.
+// a registry descriptor, or a manifest retrieved with a BlobFetcher).
+type resolvableManifest interface {
+	Image() (v1.Image, error)
+	ImageIndex() (v1.ImageIndex, error)
+}
+
+// selectImage returns the image for the given manifest (with the given media type), where the image for the given
+// platform is selected from multi-platform indexes (see selectPlatformManifest). When no platform is given then the only
+// image within the index is selected, otherwise the default platform (linux/amd64) is selected. The platform of the
+// selected index entry is returned (nil when the manifest is not an index).
+func selectImage(mediaType types.MediaType, manifest resolvableManifest, platform *v1.Platform) (v1.Image, *v1.Platform, error) {
+	if image.SourceFromMediaType(string(mediaType)) != image.IndexMediaTypeKind {
+		img, err := manifest.Image()
+		return img, nil, err
+	}
+
+	index, err := manifest.ImageIndex()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	selected, err := selectIndexManifest(index, platform)
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Debugf("selected image manifest=%q", selected.descriptor.Digest)
+
+	img, err := selected.index.Image(selected.descriptor.Digest)
+	return img, selected.descriptor.Platform, err
+}
+
+// selectIndexManifest returns the image manifest within the given index for the given platform (see selectImage).
+func selectIndexManifest(index v1.ImageIndex, platform *v1.Platform) (indexedManifest, error) {
+	if platform != nil {
+		return selectPlatformManifest(index, *platform)
+	}
+
+	candidates, err := imageManifests(index)
+	if err != nil {
+		return indexedManifest{}, err
+	}
+	if len(candidates) == 1 {
+		return candidates[0], nil
+	}
+	return selectPlatformManifest(index, defaultPlatform)
+}
+
+// assembleImage creates the image object for the given image selected by a provider, along with the platform of the
+// index entry the image was selected from (if any). Only images (not other artifacts) are accepted, and when layers are
+// required the manifest must reference at least one layer. Note: layers may be stored with any compression (e.g. zstd), which the GCR lib does
+// not decompress, thus the layers of the image are decompressed by media type (see decompressingImage).
+func assembleImage(img v1.Image, location string, platform *v1.Platform, requireLayers bool, tmpDirGen *file.TempDirGenerator, metadata ...image.AdditionalMetadata) (*image.Image, error) {
+	img = &decompressingImage{Image: img}
+
+	// other kinds of artifacts (e.g. Helm charts) cannot be read as an image
+	imgManifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse image manifest for image=%q: %w", location, err)
+	}
+	if !image.IsImageConfigMediaType(string(imgManifest.Config.MediaType)) {
+		return nil, &image.ErrNotImage{Location: location, ConfigMediaType: string(imgManifest.Config.MediaType)}
+	}
+
+	if requireLayers {
+		if err := image.CheckLayersPresent(img); err != nil {
+			return nil, fmt.Errorf("image=%q: %w", location, err)
+		}
+	}
+
+	imageTempDir, err := tmpDirGen.NewTempDir()
+	if err != nil {
+		return nil, err
+	}
+
+	if platform != nil && platform.Variant != "" {
+		metadata = append(metadata, image.WithPlatformVariant(platform.Variant))
+	}
+
+	// make a best effort to get the manifest, should not block getting an image though if it fails
+	if manifestBytes, err := img.RawManifest(); err == nil {
+		metadata = append(metadata, image.WithManifest(manifestBytes))
+	}
+
+	return image.NewImage(img, imageTempDir, metadata...), nil
+}
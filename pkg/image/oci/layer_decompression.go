@@ -0,0 +1,150 @@
+package oci
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/hashicorp/go-multierror"
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstdMagic is the header found at the start of every zstd frame (gzip streams are detected with file.IsGzipHeader).
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// decompressingImage is a v1.Image where the layer blobs may be stored with any compression (or none at all), as with
+// OCI layouts and registries. The GCR lib assumes all layer blobs are gzip compressed, so layer content is instead
+// decompressed based on the normalized compression of the descriptor media type (thus the docker and OCI variants of
+// a media type are treated identically, see image.LayerCompressionFromMediaType).
+type decompressingImage struct {
+	v1.Image
+}
+
+func (i *decompressingImage) Layers() ([]v1.Layer, error) {
+	layers, err := i.Image.Layers()
+	if err != nil {
+		return nil, err
+	}
+	for idx, layer := range layers {
+		layers[idx] = &decompressingLayer{Layer: layer}
+	}
+	return layers, nil
+}
+
+func (i *decompressingImage) LayerByDigest(digest v1.Hash) (v1.Layer, error) {
+	layer, err := i.Image.LayerByDigest(digest)
+	if err != nil {
+		return nil, err
+	}
+	return &decompressingLayer{Layer: layer}, nil
+}
+
+func (i *decompressingImage) LayerByDiffID(diffID v1.Hash) (v1.Layer, error) {
+	layer, err := i.Image.LayerByDiffID(diffID)
+	if err != nil {
+		return nil, err
+	}
+	return &decompressingLayer{Layer: layer}, nil
+}
+
+// decompressingLayer is a v1.Layer that decompresses the blob based on the media type.
+type decompressingLayer struct {
+	v1.Layer
+}
+
+// Uncompressed returns the layer tar, decompressing the layer blob as indicated by the media type. Blobs with a gzip
+// (or unknown) media type are inspected for the compression actually used, since not all tools label layers correctly.
+func (l *decompressingLayer) Uncompressed() (io.ReadCloser, error) {
+	mediaType, err := l.MediaType()
+	if err != nil {
+		return nil, err
+	}
+
+	blob, err := l.Compressed()
+	if err != nil {
+		return nil, err
+	}
+
+	// note: the size is only used to decide if decompression is offloaded, thus an unknown size is not an error
+	size, err := l.Size()
+	if err != nil {
+		size = -1
+	}
+
+	reader, err := decompress(mediaType, blob, size)
+	if err != nil {
+		_ = blob.Close()
+		return nil, fmt.Errorf("unable to decompress layer (mediaType=%q): %w", mediaType, err)
+	}
+	return reader, nil
+}
+
+// decompress wraps the given layer blob (of the given compressed size, or -1 if unknown) with the decompressor for the
+// given media type. The blob is streamed through a buffer of file.DecompressionBufferSize, and gzip blobs may be
+// offloaded to an external decompressor (see file.SetExternalGzipDecompressor).
+func decompress(mediaType types.MediaType, blob io.ReadCloser, size int64) (io.ReadCloser, error) {
+	switch image.LayerCompressionFromMediaType(string(mediaType)) {
+	case image.UncompressedLayerCompression:
+		return blob, nil
+	case image.ZstdLayerCompression:
+		return newZstdReader(blob, blob)
+	}
+
+	buffered := bufio.NewReaderSize(blob, file.DecompressionBufferSize())
+	header, err := buffered.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	switch {
+	case file.IsGzipHeader(header):
+		gzipReader, err := file.NewGzipReader(buffered, size)
+		if err != nil {
+			return nil, err
+		}
+		return &decompressedReader{Reader: gzipReader, closers: []io.Closer{gzipReader, blob}}, nil
+	case bytes.HasPrefix(header, zstdMagic):
+		return newZstdReader(buffered, blob)
+	default:
+		return &decompressedReader{Reader: buffered, closers: []io.Closer{blob}}, nil
+	}
+}
+
+func newZstdReader(reader io.Reader, blob io.Closer) (io.ReadCloser, error) {
+	zstdReader, err := zstd.NewReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	return &decompressedReader{Reader: zstdReader, closers: []io.Closer{zstdCloser{zstdReader}, blob}}, nil
+}
+
+// zstdCloser adapts the zstd decoder (which does not return an error on close) to an io.Closer.
+type zstdCloser struct {
+	decoder *zstd.Decoder
+}
+
+func (z zstdCloser) Close() error {
+	z.decoder.Close()
+	return nil
+}
+
+// decompressedReader is the decompressed layer content, where closing the reader closes the decompressor and blob.
+type decompressedReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *decompressedReader) Close() error {
+	var allErrors error
+	for _, c := range r.closers {
+		if err := c.Close(); err != nil {
+			allErrors = multierror.Append(allErrors, err)
+		}
+	}
+	return allErrors
+}
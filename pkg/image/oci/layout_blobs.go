@@ -0,0 +1,181 @@
+package oci
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// canonicalLayout returns the path to an OCI layout where every blob referenced by the layout at the given path can be
+// found at the canonical location (blobs/<algorithm>/<hex>). Layouts may instead reference blobs by a layout-relative
+// path within the descriptor "urls" (e.g. "layers/base.tar.gz" or "file:layers/base.tar.gz"), in which case a
+// canonical layout is assembled in a temp dir (linking to the original blobs). The canonical location is always
+// preferred, and blobs that cannot be found at all are left for the layout reader to report.
+func canonicalLayout(root string, tmpDirGen *file.TempDirGenerator) (string, error) {
+	rawIndex, err := ioutil.ReadFile(filepath.Join(root, "index.json"))
+	if err != nil {
+		// let the layout reader raise the error
+		return root, nil
+	}
+
+	index, err := v1.ParseIndexManifest(bytes.NewReader(rawIndex))
+	if err != nil {
+		return root, nil
+	}
+
+	blobs := make(map[v1.Hash]string)
+	relocated, err := locateBlobs(root, index.Manifests, blobs)
+	if err != nil {
+		return "", err
+	}
+
+	if !relocated {
+		return root, nil
+	}
+
+	tempDir, err := tmpDirGen.NewTempDir()
+	if err != nil {
+		return "", err
+	}
+
+	for _, name := range []string{"oci-layout", "index.json"} {
+		if err := linkFile(filepath.Join(root, name), filepath.Join(tempDir, name)); err != nil {
+			return "", err
+		}
+	}
+
+	for digest, location := range blobs {
+		blobDir := filepath.Join(tempDir, "blobs", digest.Algorithm)
+		if err := os.MkdirAll(blobDir, 0755); err != nil {
+			return "", fmt.Errorf("unable to create OCI blob dir: %w", err)
+		}
+		if err := linkFile(location, filepath.Join(blobDir, digest.Hex)); err != nil {
+			return "", err
+		}
+	}
+
+	log.Debugf("assembled canonical OCI layout for %q at %q", root, tempDir)
+	return tempDir, nil
+}
+
+// locateBlobs records the location of every blob reachable from the given descriptors (descending into indexes and
+// manifests), indicating if any blob is not found at the canonical location.
+func locateBlobs(root string, descriptors []v1.Descriptor, blobs map[v1.Hash]string) (bool, error) {
+	var relocated bool
+	for _, descriptor := range descriptors {
+		if _, exists := blobs[descriptor.Digest]; exists {
+			continue
+		}
+
+		location, canonical := locateBlob(root, descriptor)
+		if location == "" {
+			log.Debugf("unable to find OCI blob=%q within layout=%q", descriptor.Digest, root)
+			continue
+		}
+		blobs[descriptor.Digest] = location
+		relocated = relocated || !canonical
+
+		var children []v1.Descriptor
+		switch kind := image.SourceFromMediaType(string(descriptor.MediaType)); {
+		case kind == image.IndexMediaTypeKind:
+			contents, err := ioutil.ReadFile(location)
+			if err != nil {
+				return false, fmt.Errorf("unable to read OCI index=%q: %w", descriptor.Digest, err)
+			}
+			index, err := v1.ParseIndexManifest(bytes.NewReader(contents))
+			if err != nil {
+				return false, fmt.Errorf("unable to parse OCI index=%q: %w", descriptor.Digest, err)
+			}
+			children = index.Manifests
+		case kind.IsManifest():
+			contents, err := ioutil.ReadFile(location)
+			if err != nil {
+				return false, fmt.Errorf("unable to read OCI manifest=%q: %w", descriptor.Digest, err)
+			}
+			manifest, err := v1.ParseManifest(bytes.NewReader(contents))
+			if err != nil {
+				return false, fmt.Errorf("unable to parse OCI manifest=%q: %w", descriptor.Digest, err)
+			}
+			children = append([]v1.Descriptor{manifest.Config}, manifest.Layers...)
+		}
+
+		childRelocated, err := locateBlobs(root, children, blobs)
+		if err != nil {
+			return false, err
+		}
+		relocated = relocated || childRelocated
+	}
+	return relocated, nil
+}
+
+// locateBlob returns the path to the blob for the given descriptor, preferring the canonical location over any
+// layout-relative path from the descriptor URLs (remote URLs are ignored). An empty path is returned if the blob
+// cannot be found.
+func locateBlob(root string, descriptor v1.Descriptor) (string, bool) {
+	canonical := filepath.Join(root, "blobs", descriptor.Digest.Algorithm, descriptor.Digest.Hex)
+	if isRegularFile(canonical) {
+		return canonical, true
+	}
+
+	for _, rawURL := range descriptor.URLs {
+		u, err := url.Parse(rawURL)
+		if err != nil || u.Scheme != "" && u.Scheme != "file" {
+			continue
+		}
+
+		relativePath := u.Path
+		if u.Opaque != "" {
+			// e.g. "file:layers/base.tar.gz"
+			relativePath = u.Opaque
+		}
+
+		// note: the path is cleaned as an absolute path to prevent escaping the layout root
+		location := filepath.Join(root, filepath.FromSlash(path.Clean("/"+relativePath)))
+		if isRegularFile(location) {
+			return location, false
+		}
+	}
+	return "", false
+}
+
+func isRegularFile(p string) bool {
+	info, err := os.Stat(p)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// linkFile symlinks the given file to the destination, falling back to a copy when symlinks are not supported.
+func linkFile(source, destination string) error {
+	absSource, err := filepath.Abs(source)
+	if err != nil {
+		return err
+	}
+	if err := os.Symlink(absSource, destination); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(absSource)
+	if err != nil {
+		return fmt.Errorf("unable to open OCI layout file: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(destination)
+	if err != nil {
+		return fmt.Errorf("unable to create OCI layout file: %w", err)
+	}
+	defer out.Close()
+
+	if _, err := file.Copy(out, in); err != nil {
+		return fmt.Errorf("unable to copy OCI layout file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package oci
+
+import (
+	"net/http"
+	"strings"
+)
+
+// manifestAcceptTransport is a http.RoundTripper that replaces the Accept header for manifest requests with the
+// configured media types, since some registries pick the manifest format to return based on the Accept header.
+type manifestAcceptTransport struct {
+	base   http.RoundTripper
+	accept string
+}
+
+// newManifestAcceptTransport creates a transport that accepts the given manifest media types (most preferred first)
+// when resolving manifests. When no media types are given the Accept header set by the registry client is used.
+func newManifestAcceptTransport(base http.RoundTripper, mediaTypes []string) http.RoundTripper {
+	var accept []string
+	for _, mediaType := range mediaTypes {
+		if mediaType = strings.TrimSpace(mediaType); mediaType != "" {
+			accept = append(accept, mediaType)
+		}
+	}
+
+	if len(accept) == 0 {
+		return base
+	}
+
+	return &manifestAcceptTransport{
+		base:   base,
+		accept: strings.Join(accept, ","),
+	}
+}
+
+func (t *manifestAcceptTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if isManifestRequest(req) {
+		// note: a http.RoundTripper must not modify the given request
+		req = req.Clone(req.Context())
+		req.Header.Set("Accept", t.accept)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// isManifestRequest indicates if the request resolves a manifest (/v2/<name>/manifests/<reference>).
+func isManifestRequest(req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	return strings.HasPrefix(req.URL.Path, "/v2/") && strings.Contains(req.URL.Path, "/manifests/")
+}
@@ -0,0 +1,113 @@
+package oci
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/anchore/stereoscope/pkg/image"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// selectPlatformManifest returns the image manifest within the given index (see imageManifests) for the requested
+// platform. The OS and architecture must match, as must the variant when requested (see image.NormalizePlatformVariant,
+// thus "linux/arm64" and "linux/arm64/v8" are equivalent). When no variant is requested the default variant of the
+// architecture is preferred (v7 for arm and v8 for arm64), falling back to the first image for the OS and architecture
+// (e.g. a "linux/arm" request selects arm/v7 when present, otherwise the first arm image such as arm/v6). When the OS
+// version is requested (Windows images) the candidate must be for the same OS build, where the exact version is
+// preferred over the latest revision of that build.
+func selectPlatformManifest(index v1.ImageIndex, requested v1.Platform) (indexedManifest, error) {
+	candidates, err := imageManifests(index)
+	if err != nil {
+		return indexedManifest{}, err
+	}
+
+	if requested.Variant == "" {
+		if variant := image.NormalizePlatformVariant(requested.Architecture, ""); variant != "" {
+			preferred := requested
+			preferred.Variant = variant
+			if selected, found := selectCandidate(candidates, preferred); found {
+				return selected, nil
+			}
+		}
+	}
+
+	selected, found := selectCandidate(candidates, requested)
+	if !found {
+		return indexedManifest{}, fmt.Errorf("no image found for platform=%q", formatPlatform(requested))
+	}
+	return selected, nil
+}
+
+// selectCandidate returns the first of the given image manifests that matches the requested platform, preferring the
+// exact OS version (when requested) over the latest revision of the same OS build.
+func selectCandidate(candidates []indexedManifest, requested v1.Platform) (indexedManifest, bool) {
+	var (
+		selected indexedManifest
+		found    bool
+		revision int
+	)
+	for _, candidate := range candidates {
+		platform := candidate.descriptor.Platform
+		if platform == nil || !matchesPlatform(*platform, requested) {
+			continue
+		}
+		if requested.OSVersion == "" || platform.OSVersion == requested.OSVersion {
+			return candidate, true
+		}
+		if r := osRevision(platform.OSVersion); !found || r > revision {
+			selected, found, revision = candidate, true, r
+		}
+	}
+	return selected, found
+}
+
+// matchesPlatform indicates if the given candidate platform can satisfy the requested platform, where the OS version
+// only needs to match up to the OS build (the revision is considered by selectPlatformManifest).
+func matchesPlatform(candidate, requested v1.Platform) bool {
+	if !strings.EqualFold(candidate.OS, requested.OS) || !strings.EqualFold(candidate.Architecture, requested.Architecture) {
+		return false
+	}
+	if requested.Variant != "" && image.NormalizePlatformVariant(candidate.Architecture, candidate.Variant) != image.NormalizePlatformVariant(requested.Architecture, requested.Variant) {
+		return false
+	}
+	if requested.OSVersion != "" && osBuild(candidate.OSVersion) != osBuild(requested.OSVersion) {
+		return false
+	}
+	return true
+}
+
+// osBuild returns the "major.minor.build" prefix of the given Windows OS version (e.g. "10.0.17763" for
+// "10.0.17763.1234"), which must match the host build for the image to run.
+func osBuild(osVersion string) string {
+	parts := strings.SplitN(osVersion, ".", 4)
+	if len(parts) > 3 {
+		parts = parts[:3]
+	}
+	return strings.Join(parts, ".")
+}
+
+// osRevision returns the revision of the given Windows OS version (e.g. 1234 for "10.0.17763.1234"), which is zero
+// when not present.
+func osRevision(osVersion string) int {
+	parts := strings.SplitN(osVersion, ".", 4)
+	if len(parts) < 4 {
+		return 0
+	}
+	revision, err := strconv.Atoi(parts[3])
+	if err != nil {
+		return 0
+	}
+	return revision
+}
+
+func formatPlatform(platform v1.Platform) string {
+	formatted := platform.OS + "/" + platform.Architecture
+	if platform.Variant != "" {
+		formatted += "/" + platform.Variant
+	}
+	if platform.OSVersion != "" {
+		formatted += ":" + platform.OSVersion
+	}
+	return formatted
+}
@@ -0,0 +1,250 @@
+package oci
+
+import (
+	"testing"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// windowsPlatforms is a Windows manifest list, as published for the Windows base images (one image per OS build).
+var windowsPlatforms = []v1.Platform{
+	{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.5329"},
+	{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.5458"},
+	{OS: "windows", Architecture: "amd64", OSVersion: "10.0.20348.2227"},
+	{OS: "linux", Architecture: "amd64"},
+}
+
+func TestRegistryImageProvider_Provide_WindowsPlatform(t *testing.T) {
+	imageStr, digests := pushRandomIndex(t, windowsPlatforms...)
+
+	tests := []struct {
+		name       string
+		platform   *v1.Platform
+		wantDigest string
+		wantErr    require.ErrorAssertionFunc
+	}{
+		{
+			name:       "default platform",
+			wantDigest: digests[3],
+		},
+		{
+			name:       "exact OS version",
+			platform:   &v1.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.5329"},
+			wantDigest: digests[0],
+		},
+		{
+			name:       "OS build selects the latest revision",
+			platform:   &v1.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763"},
+			wantDigest: digests[1],
+		},
+		{
+			name:       "unknown revision of a known build selects the latest revision",
+			platform:   &v1.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.20348.9999"},
+			wantDigest: digests[2],
+		},
+		{
+			name:       "no OS version selects the first windows image",
+			platform:   &v1.Platform{OS: "windows", Architecture: "amd64"},
+			wantDigest: digests[0],
+		},
+		{
+			name:     "incompatible OS build",
+			platform: &v1.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.14393"},
+			wantErr:  require.Error,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.wantErr == nil {
+				test.wantErr = require.NoError
+			}
+
+			tmpDirGen := file.NewTempDirGenerator()
+			t.Cleanup(func() {
+				assert.NoError(t, tmpDirGen.Cleanup())
+			})
+
+			provider := NewProviderFromRegistry(imageStr, &tmpDirGen, &image.RegistryOptions{
+				InsecureUseHTTP: true,
+				Platform:        test.platform,
+			})
+			img, err := provider.Provide()
+			test.wantErr(t, err)
+			if err != nil {
+				return
+			}
+
+			require.NoError(t, img.Read())
+			assert.Equal(t, test.wantDigest, img.Metadata.ManifestDigest)
+			if test.platform != nil && test.platform.OSVersion != "" {
+				assert.Equal(t, "windows", img.Platform().OS)
+				assert.Equal(t, osBuild(test.platform.OSVersion), osBuild(img.Platform().OSVersion))
+			}
+		})
+	}
+}
+
+// armPlatforms is a multi-variant ARM manifest list, as published for most official images.
+var armPlatforms = []v1.Platform{
+	{OS: "linux", Architecture: "amd64"},
+	{OS: "linux", Architecture: "arm", Variant: "v6"},
+	{OS: "linux", Architecture: "arm", Variant: "v7"},
+	{OS: "linux", Architecture: "arm64", Variant: "v8"},
+	{OS: "linux", Architecture: "386"},
+}
+
+func TestRegistryImageProvider_Provide_ARMVariant(t *testing.T) {
+	imageStr, digests := pushRandomIndex(t, armPlatforms...)
+
+	tests := []struct {
+		name        string
+		platform    *v1.Platform
+		wantDigest  string
+		wantVariant string
+		wantErr     require.ErrorAssertionFunc
+	}{
+		{
+			name:        "arm v6",
+			platform:    &v1.Platform{OS: "linux", Architecture: "arm", Variant: "v6"},
+			wantDigest:  digests[1],
+			wantVariant: "v6",
+		},
+		{
+			name:        "arm v7",
+			platform:    &v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+			wantDigest:  digests[2],
+			wantVariant: "v7",
+		},
+		{
+			name:        "arm without a variant prefers v7",
+			platform:    &v1.Platform{OS: "linux", Architecture: "arm"},
+			wantDigest:  digests[2],
+			wantVariant: "v7",
+		},
+		{
+			name:        "arm64 without a variant",
+			platform:    &v1.Platform{OS: "linux", Architecture: "arm64"},
+			wantDigest:  digests[3],
+			wantVariant: "v8",
+		},
+		{
+			name:     "unavailable variant",
+			platform: &v1.Platform{OS: "linux", Architecture: "arm", Variant: "v5"},
+			wantErr:  require.Error,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.wantErr == nil {
+				test.wantErr = require.NoError
+			}
+
+			tmpDirGen := file.NewTempDirGenerator()
+			t.Cleanup(func() {
+				assert.NoError(t, tmpDirGen.Cleanup())
+			})
+
+			provider := NewProviderFromRegistry(imageStr, &tmpDirGen, &image.RegistryOptions{
+				InsecureUseHTTP: true,
+				Platform:        test.platform,
+			})
+			img, err := provider.Provide()
+			test.wantErr(t, err)
+			if err != nil {
+				return
+			}
+
+			require.NoError(t, img.Read())
+			assert.Equal(t, test.wantDigest, img.Metadata.ManifestDigest)
+			assert.Equal(t, test.wantVariant, img.Platform().Variant)
+			assert.Equal(t, test.platform.Architecture, img.Platform().Architecture)
+		})
+	}
+}
+
+func Test_selectPlatformManifest_VariantFallback(t *testing.T) {
+	// without the default variant the first image for the architecture is selected
+	imageStr, digests := pushRandomIndex(t,
+		v1.Platform{OS: "linux", Architecture: "arm", Variant: "v5"},
+		v1.Platform{OS: "linux", Architecture: "arm", Variant: "v6"},
+	)
+
+	tmpDirGen := file.NewTempDirGenerator()
+	t.Cleanup(func() {
+		assert.NoError(t, tmpDirGen.Cleanup())
+	})
+
+	provider := NewProviderFromRegistry(imageStr, &tmpDirGen, &image.RegistryOptions{
+		InsecureUseHTTP: true,
+		Platform:        &v1.Platform{OS: "linux", Architecture: "arm"},
+	})
+	img, err := provider.Provide()
+	require.NoError(t, err)
+	require.NoError(t, img.Read())
+	assert.Equal(t, digests[0], img.Metadata.ManifestDigest)
+	assert.Equal(t, "v5", img.Platform().Variant)
+}
+
+func Test_matchesPlatform(t *testing.T) {
+	tests := []struct {
+		name      string
+		candidate v1.Platform
+		requested v1.Platform
+		want      bool
+	}{
+		{
+			name:      "same OS build with a different revision",
+			candidate: v1.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.5329"},
+			requested: v1.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.1"},
+			want:      true,
+		},
+		{
+			name:      "different OS build",
+			candidate: v1.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.5329"},
+			requested: v1.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.20348"},
+		},
+		{
+			name:      "OS version not requested",
+			candidate: v1.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.5329"},
+			requested: v1.Platform{OS: "windows", Architecture: "amd64"},
+			want:      true,
+		},
+		{
+			name:      "variant mismatch",
+			candidate: v1.Platform{OS: "linux", Architecture: "arm", Variant: "v6"},
+			requested: v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+		},
+		{
+			name:      "default arm64 variant",
+			candidate: v1.Platform{OS: "linux", Architecture: "arm64"},
+			requested: v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"},
+			want:      true,
+		},
+		{
+			name:      "variant without a v prefix",
+			candidate: v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+			requested: v1.Platform{OS: "linux", Architecture: "arm", Variant: "7"},
+			want:      true,
+		},
+		{
+			name:      "variant not requested",
+			candidate: v1.Platform{OS: "linux", Architecture: "arm", Variant: "v6"},
+			requested: v1.Platform{OS: "linux", Architecture: "arm"},
+			want:      true,
+		},
+		{
+			name:      "architecture mismatch",
+			candidate: v1.Platform{OS: "linux", Architecture: "arm64"},
+			requested: v1.Platform{OS: "linux", Architecture: "amd64"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, matchesPlatform(test.candidate, test.requested))
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package oci
+
+// ProviderOption is a functional option for the providers of OCI layouts (directory, tarball, and stream) and the
+// BlobFetcherImageProvider (the registry provider is configured with image.RegistryOptions instead).
+type ProviderOption func(*providerConfig)
+
+type providerConfig struct {
+	// requireLayers indicates that images without any layers are rejected (see WithRequireLayers)
+	requireLayers bool
+}
+
+func newProviderConfig(options ...ProviderOption) providerConfig {
+	var cfg providerConfig
+	for _, option := range options {
+		option(&cfg)
+	}
+	return cfg
+}
+
+// WithRequireLayers enables (or disables) rejecting images without any layers with an error wrapping
+// image.ErrNoLayers (see image.RegistryOptions.RequireLayers). By default images without layers are permitted.
+func WithRequireLayers(required bool) ProviderOption {
+	return func(cfg *providerConfig) {
+		cfg.requireLayers = required
+	}
+}
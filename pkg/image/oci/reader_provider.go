@@ -0,0 +1,72 @@
+package oci
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+// readerLocation is how the image location is reported for errors about an image read from a stream.
+const readerLocation = "<stream>"
+
+// ReaderImageProvider is an image.Provider for an OCI image layout archive (optionally gzip compressed) read from a
+// stream, such as the export of a buildkit build piped to stdin:
+//
+//	docker buildx build --output type=oci,dest=- . | my-tool
+//	buildctl build ... --output type=oci,dest=- | my-tool
+//
+// The stream is never written to a registry or a docker daemon. Buildkit writes an "oci-layout" file, the blobs, and an
+// index.json that refers to the image manifest directly, or (when attestations are enabled, the default for buildx)
+// to a nested image index holding the image manifest alongside attestation manifests. Attestation manifests (marked
+// with the "vnd.docker.reference.type" annotation and an "unknown/unknown" platform) are skipped, as with
+// DirectoryImageProvider, thus the archive must hold exactly one runnable image. Note: the "type=docker" export of
+// recent buildkit versions also includes the OCI layout, thus can be read the same way.
+type ReaderImageProvider struct {
+	reader    io.Reader
+	tmpDirGen *file.TempDirGenerator
+	options   []ProviderOption
+}
+
+// NewProviderFromReader creates a new provider instance for the OCI layout archive read from the given stream.
+func NewProviderFromReader(reader io.Reader, tmpDirGen *file.TempDirGenerator, options ...ProviderOption) *ReaderImageProvider {
+	return &ReaderImageProvider{
+		reader:    reader,
+		tmpDirGen: tmpDirGen,
+		options:   options,
+	}
+}
+
+// Provide an image object that represents the OCI image from the stream. The stream is read to the end.
+func (p *ReaderImageProvider) Provide() (*image.Image, error) {
+	archive, err := file.NewArchiveReader(ioutil.NopCloser(p.reader))
+	if err != nil {
+		return nil, fmt.Errorf("unable to open OCI stream: %w", err)
+	}
+	defer archive.Close()
+
+	tempDir, err := p.tmpDirGen.NewTempDir()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = file.UntarToDirectory(archive, tempDir); err != nil {
+		return nil, fmt.Errorf("unable to extract OCI stream: %w", err)
+	}
+
+	// drain any trailing padding after the end of the archive, such that the writer of a pipe is not left blocked
+	if _, err := io.Copy(ioutil.Discard, p.reader); err != nil {
+		return nil, fmt.Errorf("unable to read OCI stream: %w", err)
+	}
+
+	img, err := NewProviderFromPath(tempDir, p.tmpDirGen, p.options...).Provide()
+	var notImageErr *image.ErrNotImage
+	if errors.As(err, &notImageErr) {
+		// report the stream rather than where it was extracted to
+		notImageErr.Location = readerLocation
+	}
+	return img, err
+}
@@ -0,0 +1,174 @@
+package oci
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildkitLayout writes an OCI layout shaped as the "--output type=oci" export of buildkit with attestations enabled:
+// the index.json refers to a nested image index holding the image manifest and a provenance attestation manifest.
+// The digest of the image manifest is returned.
+func buildkitLayout(t *testing.T, dir string) string {
+	t.Helper()
+
+	img, err := random.Image(1024, 2)
+	require.NoError(t, err)
+	imgDigest, err := img.Digest()
+	require.NoError(t, err)
+
+	attestation, err := random.Image(128, 1)
+	require.NoError(t, err)
+
+	nested := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{
+			Add:        img,
+			Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}},
+		},
+		mutate.IndexAddendum{
+			Add: attestation,
+			Descriptor: v1.Descriptor{
+				Platform: &v1.Platform{OS: "unknown", Architecture: "unknown"},
+				Annotations: map[string]string{
+					dockerReferenceTypeAnnotation: attestationManifestType,
+					"vnd.docker.reference.digest": imgDigest.String(),
+				},
+			},
+		},
+	)
+
+	path, err := layout.Write(dir, empty.Index)
+	require.NoError(t, err)
+	require.NoError(t, path.AppendIndex(nested, layout.WithAnnotations(map[string]string{
+		"org.opencontainers.image.ref.name": "latest",
+	})))
+
+	return imgDigest.String()
+}
+
+// tarDirectory writes a tar of the given directory (with directory entries, as buildkit does) to the given writer.
+func tarDirectory(t *testing.T, dir string, w io.Writer) {
+	t.Helper()
+
+	writer := tar.NewWriter(w)
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || p == dir {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := writer.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		contents, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		_, err = writer.Write(contents)
+		return err
+	})
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+}
+
+func TestReaderImageProvider_Provide(t *testing.T) {
+	layoutDir := t.TempDir()
+	wantDigest := buildkitLayout(t, layoutDir)
+
+	tests := []struct {
+		name     string
+		compress bool
+	}{
+		{
+			name: "oci export stream",
+		},
+		{
+			name:     "gzip compressed oci export stream",
+			compress: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tmpDirGen := file.NewTempDirGenerator()
+			t.Cleanup(func() {
+				assert.NoError(t, tmpDirGen.Cleanup())
+			})
+
+			// stream the export through a pipe, as with "buildctl build --output type=oci,dest=- | ..."
+			reader, writer := io.Pipe()
+			go func() {
+				var w io.Writer = writer
+				var gz *gzip.Writer
+				if test.compress {
+					gz = gzip.NewWriter(writer)
+					w = gz
+				}
+				tarDirectory(t, layoutDir, w)
+				// trailing padding after the end of the archive must be consumed
+				_, err := w.Write(make([]byte, 10240))
+				if err == nil && gz != nil {
+					err = gz.Close()
+				}
+				writer.CloseWithError(err)
+			}()
+
+			img, err := NewProviderFromReader(reader, &tmpDirGen).Provide()
+			require.NoError(t, err)
+			require.NoError(t, img.Read())
+
+			assert.Equal(t, wantDigest, img.Metadata.ManifestDigest)
+			assert.Len(t, img.Layers, 2)
+		})
+	}
+}
+
+func TestReaderImageProvider_Provide_NotLayout(t *testing.T) {
+	tmpDirGen := file.NewTempDirGenerator()
+	t.Cleanup(func() {
+		assert.NoError(t, tmpDirGen.Cleanup())
+	})
+
+	reader, writer := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(writer)
+		contents := []byte("not an OCI layout")
+		err := tw.WriteHeader(&tar.Header{Name: "README", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(contents))})
+		if err == nil {
+			_, err = tw.Write(contents)
+		}
+		if err == nil {
+			err = tw.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+
+	_, err := NewProviderFromReader(reader, &tmpDirGen).Provide()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to parse OCI directory index")
+}
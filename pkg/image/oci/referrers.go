@@ -0,0 +1,260 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// maxReferrersPages bounds the number of pages followed when listing referrers.
+const maxReferrersPages = 100
+
+// ErrReferrersAPIUnsupported is returned from ListReferrers when the registry does not support the OCI referrers API and
+// the referrers tag schema fallback is disabled (see image.RegistryOptions.ReferrersTagFallback).
+var ErrReferrersAPIUnsupported = errors.New("registry does not support the referrers API")
+
+// Referrer describes an artifact (e.g. an SBOM, signature, or attestation) that refers to an image.
+type Referrer struct {
+	v1.Descriptor
+	// ArtifactType is the type of the artifact (e.g. "application/spdx+json"), if provided by the registry.
+	ArtifactType string `json:"artifactType,omitempty"`
+}
+
+type referrersIndex struct {
+	Manifests []Referrer `json:"manifests"`
+}
+
+// ListReferrers lists the artifacts attached to the given image reference using the OCI referrers API. When the
+// registry does not support the referrers API the referrers tag schema (e.g. "repo:sha256-<hex>") is used instead,
+// unless the fallback is disabled (see image.RegistryOptions.ReferrersTagFallback) in which case
+// ErrReferrersAPIUnsupported is returned. An image without any referrers results in an empty list.
+func ListReferrers(imageStr string, registryOptions *image.RegistryOptions) ([]Referrer, error) {
+	if registryOptions == nil {
+		registryOptions = &image.RegistryOptions{}
+	}
+
+	ref, err := name.ParseReference(imageStr, prepareReferenceOptions(registryOptions)...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse registry reference=%q: %+v", imageStr, err)
+	}
+
+	if err := image.CheckRegistryPermitted(ref.Context().RegistryStr()); err != nil {
+		return nil, err
+	}
+
+	baseTransport := prepareTransport(registryOptions)
+	remoteOptions := prepareRemoteOptions(ref, registryOptions, baseTransport)
+
+	descriptor, err := remote.Head(ref, remoteOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve image digest: %w", err)
+	}
+
+	repo := ref.Context()
+	authenticator, err := prepareAuthenticator(repo, registryOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := transport.NewWithContext(context.Background(), repo.Registry, authenticator, baseTransport, []string{repo.Scope(transport.PullScope)})
+	if err != nil {
+		return nil, fmt.Errorf("unable to authenticate with registry: %w", err)
+	}
+
+	referrers, supported, err := listReferrersFromAPI(&http.Client{Transport: client}, repo, descriptor.Digest)
+	if err != nil {
+		return nil, err
+	}
+	if supported {
+		return referrers, nil
+	}
+
+	if registryOptions.ReferrersTagFallback != nil && !*registryOptions.ReferrersTagFallback {
+		return nil, fmt.Errorf("unable to list referrers for image=%q: %w", imageStr, ErrReferrersAPIUnsupported)
+	}
+
+	log.Debugf("registry does not support the referrers API, using the referrers tag schema for image=%q", imageStr)
+	return listReferrersFromTag(repo, descriptor.Digest, remoteOptions)
+}
+
+// FetchReferrer fetches the raw manifest of the given referrer (as returned from ListReferrers) from the repository of
+// the given image reference. The manifest digest is verified against the referrer descriptor.
+func FetchReferrer(imageStr string, referrer Referrer, registryOptions *image.RegistryOptions) ([]byte, error) {
+	if registryOptions == nil {
+		registryOptions = &image.RegistryOptions{}
+	}
+
+	ref, err := name.ParseReference(imageStr, prepareReferenceOptions(registryOptions)...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse registry reference=%q: %+v", imageStr, err)
+	}
+
+	if err := image.CheckRegistryPermitted(ref.Context().RegistryStr()); err != nil {
+		return nil, err
+	}
+
+	digestRef := ref.Context().Digest(referrer.Digest.String())
+	descriptor, err := remote.Get(digestRef, prepareRemoteOptions(digestRef, registryOptions, prepareTransport(registryOptions))...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch referrer=%q: %w", referrer.Digest, err)
+	}
+	return descriptor.Manifest, nil
+}
+
+// listReferrersFromAPI lists referrers with the OCI referrers API, following any pagination links. If the registry does
+// not support the referrers API then no error is returned, but the referrers are indicated as not supported.
+func listReferrersFromAPI(client *http.Client, repo name.Repository, digest v1.Hash) ([]Referrer, bool, error) {
+	next := &url.URL{
+		Scheme: repo.Registry.Scheme(),
+		Host:   repo.RegistryStr(),
+		Path:   fmt.Sprintf("/v2/%s/referrers/%s", repo.RepositoryStr(), digest),
+	}
+
+	var referrers []Referrer
+	for page := 0; next != nil; page++ {
+		if page >= maxReferrersPages {
+			return nil, true, fmt.Errorf("too many pages of referrers (more than %d)", maxReferrersPages)
+		}
+
+		index, nextPage, err := fetchReferrersPage(client, next)
+		if err != nil {
+			return nil, true, err
+		}
+		if index == nil {
+			// the registry does not support the referrers API
+			return nil, false, nil
+		}
+
+		referrers = append(referrers, index.Manifests...)
+		next = nextPage
+	}
+
+	return referrers, true, nil
+}
+
+// fetchReferrersPage fetches a single page of referrers, returning the URL of the next page (if there is one). A nil
+// index indicates that the registry does not support the referrers API.
+func fetchReferrersPage(client *http.Client, location *url.URL) (*referrersIndex, *url.URL, error) {
+	req, err := http.NewRequest(http.MethodGet, location.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", string(image.MediaTypeOCIIndex))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to list referrers: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if isUnsupportedStatus(resp.StatusCode) {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		return nil, nil, nil
+	}
+
+	if err := transport.CheckError(resp, http.StatusOK); err != nil {
+		return nil, nil, fmt.Errorf("unable to list referrers: %w", err)
+	}
+
+	if image.SourceFromMediaType(resp.Header.Get("Content-Type")) != image.IndexMediaTypeKind {
+		// some registries respond to unknown routes with a successful (but unrelated) response
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		return nil, nil, nil
+	}
+
+	var index referrersIndex
+	if err := json.NewDecoder(resp.Body).Decode(&index); err != nil {
+		return nil, nil, fmt.Errorf("unable to parse referrers: %w", err)
+	}
+
+	next, err := nextPageLink(location, resp.Header.Get("Link"))
+	if err != nil {
+		return nil, nil, err
+	}
+	return &index, next, nil
+}
+
+// isUnsupportedStatus indicates if the given response status means that the registry does not support (or does not
+// have) what was requested, as opposed to a failure of the request. Registries without the referrers API respond with
+// any of 404 (not found), 400 (bad request, e.g. the digest is taken as an invalid tag), 405 (method not allowed), or
+// 501 (not implemented).
+func isUnsupportedStatus(status int) bool {
+	switch status {
+	case http.StatusNotFound, http.StatusBadRequest, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
+
+// nextPageLink resolves the "next" relation of an RFC 5988 Link header (e.g. `</v2/...?n=10&last=x>; rel="next"`).
+func nextPageLink(location *url.URL, header string) (*url.URL, error) {
+	if header == "" {
+		return nil, nil
+	}
+
+	for _, link := range strings.Split(header, ",") {
+		parts := strings.Split(link, ";")
+		target := strings.TrimSpace(parts[0])
+		if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+			continue
+		}
+
+		for _, param := range parts[1:] {
+			if strings.ReplaceAll(strings.TrimSpace(param), `"`, "") != "rel=next" {
+				continue
+			}
+			next, err := location.Parse(strings.Trim(target, "<>"))
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse referrers link=%q: %w", target, err)
+			}
+			return next, nil
+		}
+	}
+	return nil, nil
+}
+
+// listReferrersFromTag lists referrers using the referrers tag schema, where the referrers are maintained by clients
+// as an image index tagged with the digest of the image (e.g. "sha256-<hex>").
+func listReferrersFromTag(repo name.Repository, digest v1.Hash, remoteOptions []remote.Option) ([]Referrer, error) {
+	tag := repo.Tag(fmt.Sprintf("%s-%s", digest.Algorithm, digest.Hex))
+
+	descriptor, err := remote.Get(tag, remoteOptions...)
+	if err != nil {
+		var transportErr *transport.Error
+		if errors.As(err, &transportErr) && isUnsupportedStatus(transportErr.StatusCode) {
+			// there are no referrers for this image (or the registry rejects the tag)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to fetch referrers tag=%q: %w", tag, err)
+	}
+
+	var index referrersIndex
+	if err := json.Unmarshal(descriptor.Manifest, &index); err != nil {
+		return nil, fmt.Errorf("unable to parse referrers tag=%q: %w", tag, err)
+	}
+	return index.Manifests, nil
+}
+
+// prepareAuthenticator returns the authenticator for the registry of the given repository, resolved in the same order
+// as with prepareRemoteOptions.
+func prepareAuthenticator(repo name.Repository, registryOptions *image.RegistryOptions) (authn.Authenticator, error) {
+	authenticator, err := newCredentialChain(registryOptions).Resolve(repo)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve registry credentials: %w", err)
+	}
+	return authenticator, nil
+}
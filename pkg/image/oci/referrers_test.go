@@ -0,0 +1,245 @@
+package oci
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pushArtifact pushes a random image to the repository of the given image (by digest) to act as an attached artifact.
+func pushArtifact(t *testing.T, imageStr string, artifactType string) Referrer {
+	t.Helper()
+
+	ref, err := name.ParseReference(imageStr, name.Insecure)
+	require.NoError(t, err)
+
+	artifact, err := random.Image(256, 1)
+	require.NoError(t, err)
+	digest, err := artifact.Digest()
+	require.NoError(t, err)
+	rawManifest, err := artifact.RawManifest()
+	require.NoError(t, err)
+	mediaType, err := artifact.MediaType()
+	require.NoError(t, err)
+
+	require.NoError(t, remote.Write(ref.Context().Digest(digest.String()), artifact))
+
+	return Referrer{
+		Descriptor: v1.Descriptor{
+			MediaType: mediaType,
+			Size:      int64(len(rawManifest)),
+			Digest:    digest,
+		},
+		ArtifactType: artifactType,
+	}
+}
+
+func imageDigest(t *testing.T, imageStr string) v1.Hash {
+	t.Helper()
+
+	ref, err := name.ParseReference(imageStr, name.Insecure)
+	require.NoError(t, err)
+	descriptor, err := remote.Head(ref)
+	require.NoError(t, err)
+	return descriptor.Digest
+}
+
+func referrersIndexJSON(t *testing.T, referrers ...Referrer) []byte {
+	t.Helper()
+
+	contents, err := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     image.MediaTypeOCIIndex,
+		"manifests":     referrers,
+	})
+	require.NoError(t, err)
+	return contents
+}
+
+func TestListReferrers_TagSchemaFallback(t *testing.T) {
+	imageStr := pushRandomImage(t, nil)
+	sbom := pushArtifact(t, imageStr, "application/spdx+json")
+	signature := pushArtifact(t, imageStr, "application/vnd.dev.cosign.artifact.sig.v1+json")
+
+	// the registry does not support the referrers API, so maintain the referrers tag (as a client would)
+	digest := imageDigest(t, imageStr)
+	repo := strings.TrimSuffix(imageStr, ":latest")
+	host := strings.SplitN(repo, "/", 2)[0]
+	tagURL := fmt.Sprintf("http://%s/v2/stereoscope/test/manifests/%s-%s", host, digest.Algorithm, digest.Hex)
+
+	req, err := http.NewRequest(http.MethodPut, tagURL, bytes.NewReader(referrersIndexJSON(t, sbom, signature)))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", string(image.MediaTypeOCIIndex))
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	options := &image.RegistryOptions{InsecureUseHTTP: true}
+	referrers, err := ListReferrers(imageStr, options)
+	require.NoError(t, err)
+	assert.Equal(t, []Referrer{sbom, signature}, referrers)
+
+	contents, err := FetchReferrer(imageStr, referrers[0], options)
+	require.NoError(t, err)
+	digestOfContents, _, err := v1.SHA256(bytes.NewReader(contents))
+	require.NoError(t, err)
+	assert.Equal(t, sbom.Digest, digestOfContents)
+}
+
+func TestListReferrers_TagSchemaFallbackDisabled(t *testing.T) {
+	var tagLookups int32
+	imageStr := pushRandomImage(t, func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.Contains(r.URL.Path, "/manifests/sha256-") {
+				atomic.AddInt32(&tagLookups, 1)
+			}
+			handler.ServeHTTP(w, r)
+		})
+	})
+
+	disabled := false
+	_, err := ListReferrers(imageStr, &image.RegistryOptions{InsecureUseHTTP: true, ReferrersTagFallback: &disabled})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrReferrersAPIUnsupported))
+	assert.Zero(t, atomic.LoadInt32(&tagLookups))
+
+	// by default the tag schema is looked up
+	referrers, err := ListReferrers(imageStr, &image.RegistryOptions{InsecureUseHTTP: true})
+	require.NoError(t, err)
+	assert.Empty(t, referrers)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&tagLookups))
+}
+
+func TestListReferrers_UnsupportedStatus(t *testing.T) {
+	tests := []struct {
+		status       int
+		wantFallback bool
+	}{
+		{status: http.StatusBadRequest, wantFallback: true},
+		{status: http.StatusMethodNotAllowed, wantFallback: true},
+		{status: http.StatusNotImplemented, wantFallback: true},
+		{status: http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		t.Run(http.StatusText(test.status), func(t *testing.T) {
+			var tagLookups int32
+			imageStr := pushRandomImage(t, func(handler http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					switch {
+					case strings.Contains(r.URL.Path, "/referrers/"):
+						w.WriteHeader(test.status)
+						return
+					case strings.Contains(r.URL.Path, "/manifests/sha256-"):
+						atomic.AddInt32(&tagLookups, 1)
+					}
+					handler.ServeHTTP(w, r)
+				})
+			})
+
+			referrers, err := ListReferrers(imageStr, &image.RegistryOptions{InsecureUseHTTP: true})
+			if !test.wantFallback {
+				require.Error(t, err)
+				assert.Zero(t, atomic.LoadInt32(&tagLookups))
+				return
+			}
+			require.NoError(t, err)
+			assert.Empty(t, referrers)
+			assert.Equal(t, int32(1), atomic.LoadInt32(&tagLookups))
+		})
+	}
+}
+
+func TestListReferrers_NoReferrers(t *testing.T) {
+	imageStr := pushRandomImage(t, nil)
+
+	referrers, err := ListReferrers(imageStr, &image.RegistryOptions{InsecureUseHTTP: true})
+	require.NoError(t, err)
+	assert.Empty(t, referrers)
+}
+
+func TestListReferrers_ReferrersAPI(t *testing.T) {
+	var pages [][]byte
+
+	imageStr := pushRandomImage(t, func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.Contains(r.URL.Path, "/referrers/") {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			page := 0
+			if r.URL.Query().Get("page") == "1" {
+				page = 1
+			} else {
+				w.Header().Set("Link", fmt.Sprintf(`<%s?page=1>; rel="next"`, r.URL.Path))
+			}
+			w.Header().Set("Content-Type", string(image.MediaTypeOCIIndex))
+			_, _ = w.Write(pages[page])
+		})
+	})
+
+	sbom := pushArtifact(t, imageStr, "application/spdx+json")
+	attestation := pushArtifact(t, imageStr, "application/vnd.in-toto+json")
+	pages = [][]byte{referrersIndexJSON(t, sbom), referrersIndexJSON(t, attestation)}
+
+	referrers, err := ListReferrers(imageStr, &image.RegistryOptions{InsecureUseHTTP: true})
+	require.NoError(t, err)
+	assert.Equal(t, []Referrer{sbom, attestation}, referrers)
+}
+
+func Test_nextPageLink(t *testing.T) {
+	location := &url.URL{Scheme: "https", Host: "example.com", Path: "/v2/repo/referrers/sha256:abc"}
+
+	tests := []struct {
+		name     string
+		header   string
+		expected string
+	}{
+		{
+			name: "no link",
+		},
+		{
+			name:     "relative link",
+			header:   `</v2/repo/referrers/sha256:abc?last=x>; rel="next"`,
+			expected: "https://example.com/v2/repo/referrers/sha256:abc?last=x",
+		},
+		{
+			name:     "absolute link without quotes",
+			header:   `<https://other.example.com/page2>; rel=next`,
+			expected: "https://other.example.com/page2",
+		},
+		{
+			name:   "unrelated relation",
+			header: `</v2/repo/referrers/sha256:abc?last=x>; rel="prev"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			next, err := nextPageLink(location, test.header)
+			require.NoError(t, err)
+			if test.expected == "" {
+				assert.Nil(t, next)
+				return
+			}
+			require.NotNil(t, next)
+			assert.Equal(t, test.expected, next.String())
+		})
+	}
+}
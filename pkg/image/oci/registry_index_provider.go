@@ -0,0 +1,94 @@
+package oci
+
+import (
+	"fmt"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/event"
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// ProvideAll returns an image object for every platform within the manifest list (or OCI index) referenced by the
+// provider, in the order they are listed within the index. Attestation manifests are skipped, and nested indexes are
+// descended into. If the reference is for a single image manifest then only that image is returned. All images share
+// the same registry transport (and credentials), so the fetch stats for each image cover all images in the index.
+func (p *RegistryImageProvider) ProvideAll() ([]*image.Image, error) {
+	log.Debugf("pulling all images in the index directly from registry image=%q", p.imageStr)
+
+	ref, err := name.ParseReference(p.imageStr, prepareReferenceOptions(p.registryOptions)...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse registry reference=%q: %+v", p.imageStr, err)
+	}
+
+	if err := image.CheckRegistryPermitted(ref.Context().RegistryStr()); err != nil {
+		return nil, err
+	}
+
+	recorder := newFetchRecorder(prepareTransport(p.registryOptions))
+
+	prog, stage := p.trackFetchProgress(nil)
+
+	stage.Set(event.PullingStage, "fetching image manifest")
+	descriptor, err := remote.Get(ref, prepareRemoteOptions(ref, p.registryOptions, recorder)...)
+	if err != nil {
+		prog.Err = err
+		return nil, fmt.Errorf("failed to get image descriptor from registry: %+v", err)
+	}
+
+	if image.SourceFromMediaType(string(descriptor.MediaType)) != image.IndexMediaTypeKind {
+		img, err := descriptor.Image()
+		if err != nil {
+			prog.Err = err
+			return nil, fmt.Errorf("failed to get image from registry: %+v", err)
+		}
+		prog.N++
+		prog.SetCompleted()
+
+		result, err := p.newImage(ref, descriptor.Digest, img, nil, recorder)
+		if err != nil {
+			return nil, err
+		}
+		return []*image.Image{result}, nil
+	}
+
+	index, err := descriptor.ImageIndex()
+	if err != nil {
+		prog.Err = err
+		return nil, fmt.Errorf("failed to get image index from registry: %+v", err)
+	}
+
+	stage.Set(event.PullingStage, "fetching platform manifests")
+	candidates, err := imageManifests(index)
+	if err != nil {
+		prog.Err = err
+		return nil, fmt.Errorf("failed to get image manifests from registry index: %+v", err)
+	}
+	if len(candidates) == 0 {
+		prog.Err = fmt.Errorf("no image manifests found")
+		return nil, fmt.Errorf("no image manifests found in registry index=%q", p.imageStr)
+	}
+
+	prog.Total = int64(len(candidates))
+
+	var images []*image.Image
+	for _, candidate := range candidates {
+		img, err := candidate.index.Image(candidate.descriptor.Digest)
+		if err != nil {
+			prog.Err = err
+			return nil, fmt.Errorf("failed to get image=%q from registry index: %+v", candidate.descriptor.Digest, err)
+		}
+
+		result, err := p.newImage(ref, candidate.descriptor.Digest, img, candidate.descriptor.Platform, recorder)
+		if err != nil {
+			prog.Err = err
+			return nil, err
+		}
+		images = append(images, result)
+		prog.N++
+	}
+	prog.SetCompleted()
+
+	return images, nil
+}
@@ -0,0 +1,116 @@
+package oci
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pushRandomIndex starts an in-memory registry with a multi-platform index (with an attestation manifest), returning
+// the index reference along with the platform image digests (in index order).
+func pushRandomIndex(t *testing.T, platforms ...v1.Platform) (string, []string) {
+	t.Helper()
+
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	imageStr := fmt.Sprintf("%s/stereoscope/multi:latest", u.Host)
+	ref, err := name.ParseReference(imageStr, name.Insecure)
+	require.NoError(t, err)
+
+	var digests []string
+	var index v1.ImageIndex = empty.Index
+	for _, platform := range platforms {
+		platform := platform
+		img, err := random.Image(1024, 1)
+		require.NoError(t, err)
+
+		cfg, err := img.ConfigFile()
+		require.NoError(t, err)
+		cfg = cfg.DeepCopy()
+		cfg.OS, cfg.Architecture, cfg.OSVersion = platform.OS, platform.Architecture, platform.OSVersion
+		img, err = mutate.ConfigFile(img, cfg)
+		require.NoError(t, err)
+
+		digest, err := img.Digest()
+		require.NoError(t, err)
+		digests = append(digests, digest.String())
+
+		index = mutate.AppendManifests(index, mutate.IndexAddendum{
+			Add:        img,
+			Descriptor: v1.Descriptor{Platform: &platform},
+		})
+	}
+
+	attestation, err := random.Image(128, 1)
+	require.NoError(t, err)
+	index = mutate.AppendManifests(index, mutate.IndexAddendum{
+		Add: attestation,
+		Descriptor: v1.Descriptor{
+			Platform:    &v1.Platform{OS: "unknown", Architecture: "unknown"},
+			Annotations: map[string]string{dockerReferenceTypeAnnotation: attestationManifestType},
+		},
+	})
+
+	require.NoError(t, remote.WriteIndex(ref, index))
+	return imageStr, digests
+}
+
+func TestRegistryImageProvider_ProvideAll(t *testing.T) {
+	imageStr, digests := pushRandomIndex(t,
+		v1.Platform{OS: "linux", Architecture: "amd64"},
+		v1.Platform{OS: "linux", Architecture: "arm64"},
+	)
+
+	tmpDirGen := file.NewTempDirGenerator()
+	t.Cleanup(func() {
+		assert.NoError(t, tmpDirGen.Cleanup())
+	})
+
+	provider := NewProviderFromRegistry(imageStr, &tmpDirGen, &image.RegistryOptions{InsecureUseHTTP: true})
+	images, err := provider.ProvideAll()
+	require.NoError(t, err)
+	require.Len(t, images, 2)
+
+	var architectures []string
+	for idx, img := range images {
+		require.NoError(t, img.Read())
+		architectures = append(architectures, img.Metadata.Config.Architecture)
+		require.Len(t, img.Metadata.RepoDigests, 1)
+		assert.Contains(t, img.Metadata.RepoDigests[0], "@"+digests[idx])
+		assert.Len(t, img.Layers, 1)
+	}
+	assert.Equal(t, []string{"amd64", "arm64"}, architectures)
+}
+
+func TestRegistryImageProvider_ProvideAll_SingleImage(t *testing.T) {
+	imageStr := pushRandomImage(t, nil)
+
+	tmpDirGen := file.NewTempDirGenerator()
+	t.Cleanup(func() {
+		assert.NoError(t, tmpDirGen.Cleanup())
+	})
+
+	provider := NewProviderFromRegistry(imageStr, &tmpDirGen, &image.RegistryOptions{InsecureUseHTTP: true})
+	images, err := provider.ProvideAll()
+	require.NoError(t, err)
+	require.Len(t, images, 1)
+	require.NoError(t, images[0].Read())
+	assert.Len(t, images[0].Layers, 2)
+}
@@ -1,16 +1,22 @@
 package oci
 
 import (
-	"crypto/tls"
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 
+	"github.com/anchore/stereoscope/internal/bus"
 	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/event"
 	"github.com/anchore/stereoscope/pkg/file"
 	"github.com/anchore/stereoscope/pkg/image"
-	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/wagoodman/go-partybus"
+	"github.com/wagoodman/go-progress"
 )
 
 // RegistryImageProvider is a image.Provider capable of fetching and representing a container image fetched from a remote registry (described by the OCI distribution spec).
@@ -31,42 +37,135 @@ func NewProviderFromRegistry(imgStr string, tmpDirGen *file.TempDirGenerator, re
 
 // Provide an image object that represents the cached docker image tar fetched a registry.
 func (p *RegistryImageProvider) Provide() (*image.Image, error) {
-	log.Debugf("pulling image info directly from registry image=%q", p.imageStr)
+	return p.ProvideContext(context.Background())
+}
 
-	imageTempDir, err := p.tmpDirGen.NewTempDir()
-	if err != nil {
-		return nil, err
-	}
+// ProvideContext is Provide where the given context is used for all registry requests, including the layer fetches made
+// while the image is read (thus the context must remain valid until Image.Read returns). If the context deadline passes
+// while fetching the manifest then a *image.ErrFetchDeadlineExceeded is returned.
+func (p *RegistryImageProvider) ProvideContext(ctx context.Context) (*image.Image, error) {
+	log.Debugf("pulling image info directly from registry image=%q", p.imageStr)
 
 	ref, err := name.ParseReference(p.imageStr, prepareReferenceOptions(p.registryOptions)...)
 	if err != nil {
 		return nil, fmt.Errorf("unable to parse registry reference=%q: %+v", p.imageStr, err)
 	}
 
-	descriptor, err := remote.Get(ref, prepareRemoteOptions(ref, p.registryOptions)...)
+	if err := image.CheckRegistryPermitted(ref.Context().RegistryStr()); err != nil {
+		return nil, err
+	}
+
+	recorder := newFetchRecorder(prepareTransport(p.registryOptions))
+
+	prog, stage := p.trackFetchProgress(event.DisabledTypes(ctx))
+
+	stage.Set(event.PullingStage, "fetching image manifest")
+	bus.ReportStage(p.imageStr, event.PullingStage, "fetching image manifest")
+	descriptor, err := remote.Get(ref, append(prepareRemoteOptions(ref, p.registryOptions, recorder), remote.WithContext(ctx))...)
 	if err != nil {
+		prog.Err = err
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			stats := recorder.snapshot()
+			return nil, image.NewErrFetchDeadlineExceeded(p.imageStr, image.FetchProgress{
+				Stage:    "fetching manifest",
+				Bytes:    stats.Bytes,
+				Duration: stats.Duration,
+			})
+		}
 		return nil, fmt.Errorf("failed to get image descriptor from registry: %+v", err)
 	}
 
-	img, err := descriptor.Image()
+	img, platform, err := selectImage(descriptor.MediaType, descriptor, p.registryOptions.Platform)
 	if err != nil {
+		prog.Err = err
 		return nil, fmt.Errorf("failed to get image from registry: %+v", err)
 	}
+	prog.N++
+	prog.SetCompleted()
+	bus.ReportProgress(p.imageStr, event.PullingStage, prog.N, prog.Total)
 
-	// craft a repo digest from the registry reference and the known digest
 	// note: the descriptor is fetched from the registry, and the descriptor digest is the same as the repo digest
-	repoDigest := fmt.Sprintf("%s/%s@%s", ref.Context().RegistryStr(), ref.Context().RepositoryStr(), descriptor.Digest.String())
+	return p.newImage(ref, descriptor.Digest, img, platform, recorder)
+}
 
-	metadata := []image.AdditionalMetadata{
-		image.WithRepoDigests([]string{repoDigest}),
+// Exists indicates if the image manifest exists within the registry, which is checked with a single manifest HEAD
+// request (no manifest, config, or layer content is fetched). An image is reported as not existing (false without an
+// error) only when the registry says so; an error is returned when existence cannot be determined (e.g. the registry
+// is unreachable or the credentials are rejected).
+func (p *RegistryImageProvider) Exists(ctx context.Context) (bool, error) {
+	_, err := p.head(ctx)
+	if err == nil {
+		return true, nil
+	}
+
+	var transportErr *transport.Error
+	if errors.As(err, &transportErr) && transportErr.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	return false, fmt.Errorf("unable to check for image within registry: %w", err)
+}
+
+// Digest returns the digest of the manifest (or index) that the reference currently refers to within the registry
+// (e.g. the digest a tag points to), which is resolved with a single manifest HEAD request.
+func (p *RegistryImageProvider) Digest(ctx context.Context) (v1.Hash, error) {
+	descriptor, err := p.head(ctx)
+	if err != nil {
+		return v1.Hash{}, fmt.Errorf("unable to resolve image digest within registry: %w", err)
+	}
+	return descriptor.Digest, nil
+}
+
+// head requests the descriptor of the image manifest without fetching the manifest content.
+func (p *RegistryImageProvider) head(ctx context.Context) (*v1.Descriptor, error) {
+	ref, err := name.ParseReference(p.imageStr, prepareReferenceOptions(p.registryOptions)...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse registry reference=%q: %+v", p.imageStr, err)
 	}
 
-	// make a best effort to get the manifest, should not block getting an image though if it fails
-	if manifestBytes, err := img.RawManifest(); err == nil {
-		metadata = append(metadata, image.WithManifest(manifestBytes))
+	if err := image.CheckRegistryPermitted(ref.Context().RegistryStr()); err != nil {
+		return nil, err
 	}
 
-	return image.NewImage(img, imageTempDir, metadata...), nil
+	return remote.Head(ref, append(prepareRemoteOptions(ref, p.registryOptions, prepareTransport(p.registryOptions)), remote.WithContext(ctx))...)
+}
+
+// newImage creates an image object for the given image fetched from the registry with the given manifest digest, along
+// with the platform of the index entry the image was selected from (if any).
+func (p *RegistryImageProvider) newImage(ref name.Reference, digest v1.Hash, img v1.Image, platform *v1.Platform, recorder *fetchRecorder) (*image.Image, error) {
+	// craft a repo digest from the registry reference and the known digest
+	repoDigest := fmt.Sprintf("%s/%s@%s", ref.Context().RegistryStr(), ref.Context().RepositoryStr(), digest.String())
+
+	return assembleImage(img, p.imageStr, platform, p.registryOptions.RequireLayers, p.tmpDirGen,
+		image.WithRepoDigests([]string{repoDigest}),
+		image.WithFetchStatsRecorder(recorder.snapshot),
+		image.WithForeignLayers(p.registryOptions.AllowForeignLayers),
+	)
+}
+
+// trackFetchProgress publishes the fetch event for the image. Note: only the image manifest and config are fetched by
+// the provider, the layers are fetched as the image is read (see the read image event). The event is not published when
+// the event type is disabled, either globally or by the given types.
+func (p *RegistryImageProvider) trackFetchProgress(disabled event.TypeSet) (*progress.Manual, *event.Stage) {
+	prog := &progress.Manual{
+		Total: 1,
+	}
+	stage := &event.Stage{}
+
+	bus.PublishUnless(partybus.Event{
+		Type:   event.FetchImage,
+		Source: p.imageStr,
+		Value: progress.StagedProgressable(&struct {
+			progress.Stager
+			event.StageCoder
+			*progress.Manual
+		}{
+			Stager:     progress.Stager(stage),
+			StageCoder: event.StageCoder(stage),
+			Manual:     prog,
+		}),
+	}, disabled)
+
+	return prog, stage
 }
 
 func prepareReferenceOptions(registryOptions *image.RegistryOptions) []name.Option {
@@ -77,26 +176,27 @@ func prepareReferenceOptions(registryOptions *image.RegistryOptions) []name.Opti
 	return options
 }
 
-func prepareRemoteOptions(ref name.Reference, registryOptions *image.RegistryOptions) []remote.Option {
-	var opts []remote.Option
-	if registryOptions.InsecureSkipTLSVerify {
-		t := &http.Transport{
-			// nolint: gosec
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
-		opts = append(opts, remote.WithTransport(t))
+func prepareTransport(registryOptions *image.RegistryOptions) http.RoundTripper {
+	var transport http.RoundTripper = sharedTransport(registryOptions)
+	transport = newTokenExpiryTransport(transport)
+	transport = newHeaderTransport(transport, registryOptions.ExtraHeaders)
+	transport = newScopeTransport(transport, registryOptions.TokenScopes)
+	transport = newManifestAcceptTransport(transport, registryOptions.ManifestMediaTypes)
+
+	if registryOptions.ResumeDownloads == nil || *registryOptions.ResumeDownloads {
+		transport = newResumingTransport(transport)
 	}
+	return newContentEncodingTransport(transport)
+}
 
-	// note: the authn.Authenticator and authn.Keychain options are mutually exclusive, only one may be provided.
-	// If no explicit authenticator can be found, then fallback to the keychain.
-	authenticator := registryOptions.Authenticator(ref.Context().RegistryStr())
-	if authenticator != nil {
-		opts = append(opts, remote.WithAuth(authenticator))
-	} else {
-		// use the Keychain specified from a docker config file.
-		log.Debugf("no registry credentials configured, using the default keychain")
-		opts = append(opts, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+func prepareRemoteOptions(ref name.Reference, registryOptions *image.RegistryOptions, transport http.RoundTripper) []remote.Option {
+	opts := []remote.Option{
+		remote.WithTransport(transport),
 	}
 
+	// credentials are resolved from the explicit registry options, then the docker credential helpers, then the docker
+	// config file, otherwise the registry is accessed anonymously.
+	opts = append(opts, remote.WithAuthFromKeychain(newCredentialChain(registryOptions)))
+
 	return opts
 }
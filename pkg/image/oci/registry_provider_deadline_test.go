@@ -0,0 +1,48 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryImageProvider_ProvideContext_DeadlineExceeded(t *testing.T) {
+	var stall int32
+	imageStr := pushRandomImage(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.LoadInt32(&stall) == 1 && strings.Contains(r.URL.Path, "/manifests/") {
+				<-r.Context().Done()
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	atomic.StoreInt32(&stall, 1)
+
+	tmpDirGen := file.NewTempDirGenerator()
+	t.Cleanup(func() {
+		assert.NoError(t, tmpDirGen.Cleanup())
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	provider := NewProviderFromRegistry(imageStr, &tmpDirGen, &image.RegistryOptions{InsecureUseHTTP: true})
+	_, err := provider.ProvideContext(ctx)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "unexpected error: %+v", err)
+
+	var deadlineErr *image.ErrFetchDeadlineExceeded
+	require.True(t, errors.As(err, &deadlineErr), "unexpected error: %+v", err)
+	assert.Equal(t, "fetching manifest", deadlineErr.Progress().Stage)
+	assert.Zero(t, deadlineErr.Progress().LayersTotal)
+}
@@ -1,12 +1,38 @@
 package oci
 
 import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
 
+	"github.com/anchore/stereoscope/internal/bus"
+	"github.com/anchore/stereoscope/pkg/event"
+	"github.com/anchore/stereoscope/pkg/event/parsers"
+	"github.com/anchore/stereoscope/pkg/file"
 	"github.com/anchore/stereoscope/pkg/image"
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wagoodman/go-partybus"
+	"github.com/wagoodman/go-progress"
 )
 
 func Test_prepareReferenceOptions(t *testing.T) {
@@ -44,3 +70,420 @@ func Test_prepareReferenceOptions(t *testing.T) {
 		})
 	}
 }
+
+// pushRandomImage starts an in-memory registry with a single random image, returning the image reference. The
+// registry handler may optionally be wrapped (e.g. to inspect requests).
+func pushRandomImage(t *testing.T, wrap func(http.Handler) http.Handler) string {
+	t.Helper()
+
+	var handler = registry.New()
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	imageStr := fmt.Sprintf("%s/stereoscope/test:latest", u.Host)
+	ref, err := name.ParseReference(imageStr, name.Insecure)
+	require.NoError(t, err)
+
+	img, err := random.Image(1024, 2)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+
+	return imageStr
+}
+
+func TestRegistryImageProvider_Provide_FetchStats(t *testing.T) {
+	imageStr := pushRandomImage(t, nil)
+
+	tmpDirGen := file.NewTempDirGenerator()
+	t.Cleanup(func() {
+		assert.NoError(t, tmpDirGen.Cleanup())
+	})
+
+	provider := NewProviderFromRegistry(imageStr, &tmpDirGen, &image.RegistryOptions{InsecureUseHTTP: true})
+	img, err := provider.Provide()
+	require.NoError(t, err)
+
+	require.NoError(t, img.Read())
+	require.NotNil(t, img.Metadata.FetchStats)
+
+	// the layers are fetched while reading, which must be reflected in the final stats
+	assert.True(t, img.Metadata.FetchStats.PulledFromRegistry)
+	assert.Greater(t, img.Metadata.FetchStats.Bytes, img.Metadata.Size)
+	assert.Greater(t, int64(img.Metadata.FetchStats.Duration), int64(0))
+}
+
+func TestRegistryImageProvider_Provide_ConfigDigest(t *testing.T) {
+	imageStr := pushRandomImage(t, nil)
+
+	tmpDirGen := file.NewTempDirGenerator()
+	t.Cleanup(func() {
+		assert.NoError(t, tmpDirGen.Cleanup())
+	})
+
+	img, err := NewProviderFromRegistry(imageStr, &tmpDirGen, &image.RegistryOptions{InsecureUseHTTP: true}).Provide()
+	require.NoError(t, err)
+	require.NoError(t, img.Read())
+
+	manifest, err := img.Manifest()
+	require.NoError(t, err)
+
+	// the image ID is the digest of the config descriptor (not the manifest)
+	assert.Equal(t, manifest.Config.Digest.String(), img.Metadata.ConfigDigest)
+	assert.Equal(t, img.Metadata.ConfigDigest, img.Metadata.ID)
+	assert.NotEqual(t, img.Metadata.ManifestDigest, img.Metadata.ConfigDigest)
+}
+
+func TestRegistryImageProvider_Provide_DisplayReference(t *testing.T) {
+	imageStr := pushRandomImage(t, nil)
+
+	tmpDirGen := file.NewTempDirGenerator()
+	t.Cleanup(func() {
+		assert.NoError(t, tmpDirGen.Cleanup())
+	})
+
+	img, err := NewProviderFromRegistry(imageStr, &tmpDirGen, &image.RegistryOptions{InsecureUseHTTP: true}).Provide()
+	require.NoError(t, err)
+	require.NoError(t, img.Read(image.WithDisplayReference("registry.example.com/team/app:friendly")))
+
+	assert.Equal(t, "registry.example.com/team/app:friendly", img.Metadata.Identity())
+	// the repo digest still refers to what was fetched
+	require.Len(t, img.Metadata.RepoDigests, 1)
+	assert.Contains(t, img.Metadata.RepoDigests[0], "stereoscope/test@sha256:")
+}
+
+func TestRegistryImageProvider_Provide_ExtraHeaders(t *testing.T) {
+	var pushed bool
+	var requests []http.Header
+	imageStr := pushRandomImage(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if pushed {
+				requests = append(requests, r.Header.Clone())
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	pushed = true
+
+	tmpDirGen := file.NewTempDirGenerator()
+	t.Cleanup(func() {
+		assert.NoError(t, tmpDirGen.Cleanup())
+	})
+
+	options := &image.RegistryOptions{
+		InsecureUseHTTP: true,
+		ExtraHeaders: map[string]string{
+			"X-Tenant-ID":   "tenant-1",
+			"Authorization": "Bearer clobbered",
+			"accept":        "clobbered",
+		},
+	}
+
+	img, err := NewProviderFromRegistry(imageStr, &tmpDirGen, options).Provide()
+	require.NoError(t, err)
+	require.NoError(t, img.Read())
+
+	require.NotEmpty(t, requests)
+	for _, header := range requests {
+		assert.Equal(t, []string{"tenant-1"}, header.Values("X-Tenant-ID"))
+		assert.Empty(t, header.Values("Authorization"))
+		for _, accept := range header.Values("Accept") {
+			assert.NotEqual(t, "clobbered", accept)
+		}
+	}
+}
+
+// recordingPublisher is a partybus.Publisher that keeps all published events.
+type recordingPublisher struct {
+	events []partybus.Event
+}
+
+func (r *recordingPublisher) Publish(e partybus.Event) {
+	r.events = append(r.events, e)
+}
+
+func TestRegistryImageProvider_Provide_StageEvents(t *testing.T) {
+	imageStr := pushRandomImage(t, nil)
+
+	publisher := &recordingPublisher{}
+	bus.SetPublisher(publisher)
+	t.Cleanup(func() {
+		bus.SetPublisher(nil)
+	})
+
+	tmpDirGen := file.NewTempDirGenerator()
+	t.Cleanup(func() {
+		assert.NoError(t, tmpDirGen.Cleanup())
+	})
+
+	img, err := NewProviderFromRegistry(imageStr, &tmpDirGen, &image.RegistryOptions{InsecureUseHTTP: true}).Provide()
+	require.NoError(t, err)
+	require.NoError(t, img.Read())
+
+	stages := make(map[partybus.EventType]event.StageCode)
+	for _, e := range publisher.events {
+		var prog progress.StagedProgressable
+		switch e.Type {
+		case event.FetchImage:
+			_, prog, err = parsers.ParseFetchImage(e)
+		case event.ReadImage:
+			prog, err = parseReadImageStage(e)
+		default:
+			continue
+		}
+		require.NoError(t, err)
+
+		coder, ok := prog.(event.StageCoder)
+		require.True(t, ok, "event %q does not provide a stage code", e.Type)
+		stages[e.Type] = coder.StageCode()
+		assert.True(t, progress.IsCompleted(prog), "event %q is not complete", e.Type)
+	}
+
+	assert.Equal(t, map[partybus.EventType]event.StageCode{
+		event.FetchImage: event.PullingStage,
+		event.ReadImage:  event.SquashingStage,
+	}, stages)
+}
+
+func parseReadImageStage(e partybus.Event) (progress.StagedProgressable, error) {
+	_, prog, err := parsers.ParseReadImage(e)
+	if err != nil {
+		return nil, err
+	}
+	staged, ok := prog.(progress.StagedProgressable)
+	if !ok {
+		return nil, fmt.Errorf("read image progress is not staged")
+	}
+	return staged, nil
+}
+
+func TestRegistryImageProvider_Provide_RegistryNotPermitted(t *testing.T) {
+	var requests int
+	imageStr := pushRandomImage(t, func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			handler.ServeHTTP(w, r)
+		})
+	})
+	requests = 0
+
+	host := strings.SplitN(imageStr, "/", 2)[0]
+	require.NoError(t, image.SetRegistryPolicy(image.RegistryPolicy{Denied: []string{host}}))
+	t.Cleanup(func() {
+		require.NoError(t, image.SetRegistryPolicy(image.RegistryPolicy{}))
+	})
+
+	tmpDirGen := file.NewTempDirGenerator()
+	t.Cleanup(func() {
+		assert.NoError(t, tmpDirGen.Cleanup())
+	})
+
+	_, err := NewProviderFromRegistry(imageStr, &tmpDirGen, &image.RegistryOptions{InsecureUseHTTP: true}).Provide()
+	assert.True(t, errors.Is(err, image.ErrRegistryNotPermitted), "unexpected error: %+v", err)
+
+	_, err = ListReferrers(imageStr, &image.RegistryOptions{InsecureUseHTTP: true})
+	assert.True(t, errors.Is(err, image.ErrRegistryNotPermitted), "unexpected error: %+v", err)
+
+	assert.Zero(t, requests, "no requests should be made to a registry that is not permitted")
+}
+
+// foreignTestLayer is a layer with the foreign layer media type.
+type foreignTestLayer struct {
+	v1.Layer
+}
+
+func (foreignTestLayer) MediaType() (types.MediaType, error) {
+	return types.DockerForeignLayer, nil
+}
+
+// pushForeignLayerImage pushes an image to an in-memory registry with a regular layer and a foreign layer (which is
+// only available from a separate server), returning the image reference and the number of foreign layer requests.
+func pushForeignLayerImage(t *testing.T) (string, *int32) {
+	t.Helper()
+
+	foreignLayerTar := func() []byte {
+		buf := &bytes.Buffer{}
+		writer := tar.NewWriter(buf)
+		contents := []byte("from the foreign layer")
+		require.NoError(t, writer.WriteHeader(&tar.Header{Name: "foreign.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(contents))}))
+		_, err := writer.Write(contents)
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		return buf.Bytes()
+	}()
+
+	foreignLayer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(foreignLayerTar)), nil
+	})
+	require.NoError(t, err)
+	compressedReader, err := foreignLayer.Compressed()
+	require.NoError(t, err)
+	compressed, err := ioutil.ReadAll(compressedReader)
+	require.NoError(t, err)
+
+	var foreignRequests int32
+	blobServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&foreignRequests, 1)
+		_, _ = w.Write(compressed)
+	}))
+	t.Cleanup(blobServer.Close)
+
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	imageStr := fmt.Sprintf("%s/stereoscope/foreign:latest", u.Host)
+	ref, err := name.ParseReference(imageStr, name.Insecure)
+	require.NoError(t, err)
+
+	regularLayer, err := random.Layer(1024, types.DockerLayer)
+	require.NoError(t, err)
+
+	img, err := mutate.Append(mutate.MediaType(empty.Image, types.DockerManifestSchema2),
+		mutate.Addendum{
+			Layer:     foreignTestLayer{Layer: foreignLayer},
+			URLs:      []string{blobServer.URL + "/foreign-layer.tar.gz"},
+			MediaType: types.DockerForeignLayer,
+		},
+		mutate.Addendum{
+			Layer: regularLayer,
+		},
+	)
+	require.NoError(t, err)
+	// note: foreign layers are not uploaded to the registry
+	require.NoError(t, remote.Write(ref, img))
+
+	return imageStr, &foreignRequests
+}
+
+func TestRegistryImageProvider_Provide_ForeignLayers(t *testing.T) {
+	tests := []struct {
+		name               string
+		allowForeignLayers bool
+	}{
+		{
+			name:               "skipped by default",
+			allowForeignLayers: false,
+		},
+		{
+			name:               "fetched from the layer URLs when allowed",
+			allowForeignLayers: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			imageStr, foreignRequests := pushForeignLayerImage(t)
+
+			tmpDirGen := file.NewTempDirGenerator()
+			t.Cleanup(func() {
+				assert.NoError(t, tmpDirGen.Cleanup())
+			})
+
+			provider := NewProviderFromRegistry(imageStr, &tmpDirGen, &image.RegistryOptions{
+				InsecureUseHTTP:    true,
+				AllowForeignLayers: test.allowForeignLayers,
+			})
+			img, err := provider.Provide()
+			require.NoError(t, err)
+			require.NoError(t, img.Read())
+			require.Len(t, img.Layers, 2)
+
+			foreign := img.Layers[0].Metadata
+			assert.True(t, foreign.IsForeign())
+			assert.Len(t, foreign.URLs, 1)
+			assert.False(t, img.Layers[1].Metadata.IsForeign())
+			assert.False(t, img.Layers[1].Metadata.Skipped)
+
+			exists, _, err := img.SquashedTree().File("/foreign.txt")
+			require.NoError(t, err)
+
+			if !test.allowForeignLayers {
+				assert.True(t, foreign.Skipped)
+				assert.False(t, exists)
+				assert.Equal(t, int32(0), atomic.LoadInt32(foreignRequests))
+				return
+			}
+
+			assert.False(t, foreign.Skipped)
+			assert.True(t, exists)
+			assert.Greater(t, atomic.LoadInt32(foreignRequests), int32(0))
+
+			contents, err := img.FileContentsFromSquash("/foreign.txt")
+			require.NoError(t, err)
+			actual, err := ioutil.ReadAll(contents)
+			require.NoError(t, err)
+			assert.Equal(t, "from the foreign layer", string(actual))
+		})
+	}
+}
+
+func TestRegistryImageProvider_Provide_ManifestMediaTypes(t *testing.T) {
+	const ociManifest = "application/vnd.oci.image.manifest.v1+json"
+
+	tests := []struct {
+		name               string
+		manifestMediaTypes []string
+		wantErr            bool
+	}{
+		{
+			name:    "default accept header",
+			wantErr: true,
+		},
+		{
+			name:               "prefer OCI manifests",
+			manifestMediaTypes: []string{ociManifest, "application/vnd.oci.image.index.v1+json"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var pushed bool
+			var accepted []string
+			imageStr := pushRandomImage(t, func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if pushed && strings.Contains(r.URL.Path, "/manifests/") {
+						// a picky registry that only serves manifests when OCI manifests are preferred
+						accept := r.Header.Get("Accept")
+						accepted = append(accepted, accept)
+						if !strings.HasPrefix(accept, ociManifest) {
+							w.WriteHeader(http.StatusNotFound)
+							return
+						}
+					}
+					next.ServeHTTP(w, r)
+				})
+			})
+			pushed = true
+
+			tmpDirGen := file.NewTempDirGenerator()
+			t.Cleanup(func() {
+				assert.NoError(t, tmpDirGen.Cleanup())
+			})
+
+			options := &image.RegistryOptions{
+				InsecureUseHTTP:    true,
+				ManifestMediaTypes: test.manifestMediaTypes,
+			}
+
+			_, err := NewProviderFromRegistry(imageStr, &tmpDirGen, options).Provide()
+			require.NotEmpty(t, accepted)
+			if test.wantErr {
+				assert.Error(t, err)
+				assert.Contains(t, accepted[0], "application/vnd.docker.distribution.manifest.v2+json")
+				return
+			}
+			require.NoError(t, err)
+			for _, accept := range accepted {
+				assert.Equal(t, strings.Join(test.manifestMediaTypes, ","), accept)
+			}
+		})
+	}
+}
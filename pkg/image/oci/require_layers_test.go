@@ -0,0 +1,158 @@
+package oci
+
+import (
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func emptyTestImage(t *testing.T) v1.Image {
+	t.Helper()
+
+	img, err := mutate.ConfigFile(empty.Image, &v1.ConfigFile{
+		OS:           "linux",
+		Architecture: "amd64",
+	})
+	require.NoError(t, err)
+	return img
+}
+
+// pushEmptyImage pushes an image without any layers to an in-memory registry, returning the image reference.
+func pushEmptyImage(t *testing.T) string {
+	t.Helper()
+
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	imageStr := fmt.Sprintf("%s/stereoscope/empty:latest", u.Host)
+	ref, err := name.ParseReference(imageStr, name.Insecure)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, emptyTestImage(t)))
+
+	return imageStr
+}
+
+func TestRegistryImageProvider_Provide_RequireLayers(t *testing.T) {
+	imageStr := pushEmptyImage(t)
+
+	tests := []struct {
+		name          string
+		requireLayers bool
+		wantErr       bool
+	}{
+		{
+			name:          "images without layers are permitted by default",
+			requireLayers: false,
+		},
+		{
+			name:          "images without layers are rejected when layers are required",
+			requireLayers: true,
+			wantErr:       true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tmpDirGen := file.NewTempDirGenerator()
+			t.Cleanup(func() {
+				assert.NoError(t, tmpDirGen.Cleanup())
+			})
+
+			registryOptions := &image.RegistryOptions{InsecureUseHTTP: true, RequireLayers: test.requireLayers}
+			img, err := NewProviderFromRegistry(imageStr, &tmpDirGen, registryOptions).Provide()
+			if test.wantErr {
+				assert.True(t, errors.Is(err, image.ErrNoLayers), "unexpected error: %+v", err)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, img.Read())
+			assert.Empty(t, img.Layers)
+		})
+	}
+}
+
+func TestRegistryImageProvider_Provide_RequireLayers_SideBySide(t *testing.T) {
+	imageStr := pushEmptyImage(t)
+
+	tmpDirGen := file.NewTempDirGenerator()
+	t.Cleanup(func() {
+		assert.NoError(t, tmpDirGen.Cleanup())
+	})
+
+	strict := NewProviderFromRegistry(imageStr, &tmpDirGen, &image.RegistryOptions{InsecureUseHTTP: true, RequireLayers: true})
+	permissive := NewProviderFromRegistry(imageStr, &tmpDirGen, &image.RegistryOptions{InsecureUseHTTP: true})
+
+	var wg sync.WaitGroup
+	var strictErr, permissiveErr error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, strictErr = strict.Provide()
+	}()
+	go func() {
+		defer wg.Done()
+		_, permissiveErr = permissive.Provide()
+	}()
+	wg.Wait()
+
+	assert.True(t, errors.Is(strictErr, image.ErrNoLayers), "unexpected error: %+v", strictErr)
+	assert.NoError(t, permissiveErr)
+}
+
+func TestDirectoryImageProvider_Provide_RequireLayers(t *testing.T) {
+	layoutDir := t.TempDir()
+	path, err := layout.Write(layoutDir, empty.Index)
+	require.NoError(t, err)
+	require.NoError(t, path.AppendImage(emptyTestImage(t)))
+
+	tests := []struct {
+		name          string
+		requireLayers bool
+		wantErr       bool
+	}{
+		{
+			name:          "images without layers are permitted by default",
+			requireLayers: false,
+		},
+		{
+			name:          "images without layers are rejected when layers are required",
+			requireLayers: true,
+			wantErr:       true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tmpDirGen := file.NewTempDirGenerator()
+			t.Cleanup(func() {
+				assert.NoError(t, tmpDirGen.Cleanup())
+			})
+
+			img, err := NewProviderFromPath(layoutDir, &tmpDirGen, WithRequireLayers(test.requireLayers)).Provide()
+			if test.wantErr {
+				assert.True(t, errors.Is(err, image.ErrNoLayers), "unexpected error: %+v", err)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, img.Read())
+			assert.Empty(t, img.Layers)
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package oci
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/anchore/stereoscope/internal/log"
+)
+
+// maxResumeAttempts is the number of times a single download may be resumed before giving up.
+const maxResumeAttempts = 5
+
+// resumingTransport is a http.RoundTripper that continues interrupted downloads with HTTP range requests (instead of
+// restarting the download). Only the remainder of the content is requested, thus the bytes already read are never
+// fetched again. Note: blob digests are verified by the GCR lib once fully read, which covers resumed downloads too.
+type resumingTransport struct {
+	base http.RoundTripper
+}
+
+func newResumingTransport(base http.RoundTripper) *resumingTransport {
+	return &resumingTransport{
+		base: base,
+	}
+}
+
+func (t *resumingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// only complete downloads of a known size can be resumed
+	if req.Method != http.MethodGet || resp.StatusCode != http.StatusOK || resp.ContentLength <= 0 || req.Header.Get("Range") != "" {
+		return resp, nil
+	}
+
+	resp.Body = &resumingBody{
+		transport: t,
+		req:       req,
+		body:      resp.Body,
+		etag:      resp.Header.Get("ETag"),
+		size:      resp.ContentLength,
+	}
+	return resp, nil
+}
+
+// resumingBody is a response body that resumes the download from the last byte read when a read fails.
+type resumingBody struct {
+	transport *resumingTransport
+	req       *http.Request
+	body      io.ReadCloser
+	etag      string
+	size      int64
+	offset    int64
+	attempts  int
+}
+
+func (b *resumingBody) Read(p []byte) (int, error) {
+	for {
+		n, err := b.body.Read(p)
+		b.offset += int64(n)
+
+		if err == nil || err == io.EOF && b.offset >= b.size {
+			return n, err
+		}
+
+		if n > 0 {
+			// return what we have, the next read will surface the error again (which is when we resume)
+			return n, nil
+		}
+
+		if resumeErr := b.resume(); resumeErr != nil {
+			log.Debugf("unable to resume download of %q: %+v", b.req.URL.Redacted(), resumeErr)
+			return n, err
+		}
+	}
+}
+
+// resume requests the remaining content (from the current offset), replacing the current response body.
+func (b *resumingBody) resume() error {
+	if b.attempts >= maxResumeAttempts {
+		return fmt.Errorf("exceeded %d resume attempts", maxResumeAttempts)
+	}
+	b.attempts++
+
+	_ = b.body.Close()
+
+	req := b.req.Clone(b.req.Context())
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", b.offset))
+	if b.etag != "" {
+		// the server must respond with the full content (which we reject) if the content has changed
+		req.Header.Set("If-Range", b.etag)
+	}
+
+	resp, err := b.transport.base.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+
+	expectedRange := fmt.Sprintf("bytes %d-", b.offset)
+	if resp.StatusCode != http.StatusPartialContent || !strings.HasPrefix(resp.Header.Get("Content-Range"), expectedRange) {
+		_ = resp.Body.Close()
+		return fmt.Errorf("server did not honor range request (status=%d content-range=%q)", resp.StatusCode, resp.Header.Get("Content-Range"))
+	}
+
+	log.Debugf("resuming download of %q at byte %d (attempt %d)", b.req.URL.Redacted(), b.offset, b.attempts)
+	b.body = resp.Body
+	return nil
+}
+
+func (b *resumingBody) Close() error {
+	return b.body.Close()
+}
@@ -0,0 +1,128 @@
+package oci
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// interruptedResponse writes the response headers and the first half of the given content, then drops the connection.
+func interruptedResponse(t *testing.T, w http.ResponseWriter, status int, content []byte) {
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(content)))
+	w.WriteHeader(status)
+	_, _ = w.Write(content[:len(content)/2])
+	w.(http.Flusher).Flush()
+
+	conn, _, err := w.(http.Hijacker).Hijack()
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+}
+
+func TestResumingTransport(t *testing.T) {
+	content := make([]byte, 64*1024)
+	rand.New(rand.NewSource(42)).Read(content)
+
+	tests := []struct {
+		name    string
+		handler func(t *testing.T, call int) http.HandlerFunc
+		wantErr bool
+		calls   int
+	}{
+		{
+			name: "resume after interruption",
+			handler: func(t *testing.T, call int) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("ETag", `"v1"`)
+					if call == 1 {
+						interruptedResponse(t, w, http.StatusOK, content)
+						return
+					}
+					http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+				}
+			},
+			calls: 2,
+		},
+		{
+			name: "server does not support ranges",
+			handler: func(t *testing.T, call int) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					if call == 1 {
+						interruptedResponse(t, w, http.StatusOK, content)
+						return
+					}
+					_, _ = w.Write(content)
+				}
+			},
+			wantErr: true,
+			calls:   2,
+		},
+		{
+			name: "content changed before resuming",
+			handler: func(t *testing.T, call int) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					if call == 1 {
+						w.Header().Set("ETag", `"v1"`)
+						interruptedResponse(t, w, http.StatusOK, content)
+						return
+					}
+					w.Header().Set("ETag", `"v2"`)
+					http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+				}
+			},
+			wantErr: true,
+			calls:   2,
+		},
+		{
+			name: "too many interruptions",
+			handler: func(t *testing.T, call int) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					if call == 1 {
+						interruptedResponse(t, w, http.StatusOK, content)
+						return
+					}
+					// serve a single byte of the remaining content before each interruption
+					var offset int
+					_, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-", &offset)
+					require.NoError(t, err)
+					w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, len(content)-1, len(content)))
+					interruptedResponse(t, w, http.StatusPartialContent, content[offset:offset+2])
+				}
+			},
+			wantErr: true,
+			calls:   maxResumeAttempts + 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				test.handler(t, calls)(w, r)
+			}))
+			defer server.Close()
+
+			client := &http.Client{Transport: newResumingTransport(http.DefaultTransport)}
+			resp, err := client.Get(server.URL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			actual, err := ioutil.ReadAll(resp.Body)
+			if test.wantErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, content, actual)
+			}
+			assert.Equal(t, test.calls, calls)
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package oci
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// scopeTransport is a http.RoundTripper that adds the configured scopes to every registry token request, so that the
+// token grants access beyond what the registry client derives from the operation (e.g. pulling from other
+// repositories). Token requests are recognized by their "scope" parameter, which is sent either as a query parameter
+// (a token request with basic auth or anonymous) or within a form body (an OAuth2 token request with an identity token).
+type scopeTransport struct {
+	base   http.RoundTripper
+	scopes []string
+}
+
+// newScopeTransport creates a transport that requests the given scopes along with the scopes requested by the registry
+// client for every token request.
+func newScopeTransport(base http.RoundTripper, scopes []string) http.RoundTripper {
+	var nonEmpty []string
+	for _, scope := range scopes {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			nonEmpty = append(nonEmpty, scope)
+		}
+	}
+
+	if len(nonEmpty) == 0 {
+		return base
+	}
+
+	return &scopeTransport{
+		base:   base,
+		scopes: nonEmpty,
+	}
+}
+
+func (t *scopeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch {
+	case req.Method == http.MethodGet && req.URL.Query().Get("service") != "" && req.URL.Query()["scope"] != nil:
+		// note: a http.RoundTripper must not modify the given request
+		req = req.Clone(req.Context())
+		query := req.URL.Query()
+		query["scope"] = mergeScopes(query["scope"], t.scopes)
+		req.URL.RawQuery = query.Encode()
+	case req.Method == http.MethodPost && req.Header.Get("Content-Type") == "application/x-www-form-urlencoded" && req.GetBody != nil:
+		form, err := readForm(req)
+		if err != nil || form.Get("grant_type") == "" || form["scope"] == nil {
+			break
+		}
+		form.Set("scope", strings.Join(mergeScopes(strings.Fields(form.Get("scope")), t.scopes), " "))
+
+		encoded := form.Encode()
+		req = req.Clone(req.Context())
+		req.Body = ioutil.NopCloser(strings.NewReader(encoded))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(strings.NewReader(encoded)), nil
+		}
+		req.ContentLength = int64(len(encoded))
+	}
+	return t.base.RoundTrip(req)
+}
+
+// readForm reads the form body of the given request, without consuming the body of the request.
+func readForm(req *http.Request) (url.Values, error) {
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	contents, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	return url.ParseQuery(string(contents))
+}
+
+// mergeScopes returns the given requested scopes followed by any additional scopes that were not already requested.
+func mergeScopes(requested, additional []string) []string {
+	merged := append([]string{}, requested...)
+	for _, scope := range additional {
+		var found bool
+		for _, existing := range merged {
+			if existing == scope {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, scope)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,125 @@
+package oci
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scopeRecordingRegistry wraps a registry with bearer token auth, recording the scopes of each token request.
+type scopeRecordingRegistry struct {
+	lock    sync.Mutex
+	enabled bool
+	scopes  [][]string
+}
+
+func (r *scopeRecordingRegistry) wrap(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.lock.Lock()
+		enabled := r.enabled
+		if enabled && req.URL.Path == "/token" {
+			r.scopes = append(r.scopes, req.URL.Query()["scope"])
+		}
+		r.lock.Unlock()
+
+		switch {
+		case !enabled:
+			handler.ServeHTTP(w, req)
+		case req.URL.Path == "/token":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"token": "token"}`))
+		case req.Header.Get("Authorization") != "Bearer token":
+			w.Header().Set("WWW-Authenticate", `Bearer realm="http://`+req.Host+`/token",service="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			handler.ServeHTTP(w, req)
+		}
+	})
+}
+
+func TestRegistryImageProvider_Provide_TokenScopes(t *testing.T) {
+	tests := []struct {
+		name        string
+		tokenScopes []string
+		want        []string
+	}{
+		{
+			name: "scopes derived from the operation",
+			want: []string{"repository:stereoscope/test:pull"},
+		},
+		{
+			name:        "additional scopes",
+			tokenScopes: []string{"repository:stereoscope/other:pull", " ", "repository:stereoscope/test:pull"},
+			want:        []string{"repository:stereoscope/test:pull", "repository:stereoscope/other:pull"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reg := &scopeRecordingRegistry{}
+			imageStr := pushRandomImage(t, reg.wrap)
+			reg.lock.Lock()
+			reg.enabled = true
+			reg.lock.Unlock()
+
+			tmpDirGen := file.NewTempDirGenerator()
+			t.Cleanup(func() {
+				assert.NoError(t, tmpDirGen.Cleanup())
+			})
+
+			provider := NewProviderFromRegistry(imageStr, &tmpDirGen, &image.RegistryOptions{
+				InsecureUseHTTP: true,
+				TokenScopes:     test.tokenScopes,
+			})
+			img, err := provider.Provide()
+			require.NoError(t, err)
+			require.NoError(t, img.Read())
+
+			require.NotEmpty(t, reg.scopes)
+			for _, scopes := range reg.scopes {
+				assert.Equal(t, test.want, scopes)
+			}
+		})
+	}
+}
+
+// roundTripFunc is a http.RoundTripper made from a function.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestScopeTransport_OAuthForm(t *testing.T) {
+	var body string
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		contents, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		body = string(contents)
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("{}"))}, nil
+	})
+
+	form := url.Values{}
+	form.Set("scope", "repository:stereoscope/test:pull")
+	form.Set("service", "test")
+	form.Set("grant_type", "refresh_token")
+	req, err := http.NewRequest(http.MethodPost, "https://auth.example.com/token", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := newScopeTransport(base, []string{"repository:stereoscope/other:pull"}).RoundTrip(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	sent, err := url.ParseQuery(body)
+	require.NoError(t, err)
+	assert.Equal(t, "repository:stereoscope/test:pull repository:stereoscope/other:pull", sent.Get("scope"))
+	assert.Equal(t, "refresh_token", sent.Get("grant_type"))
+}
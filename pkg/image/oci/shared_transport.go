@@ -0,0 +1,65 @@
+package oci
+
+import (
+	"crypto/tls"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// transportKey describes the connection options of a shared transport.
+type transportKey struct {
+	insecureSkipTLSVerify bool
+	maxIdleConnsPerHost   int
+	idleConnTimeout       time.Duration
+	// hostOverrides is the canonical form of the host overrides (see hostOverridesKey)
+	hostOverrides string
+}
+
+var (
+	sharedTransportsLock sync.Mutex
+	sharedTransports     = make(map[transportKey]*http.Transport)
+)
+
+// sharedTransport returns the base transport for registry requests with the given connection options. Transports are
+// shared by all fetches with the same options (within the process), thus idle connections (along with completed TLS
+// handshakes) are reused across fetches from the same registry instead of being established again for every fetch.
+func sharedTransport(registryOptions *image.RegistryOptions) *http.Transport {
+	key := transportKey{
+		insecureSkipTLSVerify: registryOptions.InsecureSkipTLSVerify,
+		maxIdleConnsPerHost:   registryOptions.MaxIdleConnsPerHost,
+		idleConnTimeout:       registryOptions.IdleConnTimeout,
+		hostOverrides:         hostOverridesKey(registryOptions.HostOverrides),
+	}
+
+	sharedTransportsLock.Lock()
+	defer sharedTransportsLock.Unlock()
+
+	if transport, ok := sharedTransports[key]; ok {
+		return transport
+	}
+
+	transport := remote.DefaultTransport.Clone()
+	if key.insecureSkipTLSVerify {
+		// nolint: gosec
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	if key.maxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = key.maxIdleConnsPerHost
+		if transport.MaxIdleConns < key.maxIdleConnsPerHost {
+			transport.MaxIdleConns = key.maxIdleConnsPerHost
+		}
+	}
+	if key.idleConnTimeout > 0 {
+		transport.IdleConnTimeout = key.idleConnTimeout
+	}
+	if len(registryOptions.HostOverrides) > 0 {
+		transport.DialContext = newHostOverrideDialer(registryOptions.HostOverrides, transport.DialContext)
+	}
+
+	sharedTransports[key] = transport
+	return transport
+}
@@ -0,0 +1,121 @@
+package oci
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pushRandomImageTLS starts an in-memory TLS registry with a single random image, returning the image reference and a
+// counter of the connections made to the registry.
+func pushRandomImageTLS(tb testing.TB) (string, *int64) {
+	tb.Helper()
+
+	var connections int64
+	server := httptest.NewUnstartedServer(registry.New())
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&connections, 1)
+		}
+	}
+	server.StartTLS()
+	tb.Cleanup(server.Close)
+
+	u, err := url.Parse(server.URL)
+	require.NoError(tb, err)
+
+	imageStr := fmt.Sprintf("%s/stereoscope/test:latest", u.Host)
+	ref, err := name.ParseReference(imageStr)
+	require.NoError(tb, err)
+
+	img, err := random.Image(1024, 2)
+	require.NoError(tb, err)
+	require.NoError(tb, remote.Write(ref, img, remote.WithTransport(server.Client().Transport)))
+
+	atomic.StoreInt64(&connections, 0)
+	return imageStr, &connections
+}
+
+func fetchImage(tb testing.TB, imageStr string, tmpDirGen *file.TempDirGenerator, registryOptions *image.RegistryOptions) {
+	tb.Helper()
+
+	img, err := NewProviderFromRegistry(imageStr, tmpDirGen, registryOptions).Provide()
+	require.NoError(tb, err)
+	require.NoError(tb, img.Read())
+}
+
+func Test_sharedTransport(t *testing.T) {
+	defaults := sharedTransport(&image.RegistryOptions{})
+	assert.Same(t, defaults, sharedTransport(&image.RegistryOptions{ExtraHeaders: map[string]string{"X-Tenant": "a"}}))
+	assert.NotSame(t, defaults, remote.DefaultTransport)
+	assert.False(t, defaults.TLSClientConfig != nil && defaults.TLSClientConfig.InsecureSkipVerify)
+
+	insecure := sharedTransport(&image.RegistryOptions{InsecureSkipTLSVerify: true})
+	assert.NotSame(t, defaults, insecure)
+	assert.True(t, insecure.TLSClientConfig.InsecureSkipVerify)
+	assert.NotNil(t, insecure.Proxy)
+
+	pooled := sharedTransport(&image.RegistryOptions{MaxIdleConnsPerHost: 200, IdleConnTimeout: time.Minute})
+	assert.Equal(t, 200, pooled.MaxIdleConnsPerHost)
+	assert.Equal(t, 200, pooled.MaxIdleConns)
+	assert.Equal(t, time.Minute, pooled.IdleConnTimeout)
+}
+
+func TestRegistryImageProvider_Provide_ReusesConnections(t *testing.T) {
+	imageStr, connections := pushRandomImageTLS(t)
+
+	tmpDirGen := file.NewTempDirGenerator()
+	t.Cleanup(func() {
+		assert.NoError(t, tmpDirGen.Cleanup())
+	})
+
+	registryOptions := &image.RegistryOptions{InsecureSkipTLSVerify: true, MaxIdleConnsPerHost: 16}
+	t.Cleanup(sharedTransport(registryOptions).CloseIdleConnections)
+
+	fetchImage(t, imageStr, &tmpDirGen, registryOptions)
+	first := atomic.LoadInt64(connections)
+	require.Greater(t, first, int64(0))
+
+	fetchImage(t, imageStr, &tmpDirGen, registryOptions)
+	assert.Equal(t, first, atomic.LoadInt64(connections), "expected the second fetch to reuse the idle connections")
+}
+
+// BenchmarkRegistryImageProvider_SequentialPulls compares sequential fetches from the same registry that reuse idle
+// connections (the default) to fetches that establish new connections (and TLS handshakes) every time.
+func BenchmarkRegistryImageProvider_SequentialPulls(b *testing.B) {
+	for _, reuse := range []bool{true, false} {
+		b.Run(fmt.Sprintf("reuse=%v", reuse), func(b *testing.B) {
+			imageStr, connections := pushRandomImageTLS(b)
+
+			tmpDirGen := file.NewTempDirGenerator()
+			defer tmpDirGen.Cleanup()
+
+			registryOptions := &image.RegistryOptions{InsecureSkipTLSVerify: true, MaxIdleConnsPerHost: 16}
+			transport := sharedTransport(registryOptions)
+			transport.CloseIdleConnections()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if !reuse {
+					transport.CloseIdleConnections()
+				}
+				fetchImage(b, imageStr, &tmpDirGen, registryOptions)
+			}
+			b.ReportMetric(float64(atomic.LoadInt64(connections))/float64(b.N), "conns/op")
+		})
+	}
+}
@@ -1,8 +1,8 @@
 package oci
 
 import (
+	"errors"
 	"fmt"
-	"os"
 
 	"github.com/anchore/stereoscope/pkg/file"
 	"github.com/anchore/stereoscope/pkg/image"
@@ -12,13 +12,15 @@ import (
 type TarballImageProvider struct {
 	path      string
 	tmpDirGen *file.TempDirGenerator
+	options   []ProviderOption
 }
 
 // NewProviderFromTarball creates a new provider instance for the specific image tarball already at the given path.
-func NewProviderFromTarball(path string, tmpDirGen *file.TempDirGenerator) *TarballImageProvider {
+func NewProviderFromTarball(path string, tmpDirGen *file.TempDirGenerator, options ...ProviderOption) *TarballImageProvider {
 	return &TarballImageProvider{
 		path:      path,
 		tmpDirGen: tmpDirGen,
+		options:   options,
 	}
 }
 
@@ -26,10 +28,11 @@ func NewProviderFromTarball(path string, tmpDirGen *file.TempDirGenerator) *Tarb
 func (p *TarballImageProvider) Provide() (*image.Image, error) {
 	// note: we are untaring the image and using the existing directory provider, we could probably enhance the google
 	// container registry lib to do this without needing to untar to a temp dir (https://github.com/google/go-containerregistry/issues/726)
-	f, err := os.Open(p.path)
+	f, err := file.OpenArchive(p.path)
 	if err != nil {
 		return nil, fmt.Errorf("unable to open OCI tarball: %w", err)
 	}
+	defer f.Close()
 
 	tempDir, err := p.tmpDirGen.NewTempDir()
 	if err != nil {
@@ -40,5 +43,11 @@ func (p *TarballImageProvider) Provide() (*image.Image, error) {
 		return nil, err
 	}
 
-	return NewProviderFromPath(tempDir, p.tmpDirGen).Provide()
+	img, err := NewProviderFromPath(tempDir, p.tmpDirGen, p.options...).Provide()
+	var notImageErr *image.ErrNotImage
+	if errors.As(err, &notImageErr) {
+		// report the archive path rather than where it was extracted to
+		notImageErr.Location = p.path
+	}
+	return img, err
 }
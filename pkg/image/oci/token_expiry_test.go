@@ -0,0 +1,113 @@
+package oci
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expiringTokenRegistry wraps a registry with bearer token auth, where all issued tokens expire once the first blob
+// has been served (as with short-lived tokens expiring during a long pull).
+type expiringTokenRegistry struct {
+	lock          sync.Mutex
+	enabled       bool
+	challenge     bool
+	issued        int
+	validFrom     int
+	blobsServed   int
+	unauthorized  int
+	tokenRequests int
+}
+
+func (r *expiringTokenRegistry) wrap(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.lock.Lock()
+		if !r.enabled {
+			r.lock.Unlock()
+			handler.ServeHTTP(w, req)
+			return
+		}
+
+		if req.URL.Path == "/token" {
+			r.issued++
+			r.tokenRequests++
+			token := r.issued
+			r.lock.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = fmt.Fprintf(w, `{"token": "token-%d"}`, token)
+			return
+		}
+
+		var token int
+		_, _ = fmt.Sscanf(req.Header.Get("Authorization"), "Bearer token-%d", &token)
+		if token == 0 || token < r.validFrom {
+			r.unauthorized++
+			if r.challenge || req.URL.Path == "/v2/" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="http://%s/token",service="test"`, req.Host))
+			}
+			r.lock.Unlock()
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if strings.Contains(req.URL.Path, "/blobs/") && req.Method == http.MethodGet {
+			r.blobsServed++
+			if r.blobsServed == 1 {
+				// all tokens issued so far expire after this request
+				r.validFrom = r.issued + 1
+			}
+		}
+		r.lock.Unlock()
+
+		handler.ServeHTTP(w, req)
+	})
+}
+
+func TestRegistryImageProvider_Provide_TokenExpiry(t *testing.T) {
+	tests := []struct {
+		name      string
+		challenge bool
+	}{
+		{
+			name:      "expired token response with an auth challenge",
+			challenge: true,
+		},
+		{
+			name:      "expired token response without an auth challenge",
+			challenge: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reg := &expiringTokenRegistry{challenge: test.challenge}
+			imageStr := pushRandomImage(t, reg.wrap)
+
+			reg.lock.Lock()
+			reg.enabled = true
+			reg.lock.Unlock()
+
+			tmpDirGen := file.NewTempDirGenerator()
+			t.Cleanup(func() {
+				assert.NoError(t, tmpDirGen.Cleanup())
+			})
+
+			img, err := NewProviderFromRegistry(imageStr, &tmpDirGen, &image.RegistryOptions{InsecureUseHTTP: true}).Provide()
+			require.NoError(t, err)
+			require.NoError(t, img.Read())
+			require.Len(t, img.Layers, 2)
+
+			reg.lock.Lock()
+			defer reg.lock.Unlock()
+			assert.Greater(t, reg.unauthorized, 1, "expected the token to expire mid-pull")
+			assert.Greater(t, reg.tokenRequests, 1, "expected re-authentication")
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package oci
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/anchore/stereoscope/internal/log"
+)
+
+// tokenExpiryTransport is a http.RoundTripper that allows the registry client to recover from bearer tokens that
+// expire mid-pull (common with cloud registries issuing short-lived tokens). The registry client re-authenticates when
+// a 401 response carries a bearer challenge, however, some registries respond to expired tokens without a challenge,
+// failing the pull. In that case the bearer challenge last issued by the same host is added to the response, which
+// prompts the registry client to fetch a new token and retry the request.
+type tokenExpiryTransport struct {
+	base       http.RoundTripper
+	lock       sync.RWMutex
+	challenges map[string]string
+}
+
+func newTokenExpiryTransport(base http.RoundTripper) *tokenExpiryTransport {
+	return &tokenExpiryTransport{
+		base:       base,
+		challenges: make(map[string]string),
+	}
+}
+
+func (t *tokenExpiryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	host := req.URL.Host
+	if challenge := resp.Header.Get("WWW-Authenticate"); challenge != "" {
+		if strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+			t.lock.Lock()
+			t.challenges[host] = challenge
+			t.lock.Unlock()
+		}
+		return resp, nil
+	}
+
+	// only requests that were made with a (now expired) token can be recovered by re-authenticating
+	if !strings.HasPrefix(strings.ToLower(req.Header.Get("Authorization")), "bearer ") {
+		return resp, nil
+	}
+
+	t.lock.RLock()
+	challenge, ok := t.challenges[host]
+	t.lock.RUnlock()
+	if ok {
+		log.Debugf("registry token appears to have expired, re-authenticating for %q", req.URL.Redacted())
+		resp.Header.Set("WWW-Authenticate", challenge)
+	}
+	return resp, nil
+}
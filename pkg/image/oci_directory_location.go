@@ -0,0 +1,48 @@
+package image
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// ociDirectoryTagPattern matches a valid image tag (as with the tag of an image reference).
+var ociDirectoryTagPattern = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+
+// windowsDrivePattern matches a bare windows drive letter (e.g. "C").
+var windowsDrivePattern = regexp.MustCompile(`^[a-zA-Z]$`)
+
+// ParseOCIDirectoryLocation splits the location of an OCI layout directory (as given with the oci-dir scheme, after the
+// scheme is removed) into the path of the layout and an optional tag selector. The grammar is "<path>[:<tag>]", where
+// the tag selects the image whose descriptor within index.json is annotated with the tag (by the
+// "org.opencontainers.image.ref.name" annotation), e.g. "oci-dir:/path/to/layout:mytag". Since paths may hold colons
+// the selector is ambiguous, which is resolved as follows:
+//
+// Only the text after the last colon is considered as a tag, and only when it is a valid tag (thus never contains a path
+// separator, as with "C:\layout" on windows or "/a:b/layout"). When the whole location is an existing path it is always
+// the path (e.g. a directory named "layout:v1"), otherwise the tag is split from the path. A bare windows drive letter
+// is never taken as a path (thus "C:layout" is the drive-relative path on windows, not the tag "layout" of the layout
+// at "C"). The scheme colon is not part of the location (see DetectSource), thus "oci-dir:C:\layout:mytag" is the
+// layout at "C:\layout" with the tag "mytag". An empty tag is returned when there is no selector.
+func ParseOCIDirectoryLocation(location string) (string, string) {
+	return parseOCIDirectoryLocation(afero.NewOsFs(), location)
+}
+
+func parseOCIDirectoryLocation(fs afero.Fs, location string) (string, string) {
+	idx := strings.LastIndex(location, ":")
+	if idx < 0 {
+		return location, ""
+	}
+
+	path, tag := location[:idx], location[idx+1:]
+	if path == "" || windowsDrivePattern.MatchString(path) || !ociDirectoryTagPattern.MatchString(tag) {
+		return location, ""
+	}
+
+	if _, err := fs.Stat(location); err == nil {
+		// the colon is part of an existing path
+		return location, ""
+	}
+	return path, tag
+}
@@ -0,0 +1,47 @@
+package image
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseOCIDirectoryLocation(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, fs.MkdirAll("/layouts/layout", 0755))
+	require.NoError(t, fs.MkdirAll("/layouts/layout:v1", 0755))
+
+	tests := []struct {
+		location string
+		wantPath string
+		wantTag  string
+	}{
+		{location: "/layouts/layout", wantPath: "/layouts/layout"},
+		{location: "/layouts/layout:mytag", wantPath: "/layouts/layout", wantTag: "mytag"},
+		{location: "relative/layout:v1.2.3-rc_1", wantPath: "relative/layout", wantTag: "v1.2.3-rc_1"},
+		// the tag is split even when the layout does not exist (the provider reports the missing layout)
+		{location: "/missing:mytag", wantPath: "/missing", wantTag: "mytag"},
+		// an existing path with a colon is never split
+		{location: "/layouts/layout:v1", wantPath: "/layouts/layout:v1"},
+		// only valid tags are split
+		{location: "/layouts/layout:", wantPath: "/layouts/layout:"},
+		{location: "/a:b/layout", wantPath: "/a:b/layout"},
+		{location: "/layouts/layout:-invalid", wantPath: "/layouts/layout:-invalid"},
+		{location: ":mytag", wantPath: ":mytag"},
+		// windows drive letters
+		{location: `C:\layouts\layout`, wantPath: `C:\layouts\layout`},
+		{location: `C:\layouts\layout:mytag`, wantPath: `C:\layouts\layout`, wantTag: "mytag"},
+		{location: "C:/layouts/layout:mytag", wantPath: "C:/layouts/layout", wantTag: "mytag"},
+		{location: "C:layout", wantPath: "C:layout"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.location, func(t *testing.T) {
+			path, tag := parseOCIDirectoryLocation(fs, test.location)
+			assert.Equal(t, test.wantPath, path)
+			assert.Equal(t, test.wantTag, tag)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package image
+
+import (
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// ociLabelPrefix is the prefix of the pre-defined annotation keys from the OCI image spec, which are commonly set as
+// image labels (see https://github.com/opencontainers/image-spec/blob/main/annotations.md).
+const ociLabelPrefix = "org.opencontainers.image."
+
+// OCILabels are the standard "org.opencontainers.image.*" labels of the image config, which describe the provenance of
+// the image. Labels that are not set are empty (the full label map remains available within Config.Config.Labels).
+type OCILabels struct {
+	// Created is the date and time the image was built, as given (RFC 3339)
+	Created string
+	// CreatedTime is the parsed Created label (zero if the label is not set or is not a valid RFC 3339 date-time)
+	CreatedTime time.Time
+	// Authors is the contact details of the people or organization responsible for the image
+	Authors string
+	// URL is where to find more information on the image
+	URL string
+	// Documentation is where to get documentation on the image
+	Documentation string
+	// Source is where to get the source code used to build the image
+	Source string
+	// Version is the version of the packaged software
+	Version string
+	// Revision is the source control revision identifier for the packaged software
+	Revision string
+	// Vendor is the name of the distributing entity, organization, or individual
+	Vendor string
+	// Licenses is the SPDX license expression for the software contained within the image
+	Licenses string
+	// RefName is the name of the reference for a target (e.g. a tag)
+	RefName string
+	// Title is the human-readable title of the image
+	Title string
+	// Description is the human-readable description of the software packaged within the image
+	Description string
+	// BaseDigest is the digest of the image this image is based on
+	BaseDigest string
+	// BaseName is the image reference of the image this image is based on
+	BaseName string
+}
+
+// ociLabels extracts the standard OCI labels from the given image config.
+func ociLabels(config v1.Config) OCILabels {
+	labels := config.Labels
+	get := func(key string) string {
+		return labels[ociLabelPrefix+key]
+	}
+
+	result := OCILabels{
+		Created:       get("created"),
+		Authors:       get("authors"),
+		URL:           get("url"),
+		Documentation: get("documentation"),
+		Source:        get("source"),
+		Version:       get("version"),
+		Revision:      get("revision"),
+		Vendor:        get("vendor"),
+		Licenses:      get("licenses"),
+		RefName:       get("ref.name"),
+		Title:         get("title"),
+		Description:   get("description"),
+		BaseDigest:    get("base.digest"),
+		BaseName:      get("base.name"),
+	}
+
+	if result.Created != "" {
+		if created, err := time.Parse(time.RFC3339, result.Created); err == nil {
+			result.CreatedTime = created
+		}
+	}
+	return result
+}
@@ -0,0 +1,79 @@
+package image
+
+import (
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImage_OCILabels(t *testing.T) {
+	tests := []struct {
+		name     string
+		labels   map[string]string
+		expected OCILabels
+	}{
+		{
+			name: "standard labels",
+			labels: map[string]string{
+				"org.opencontainers.image.created":     "2022-03-04T05:06:07Z",
+				"org.opencontainers.image.source":      "https://github.com/anchore/stereoscope",
+				"org.opencontainers.image.revision":    "a1b2c3d",
+				"org.opencontainers.image.version":     "v1.2.3",
+				"org.opencontainers.image.licenses":    "Apache-2.0",
+				"org.opencontainers.image.ref.name":    "latest",
+				"org.opencontainers.image.base.name":   "docker.io/library/alpine:3.15",
+				"org.opencontainers.image.title":       "stereoscope",
+				"com.example.unrelated":                "ignored",
+				"org.opencontainers.image.unsupported": "ignored",
+			},
+			expected: OCILabels{
+				Created:     "2022-03-04T05:06:07Z",
+				CreatedTime: time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC),
+				Source:      "https://github.com/anchore/stereoscope",
+				Revision:    "a1b2c3d",
+				Version:     "v1.2.3",
+				Licenses:    "Apache-2.0",
+				RefName:     "latest",
+				BaseName:    "docker.io/library/alpine:3.15",
+				Title:       "stereoscope",
+			},
+		},
+		{
+			name: "invalid created date",
+			labels: map[string]string{
+				"org.opencontainers.image.created": "last tuesday",
+			},
+			expected: OCILabels{
+				Created: "last tuesday",
+			},
+		},
+		{
+			name:     "no labels",
+			expected: OCILabels{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v1Image, err := mutate.Config(newTestV1Image(t, []testEntry{testDir("/data")}), v1.Config{
+				Labels: test.labels,
+			})
+			require.NoError(t, err)
+
+			img := NewImage(v1Image, t.TempDir())
+			require.NoError(t, img.Read())
+
+			actual := img.Metadata.OCILabels
+			assert.True(t, test.expected.CreatedTime.Equal(actual.CreatedTime))
+			actual.CreatedTime = test.expected.CreatedTime
+			assert.Equal(t, test.expected, actual)
+
+			// the full label map remains available
+			assert.Equal(t, len(test.labels), len(img.Metadata.Config.Config.Labels))
+		})
+	}
+}
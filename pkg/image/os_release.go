@@ -0,0 +1,225 @@
+package image
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/filetree"
+)
+
+// OSRelease is the distro information for an image, as described by the os-release file (or a fallback release file).
+type OSRelease struct {
+	// ID is the lowercase identifier of the distro (e.g. "ubuntu", "alpine", "rhel")
+	ID string
+	// IDLike are the identifiers of closely related distros (e.g. ["debian"] for ubuntu)
+	IDLike []string
+	// Name is the name of the distro without the version (e.g. "Ubuntu")
+	Name string
+	// Version is the version of the distro, possibly with a codename (e.g. "20.04.3 LTS (Focal Fossa)")
+	Version string
+	// VersionID is the version of the distro suitable for parsing (e.g. "20.04")
+	VersionID string
+	// VersionCodename is the release codename (e.g. "focal")
+	VersionCodename string
+	// PrettyName is a human readable name of the distro with the version (e.g. "Ubuntu 20.04.3 LTS")
+	PrettyName string
+	// CPEName is the CPE of the distro (if provided)
+	CPEName string
+	// Fields contains every key-value pair found in the release file (only for files with key-value pairs)
+	Fields map[string]string
+	// Path is the path of the release file within the image squash
+	Path string
+}
+
+// ErrOSReleaseNotFound is returned from Image.OSRelease when none of the release files are found in the image squash.
+type ErrOSReleaseNotFound struct {
+	// Paths are the release files that were searched for (in order)
+	Paths []string
+}
+
+func (e *ErrOSReleaseNotFound) Error() string {
+	return fmt.Sprintf("no OS release file found (searched: %s)", strings.Join(e.Paths, ", "))
+}
+
+type osReleaseParser func(contents string) *OSRelease
+
+// osReleaseFiles are the release files searched for within the image, in order of preference.
+var osReleaseFiles = []struct {
+	path   string
+	parser osReleaseParser
+}{
+	{path: "/etc/os-release", parser: parseOSRelease},
+	{path: "/usr/lib/os-release", parser: parseOSRelease},
+	{path: "/etc/lsb-release", parser: parseLSBRelease},
+	{path: "/etc/centos-release", parser: parseRedHatRelease},
+	{path: "/etc/redhat-release", parser: parseRedHatRelease},
+	{path: "/etc/system-release", parser: parseRedHatRelease},
+}
+
+// OSRelease returns the distro information for the image, read from the first release file found within the image
+// squash: /etc/os-release, /usr/lib/os-release, /etc/lsb-release, and lastly /etc/centos-release, /etc/redhat-release,
+// or /etc/system-release. Release files without any distro information are skipped. A *ErrOSReleaseNotFound is
+// returned if no release file is found.
+func (i *Image) OSRelease() (*OSRelease, error) {
+	tree := i.SquashedTree()
+
+	var searched []string
+	for _, candidate := range osReleaseFiles {
+		searched = append(searched, candidate.path)
+
+		exists, ref, err := tree.File(file.Path(candidate.path), filetree.FollowBasenameLinks)
+		if err != nil {
+			return nil, fmt.Errorf("unable to resolve %q: %w", candidate.path, err)
+		}
+		if !exists || ref == nil {
+			continue
+		}
+
+		reader, err := i.FileCatalog.FileContents(*ref)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %q: %w", candidate.path, err)
+		}
+		contents, err := ioutil.ReadAll(reader)
+		_ = reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %q: %w", candidate.path, err)
+		}
+
+		release := candidate.parser(string(contents))
+		if release == nil {
+			log.Debugf("no distro information found in %q", candidate.path)
+			continue
+		}
+		release.Path = candidate.path
+		return release, nil
+	}
+
+	return nil, &ErrOSReleaseNotFound{Paths: searched}
+}
+
+// parseKeyValues parses a shell-compatible variable assignment file (as with os-release and lsb-release).
+func parseKeyValues(contents string) map[string]string {
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		fields[strings.TrimSpace(parts[0])] = unquoteReleaseValue(strings.TrimSpace(parts[1]))
+	}
+	return fields
+}
+
+// unquoteReleaseValue removes surrounding quotes and shell escapes (\", \\, \$, \`) from a release value.
+func unquoteReleaseValue(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+	}
+	return strings.NewReplacer(`\"`, `"`, `\\`, `\`, `\$`, `$`, "\\`", "`").Replace(value)
+}
+
+func parseOSRelease(contents string) *OSRelease {
+	fields := parseKeyValues(contents)
+	release := &OSRelease{
+		ID:              strings.ToLower(fields["ID"]),
+		Name:            fields["NAME"],
+		Version:         fields["VERSION"],
+		VersionID:       fields["VERSION_ID"],
+		VersionCodename: fields["VERSION_CODENAME"],
+		PrettyName:      fields["PRETTY_NAME"],
+		CPEName:         fields["CPE_NAME"],
+		Fields:          fields,
+	}
+	if idLike := strings.Fields(fields["ID_LIKE"]); len(idLike) > 0 {
+		release.IDLike = idLike
+	}
+
+	if release.ID == "" && release.Name == "" && release.PrettyName == "" {
+		return nil
+	}
+	return release
+}
+
+func parseLSBRelease(contents string) *OSRelease {
+	fields := parseKeyValues(contents)
+	release := &OSRelease{
+		ID:              strings.ToLower(fields["DISTRIB_ID"]),
+		Name:            fields["DISTRIB_ID"],
+		Version:         fields["DISTRIB_RELEASE"],
+		VersionID:       fields["DISTRIB_RELEASE"],
+		VersionCodename: fields["DISTRIB_CODENAME"],
+		PrettyName:      fields["DISTRIB_DESCRIPTION"],
+		Fields:          fields,
+	}
+
+	if release.ID == "" && release.PrettyName == "" {
+		return nil
+	}
+	return release
+}
+
+// redHatReleasePattern matches release files such as "CentOS Linux release 7.9.2009 (Core)".
+var redHatReleasePattern = regexp.MustCompile(`^(?P<name>.+?)\s+release\s+(?P<version>[0-9][0-9.]*)(?:\s+\((?P<codename>[^)]+)\))?`)
+
+// redHatReleaseIDs maps distro names (as found in release files) to the equivalent os-release ID.
+var redHatReleaseIDs = map[string]string{
+	"red hat enterprise linux": "rhel",
+	"centos":                   "centos",
+	"centos linux":             "centos",
+	"centos stream":            "centos",
+	"fedora":                   "fedora",
+	"amazon linux":             "amzn",
+	"oracle linux":             "ol",
+	"rocky linux":              "rocky",
+	"almalinux":                "almalinux",
+}
+
+func parseRedHatRelease(contents string) *OSRelease {
+	line := strings.TrimSpace(strings.SplitN(contents, "\n", 2)[0])
+	match := redHatReleasePattern.FindStringSubmatch(line)
+	if match == nil {
+		return nil
+	}
+
+	name, version, codename := match[1], match[2], match[3]
+	release := &OSRelease{
+		ID:         redHatReleaseID(name),
+		Name:       name,
+		Version:    version,
+		VersionID:  version,
+		PrettyName: line,
+	}
+	if codename != "" {
+		release.Version = fmt.Sprintf("%s (%s)", version, codename)
+	}
+	return release
+}
+
+// redHatReleaseID returns the os-release ID for the given distro name, preferring the longest known name prefix (e.g.
+// "Red Hat Enterprise Linux Server" is "rhel"), otherwise the first word of the name is used.
+func redHatReleaseID(name string) string {
+	lower := strings.ToLower(name)
+
+	var id string
+	var longest int
+	for prefix, candidate := range redHatReleaseIDs {
+		if strings.HasPrefix(lower, prefix) && len(prefix) > longest {
+			id, longest = candidate, len(prefix)
+		}
+	}
+	if id != "" {
+		return id
+	}
+	return strings.Fields(lower)[0]
+}
@@ -0,0 +1,162 @@
+package image
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImage_OSRelease(t *testing.T) {
+	ubuntuOSRelease := `NAME="Ubuntu"
+VERSION="20.04.3 LTS (Focal Fossa)"
+ID=ubuntu
+ID_LIKE=debian
+PRETTY_NAME="Ubuntu 20.04.3 LTS"
+VERSION_ID="20.04"
+# a comment
+VERSION_CODENAME=focal
+`
+
+	tests := []struct {
+		name     string
+		layers   [][]testEntry
+		expected *OSRelease
+	}{
+		{
+			name: "os-release",
+			layers: [][]testEntry{
+				{testDir("/etc"), testFile("/etc/os-release", ubuntuOSRelease)},
+			},
+			expected: &OSRelease{
+				ID:              "ubuntu",
+				IDLike:          []string{"debian"},
+				Name:            "Ubuntu",
+				Version:         "20.04.3 LTS (Focal Fossa)",
+				VersionID:       "20.04",
+				VersionCodename: "focal",
+				PrettyName:      "Ubuntu 20.04.3 LTS",
+				Fields: map[string]string{
+					"NAME":             "Ubuntu",
+					"VERSION":          "20.04.3 LTS (Focal Fossa)",
+					"ID":               "ubuntu",
+					"ID_LIKE":          "debian",
+					"PRETTY_NAME":      "Ubuntu 20.04.3 LTS",
+					"VERSION_ID":       "20.04",
+					"VERSION_CODENAME": "focal",
+				},
+				Path: "/etc/os-release",
+			},
+		},
+		{
+			name: "os-release symlink to usr lib",
+			layers: [][]testEntry{
+				{
+					testDir("/etc"),
+					testDir("/usr"),
+					testDir("/usr/lib"),
+					testFile("/usr/lib/os-release", "ID='alpine'\nPRETTY_NAME=\"Alpine \\\"Edge\\\"\"\n"),
+					testSymlink("/etc/os-release", "../usr/lib/os-release"),
+				},
+			},
+			expected: &OSRelease{
+				ID:         "alpine",
+				PrettyName: `Alpine "Edge"`,
+				Fields: map[string]string{
+					"ID":          "alpine",
+					"PRETTY_NAME": `Alpine "Edge"`,
+				},
+				Path: "/etc/os-release",
+			},
+		},
+		{
+			name: "empty os-release falls back to lsb-release",
+			layers: [][]testEntry{
+				{
+					testDir("/etc"),
+					testFile("/etc/os-release", "# nothing here\n"),
+					testFile("/etc/lsb-release", "DISTRIB_ID=Ubuntu\nDISTRIB_RELEASE=18.04\nDISTRIB_CODENAME=bionic\nDISTRIB_DESCRIPTION=\"Ubuntu 18.04.6 LTS\"\n"),
+				},
+			},
+			expected: &OSRelease{
+				ID:              "ubuntu",
+				Name:            "Ubuntu",
+				Version:         "18.04",
+				VersionID:       "18.04",
+				VersionCodename: "bionic",
+				PrettyName:      "Ubuntu 18.04.6 LTS",
+				Fields: map[string]string{
+					"DISTRIB_ID":          "Ubuntu",
+					"DISTRIB_RELEASE":     "18.04",
+					"DISTRIB_CODENAME":    "bionic",
+					"DISTRIB_DESCRIPTION": "Ubuntu 18.04.6 LTS",
+				},
+				Path: "/etc/lsb-release",
+			},
+		},
+		{
+			name: "redhat-release",
+			layers: [][]testEntry{
+				{testDir("/etc"), testFile("/etc/redhat-release", "Red Hat Enterprise Linux Server release 7.9 (Maipo)\n")},
+			},
+			expected: &OSRelease{
+				ID:         "rhel",
+				Name:       "Red Hat Enterprise Linux Server",
+				Version:    "7.9 (Maipo)",
+				VersionID:  "7.9",
+				PrettyName: "Red Hat Enterprise Linux Server release 7.9 (Maipo)",
+				Path:       "/etc/redhat-release",
+			},
+		},
+		{
+			name: "centos-release",
+			layers: [][]testEntry{
+				{testDir("/etc"), testFile("/etc/centos-release", "CentOS Linux release 7.9.2009 (Core)\n")},
+			},
+			expected: &OSRelease{
+				ID:         "centos",
+				Name:       "CentOS Linux",
+				Version:    "7.9.2009 (Core)",
+				VersionID:  "7.9.2009",
+				PrettyName: "CentOS Linux release 7.9.2009 (Core)",
+				Path:       "/etc/centos-release",
+			},
+		},
+		{
+			name: "os-release removed in a later layer",
+			layers: [][]testEntry{
+				{testDir("/etc"), testFile("/etc/os-release", ubuntuOSRelease)},
+				{testFile("/etc/.wh.os-release", ""), testFile("/etc/system-release", "Fedora release 35 (Thirty Five)\n")},
+			},
+			expected: &OSRelease{
+				ID:         "fedora",
+				Name:       "Fedora",
+				Version:    "35 (Thirty Five)",
+				VersionID:  "35",
+				PrettyName: "Fedora release 35 (Thirty Five)",
+				Path:       "/etc/system-release",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			img := newTestImage(t, test.layers...)
+
+			actual, err := img.OSRelease()
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestImage_OSRelease_NotFound(t *testing.T) {
+	img := newTestImage(t, []testEntry{testDir("/etc"), testFile("/etc/hosts", "127.0.0.1 localhost\n")})
+
+	_, err := img.OSRelease()
+	var notFound *ErrOSReleaseNotFound
+	require.True(t, errors.As(err, &notFound), "unexpected error: %+v", err)
+	assert.Equal(t, "/etc/os-release", notFound.Paths[0])
+	assert.Len(t, notFound.Paths, len(osReleaseFiles))
+}
@@ -0,0 +1,136 @@
+package image
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/filetree"
+)
+
+// PackageDatabaseType is the package manager that maintains a package database.
+type PackageDatabaseType string
+
+const (
+	DpkgPackageDatabase   PackageDatabaseType = "dpkg"
+	RpmPackageDatabase    PackageDatabaseType = "rpm"
+	ApkPackageDatabase    PackageDatabaseType = "apk"
+	PacmanPackageDatabase PackageDatabaseType = "pacman"
+)
+
+// packageDatabaseLocations are the well-known locations of the package database files for each package manager (as
+// doublestar globs).
+var packageDatabaseLocations = []struct {
+	ty       PackageDatabaseType
+	patterns []string
+}{
+	{
+		ty: DpkgPackageDatabase,
+		patterns: []string{
+			"/var/lib/dpkg/status",
+			// distroless images keep a status file per package
+			"/var/lib/dpkg/status.d/*",
+		},
+	},
+	{
+		ty: RpmPackageDatabase,
+		patterns: []string{
+			// berkeley DB (e.g. centos 7), NDB (e.g. sles 15), and sqlite (e.g. fedora 33+ and rhel 9)
+			"/var/lib/rpm/{Packages,Packages.db,rpmdb.sqlite}",
+			"/usr/lib/sysimage/rpm/{Packages,Packages.db,rpmdb.sqlite}",
+			"/usr/share/rpm/{Packages,Packages.db,rpmdb.sqlite}",
+		},
+	},
+	{
+		ty: ApkPackageDatabase,
+		patterns: []string{
+			"/lib/apk/db/installed",
+		},
+	},
+	{
+		ty: PacmanPackageDatabase,
+		patterns: []string{
+			"/var/lib/pacman/local/*/{desc,files}",
+		},
+	},
+}
+
+// PackageDatabase is a package database file found within the image squash.
+type PackageDatabase struct {
+	// Type is the package manager that maintains the database
+	Type PackageDatabaseType
+	// Path is the path of the database file (as found by the well-known location, which may be through links)
+	Path string
+	// RealPath is the path of the database file with all links resolved
+	RealPath string
+	// Reference is the file holding the contents of the database
+	Reference file.Reference
+}
+
+// PackageDatabaseGlobs returns the well-known locations of the package database files of all supported package managers
+// (dpkg, rpm, apk, and pacman) as globs, suitable for WithSparseExtraction (see WithPackageDatabasesOnly).
+func PackageDatabaseGlobs() []string {
+	var globs []string
+	for _, location := range packageDatabaseLocations {
+		globs = append(globs, location.patterns...)
+	}
+	return globs
+}
+
+// WithPackageDatabasesOnly extracts only the package database files from each layer (see WithSparseExtraction and
+// PackageDatabaseGlobs), such that the package databases can be read (see Image.PackageDatabases) without extracting
+// any application files. Note: the package databases are the only file contents available within the image.
+func WithPackageDatabasesOnly() AdditionalMetadata {
+	return WithSparseExtraction(PackageDatabaseGlobs()...)
+}
+
+// PackageDatabases returns the package database files found at the well-known locations within the image squash (the
+// image must be read first), ordered by type and path. Links are followed (e.g. /var/lib/rpm -> /usr/lib/sysimage/rpm),
+// while dead links are skipped. A database reachable from several locations is returned once, by the first location
+// found. Use OpenPackageDatabase to read the contents of each database.
+func (i *Image) PackageDatabases() ([]PackageDatabase, error) {
+	tree := i.SquashedTree()
+
+	var databases []PackageDatabase
+	for _, location := range packageDatabaseLocations {
+		var found []PackageDatabase
+		seen := make(map[file.Path]struct{})
+		for _, pattern := range location.patterns {
+			results, err := tree.FilesByGlob(pattern, filetree.DoNotFollowDeadBasenameLinks)
+			if err != nil {
+				return nil, fmt.Errorf("unable to search for %s package databases (pattern=%q): %w", location.ty, pattern, err)
+			}
+			for _, result := range results {
+				if result.IsDeadLink {
+					continue
+				}
+				if _, ok := seen[result.RealPath]; ok {
+					continue
+				}
+				seen[result.RealPath] = struct{}{}
+				found = append(found, PackageDatabase{
+					Type:      location.ty,
+					Path:      string(result.MatchPath),
+					RealPath:  string(result.RealPath),
+					Reference: result.Reference,
+				})
+			}
+		}
+		sort.SliceStable(found, func(a, b int) bool {
+			return found[a].Path < found[b].Path
+		})
+		databases = append(databases, found...)
+	}
+	return databases, nil
+}
+
+// OpenPackageDatabase returns a reader for the contents of the given package database (see PackageDatabases). The
+// caller must close the reader.
+func (i *Image) OpenPackageDatabase(db PackageDatabase) (io.ReadCloser, error) {
+	reader, err := i.FileCatalog.FileContents(db.Reference)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %s package database=%q: %w", db.Type, db.Path, err)
+	}
+	return reader, nil
+}
@@ -0,0 +1,98 @@
+package image
+
+import (
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImage_PackageDatabases(t *testing.T) {
+	layers := [][]testEntry{
+		{
+			testFile("var/lib/dpkg/status", "Package: base-files"),
+			testFile("var/lib/dpkg/status.d/tzdata", "Package: tzdata"),
+			testFile("usr/lib/sysimage/rpm/rpmdb.sqlite", "sqlite"),
+			testSymlink("var/lib/rpm", "../../usr/lib/sysimage/rpm"),
+			testFile("lib/apk/db/installed", "P:musl"),
+			testSymlink("lib/apk/db/dead", "/does-not-exist"),
+			testFile("app/server", "binary"),
+		},
+		{
+			testFile("var/lib/pacman/local/bash-5.1-1/desc", "%NAME%\nbash"),
+			testFile("var/lib/pacman/local/bash-5.1-1/files", "%FILES%\nusr/bin/bash"),
+			testFile("var/lib/pacman/local/bash-5.1-1/mtree", "mtree"),
+			testFile("var/lib/dpkg/status", "Package: base-files\n\nPackage: bash"),
+		},
+	}
+
+	expected := []struct {
+		ty       PackageDatabaseType
+		path     string
+		realPath string
+		contents string
+	}{
+		{DpkgPackageDatabase, "/var/lib/dpkg/status", "/var/lib/dpkg/status", "Package: base-files\n\nPackage: bash"},
+		{DpkgPackageDatabase, "/var/lib/dpkg/status.d/tzdata", "/var/lib/dpkg/status.d/tzdata", "Package: tzdata"},
+		// the database is found through the link, and only once
+		{RpmPackageDatabase, "/var/lib/rpm/rpmdb.sqlite", "/usr/lib/sysimage/rpm/rpmdb.sqlite", "sqlite"},
+		{ApkPackageDatabase, "/lib/apk/db/installed", "/lib/apk/db/installed", "P:musl"},
+		{PacmanPackageDatabase, "/var/lib/pacman/local/bash-5.1-1/desc", "/var/lib/pacman/local/bash-5.1-1/desc", "%NAME%\nbash"},
+		{PacmanPackageDatabase, "/var/lib/pacman/local/bash-5.1-1/files", "/var/lib/pacman/local/bash-5.1-1/files", "%FILES%\nusr/bin/bash"},
+	}
+
+	tests := []struct {
+		name    string
+		options []AdditionalMetadata
+	}{
+		{
+			name: "full extraction",
+		},
+		{
+			name:    "package databases only",
+			options: []AdditionalMetadata{WithPackageDatabasesOnly()},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			img := NewImage(newTestV1Image(t, layers...), t.TempDir(), test.options...)
+			require.NoError(t, img.Read())
+
+			databases, err := img.PackageDatabases()
+			require.NoError(t, err)
+			require.Len(t, databases, len(expected))
+
+			for idx, db := range databases {
+				assert.Equal(t, expected[idx].ty, db.Type)
+				assert.Equal(t, expected[idx].path, db.Path)
+				assert.Equal(t, expected[idx].realPath, db.RealPath)
+
+				reader, err := img.OpenPackageDatabase(db)
+				require.NoError(t, err)
+				contents, err := ioutil.ReadAll(reader)
+				require.NoError(t, err)
+				require.NoError(t, reader.Close())
+				assert.Equal(t, expected[idx].contents, string(contents))
+			}
+
+			_, err = img.ReadFile("/app/server")
+			if len(test.options) > 0 {
+				assert.True(t, errors.Is(err, fs.ErrNotExist), "expected application files to be skipped, got %+v", err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestImage_PackageDatabases_NoneFound(t *testing.T) {
+	img := newTestImage(t, []testEntry{testFile("app/server", "binary")})
+
+	databases, err := img.PackageDatabases()
+	require.NoError(t, err)
+	assert.Empty(t, databases)
+}
@@ -0,0 +1,117 @@
+package image
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+// PathAction describes how a layer affected a path.
+type PathAction string
+
+const (
+	// PathAdded indicates the layer introduced a path that did not exist in the layers below it.
+	PathAdded PathAction = "added"
+	// PathModified indicates the layer replaced (or re-declared) a path that existed in the layers below it.
+	PathModified PathAction = "modified"
+	// PathDeleted indicates the layer removed a path, either with a whiteout or an opaque parent directory.
+	PathDeleted PathAction = "deleted"
+)
+
+// PathChange is a single layer's effect on a path.
+type PathChange struct {
+	// LayerIndex is the index of the layer within the image (in build order)
+	LayerIndex int
+	// LayerDigest is the digest of the layer
+	LayerDigest string
+	// Action is how the layer affected the path
+	Action PathAction
+}
+
+// WithPathHistory retains, for every path, the ordered list of layers that contained an entry for the path (see
+// Image.PathHistory). This is not enabled by default since the history grows with the number of tar entries across
+// all layers.
+func WithPathHistory() AdditionalMetadata {
+	return func(image *Image) error {
+		image.pathHistory = make(map[file.Path][]PathChange)
+		return nil
+	}
+}
+
+// PathHistory returns the changes made to the given path by each layer that touched it (in build order). Nil is
+// returned if the path was never touched or the image was not read with WithPathHistory.
+func (i *Image) PathHistory(path string) []PathChange {
+	if i.pathHistory == nil {
+		return nil
+	}
+	return i.pathHistory[file.Path(file.DirSeparator+path).Normalize()]
+}
+
+// recordPathHistory captures how the given (read) layer affected each path relative to the layers below it.
+func (i *Image) recordPathHistory(layer *Layer, idx int) {
+	if i.pathHistory == nil || layer.Tree == nil {
+		return
+	}
+
+	var paths []file.Path
+	for _, ref := range layer.Tree.AllFiles(file.AllTypes...) {
+		paths = append(paths, ref.RealPath)
+	}
+	sort.Sort(file.Paths(paths))
+
+	// opaque directories remove all lower layer children that are not re-declared within this layer
+	for _, p := range paths {
+		if !p.IsDirWhiteout() {
+			continue
+		}
+		if dir, err := p.UnWhiteoutPath(); err == nil {
+			i.recordDescendantsDeleted(dir, layer, idx)
+		}
+	}
+
+	for _, p := range paths {
+		switch {
+		case p.IsDirWhiteout():
+			continue
+		case p.IsWhiteout():
+			lowerPath, err := p.UnWhiteoutPath()
+			if err != nil {
+				continue
+			}
+			if i.pathExists(lowerPath) {
+				i.recordPathChange(lowerPath, layer, idx, PathDeleted)
+			}
+			i.recordDescendantsDeleted(lowerPath, layer, idx)
+		case i.pathExists(p):
+			i.recordPathChange(p, layer, idx, PathModified)
+		default:
+			i.recordPathChange(p, layer, idx, PathAdded)
+		}
+	}
+}
+
+func (i *Image) recordPathChange(p file.Path, layer *Layer, idx int, action PathAction) {
+	i.pathHistory[p] = append(i.pathHistory[p], PathChange{
+		LayerIndex:  idx,
+		LayerDigest: layer.Metadata.Digest,
+		Action:      action,
+	})
+}
+
+// recordDescendantsDeleted marks all existing paths under the given directory as deleted by the given layer, unless
+// the layer itself declares the path.
+func (i *Image) recordDescendantsDeleted(dir file.Path, layer *Layer, idx int) {
+	prefix := strings.TrimSuffix(string(dir), file.DirSeparator) + file.DirSeparator
+	for p := range i.pathHistory {
+		if strings.HasPrefix(string(p), prefix) && i.pathExists(p) && !layer.Tree.HasPath(p) {
+			i.recordPathChange(p, layer, idx, PathDeleted)
+		}
+	}
+}
+
+// pathExists indicates if the most recent recorded change to the given path leaves it in place.
+func (i *Image) pathExists(p file.Path) bool {
+	changes := i.pathHistory[p]
+	return len(changes) > 0 && changes[len(changes)-1].Action != PathDeleted
+}
@@ -0,0 +1,54 @@
+package image
+
+import (
+	"testing"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImage_PathHistory(t *testing.T) {
+	v1Img := newTestV1Image(t,
+		[]testEntry{
+			testFile("etc/passwd", "root"),
+			testFile("etc/shadow", "secret"),
+			testFile("opt/app/old", "old"),
+		},
+		[]testEntry{
+			testFile("etc/passwd", "root\nuser"),
+			testFile("etc/.wh.shadow", ""),
+		},
+		[]testEntry{
+			testFile("opt/app/"+file.OpaqueWhiteout, ""),
+			testFile("opt/app/new", "new"),
+		},
+	)
+
+	img := NewImage(v1Img, t.TempDir(), WithPathHistory())
+	require.NoError(t, img.Read())
+
+	actions := func(path string) []PathAction {
+		var result []PathAction
+		for idx, change := range img.PathHistory(path) {
+			assert.Equal(t, img.Layers[change.LayerIndex].Metadata.Digest, change.LayerDigest, "change %d of %q", idx, path)
+			result = append(result, change.Action)
+		}
+		return result
+	}
+
+	assert.Equal(t, []PathAction{PathAdded, PathModified}, actions("/etc/passwd"))
+	assert.Equal(t, []PathAction{PathAdded, PathDeleted}, actions("/etc/shadow"))
+	assert.Equal(t, []PathAction{PathAdded, PathDeleted}, actions("/opt/app/old"))
+	assert.Equal(t, []PathAction{PathAdded}, actions("opt/app/new"))
+	assert.Nil(t, img.PathHistory("/does/not/exist"))
+
+	assert.Equal(t, 1, img.PathHistory("/etc/passwd")[1].LayerIndex)
+	assert.Equal(t, 2, img.PathHistory("/opt/app/old")[1].LayerIndex)
+}
+
+func TestImage_PathHistory_NotTracked(t *testing.T) {
+	img := newTestImage(t, []testEntry{testFile("etc/passwd", "root")})
+
+	assert.Nil(t, img.PathHistory("/etc/passwd"))
+}
@@ -0,0 +1,64 @@
+package image
+
+import (
+	"encoding/json"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// defaultVariants are the CPU variants assumed for architectures when no variant is given (as with container runtimes).
+var defaultVariants = map[string]string{
+	"arm":   "v7",
+	"arm64": "v8",
+}
+
+// Platform returns the platform the image was built for, as declared within the image config. For Windows images this
+// includes the OS version (e.g. "10.0.17763.1234"), which must be compatible with the build of the host that runs the
+// image. For ARM images this includes the CPU variant (e.g. "v7"), see Metadata.Variant.
+func (i *Image) Platform() v1.Platform {
+	cfg := i.Metadata.Config
+	return v1.Platform{
+		OS:           cfg.OS,
+		Architecture: cfg.Architecture,
+		OSVersion:    cfg.OSVersion,
+		Variant:      i.Metadata.Variant,
+	}
+}
+
+// WithPlatformVariant sets the CPU variant of the image platform (e.g. "v7") when the image config does not declare one,
+// as with the platform of the index entry the image was selected from.
+func WithPlatformVariant(variant string) AdditionalMetadata {
+	return func(image *Image) error {
+		if image.Metadata.Variant == "" {
+			image.Metadata.Variant = variant
+		}
+		return nil
+	}
+}
+
+// NormalizePlatformVariant returns the canonical form of the given CPU variant for the given architecture, such that
+// equivalent variants compare equal: variants are lowercase and "v" prefixed (e.g. "7" is "v7"), and an empty variant is
+// the default variant of the architecture ("v7" for arm and "v8" for arm64, as with container runtimes). An empty
+// variant is returned for architectures without variants (e.g. amd64).
+func NormalizePlatformVariant(architecture, variant string) string {
+	variant = strings.ToLower(strings.TrimSpace(variant))
+	if variant == "" {
+		return defaultVariants[strings.ToLower(architecture)]
+	}
+	if variant[0] >= '0' && variant[0] <= '9' {
+		variant = "v" + variant
+	}
+	return variant
+}
+
+// configVariant returns the CPU variant declared within the given raw image config (not available from v1.ConfigFile).
+func configVariant(rawConfig []byte) string {
+	var config struct {
+		Variant string `json:"variant"`
+	}
+	if err := json.Unmarshal(rawConfig, &config); err != nil {
+		return ""
+	}
+	return config.Variant
+}
@@ -0,0 +1,49 @@
+package image
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizePlatformVariant(t *testing.T) {
+	tests := []struct {
+		architecture string
+		variant      string
+		expected     string
+	}{
+		{architecture: "arm", variant: "", expected: "v7"},
+		{architecture: "arm", variant: "v6", expected: "v6"},
+		{architecture: "arm", variant: "7", expected: "v7"},
+		{architecture: "ARM", variant: "V5", expected: "v5"},
+		{architecture: "arm64", variant: "", expected: "v8"},
+		{architecture: "arm64", variant: "8", expected: "v8"},
+		{architecture: "amd64", variant: "", expected: ""},
+		{architecture: "amd64", variant: "v3", expected: "v3"},
+	}
+	for _, test := range tests {
+		t.Run(test.architecture+"/"+test.variant, func(t *testing.T) {
+			assert.Equal(t, test.expected, NormalizePlatformVariant(test.architecture, test.variant))
+		})
+	}
+}
+
+func TestImage_Platform_Variant(t *testing.T) {
+	img := newTestImage(t, []testEntry{testFile("etc/os-release", "ID=alpine")})
+	assert.Empty(t, img.Platform().Variant)
+
+	// the variant of an index entry only applies when the config does not declare one
+	require.NoError(t, WithPlatformVariant("v7")(img))
+	assert.Equal(t, "v7", img.Platform().Variant)
+
+	img.Metadata.Variant = "v6"
+	require.NoError(t, WithPlatformVariant("v7")(img))
+	assert.Equal(t, "v6", img.Platform().Variant)
+}
+
+func Test_configVariant(t *testing.T) {
+	assert.Equal(t, "v7", configVariant([]byte(`{"architecture": "arm", "os": "linux", "variant": "v7"}`)))
+	assert.Empty(t, configVariant([]byte(`{"architecture": "amd64", "os": "linux"}`)))
+	assert.Empty(t, configVariant([]byte(`not json`)))
+}
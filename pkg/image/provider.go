@@ -1,7 +1,15 @@
 package image
 
+import "context"
+
 // Provider is an abstraction for any object that provides image objects (e.g. the docker daemon API, a tar file of
 // an OCI image, podman varlink API, etc.).
 type Provider interface {
 	Provide() (*Image, error)
 }
+
+// ContextProvider is a Provider where providing the image can be cancelled with the given context.
+type ContextProvider interface {
+	Provider
+	ProvideContext(ctx context.Context) (*Image, error)
+}
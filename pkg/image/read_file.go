@@ -0,0 +1,70 @@
+package image
+
+import (
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/filetree"
+)
+
+// ErrPathNotFound is returned from Image.ReadFile when the path does not exist within the image squash (including paths
+// removed by whiteouts and dead links). It matches fs.ErrNotExist with errors.Is.
+type ErrPathNotFound struct {
+	Path string
+}
+
+func (e *ErrPathNotFound) Error() string {
+	return fmt.Sprintf("path not found within image: %s", e.Path)
+}
+
+// Is allows errors.Is(err, fs.ErrNotExist) to match.
+func (e *ErrPathNotFound) Is(target error) bool {
+	return target == fs.ErrNotExist
+}
+
+// ReadFile returns the contents of the file at the given path within the image squash tree (the image must be read
+// first), following any links. The path may be absolute (e.g. "/etc/os-release") or relative to the image root. A
+// *ErrPathNotFound is returned when the path does not exist, any other error means the file exists but could not be
+// read (e.g. a directory, or a *file.ErrFileTooLarge when the file exceeds the maximum file read size).
+func (i *Image) ReadFile(path string) ([]byte, error) {
+	p := file.Path(file.DirSeparator + path).Normalize()
+
+	exists, ref, err := i.SquashedTree().File(p, filetree.FollowBasenameLinks)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve path=%q: %w", path, err)
+	}
+	if !exists {
+		return nil, &ErrPathNotFound{Path: path}
+	}
+	if ref == nil {
+		// a directory that is implied by the paths of other files (e.g. the root), but has no tar entry
+		return nil, fmt.Errorf("unable to read path=%q: %w", path, errIsDir)
+	}
+
+	entry, err := i.FileCatalog.Get(*ref)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read path=%q: %w", path, err)
+	}
+	if entry.Metadata.TypeFlag == byte(file.TypeDir) {
+		return nil, fmt.Errorf("unable to read path=%q: %w", path, errIsDir)
+	}
+
+	reader, err := i.FileCatalog.FileContents(*ref)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read path=%q: %w", path, err)
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			log.Warnf("unable to close file=%q: %+v", path, err)
+		}
+	}()
+
+	contents, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read path=%q: %w", path, err)
+	}
+	return contents, nil
+}
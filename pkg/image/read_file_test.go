@@ -0,0 +1,101 @@
+package image
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImage_ReadFile(t *testing.T) {
+	img := fsTestImage(t)
+
+	tests := []struct {
+		name         string
+		path         string
+		want         string
+		wantNotFound bool
+		wantErr      bool
+	}{
+		{
+			name: "absolute path",
+			path: "/etc/hostname",
+			want: "stereoscope\n",
+		},
+		{
+			name: "relative path",
+			path: "etc/hostname",
+			want: "stereoscope\n",
+		},
+		{
+			name: "symlink",
+			path: "/etc/os-release",
+			want: "ID=test\n",
+		},
+		{
+			name: "symlink within the path",
+			path: "/lib/os-release",
+			want: "ID=test\n",
+		},
+		{
+			name:         "missing file",
+			path:         "/etc/missing",
+			wantNotFound: true,
+		},
+		{
+			name:         "removed by whiteout",
+			path:         "/etc/removed",
+			wantNotFound: true,
+		},
+		{
+			name:         "dead link",
+			path:         "/dead",
+			wantNotFound: true,
+		},
+		{
+			name:    "directory",
+			path:    "/etc",
+			wantErr: true,
+		},
+		{
+			name:    "implied directory",
+			path:    "/implied/dir",
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contents, err := img.ReadFile(test.path)
+
+			var notFound *ErrPathNotFound
+			switch {
+			case test.wantNotFound:
+				require.True(t, errors.As(err, &notFound), "unexpected error: %+v", err)
+				assert.Equal(t, test.path, notFound.Path)
+				assert.True(t, errors.Is(err, fs.ErrNotExist))
+			case test.wantErr:
+				require.Error(t, err)
+				assert.False(t, errors.As(err, &notFound), "unexpected not found error: %+v", err)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, test.want, string(contents))
+			}
+		})
+	}
+}
+
+func TestImage_ReadFile_MaxFileReadSize(t *testing.T) {
+	img := NewImage(newSparseFileImage(t), t.TempDir(), WithMaxFileReadSize(file.MB))
+	require.NoError(t, img.Read())
+
+	contents, err := img.ReadFile("/small.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "a small file\n", string(contents))
+
+	_, err = img.ReadFile("/big.bin")
+	var tooLarge *file.ErrFileTooLarge
+	assert.True(t, errors.As(err, &tooLarge), "unexpected error: %+v", err)
+}
@@ -0,0 +1,25 @@
+package image
+
+import "context"
+
+type readOptionsKey struct{}
+
+// WithReadOptions returns a copy of the given context where images read by calls given the context (e.g.
+// stereoscope.GetImageContext) are read with the given options, in addition to any options of the parent context. This
+// is how a single call is configured (e.g. WithLowMemoryMode, WithMaxFileEntries, or WithMaxFileReadSize) without
+// changing the process-wide defaults (e.g. SetLowMemoryMode), thus calls with different settings can run side by side.
+func WithReadOptions(ctx context.Context, options ...AdditionalMetadata) context.Context {
+	combined := append(ReadOptions(ctx), options...)
+	return context.WithValue(ctx, readOptionsKey{}, combined)
+}
+
+// ReadOptions returns the options that images are read with for calls given the context (see WithReadOptions), which
+// is nil when there are none.
+func ReadOptions(ctx context.Context) []AdditionalMetadata {
+	if ctx == nil {
+		return nil
+	}
+	options, _ := ctx.Value(readOptionsKey{}).([]AdditionalMetadata)
+	// note: the options are copied so that sibling contexts never share (and overwrite) a backing array
+	return append([]AdditionalMetadata(nil), options...)
+}
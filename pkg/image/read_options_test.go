@@ -0,0 +1,35 @@
+package image
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithReadOptions(t *testing.T) {
+	assert.Nil(t, ReadOptions(context.Background()))
+
+	parent := WithReadOptions(context.Background(), WithMaxFileEntries(10))
+	first := WithReadOptions(parent, WithMaxFileReadSize(20))
+	second := WithReadOptions(parent, WithLowMemoryMode(true))
+
+	apply := func(ctx context.Context) *Image {
+		img := NewImage(nil, t.TempDir())
+		require.NoError(t, img.applyOverrideMetadata(ReadOptions(ctx)...))
+		return img
+	}
+
+	// options of the parent are inherited...
+	img := apply(first)
+	assert.Equal(t, int64(10), img.FileCatalog.maxEntries)
+	assert.Equal(t, int64(20), img.FileCatalog.maxFileReadSize)
+	assert.False(t, img.lowMemory)
+
+	// ...without leaking between sibling contexts
+	img = apply(second)
+	assert.Equal(t, int64(10), img.FileCatalog.maxEntries)
+	assert.Equal(t, int64(0), img.FileCatalog.maxFileReadSize)
+	assert.True(t, img.lowMemory)
+}
@@ -0,0 +1,194 @@
+package image
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// dockerHubRegistry is the canonical name for Docker Hub, as used by the docker daemon (the GCR lib uses an alias
+// of "index.docker.io" for the same registry).
+const dockerHubRegistry = "docker.io"
+
+// NormalizeReference returns the fully qualified form of the given image reference (registry/repository:tag or
+// registry/repository@digest) with any defaults filled in (e.g. "alpine" is "docker.io/library/alpine:latest"). The
+// reference may be prefixed with the "docker" or "registry" scheme. When the reference has both a tag and a digest
+// only the digest is kept, since that is what is fetched.
+func NormalizeReference(userStr string) (string, error) {
+	imageStr, err := trimReferenceScheme(userStr)
+	if err != nil {
+		return "", err
+	}
+
+	ref, err := name.ParseReference(imageStr)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse image reference=%q: %w", userStr, err)
+	}
+
+	registry := ref.Context().RegistryStr()
+	if registry == name.DefaultRegistry {
+		registry = dockerHubRegistry
+	}
+	repository := fmt.Sprintf("%s/%s", registry, ref.Context().RepositoryStr())
+
+	switch r := ref.(type) {
+	case name.Digest:
+		return fmt.Sprintf("%s@%s", repository, r.DigestStr()), nil
+	case name.Tag:
+		return fmt.Sprintf("%s:%s", repository, r.TagStr()), nil
+	}
+	return "", fmt.Errorf("unsupported image reference=%q", userStr)
+}
+
+// trimReferenceScheme removes any "docker" or "registry" scheme from the given image reference, raising an error for
+// schemes of sources that do not use image references.
+func trimReferenceScheme(userStr string) (string, error) {
+	candidates := strings.SplitN(userStr, SchemeSeparator, 2)
+	if len(candidates) == 2 {
+		switch ParseSourceScheme(candidates[0]) {
+		case DockerDaemonSource, OciRegistrySource:
+			return candidates[1], nil
+		case UnknownSource:
+			// this is not a scheme (e.g. "localhost:5000/repo"), keep the entire string
+		default:
+			return "", fmt.Errorf("source %q does not use image references", candidates[0])
+		}
+	}
+	return userStr, nil
+}
+
+// ErrInvalidReference is returned from ValidateReference when the given image reference is malformed.
+type ErrInvalidReference struct {
+	Reference string
+	Reason    string
+}
+
+func (e *ErrInvalidReference) Error() string {
+	return fmt.Sprintf("invalid image reference=%q: %s", e.Reference, e.Reason)
+}
+
+var (
+	// referenceDomainComponentPattern matches a single component of a registry hostname (as with the docker
+	// distribution reference grammar).
+	referenceDomainComponentPattern = regexp.MustCompile(`^(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])$`)
+	// referencePathComponentPattern matches a single component of a repository path (e.g. "library" or "alpine").
+	referencePathComponentPattern = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|[-]*)[a-z0-9]+)*$`)
+	referenceTagPattern           = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+	referencePortPattern          = regexp.MustCompile(`^[0-9]+$`)
+)
+
+// maxRepositoryLength is the maximum length of a repository name (including the registry).
+const maxRepositoryLength = 255
+
+// ValidateReference checks that the given image reference (optionally prefixed with the "docker" or "registry" scheme)
+// is well formed, returning an *ErrInvalidReference describing the problem if it is not.
+//
+// Weak validation only checks the bare minimum needed to interpret the input as a reference (the same validation used
+// when detecting the image source), so some references are accepted that will fail once the image is fetched (e.g.
+// "registry_with_underscores.io/repo", "repo//name", or "repo-/name"). Strict validation additionally checks the
+// reference against the docker distribution reference grammar: the registry must be a valid hostname (with an optional
+// numeric port), each repository path component must be lowercase alphanumeric with single separators ("." "_" "__"
+// or "-") between characters, tags must be at most 128 word characters (with "." or "-" after the first character),
+// and digests must be a valid algorithm and hex value. Neither mode requires the registry or tag to be specified
+// (defaults are assumed) and neither contacts a registry.
+func ValidateReference(ref string, strict bool) error {
+	imageStr, err := trimReferenceScheme(ref)
+	if err != nil {
+		return &ErrInvalidReference{Reference: ref, Reason: err.Error()}
+	}
+
+	if strict {
+		if reason := strictReferenceViolation(imageStr); reason != "" {
+			return &ErrInvalidReference{Reference: ref, Reason: reason}
+		}
+	}
+
+	if _, err := name.ParseReference(imageStr, name.WeakValidation); err != nil {
+		return &ErrInvalidReference{Reference: ref, Reason: err.Error()}
+	}
+	return nil
+}
+
+// strictReferenceViolation returns a description of the first violation of the reference grammar found in the given
+// image reference (without any scheme), or an empty string if there is none.
+func strictReferenceViolation(imageStr string) string {
+	if imageStr == "" {
+		return "reference is empty"
+	}
+	if strings.IndexFunc(imageStr, unicode.IsSpace) >= 0 {
+		return "reference must not contain whitespace"
+	}
+
+	remaining := imageStr
+	if idx := strings.Index(remaining, "@"); idx >= 0 {
+		digest := remaining[idx+1:]
+		remaining = remaining[:idx]
+		if _, err := v1.NewHash(digest); err != nil {
+			return fmt.Sprintf("invalid digest %q: %v", digest, err)
+		}
+	}
+
+	if idx := strings.LastIndex(remaining, ":"); idx > strings.LastIndex(remaining, "/") {
+		tag := remaining[idx+1:]
+		remaining = remaining[:idx]
+		if !referenceTagPattern.MatchString(tag) {
+			return fmt.Sprintf("invalid tag %q: tags must be at most 128 characters of letters, digits, \"_\", \".\" or \"-\" (and may not start with \".\" or \"-\")", tag)
+		}
+	}
+
+	if remaining == "" {
+		return "missing repository"
+	}
+	if len(remaining) > maxRepositoryLength {
+		return fmt.Sprintf("repository name must not be longer than %d characters", maxRepositoryLength)
+	}
+
+	components := strings.Split(remaining, "/")
+	if len(components) > 1 && isRegistryComponent(components[0]) {
+		if reason := registryViolation(components[0]); reason != "" {
+			return reason
+		}
+		components = components[1:]
+	}
+
+	for _, component := range components {
+		switch {
+		case component == "":
+			return fmt.Sprintf("missing repository path component in %q", remaining)
+		case strings.ToLower(component) != component:
+			return fmt.Sprintf("invalid repository path component %q: must be lowercase", component)
+		case !referencePathComponentPattern.MatchString(component):
+			return fmt.Sprintf("invalid repository path component %q: must be alphanumeric, separated by a single \".\", \"_\", \"__\", or \"-\"", component)
+		}
+	}
+	return ""
+}
+
+// isRegistryComponent indicates if the first component of a reference is a registry (as opposed to the first
+// component of the repository path), following the same rules as the docker daemon.
+func isRegistryComponent(component string) bool {
+	return strings.ContainsAny(component, ".:") || component == "localhost"
+}
+
+// registryViolation returns a description of why the given registry (host with an optional port) is invalid, or an
+// empty string if it is valid.
+func registryViolation(registry string) string {
+	host := registry
+	if idx := strings.LastIndex(registry, ":"); idx >= 0 {
+		host = registry[:idx]
+		if port := registry[idx+1:]; !referencePortPattern.MatchString(port) {
+			return fmt.Sprintf("invalid registry %q: port %q must be numeric", registry, port)
+		}
+	}
+
+	for _, component := range strings.Split(host, ".") {
+		if !referenceDomainComponentPattern.MatchString(component) {
+			return fmt.Sprintf("invalid registry %q: %q is not a valid hostname component", registry, component)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,188 @@
+package image
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeReference(t *testing.T) {
+	const digest = "sha256:8f1c7d2b9a4c1e9e2c3a2f1b0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f2e1d"
+
+	tests := []struct {
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{
+			input:    "alpine",
+			expected: "docker.io/library/alpine:latest",
+		},
+		{
+			input:    "alpine:3.15",
+			expected: "docker.io/library/alpine:3.15",
+		},
+		{
+			input:    "library/alpine:3.15",
+			expected: "docker.io/library/alpine:3.15",
+		},
+		{
+			input:    "docker.io/alpine",
+			expected: "docker.io/library/alpine:latest",
+		},
+		{
+			input:    "index.docker.io/library/alpine:latest",
+			expected: "docker.io/library/alpine:latest",
+		},
+		{
+			input:    "anchore/syft",
+			expected: "docker.io/anchore/syft:latest",
+		},
+		{
+			input:    "alpine@" + digest,
+			expected: "docker.io/library/alpine@" + digest,
+		},
+		{
+			input:    "alpine:3.15@" + digest,
+			expected: "docker.io/library/alpine@" + digest,
+		},
+		{
+			input:    "ghcr.io/anchore/syft:v0.30.0",
+			expected: "ghcr.io/anchore/syft:v0.30.0",
+		},
+		{
+			input:    "localhost:5000/alpine",
+			expected: "localhost:5000/alpine:latest",
+		},
+		{
+			input:    "docker:alpine",
+			expected: "docker.io/library/alpine:latest",
+		},
+		{
+			input:    "registry:ghcr.io/anchore/syft",
+			expected: "ghcr.io/anchore/syft:latest",
+		},
+		{
+			input:   "docker-archive:image.tar",
+			wantErr: true,
+		},
+		{
+			input:   "Not/A/Valid/Reference",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			actual, err := NormalizeReference(test.input)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestValidateReference(t *testing.T) {
+	const digest = "sha256:8f1c7d2b9a4c1e9e2c3a2f1b0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f2e1d"
+
+	tests := []struct {
+		input         string
+		wantWeakErr   bool
+		wantStrictErr string
+	}{
+		{input: "alpine"},
+		{input: "alpine:3.15"},
+		{input: "alpine:3.15@" + digest},
+		{input: "docker:alpine"},
+		{input: "registry:ghcr.io/anchore/syft:v0.30.0"},
+		{input: "localhost:5000/some/repo_name/with-parts"},
+		{input: "localhost/repo"},
+		{input: "my-registry.example.com:443/repo__name/v2.x"},
+		{
+			input:         "",
+			wantWeakErr:   true,
+			wantStrictErr: "reference is empty",
+		},
+		{
+			input:         "Not/A/Valid/Reference",
+			wantWeakErr:   true,
+			wantStrictErr: `invalid repository path component "Not": must be lowercase`,
+		},
+		{
+			input:         "alpine:bad!tag",
+			wantWeakErr:   true,
+			wantStrictErr: `invalid tag "bad!tag"`,
+		},
+		{
+			input:         "alpine:.tag",
+			wantStrictErr: `invalid tag ".tag"`,
+		},
+		{
+			input:         "alpine@sha256:abc",
+			wantWeakErr:   true,
+			wantStrictErr: `invalid digest "sha256:abc"`,
+		},
+		{
+			input:         "registry_with_underscores.io/repo",
+			wantStrictErr: `invalid registry "registry_with_underscores.io"`,
+		},
+		{
+			input:         "localhost:port/repo",
+			wantWeakErr:   true,
+			wantStrictErr: `port "port" must be numeric`,
+		},
+		{
+			input:         "repo//name",
+			wantStrictErr: `missing repository path component in "repo//name"`,
+		},
+		{
+			input:         "repo-/name",
+			wantStrictErr: `invalid repository path component "repo-"`,
+		},
+		{
+			input:         "repo..name",
+			wantStrictErr: `invalid repository path component "repo..name"`,
+		},
+		{
+			input:         ":latest",
+			wantWeakErr:   true,
+			wantStrictErr: "missing repository",
+		},
+		{
+			input:         "alpine latest",
+			wantWeakErr:   true,
+			wantStrictErr: "reference must not contain whitespace",
+		},
+		{
+			input:         "docker-archive:image.tar",
+			wantWeakErr:   true,
+			wantStrictErr: `source "docker-archive" does not use image references`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			err := ValidateReference(test.input, false)
+			if test.wantWeakErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			err = ValidateReference(test.input, true)
+			if test.wantStrictErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var invalid *ErrInvalidReference
+			require.True(t, errors.As(err, &invalid), "unexpected error: %+v", err)
+			assert.Equal(t, test.input, invalid.Reference)
+			assert.Contains(t, invalid.Reason, test.wantStrictErr)
+		})
+	}
+}
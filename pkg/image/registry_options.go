@@ -1,8 +1,11 @@
 package image
 
 import (
+	"time"
+
 	"github.com/anchore/stereoscope/internal/log"
 	"github.com/google/go-containerregistry/pkg/authn"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 )
 
 // RegistryOptions for the OCI registry provider.
@@ -10,6 +13,58 @@ type RegistryOptions struct {
 	InsecureSkipTLSVerify bool
 	InsecureUseHTTP       bool
 	Credentials           []RegistryCredentials
+	// ResumeDownloads indicates that interrupted downloads should be continued with HTTP range requests (when the
+	// registry supports them) instead of failing. When nil this is enabled.
+	ResumeDownloads *bool
+	// ExtraHeaders are added to all registry HTTP requests (e.g. a tenant ID or API gateway key). Headers managed by
+	// stereoscope (Authorization and Accept) cannot be set this way.
+	ExtraHeaders map[string]string
+	// AllowForeignLayers indicates that foreign layers (layers declaring external URLs instead of being distributed by
+	// the registry, as with Windows base images) should be fetched from the declared URLs, using the same transport as
+	// the registry. By default the contents of foreign layers are skipped (see image.LayerMetadata.Skipped).
+	AllowForeignLayers bool
+	// ManifestMediaTypes is the ordered list of media types accepted when resolving manifests (most preferred first),
+	// which is sent as the Accept header. Some registries choose the manifest format to return based on this header, so
+	// this can be used to prefer OCI or Docker manifests (e.g. "application/vnd.oci.image.manifest.v1+json"). Include
+	// the index media types to allow resolving multi-platform images. When empty the standard set of Docker and OCI
+	// manifest and index media types is accepted.
+	ManifestMediaTypes []string
+	// TokenScopes are additional scopes requested with every registry token (e.g. "repository:other/repo:pull"), for
+	// registries that are strict about scope where an operation spans several repositories. The scopes derived from the
+	// operation (e.g. pulling the referenced repository) are always requested.
+	TokenScopes []string
+	// MaxIdleConnsPerHost is the number of idle connections kept open to each registry host, which are reused by later
+	// requests, including those of later fetches within the process (fetches with the same connection options share
+	// connections). When zero the default of the http package is used (see http.DefaultMaxIdleConnsPerHost).
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout is how long an idle registry connection is kept open for reuse. When zero the default of the
+	// registry client is used (90 seconds).
+	IdleConnTimeout time.Duration
+	// HostOverrides maps registry hostnames to the address that is dialed instead of resolving the hostname (as with
+	// --add-host for containers), e.g. "registry.example.com" to "10.0.0.5". The address may include a port (e.g.
+	// "10.0.0.5:5000"), otherwise the port of the request is kept. Hostnames match case-insensitively, and only the
+	// connections made for stereoscope requests are affected (requests keep the original hostname, thus TLS
+	// verification and the Host header still use it). Note: the override is not applied when the request is sent through
+	// a proxy (the proxy resolves the hostname instead).
+	HostOverrides map[string]string
+	// Platform selects the image to fetch when the reference is for a multi-platform index (e.g. a manifest list). When
+	// the OSVersion is set (as with Windows images, e.g. "10.0.17763.1234") only images for the same OS build (e.g.
+	// "10.0.17763") are considered, preferring the exact version and otherwise the latest revision. The Variant (e.g. "v7"
+	// for linux/arm/v7) must match when given; when not given the default variant of the architecture is preferred (v7
+	// for arm and v8 for arm64), otherwise the first image for the architecture is selected. When nil the only
+	// image within the index is selected, otherwise the default platform (linux/amd64).
+	Platform *v1.Platform
+	// ReferrersTagFallback indicates that referrers (e.g. SBOMs and signatures attached to an image) are listed with the
+	// referrers tag schema (an index tagged "sha256-<hex>" within the repository) when the registry does not support the
+	// OCI referrers API. The referrers API is always tried first. Disable this for registries where looking up the tag is
+	// unwanted or yields unrelated content (e.g. tags that coincidentally match the schema). When nil this is enabled.
+	ReferrersTagFallback *bool
+	// RequireLayers indicates that images without any layers are rejected with an error wrapping ErrNoLayers, which is
+	// useful for pipelines that consider such remote images malformed. By default images without layers are permitted
+	// (e.g. "FROM scratch" images with only metadata instructions). This applies to the registry provider, and to OCI
+	// directories and archives read with these options (e.g. with stereoscope.GetImage), though not to docker archives
+	// or images from a docker daemon.
+	RequireLayers bool
 }
 
 // Authenticator returns an object capable of authenticating against the given registry. If no credentials match the
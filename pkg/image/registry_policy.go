@@ -0,0 +1,117 @@
+package image
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// ErrRegistryNotPermitted is returned when accessing a registry that is denied (or not allowed) by the registry policy.
+var ErrRegistryNotPermitted = errors.New("registry not permitted")
+
+// RegistryPolicy restricts which registries may be accessed, where each entry is a registry host pattern (e.g.
+// "docker.io", "*.gcr.io", or "localhost:5000"). Patterns are case-insensitive and use path.Match syntax. Note that
+// "docker.io" and "index.docker.io" are treated as the same registry.
+type RegistryPolicy struct {
+	// Allowed are the registries that may be accessed. When empty all registries are allowed (unless denied).
+	Allowed []string
+	// Denied are the registries that may never be accessed, which takes precedence over the allowed registries.
+	Denied []string
+}
+
+var registryPolicy = struct {
+	lock   sync.RWMutex
+	policy RegistryPolicy
+}{}
+
+// SetRegistryPolicy restricts the registries that may be accessed when fetching images (by the registry provider, the
+// docker daemon provider when pulling, and source detection), which is checked before any network call is made.
+// An empty policy (the default) permits all registries.
+func SetRegistryPolicy(policy RegistryPolicy) error {
+	normalized := RegistryPolicy{
+		Allowed: make([]string, 0, len(policy.Allowed)),
+		Denied:  make([]string, 0, len(policy.Denied)),
+	}
+	for _, pattern := range policy.Allowed {
+		p, err := normalizeRegistryPattern(pattern)
+		if err != nil {
+			return err
+		}
+		normalized.Allowed = append(normalized.Allowed, p)
+	}
+	for _, pattern := range policy.Denied {
+		p, err := normalizeRegistryPattern(pattern)
+		if err != nil {
+			return err
+		}
+		normalized.Denied = append(normalized.Denied, p)
+	}
+
+	registryPolicy.lock.Lock()
+	defer registryPolicy.lock.Unlock()
+
+	registryPolicy.policy = normalized
+	return nil
+}
+
+func normalizeRegistryPattern(pattern string) (string, error) {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if pattern == "" {
+		return "", fmt.Errorf("empty registry pattern")
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return "", fmt.Errorf("invalid registry pattern=%q: %w", pattern, err)
+	}
+	return pattern, nil
+}
+
+// CheckRegistryPermitted returns an error wrapping ErrRegistryNotPermitted if the given registry host is denied (or not
+// allowed) by the registry policy (see SetRegistryPolicy).
+func CheckRegistryPermitted(registry string) error {
+	registryPolicy.lock.RLock()
+	defer registryPolicy.lock.RUnlock()
+
+	policy := registryPolicy.policy
+	host := strings.ToLower(registry)
+
+	if matchesRegistryPattern(host, policy.Denied) {
+		return fmt.Errorf("%w: %q is denied", ErrRegistryNotPermitted, registry)
+	}
+	if len(policy.Allowed) > 0 && !matchesRegistryPattern(host, policy.Allowed) {
+		return fmt.Errorf("%w: %q is not allowed", ErrRegistryNotPermitted, registry)
+	}
+	return nil
+}
+
+// checkReferencePermitted checks the registry of the given image reference against the registry policy. References
+// that cannot be parsed are not checked (these cannot be pulled).
+func checkReferencePermitted(imageStr string) error {
+	ref, err := name.ParseReference(imageStr, name.WeakValidation)
+	if err != nil {
+		return nil
+	}
+	return CheckRegistryPermitted(ref.Context().RegistryStr())
+}
+
+func matchesRegistryPattern(host string, patterns []string) bool {
+	candidates := []string{host}
+	switch host {
+	case name.DefaultRegistry:
+		candidates = append(candidates, "docker.io")
+	case "docker.io":
+		candidates = append(candidates, name.DefaultRegistry)
+	}
+
+	for _, pattern := range patterns {
+		for _, candidate := range candidates {
+			if matched, _ := path.Match(pattern, candidate); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package image
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setRegistryPolicy(t *testing.T, policy RegistryPolicy) {
+	t.Helper()
+
+	require.NoError(t, SetRegistryPolicy(policy))
+	t.Cleanup(func() {
+		require.NoError(t, SetRegistryPolicy(RegistryPolicy{}))
+	})
+}
+
+func TestCheckRegistryPermitted(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    RegistryPolicy
+		registry  string
+		permitted bool
+	}{
+		{
+			name:      "empty policy permits everything",
+			registry:  "registry.example.com",
+			permitted: true,
+		},
+		{
+			name:      "allowed registry",
+			policy:    RegistryPolicy{Allowed: []string{"registry.example.com"}},
+			registry:  "registry.example.com",
+			permitted: true,
+		},
+		{
+			name:     "registry not in the allowlist",
+			policy:   RegistryPolicy{Allowed: []string{"registry.example.com"}},
+			registry: "other.example.com",
+		},
+		{
+			name:      "allowed by pattern",
+			policy:    RegistryPolicy{Allowed: []string{"*.example.com"}},
+			registry:  "Registry.Example.com",
+			permitted: true,
+		},
+		{
+			name:      "allowed with a port",
+			policy:    RegistryPolicy{Allowed: []string{"localhost:*"}},
+			registry:  "localhost:5000",
+			permitted: true,
+		},
+		{
+			name:     "denied registry",
+			policy:   RegistryPolicy{Denied: []string{"registry.example.com"}},
+			registry: "registry.example.com",
+		},
+		{
+			name:      "registry not in the denylist",
+			policy:    RegistryPolicy{Denied: []string{"registry.example.com"}},
+			registry:  "other.example.com",
+			permitted: true,
+		},
+		{
+			name: "deny takes precedence over allow",
+			policy: RegistryPolicy{
+				Allowed: []string{"*.example.com"},
+				Denied:  []string{"untrusted.example.com"},
+			},
+			registry: "untrusted.example.com",
+		},
+		{
+			name: "allowed when another host is denied",
+			policy: RegistryPolicy{
+				Allowed: []string{"*.example.com"},
+				Denied:  []string{"untrusted.example.com"},
+			},
+			registry:  "trusted.example.com",
+			permitted: true,
+		},
+		{
+			name:     "docker hub aliases are denied together",
+			policy:   RegistryPolicy{Denied: []string{"docker.io"}},
+			registry: "index.docker.io",
+		},
+		{
+			name:      "docker hub aliases are allowed together",
+			policy:    RegistryPolicy{Allowed: []string{"index.docker.io"}},
+			registry:  "docker.io",
+			permitted: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setRegistryPolicy(t, test.policy)
+
+			err := CheckRegistryPermitted(test.registry)
+			if test.permitted {
+				assert.NoError(t, err)
+				return
+			}
+			assert.True(t, errors.Is(err, ErrRegistryNotPermitted), "unexpected error: %+v", err)
+		})
+	}
+}
+
+func TestSetRegistryPolicy_InvalidPattern(t *testing.T) {
+	assert.Error(t, SetRegistryPolicy(RegistryPolicy{Allowed: []string{"[registry"}}))
+	assert.Error(t, SetRegistryPolicy(RegistryPolicy{Denied: []string{" "}}))
+	assert.NoError(t, CheckRegistryPermitted("registry.example.com"))
+}
+
+func TestDetectSource_RegistryNotPermitted(t *testing.T) {
+	setRegistryPolicy(t, RegistryPolicy{Denied: []string{"docker.io"}})
+
+	_, _, err := detectSource(afero.NewMemMapFs(), "alpine:latest", newDetectSourceConfig())
+	assert.True(t, errors.Is(err, ErrRegistryNotPermitted), "unexpected error: %+v", err)
+
+	// the daemon is not consulted for references to registries that are not permitted
+	assert.Equal(t, UnknownSource, DetermineImagePullSource("alpine:latest"))
+}
@@ -0,0 +1,25 @@
+package image
+
+import (
+	"errors"
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// ErrNoLayers is returned by the registry and OCI providers when the image manifest has no layers and layers are
+// required (see RegistryOptions.RequireLayers).
+var ErrNoLayers = errors.New("image has no layers")
+
+// CheckLayersPresent returns an error wrapping ErrNoLayers if the manifest of the given image does not reference any
+// layers (for providers where layers are required, see RegistryOptions.RequireLayers).
+func CheckLayersPresent(img v1.Image) error {
+	manifest, err := img.Manifest()
+	if err != nil {
+		return fmt.Errorf("unable to read image manifest: %w", err)
+	}
+	if manifest == nil || len(manifest.Layers) == 0 {
+		return ErrNoLayers
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package image
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"testing"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRawLayerImage creates an (unread) image with a single layer made of the given raw (uncompressed) bytes.
+func newRawLayerImage(t *testing.T, raw []byte) v1.Image {
+	t.Helper()
+
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(raw)), nil
+	})
+	require.NoError(t, err)
+
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	require.NoError(t, err)
+	return img
+}
+
+func TestImage_Read_Scratch(t *testing.T) {
+	tests := []struct {
+		name   string
+		image  func(t *testing.T) v1.Image
+		layers int
+	}{
+		{
+			name: "no layers",
+			image: func(t *testing.T) v1.Image {
+				return empty.Image
+			},
+		},
+		{
+			name: "zero byte layer",
+			image: func(t *testing.T) v1.Image {
+				return newRawLayerImage(t, nil)
+			},
+			layers: 1,
+		},
+		{
+			name: "layer with only the end of archive marker",
+			image: func(t *testing.T) v1.Image {
+				return newRawLayerImage(t, testLayerTar(t, nil))
+			},
+			layers: 1,
+		},
+		{
+			name: "several empty layers",
+			image: func(t *testing.T) v1.Image {
+				return newTestV1Image(t, nil, nil, nil)
+			},
+			layers: 3,
+		},
+	}
+
+	for _, test := range tests {
+		for _, lowMemory := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s (low memory=%v)", test.name, lowMemory), func(t *testing.T) {
+				img := NewImage(test.image(t), t.TempDir())
+				if lowMemory {
+					img.overrideMetadata = append(img.overrideMetadata, func(i *Image) error {
+						i.lowMemory = true
+						return nil
+					})
+				}
+				require.NoError(t, img.Read())
+				require.Len(t, img.Layers, test.layers)
+
+				tree := img.SquashedTree()
+				require.NotNil(t, tree)
+				assert.Empty(t, tree.AllFiles())
+
+				_, err := img.FileContentsFromSquash("/")
+				assert.Error(t, err)
+				_, err = img.FileContentsFromSquash("/bin/app")
+				assert.Error(t, err)
+
+				refs, err := img.FilesByMIMETypeFromSquash("application/x-executable")
+				require.NoError(t, err)
+				assert.Empty(t, refs)
+
+				resolved, err := img.ResolveLinkByImageSquash(*file.NewFileReference("/bin/app"))
+				require.NoError(t, err)
+				assert.Nil(t, resolved)
+
+				entries, err := fs.ReadDir(img.FS(), ".")
+				require.NoError(t, err)
+				assert.Empty(t, entries)
+
+				_, err = img.OSRelease()
+				var notFound *ErrOSReleaseNotFound
+				assert.True(t, errors.As(err, &notFound))
+			})
+		}
+	}
+}
+
+func TestImage_Read_ScratchWithBinary(t *testing.T) {
+	// e.g. a static binary copied into a scratch image
+	img := newTestImage(t, []testEntry{testFile("/app", "binary")})
+
+	ok, ref, err := img.SquashedTree().File(file.Path("/app"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.NotNil(t, ref)
+
+	reader, err := img.FileContentsFromSquash("/app")
+	require.NoError(t, err)
+	contents, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "binary", string(contents))
+}
@@ -2,18 +2,16 @@ package image
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"io"
 	"os"
 	"path"
 	"strings"
 	"time"
 
 	"github.com/anchore/stereoscope/internal/docker"
+	"github.com/anchore/stereoscope/internal/log"
 	"github.com/anchore/stereoscope/pkg/file"
 	"github.com/google/go-containerregistry/pkg/name"
-	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/afero"
 )
 
@@ -37,6 +35,24 @@ var sourceStr = [...]string{
 	"OciRegistry",
 }
 
+// archiveMarkers are the paths within an archive that indicate a particular image source, listed in precedence order.
+// Note: newer docker daemons (v25+) "docker save" an OCI image layout along with the classic manifest.json; these
+// archives are treated as a docker-archive since the manifest.json carries the image tags. The precedence for archives
+// with both markers is: WithPreferredArchiveSource, then the extension hint (see WithExtensionHint), then this order.
+var archiveMarkers = []struct {
+	path   string
+	source Source
+}{
+	{
+		path:   "manifest.json",
+		source: DockerTarballSource,
+	},
+	{
+		path:   "oci-layout",
+		source: OciTarballSource,
+	},
+}
+
 var AllSources = []Source{
 	DockerTarballSource,
 	DockerDaemonSource,
@@ -56,33 +72,50 @@ func isRegistryReference(imageSpec string) bool {
 	return err == nil
 }
 
-// ParseSourceScheme attempts to resolve a concrete image source selection from a scheme in a user string.
+// sourceSchemes maps normalized scheme names (lowercase, without "-" or "_" separators) to an image source.
+var sourceSchemes = map[string]Source{
+	"dockerarchive": DockerTarballSource,
+	"dockertar":     DockerTarballSource,
+	"dockertarball": DockerTarballSource,
+	"docker":        DockerDaemonSource,
+	"dockerdaemon":  DockerDaemonSource,
+	"dockerengine":  DockerDaemonSource,
+	"ocidir":        OciDirectorySource,
+	"ocidirectory":  OciDirectorySource,
+	"ociarchive":    OciTarballSource,
+	"ocitar":        OciTarballSource,
+	"ocitarball":    OciTarballSource,
+	"ociregistry":   OciRegistrySource,
+	"registry":      OciRegistrySource,
+}
+
+// ParseSourceScheme attempts to resolve a concrete image source selection from a scheme in a user string. Parsing is
+// case-insensitive and words may be separated by "-", "_", or nothing at all (e.g. "docker-archive", "docker_archive",
+// and "DockerArchive" are equivalent). The accepted schemes (and aliases) are:
+//   - docker-archive, docker-tar, docker-tarball: DockerTarballSource
+//   - docker, docker-daemon, docker-engine: DockerDaemonSource
+//   - oci-dir, oci-directory: OciDirectorySource (optionally with a tag selector, see ParseOCIDirectoryLocation)
+//   - oci-archive, oci-tar, oci-tarball: OciTarballSource
+//   - oci-registry, registry: OciRegistrySource
+//
+// Note: ambiguous names (e.g. "tar", "archive", or "oci") are not accepted since they are also plausible image names.
 func ParseSourceScheme(source string) Source {
-	source = strings.ToLower(source)
-	switch source {
-	case "docker-archive":
-		return DockerTarballSource
-	case "docker":
-		return DockerDaemonSource
-	case "oci-dir":
-		return OciDirectorySource
-	case "oci-archive":
-		return OciTarballSource
-	case "oci-registry", "registry":
-		return OciRegistrySource
+	normalized := strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(strings.TrimSpace(source)))
+	if s, ok := sourceSchemes[normalized]; ok {
+		return s
 	}
 	return UnknownSource
 }
 
 // DetectSource takes a user string and determines the image source (e.g. the docker daemon, a tar file, etc.) returning the string subset representing the image (or nothing if it is unknown).
 // note: parsing is done relative to the given string and environmental evidence (i.e. the given filesystem) to determine the actual source.
-func DetectSource(userInput string) (Source, string, error) {
-	return detectSource(afero.NewOsFs(), userInput)
+func DetectSource(userInput string, options ...DetectSourceOption) (Source, string, error) {
+	return detectSource(afero.NewOsFs(), userInput, newDetectSourceConfig(options...))
 }
 
 // DetectSource takes a user string and determines the image source (e.g. the docker daemon, a tar file, etc.) returning the string subset representing the image (or nothing if it is unknown).
 // note: parsing is done relative to the given string and environmental evidence (i.e. the given filesystem) to determine the actual source.
-func detectSource(fs afero.Fs, userInput string) (Source, string, error) {
+func detectSource(fs afero.Fs, userInput string, cfg detectSourceConfig) (Source, string, error) {
 	candidates := strings.SplitN(userInput, SchemeSeparator, 2)
 
 	var source Source
@@ -92,7 +125,7 @@ func detectSource(fs afero.Fs, userInput string) (Source, string, error) {
 	switch len(candidates) {
 	case 1:
 		// no source hint has been provided, detect one
-		source, err = detectSourceFromPath(fs, location)
+		source, err = detectSourceFromPath(fs, location, cfg)
 		if err != nil {
 			return UnknownSource, "", err
 		}
@@ -108,12 +141,17 @@ func detectSource(fs afero.Fs, userInput string) (Source, string, error) {
 	switch source {
 	case OciDirectorySource, OciTarballSource, DockerTarballSource:
 		// since the scheme was explicitly given, that means that home dir tilde expansion would not have been done by the shell (so we have to)
-		location, err = homedir.Expand(location)
+		location, err = cfg.expandPath(location)
 		if err != nil {
-			return UnknownSource, "", fmt.Errorf("unable to expand potential home dir expression: %w", err)
+			return UnknownSource, "", err
 		}
 	case UnknownSource:
 		// Ignore any source hint since the source is still unknown. See if this could be a Docker image.
+		if isRegistryReference(userInput) {
+			if err := checkReferencePermitted(userInput); err != nil {
+				return UnknownSource, "", err
+			}
+		}
 		if imagePullSource := DetermineImagePullSource(userInput); imagePullSource != UnknownSource {
 			return imagePullSource, userInput, nil
 		}
@@ -129,12 +167,24 @@ func detectSource(fs afero.Fs, userInput string) (Source, string, error) {
 // determines a Source to use to pull the image. If the input doesn't specify an
 // image reference (i.e. an image that can be _pulled_), UnknownSource is
 // returned. Otherwise, if the Docker daemon is available, DockerDaemonSource is
-// returned, and if not, OciRegistrySource is returned.
+// returned, and if not, OciRegistrySource is returned. References to registries
+// that are not permitted by the registry policy (see SetRegistryPolicy) are
+// UnknownSource (without contacting the Docker daemon). The daemon is pinged on
+// every call, however the docker client is created once and shared within the
+// process, retaining the DOCKER_HOST it was created with and the API version it
+// negotiated with the daemon (where a daemon that was unreachable at the time
+// leaves the client at the oldest API version). Processes where the daemon may
+// come and go should call ResetDaemonState when availability changes.
 func DetermineImagePullSource(userInput string) Source {
 	if !isRegistryReference(userInput) {
 		return UnknownSource
 	}
 
+	if err := checkReferencePermitted(userInput); err != nil {
+		log.Debugf("unable to pull image=%q: %+v", userInput, err)
+		return UnknownSource
+	}
+
 	// verify that the Docker daemon is accessible before assuming we can use it
 	dockerClient, err := docker.GetClient()
 	if err == nil {
@@ -152,16 +202,29 @@ func DetermineImagePullSource(userInput string) Source {
 	return OciRegistrySource
 }
 
+// ResetDaemonState closes the docker client shared by the daemon provider and source detection within the process
+// (releasing its connections), along with the daemon host and API version it learned. The client is created on first
+// use (e.g. DetermineImagePullSource or the daemon provider) and kept until this is called, thus the next use creates a
+// new client that probes the docker daemon afresh. This should be called when the daemon availability may have changed
+// (e.g. after a Docker Desktop restart), or by long-running processes after a batch of work to avoid accumulating idle
+// connections, but not while any image is being fetched from the docker daemon.
+func ResetDaemonState() error {
+	if err := docker.Close(); err != nil {
+		return fmt.Errorf("unable to close docker client: %w", err)
+	}
+	return nil
+}
+
 // DetectSourceFromPath will distinguish between a oci-layout dir, oci-archive, and a docker-archive for a given filesystem.
-func DetectSourceFromPath(imgPath string) (Source, error) {
-	return detectSourceFromPath(afero.NewOsFs(), imgPath)
+func DetectSourceFromPath(imgPath string, options ...DetectSourceOption) (Source, error) {
+	return detectSourceFromPath(afero.NewOsFs(), imgPath, newDetectSourceConfig(options...))
 }
 
 // detectSourceFromPath will distinguish between a oci-layout dir, oci-archive, and a docker-archive for a given filesystem.
-func detectSourceFromPath(fs afero.Fs, imgPath string) (Source, error) {
-	imgPath, err := homedir.Expand(imgPath)
+func detectSourceFromPath(fs afero.Fs, imgPath string, cfg detectSourceConfig) (Source, error) {
+	imgPath, err := cfg.expandPath(imgPath)
 	if err != nil {
-		return UnknownSource, fmt.Errorf("unable to expand potential home dir expression: %w", err)
+		return UnknownSource, err
 	}
 
 	pathStat, err := fs.Stat(imgPath)
@@ -174,6 +237,9 @@ func detectSourceFromPath(fs afero.Fs, imgPath string) (Source, error) {
 	if pathStat.IsDir() {
 		//  check for oci-directory
 		if _, err := fs.Stat(path.Join(imgPath, "oci-layout")); !os.IsNotExist(err) {
+			if err := checkOCIDirectoryIsImage(fs, imgPath); err != nil {
+				return UnknownSource, err
+			}
 			return OciDirectorySource, nil
 		}
 
@@ -182,40 +248,156 @@ func detectSourceFromPath(fs afero.Fs, imgPath string) (Source, error) {
 	}
 
 	// assume this is an archive...
-	archive, err := fs.Open(imgPath)
+	var preferred Source
+	if cfg.useExtensionHint {
+		preferred = sourceFromExtension(imgPath)
+	}
+	if cfg.preferredArchiveSource != UnknownSource {
+		preferred = cfg.preferredArchiveSource
+	}
+
+	source, err := detectSourceFromArchive(fs, imgPath, preferred, cfg)
+	if err != nil || source != OciTarballSource {
+		return source, err
+	}
+
+	// OCI archives may hold other kinds of artifacts (e.g. Helm charts), which should not be mistaken for an image
+	if err := checkOCIArchiveIsImage(fs, imgPath, cfg); err != nil {
+		return UnknownSource, err
+	}
+	return source, nil
+}
+
+// detectSourceFromArchive inspects the archive contents for files that are indicative of a docker-archive or an
+// oci-archive. The archive is read at most once; if the preferred source marker is found the scan stops early,
+// otherwise the first matching marker (in archiveMarkers order) is used. The scan is bounded by the configured
+// header limit and timeout, after which the markers found so far are used.
+func detectSourceFromArchive(fs afero.Fs, imgPath string, preferred Source, cfg detectSourceConfig) (Source, error) {
+	f, err := fs.Open(imgPath)
 	if err != nil {
 		return UnknownSource, fmt.Errorf("unable to open archive=%s: %w", imgPath, err)
 	}
 
-	for _, pair := range []struct {
-		path   string
-		source Source
-	}{
-		{
-			"manifest.json",
-			DockerTarballSource,
-		},
-		{
-			"oci-layout",
-			OciTarballSource,
-		},
-	} {
-		if _, err = archive.Seek(0, io.SeekStart); err != nil {
-			return UnknownSource, fmt.Errorf("unable to seek archive=%s: %w", imgPath, err)
+	archive, err := file.NewArchiveReader(f)
+	if err != nil {
+		_ = f.Close()
+		return UnknownSource, fmt.Errorf("unable to read archive=%s: %w", imgPath, err)
+	}
+	defer archive.Close()
+
+	var deadline time.Time
+	if cfg.archiveScanTimeout > 0 {
+		deadline = time.Now().Add(cfg.archiveScanTimeout)
+	}
+
+	found := make(map[Source]bool)
+	visitor := func(entry file.TarFileEntry) error {
+		if cfg.maxArchiveHeaders > 0 && entry.Sequence >= int64(cfg.maxArchiveHeaders) {
+			log.Warnf("stopping source detection for archive=%q after the limit of %d headers (see WithMaxArchiveHeaders)", imgPath, cfg.maxArchiveHeaders)
+			return file.ErrTarStopIteration
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			log.Debugf("stopping source detection for archive=%q after %s", imgPath, cfg.archiveScanTimeout)
+			return file.ErrTarStopIteration
+		}
+
+		for _, marker := range archiveMarkers {
+			if entry.Header.Name != marker.path {
+				continue
+			}
+			found[marker.source] = true
+			if marker.source == preferred || preferred == UnknownSource && marker.source == archiveMarkers[0].source {
+				// there is no need to continue scanning, nothing else found can change the result
+				return file.ErrTarStopIteration
+			}
+		}
+		return nil
+	}
+
+	if err := file.IterateTar(archive, visitor); err != nil {
+		// there is something wrong with the tar reading process
+		return UnknownSource, err
+	}
+
+	source := UnknownSource
+	if found[preferred] {
+		source = preferred
+	} else {
+		for _, marker := range archiveMarkers {
+			if found[marker.source] {
+				source = marker.source
+				break
+			}
 		}
+	}
 
-		var fileErr *file.ErrFileNotFound
-		_, err = file.ReaderFromTar(archive, pair.path)
-		if err == nil {
-			return pair.source, nil
-		} else if !errors.As(err, &fileErr) {
-			// short-circuit, there is something wrong with the tar reading process
-			return UnknownSource, err
+	// note: this is not a warning, since every "docker save" on docker v25+ writes both markers
+	if len(found) > 1 && cfg.preferredArchiveSource == UnknownSource {
+		log.Debugf("archive=%q is both a docker-archive and an oci-archive, using it as a %s (a preferred archive source can be configured)", imgPath, source)
+	}
+	return source, nil
+}
+
+// IsImageArchive indicates if the file at the given path is an image archive of any supported kind (a docker-archive
+// or an oci-archive, either of which may be gzip compressed). Unlike DetectSourceFromPath the archive is only scanned
+// until the first image marker is found, regardless of the kind of archive. Directories and files that are not tar
+// archives are not image archives (and do not result in an error).
+func IsImageArchive(imgPath string) (bool, error) {
+	return isImageArchive(afero.NewOsFs(), imgPath, newDetectSourceConfig())
+}
+
+func isImageArchive(fs afero.Fs, imgPath string, cfg detectSourceConfig) (bool, error) {
+	info, err := fs.Stat(imgPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to open path=%s: %w", imgPath, err)
+	}
+	if !info.Mode().IsRegular() {
+		return false, nil
+	}
+
+	f, err := fs.Open(imgPath)
+	if err != nil {
+		return false, fmt.Errorf("unable to open archive=%s: %w", imgPath, err)
+	}
+
+	archive, err := file.NewArchiveReader(f)
+	if err != nil {
+		_ = f.Close()
+		log.Debugf("unable to read archive=%q: %+v", imgPath, err)
+		return false, nil
+	}
+	defer archive.Close()
+
+	var found bool
+	visitor := func(entry file.TarFileEntry) error {
+		if cfg.maxArchiveHeaders > 0 && entry.Sequence >= int64(cfg.maxArchiveHeaders) {
+			log.Warnf("stopping the search for archive markers within archive=%q after the limit of %d headers (see WithMaxArchiveHeaders)", imgPath, cfg.maxArchiveHeaders)
+			return file.ErrTarStopIteration
 		}
+		for _, marker := range archiveMarkers {
+			if entry.Header.Name == marker.path {
+				found = true
+				return file.ErrTarStopIteration
+			}
+		}
+		return nil
+	}
+
+	if err := file.IterateTar(archive, visitor); err != nil {
+		// this is not a (readable) tar
+		log.Debugf("unable to read archive=%q: %+v", imgPath, err)
+		return false, nil
 	}
+	return found, nil
+}
 
-	// there are no other archive-based formats supported
-	return UnknownSource, nil
+// sourceFromExtension returns the source implied by the archive file extension, or UnknownSource if the extension
+// is ambiguous (e.g. ".tar" could be either a docker-archive or an oci-archive) or not recognized.
+func sourceFromExtension(imgPath string) Source {
+	if strings.HasSuffix(strings.ToLower(imgPath), ".oci") {
+		return OciTarballSource
+	}
+	return UnknownSource
 }
 
 // String returns a convenient display string for the source.
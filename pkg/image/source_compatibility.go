@@ -0,0 +1,71 @@
+package image
+
+import "fmt"
+
+// ErrSourceConflict is returned from CheckSourceCompatibility when an explicitly requested source cannot be used for
+// the given user input.
+type ErrSourceConflict struct {
+	Forced   Source
+	Detected Source
+	Input    string
+	Reason   string
+}
+
+func (e *ErrSourceConflict) Error() string {
+	return fmt.Sprintf("requested source=%s conflicts with detected source=%s for %q: %s", e.Forced, e.Detected, e.Input, e.Reason)
+}
+
+// isPullSource indicates if the source refers to an image by reference (as opposed to a path on the local filesystem).
+func (t Source) isPullSource() bool {
+	return t == DockerDaemonSource || t == OciRegistrySource
+}
+
+// CheckSourceCompatibility reports if an explicitly requested (forced) source can be used for the given user input,
+// where the detected source and location are the results of DetectSource for the same input. A nil error indicates
+// that the sources are compatible, otherwise an ErrSourceConflict describes the conflict. The sources are compatible
+// when:
+//   - no source is forced (UnknownSource)
+//   - the sources are the same
+//   - both sources pull an image by reference (the docker daemon or a registry), since the detected pull source only
+//     reflects the availability of the docker daemon
+//   - the forced source is a file-based source and nothing could be detected (leaving the provider to raise any errors)
+//
+// Note: a forced pull source is always in conflict with input that is not a valid image reference.
+func CheckSourceCompatibility(forced, detected Source, location string) error {
+	if forced == UnknownSource || forced == detected {
+		return nil
+	}
+
+	if int(forced) >= len(sourceStr) {
+		return fmt.Errorf("unknown source: %d", forced)
+	}
+
+	conflict := func(reason string) error {
+		return &ErrSourceConflict{
+			Forced:   forced,
+			Detected: detected,
+			Input:    location,
+			Reason:   reason,
+		}
+	}
+
+	if forced.isPullSource() {
+		switch {
+		case !isRegistryReference(location):
+			return conflict("the input is not a valid image reference")
+		case detected == UnknownSource || detected.isPullSource():
+			return nil
+		default:
+			return conflict("the input refers to an image on the local filesystem")
+		}
+	}
+
+	switch {
+	case detected == UnknownSource:
+		return nil
+	case detected.isPullSource():
+		return conflict("the input is not a path to an existing file or directory")
+	default:
+		return conflict("the input was detected as a different image source")
+	}
+}
@@ -0,0 +1,100 @@
+package image
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSourceCompatibility(t *testing.T) {
+	tests := []struct {
+		name         string
+		forced       Source
+		detected     Source
+		location     string
+		wantConflict bool
+	}{
+		{
+			name:     "nothing forced",
+			forced:   UnknownSource,
+			detected: DockerTarballSource,
+			location: "/path/to/image.tar",
+		},
+		{
+			name:     "same source",
+			forced:   OciDirectorySource,
+			detected: OciDirectorySource,
+			location: "/path/to/layout",
+		},
+		{
+			name:     "registry forced on daemon detection",
+			forced:   OciRegistrySource,
+			detected: DockerDaemonSource,
+			location: "alpine:latest",
+		},
+		{
+			name:     "daemon forced on registry detection",
+			forced:   DockerDaemonSource,
+			detected: OciRegistrySource,
+			location: "anchore/syft@sha256:dd65856a5e8d4bd7ab4aa1e3b0a3b5b4d1a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5",
+		},
+		{
+			name:     "registry forced with nothing detected",
+			forced:   OciRegistrySource,
+			detected: UnknownSource,
+			location: "alpine",
+		},
+		{
+			name:         "registry forced on a local archive",
+			forced:       OciRegistrySource,
+			detected:     DockerTarballSource,
+			location:     "image.tar",
+			wantConflict: true,
+		},
+		{
+			name:         "registry forced on input that is not a reference",
+			forced:       OciRegistrySource,
+			detected:     UnknownSource,
+			location:     "/path/to/Image.tar",
+			wantConflict: true,
+		},
+		{
+			name:     "archive forced with nothing detected",
+			forced:   DockerTarballSource,
+			detected: UnknownSource,
+			location: "/path/to/image.tar",
+		},
+		{
+			name:         "archive forced on an image reference",
+			forced:       DockerTarballSource,
+			detected:     OciRegistrySource,
+			location:     "alpine:latest",
+			wantConflict: true,
+		},
+		{
+			name:         "mismatched file sources",
+			forced:       OciDirectorySource,
+			detected:     OciTarballSource,
+			location:     "/path/to/image.tar",
+			wantConflict: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := CheckSourceCompatibility(test.forced, test.detected, test.location)
+			if !test.wantConflict {
+				assert.NoError(t, err)
+				return
+			}
+
+			var conflict *ErrSourceConflict
+			require.True(t, errors.As(err, &conflict), "expected a conflict error, got: %+v", err)
+			assert.Equal(t, test.forced, conflict.Forced)
+			assert.Equal(t, test.detected, conflict.Detected)
+			assert.Equal(t, test.location, conflict.Input)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package image
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/mitchellh/go-homedir"
+)
+
+// DetectSourceOption is a functional option that tunes how DetectSource and DetectSourceFromPath behave.
+type DetectSourceOption func(*detectSourceConfig)
+
+type detectSourceConfig struct {
+	// useExtensionHint indicates that the archive file extension should be used to decide which archive markers
+	// to prefer when inspecting the archive contents.
+	useExtensionHint bool
+	// maxArchiveHeaders is the number of tar headers to inspect before giving up (<= 0 is unbounded).
+	maxArchiveHeaders int
+	// archiveScanTimeout is how long to inspect the archive for before giving up (<= 0 is unbounded).
+	archiveScanTimeout time.Duration
+	// disableTildeExpansion indicates that paths should be taken literally (e.g. "~/image.tar" is not expanded).
+	disableTildeExpansion bool
+	// preferredArchiveSource is the source used when an archive has the markers of multiple sources (UnknownSource
+	// when not configured, in which case the extension hint and then the archiveMarkers precedence apply).
+	preferredArchiveSource Source
+}
+
+func newDetectSourceConfig(options ...DetectSourceOption) detectSourceConfig {
+	var cfg detectSourceConfig
+	for _, option := range options {
+		option(&cfg)
+	}
+	return cfg
+}
+
+// WithExtensionHint allows the archive file extension to be used as a hint during detection (e.g. ".oci" implies an
+// oci-archive). The hint only decides which evidence is looked for first; the archive contents are still inspected
+// and are always the tiebreaker when the extension is ambiguous (e.g. ".tar", ".tgz") or wrong.
+func WithExtensionHint() DetectSourceOption {
+	return func(cfg *detectSourceConfig) {
+		cfg.useExtensionHint = true
+	}
+}
+
+// WithPreferredArchiveSource decides the source of archives that have the markers of both a docker-archive
+// (manifest.json) and an oci-archive (oci-layout), as with archives from "docker save" on docker v25+ or from tools
+// that emit both. By default such archives are a docker-archive (since the manifest.json carries the image tags). The
+// preference takes precedence over the extension hint, though archives with only one of the markers are unaffected.
+// Only DockerTarballSource and OciTarballSource are meaningful, other sources are ignored.
+func WithPreferredArchiveSource(source Source) DetectSourceOption {
+	return func(cfg *detectSourceConfig) {
+		switch source {
+		case DockerTarballSource, OciTarballSource:
+			cfg.preferredArchiveSource = source
+		default:
+			log.Warnf("ignoring preferred archive source=%q (expected %q or %q)", source, DockerTarballSource, OciTarballSource)
+		}
+	}
+}
+
+// WithMaxArchiveHeaders limits the number of tar headers inspected when looking for evidence of the archive format
+// (unbounded by default). If the limit is reached the archive is treated as an unknown source (which is logged). A
+// limit of zero or less inspects the entire archive. Note: a docker-archive places the manifest.json after all layers
+// (~3 entries per layer), so the limit must be large enough to account for images with many layers.
+func WithMaxArchiveHeaders(limit int) DetectSourceOption {
+	return func(cfg *detectSourceConfig) {
+		cfg.maxArchiveHeaders = limit
+	}
+}
+
+// WithArchiveScanTimeout limits how long the archive is inspected for when looking for evidence of the archive format
+// (unbounded by default). If the timeout elapses the archive is treated as an unknown source. Note: the timeout is
+// checked between tar entries.
+func WithArchiveScanTimeout(timeout time.Duration) DetectSourceOption {
+	return func(cfg *detectSourceConfig) {
+		cfg.archiveScanTimeout = timeout
+	}
+}
+
+// WithoutTildeExpansion takes paths literally instead of expanding a leading "~" to the home directory (which fails
+// when the home directory cannot be determined, e.g. in minimal environments without $HOME).
+func WithoutTildeExpansion() DetectSourceOption {
+	return func(cfg *detectSourceConfig) {
+		cfg.disableTildeExpansion = true
+	}
+}
+
+// expandPath expands a leading "~" in the given path to the home directory, unless disabled by the config.
+func (cfg detectSourceConfig) expandPath(p string) (string, error) {
+	if cfg.disableTildeExpansion {
+		return p, nil
+	}
+	expanded, err := homedir.Expand(p)
+	if err != nil {
+		return "", fmt.Errorf("unable to expand potential home dir expression: %w", err)
+	}
+	return expanded, nil
+}
@@ -2,13 +2,18 @@ package image
 
 import (
 	"archive/tar"
+	"compress/gzip"
+	"fmt"
 	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"io"
 	"os"
 	"path"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestDetectSource(t *testing.T) {
@@ -136,7 +141,7 @@ func TestDetectSource(t *testing.T) {
 				getDummyTar(t, fs.(*afero.MemMapFs), c.tarPath, c.tarPaths...)
 			}
 
-			source, location, err := detectSource(fs, c.input)
+			source, location, err := detectSource(fs, c.input, detectSourceConfig{})
 			if err != nil {
 				t.Fatalf("unexecpted error: %+v", err)
 			}
@@ -157,6 +162,57 @@ func TestDetectSource(t *testing.T) {
 	}
 }
 
+func TestDetectSource_WithoutTildeExpansion(t *testing.T) {
+	tests := []struct {
+		name             string
+		input            string
+		options          []DetectSourceOption
+		source           Source
+		expectedLocation string
+		expectedErr      bool
+	}{
+		{
+			name:             "literal path",
+			input:            "~someone/image.tar",
+			options:          []DetectSourceOption{WithoutTildeExpansion()},
+			source:           OciTarballSource,
+			expectedLocation: "~someone/image.tar",
+		},
+		{
+			name:             "literal path with explicit scheme",
+			input:            "oci-archive:~someone/image.tar",
+			options:          []DetectSourceOption{WithoutTildeExpansion()},
+			source:           OciTarballSource,
+			expectedLocation: "~someone/image.tar",
+		},
+		{
+			name:        "expansion fails without the option",
+			input:       "oci-archive:~someone/image.tar",
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			// note: the archive must exist at the literal (unexpanded) path
+			tarPath := getDummyTar(t, fs.(*afero.MemMapFs), "image.tar", "oci-layout")
+			if err := fs.Rename(tarPath, "~someone/image.tar"); err != nil {
+				t.Fatalf("unable to rename dummy tar: %+v", err)
+			}
+
+			source, location, err := detectSource(fs, test.input, newDetectSourceConfig(test.options...))
+			if test.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.source, source)
+			assert.Equal(t, test.expectedLocation, location)
+		})
+	}
+}
+
 func TestParseScheme(t *testing.T) {
 	cases := []struct {
 		source   string
@@ -182,14 +238,12 @@ func TestParseScheme(t *testing.T) {
 			expected: DockerTarballSource,
 		},
 		{
-			// regression for unsupported behavior
 			source:   "docker-tar",
-			expected: UnknownSource,
+			expected: DockerTarballSource,
 		},
 		{
-			// regression for unsupported behavior
 			source:   "docker-tarball",
-			expected: UnknownSource,
+			expected: DockerTarballSource,
 		},
 		{
 			source:   "Docker",
@@ -204,28 +258,24 @@ func TestParseScheme(t *testing.T) {
 			expected: DockerDaemonSource,
 		},
 		{
-			// regression for unsupported behavior
 			source:   "docker-daemon",
-			expected: UnknownSource,
+			expected: DockerDaemonSource,
 		},
 		{
-			// regression for unsupported behavior
 			source:   "docker-engine",
-			expected: UnknownSource,
+			expected: DockerDaemonSource,
 		},
 		{
 			source:   "oci-archive",
 			expected: OciTarballSource,
 		},
 		{
-			// regression for unsupported behavior
 			source:   "oci-tar",
-			expected: UnknownSource,
+			expected: OciTarballSource,
 		},
 		{
-			// regression for unsupported behavior
 			source:   "oci-tarball",
-			expected: UnknownSource,
+			expected: OciTarballSource,
 		},
 		{
 			// regression for unsupported behavior
@@ -237,9 +287,8 @@ func TestParseScheme(t *testing.T) {
 			expected: OciDirectorySource,
 		},
 		{
-			// regression for unsupported behavior
 			source:   "oci-directory",
-			expected: UnknownSource,
+			expected: OciDirectorySource,
 		},
 		{
 			source:   "",
@@ -258,10 +307,52 @@ func TestParseScheme(t *testing.T) {
 	}
 }
 
+func TestParseScheme_Aliases(t *testing.T) {
+	tests := []struct {
+		expected Source
+		aliases  []string
+	}{
+		{
+			expected: DockerTarballSource,
+			aliases:  []string{"docker-archive", "docker_archive", "dockerarchive", "DockerArchive", "docker-tar", "docker_tarball"},
+		},
+		{
+			expected: DockerDaemonSource,
+			aliases:  []string{"docker", "docker-daemon", "docker_daemon", "dockerdaemon", "Docker-Engine"},
+		},
+		{
+			expected: OciDirectorySource,
+			aliases:  []string{"oci-dir", "oci_dir", "ocidir", "OCI-Directory"},
+		},
+		{
+			expected: OciTarballSource,
+			aliases:  []string{"oci-archive", "oci_archive", "ociarchive", "oci-tar", "OCI_Tarball"},
+		},
+		{
+			expected: OciRegistrySource,
+			aliases:  []string{"registry", "oci-registry", "oci_registry", "ociregistry", " Registry "},
+		},
+		{
+			expected: UnknownSource,
+			aliases:  []string{"", "tar", "archive", "oci", "dockerx", "docker:archive", "something"},
+		},
+	}
+
+	for _, test := range tests {
+		for _, alias := range test.aliases {
+			t.Run(alias, func(t *testing.T) {
+				assert.Equal(t, test.expected, ParseSourceScheme(alias))
+			})
+		}
+	}
+}
+
 func TestDetectSourceFromPath(t *testing.T) {
 	tests := []struct {
 		name           string
 		paths          []string
+		archiveName    string
+		options        []DetectSourceOption
 		expectedSource Source
 		sourceType     string
 		expectedErr    bool
@@ -296,6 +387,115 @@ func TestDetectSourceFromPath(t *testing.T) {
 			sourceType:     "tar",
 			expectedSource: DockerTarballSource,
 		},
+		{
+			name:           "docker and oci markers without hint",
+			paths:          []string{"oci-layout", "manifest.json"},
+			archiveName:    "image.oci",
+			sourceType:     "tar",
+			expectedSource: DockerTarballSource,
+		},
+		{
+			name:           "docker and oci markers with oci extension hint",
+			paths:          []string{"manifest.json", "oci-layout"},
+			archiveName:    "image.oci",
+			options:        []DetectSourceOption{WithExtensionHint()},
+			sourceType:     "tar",
+			expectedSource: OciTarballSource,
+		},
+		{
+			name:           "docker and oci markers with oci preference",
+			paths:          []string{"manifest.json", "oci-layout"},
+			options:        []DetectSourceOption{WithPreferredArchiveSource(OciTarballSource)},
+			sourceType:     "tar",
+			expectedSource: OciTarballSource,
+		},
+		{
+			name:           "docker and oci markers with docker preference overriding the extension hint",
+			paths:          []string{"oci-layout", "manifest.json"},
+			archiveName:    "image.oci",
+			options:        []DetectSourceOption{WithExtensionHint(), WithPreferredArchiveSource(DockerTarballSource)},
+			sourceType:     "tar",
+			expectedSource: DockerTarballSource,
+		},
+		{
+			name:           "oci preference with only a docker marker",
+			paths:          []string{"manifest.json"},
+			options:        []DetectSourceOption{WithPreferredArchiveSource(OciTarballSource)},
+			sourceType:     "tar",
+			expectedSource: DockerTarballSource,
+		},
+		{
+			name:           "wrong oci extension hint falls back to content",
+			paths:          []string{"manifest.json"},
+			archiveName:    "image.oci",
+			options:        []DetectSourceOption{WithExtensionHint()},
+			sourceType:     "tar",
+			expectedSource: DockerTarballSource,
+		},
+		{
+			name:           "ambiguous tar extension hint inspects content",
+			paths:          []string{"oci-layout"},
+			archiveName:    "image.tar",
+			options:        []DetectSourceOption{WithExtensionHint()},
+			sourceType:     "tar",
+			expectedSource: OciTarballSource,
+		},
+		{
+			name:           "gzipped docker tar path",
+			paths:          []string{"manifest.json"},
+			archiveName:    "image.tar.gz",
+			sourceType:     "tgz",
+			expectedSource: DockerTarballSource,
+		},
+		{
+			name:           "gzipped oci tar path with hint",
+			paths:          []string{"oci-layout"},
+			archiveName:    "image.tgz",
+			options:        []DetectSourceOption{WithExtensionHint()},
+			sourceType:     "tgz",
+			expectedSource: OciTarballSource,
+		},
+		{
+			name:           "marker beyond header limit",
+			paths:          []string{"layer-1/layer.tar", "layer-2/layer.tar", "manifest.json"},
+			options:        []DetectSourceOption{WithMaxArchiveHeaders(2)},
+			sourceType:     "tar",
+			expectedSource: UnknownSource,
+		},
+		{
+			name:           "marker within header limit",
+			paths:          []string{"layer-1/layer.tar", "manifest.json"},
+			options:        []DetectSourceOption{WithMaxArchiveHeaders(2)},
+			sourceType:     "tar",
+			expectedSource: DockerTarballSource,
+		},
+		{
+			name:           "marker after many headers is found by default",
+			paths:          append(layerPaths(6000), "manifest.json"),
+			sourceType:     "tar",
+			expectedSource: DockerTarballSource,
+		},
+		{
+			name:           "unbounded header limit",
+			paths:          []string{"layer-1/layer.tar", "layer-2/layer.tar", "manifest.json"},
+			options:        []DetectSourceOption{WithMaxArchiveHeaders(0)},
+			sourceType:     "tar",
+			expectedSource: DockerTarballSource,
+		},
+		{
+			name:           "markers found before the header limit are used",
+			paths:          []string{"oci-layout", "blobs/sha256/a", "manifest.json"},
+			options:        []DetectSourceOption{WithMaxArchiveHeaders(2)},
+			sourceType:     "tar",
+			expectedSource: OciTarballSource,
+		},
+		{
+			name:           "elapsed scan timeout",
+			paths:          []string{"layer-1/layer.tar", "manifest.json"},
+			options:        []DetectSourceOption{WithArchiveScanTimeout(time.Nanosecond)},
+			sourceType:     "tar",
+			expectedSource: UnknownSource,
+		},
 		{
 			name:           "no dir paths",
 			paths:          []string{},
@@ -325,10 +525,16 @@ func TestDetectSourceFromPath(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			fs := afero.NewMemMapFs()
+			archiveName := test.archiveName
+			if archiveName == "" {
+				archiveName = "image.tar"
+			}
 			var testPath string
 			switch test.sourceType {
 			case "tar":
-				testPath = getDummyTar(t, fs.(*afero.MemMapFs), "image.tar", test.paths...)
+				testPath = getDummyTar(t, fs.(*afero.MemMapFs), archiveName, test.paths...)
+			case "tgz":
+				testPath = getDummyGzipTar(t, fs.(*afero.MemMapFs), archiveName, test.paths...)
 			case "dir":
 				testPath = getDummyPath(t, fs.(*afero.MemMapFs), "image", test.paths...)
 			case "none":
@@ -336,7 +542,7 @@ func TestDetectSourceFromPath(t *testing.T) {
 			default:
 				t.Fatalf("unknown source type: %+v", test.sourceType)
 			}
-			actual, err := detectSourceFromPath(fs, testPath)
+			actual, err := detectSourceFromPath(fs, testPath, newDetectSourceConfig(test.options...))
 			if err != nil && !test.expectedErr {
 				t.Fatalf("unexpected error: %+v", err)
 			} else if err == nil && test.expectedErr {
@@ -349,6 +555,44 @@ func TestDetectSourceFromPath(t *testing.T) {
 	}
 }
 
+func TestDetectSourceFromPath_DockerAndOCIArchive(t *testing.T) {
+	// an archive from "docker save" (v25+) holding both a manifest.json and an OCI image layout
+	const fixture = "test-fixtures/docker-and-oci-archive.tar"
+
+	tests := []struct {
+		name     string
+		options  []DetectSourceOption
+		expected Source
+	}{
+		{
+			name:     "docker-archive by default",
+			expected: DockerTarballSource,
+		},
+		{
+			name:     "oci-archive when preferred",
+			options:  []DetectSourceOption{WithPreferredArchiveSource(OciTarballSource)},
+			expected: OciTarballSource,
+		},
+		{
+			name:     "docker-archive when preferred",
+			options:  []DetectSourceOption{WithPreferredArchiveSource(DockerTarballSource)},
+			expected: DockerTarballSource,
+		},
+		{
+			name:     "unsupported preference is ignored",
+			options:  []DetectSourceOption{WithPreferredArchiveSource(OciDirectorySource)},
+			expected: DockerTarballSource,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := DetectSourceFromPath(fixture, test.options...)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
 // note: we do not pass the afero.Fs interface since we are writing out to the root of the filesystem, something we never want to do with an OS filesystem. This type is more explicit.
 func getDummyTar(t *testing.T, fs *afero.MemMapFs, archivePath string, paths ...string) string {
 	t.Helper()
@@ -386,6 +630,32 @@ func getDummyTar(t *testing.T, fs *afero.MemMapFs, archivePath string, paths ...
 	return archivePath
 }
 
+// note: we do not pass the afero.Fs interface since we are writing out to the root of the filesystem, something we never want to do with an OS filesystem. This type is more explicit.
+func getDummyGzipTar(t *testing.T, fs *afero.MemMapFs, archivePath string, paths ...string) string {
+	t.Helper()
+
+	tarPath := getDummyTar(t, fs, archivePath+".uncompressed", paths...)
+	contents, err := afero.ReadFile(fs, tarPath)
+	if err != nil {
+		t.Fatalf("unable to read dummy tar: %+v", err)
+	}
+
+	testFile, err := fs.Create(archivePath)
+	if err != nil {
+		t.Fatalf("failed to create dummy gzip tar: %+v", err)
+	}
+	defer testFile.Close()
+
+	gzipWriter := gzip.NewWriter(testFile)
+	defer gzipWriter.Close()
+
+	if _, err = gzipWriter.Write(contents); err != nil {
+		t.Fatalf("could not write dummy gzip tar: %+v", err)
+	}
+
+	return archivePath
+}
+
 // note: we do not pass the afero.Fs interface since we are writing out to the root of the filesystem, something we never want to do with an OS filesystem. This type is more explicit.
 func getDummyPath(t *testing.T, fs *afero.MemMapFs, dirPath string, paths ...string) string {
 	t.Helper()
@@ -416,3 +686,89 @@ func getDummyPath(t *testing.T, fs *afero.MemMapFs, dirPath string, paths ...str
 
 	return dirPath
 }
+
+func TestIsImageArchive(t *testing.T) {
+	tests := []struct {
+		name        string
+		setup       func(fs *afero.MemMapFs) string
+		expected    bool
+		expectedErr bool
+	}{
+		{
+			name: "docker archive",
+			setup: func(fs *afero.MemMapFs) string {
+				return getDummyTar(t, fs, "image.tar", "layer/layer.tar", "manifest.json")
+			},
+			expected: true,
+		},
+		{
+			name: "oci archive",
+			setup: func(fs *afero.MemMapFs) string {
+				return getDummyTar(t, fs, "image.tar", "oci-layout", "index.json", "blobs/sha256/a")
+			},
+			expected: true,
+		},
+		{
+			name: "gzipped docker archive",
+			setup: func(fs *afero.MemMapFs) string {
+				return getDummyGzipTar(t, fs, "image.tar.gz", "manifest.json")
+			},
+			expected: true,
+		},
+		{
+			name: "gzipped oci archive",
+			setup: func(fs *afero.MemMapFs) string {
+				return getDummyGzipTar(t, fs, "image.tgz", "oci-layout")
+			},
+			expected: true,
+		},
+		{
+			name: "tar without image markers",
+			setup: func(fs *afero.MemMapFs) string {
+				return getDummyTar(t, fs, "files.tar", "index.json", "etc/hosts")
+			},
+		},
+		{
+			name: "not an archive",
+			setup: func(fs *afero.MemMapFs) string {
+				require.NoError(t, afero.WriteFile(fs, "notes.txt", []byte("not an archive, but long enough to not be mistaken for an empty one"), 0644))
+				return "notes.txt"
+			},
+		},
+		{
+			name: "oci directory",
+			setup: func(fs *afero.MemMapFs) string {
+				return getDummyPath(t, fs, "image", "oci-layout")
+			},
+		},
+		{
+			name: "missing path",
+			setup: func(fs *afero.MemMapFs) string {
+				return "/does-not-exist"
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			actual, err := isImageArchive(fs, test.setup(fs.(*afero.MemMapFs)), newDetectSourceConfig())
+			if test.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+// layerPaths returns the given number of layer paths (as found within a docker-archive of an image with many layers).
+func layerPaths(count int) []string {
+	paths := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		paths = append(paths, fmt.Sprintf("layer-%d/layer.tar", i))
+	}
+	return paths
+}
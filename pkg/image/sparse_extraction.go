@@ -0,0 +1,124 @@
+package image
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/bmatcuk/doublestar/v4"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// sparseExtraction is the set of path patterns to extract from each layer (see WithSparseExtraction).
+type sparseExtraction struct {
+	patterns []string
+}
+
+// WithSparseExtraction extracts only the regular files (and hard links) with a path matching at least one of the given
+// glob patterns (e.g. "/var/lib/dpkg/status", "/lib/apk/db/installed", "**/*.jar", "**/package.json"), building a sparse
+// file tree. Patterns are doublestar globs (as with FileTree.FilesByGlob) matched against the absolute path of each
+// tar entry, where patterns without a leading "/" or "**" are relative to the root. Directories, links, and whiteouts
+// are always kept (they hold no content) such that link resolution and the image squash behave as usual.
+//
+// Entries that do not match are skipped while each layer is decompressed: they are never written to the layer cache,
+// indexed, or inspected (e.g. for MIME types), and do not count toward the entry limit (see SetMaxFileEntries). Since
+// the bulk of the time reading an image goes to writing and indexing the layer contents, this makes reading for
+// specific files (such as package databases for an SBOM) much faster and smaller on disk for large images, though
+// each layer is still fetched and decompressed in full. Layer sizes still account for the skipped content. Note: the
+// content of a hard link is unavailable if the link target does not match, and sparse layers are never stored in (nor
+// read from) a shared layer cache (see WithLayerCache), since the cached layers are complete.
+func WithSparseExtraction(patterns ...string) AdditionalMetadata {
+	return func(image *Image) error {
+		if len(patterns) == 0 {
+			return fmt.Errorf("no sparse extraction patterns given")
+		}
+		if image.sparseExtraction == nil {
+			image.sparseExtraction = &sparseExtraction{}
+		}
+		for _, pattern := range patterns {
+			if !strings.HasPrefix(pattern, file.DirSeparator) && !strings.HasPrefix(pattern, "**") {
+				pattern = file.DirSeparator + pattern
+			}
+			if _, err := doublestar.Match(pattern, file.DirSeparator); err != nil {
+				return fmt.Errorf("invalid sparse extraction pattern=%q: %w", pattern, err)
+			}
+			image.sparseExtraction.patterns = append(image.sparseExtraction.patterns, pattern)
+		}
+		return nil
+	}
+}
+
+// keep indicates if the given tar entry is retained within the sparse layer.
+func (s *sparseExtraction) keep(header *tar.Header) bool {
+	switch header.Typeflag {
+	case tar.TypeDir, tar.TypeSymlink:
+		return true
+	}
+
+	p := string(file.Path(file.DirSeparator + header.Name).Normalize())
+	if strings.HasPrefix(path.Base(p), file.WhiteoutPrefix) {
+		return true
+	}
+
+	for _, pattern := range s.patterns {
+		if matched, _ := doublestar.Match(pattern, p); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// writeLayerTar writes a tar of only the retained entries of the given layer to the given path, returning the size of
+// the content that was skipped.
+func (s *sparseExtraction) writeLayerTar(layer v1.Layer, tarPath string) (int64, error) {
+	rawReader, err := layer.Uncompressed()
+	if err != nil {
+		return 0, err
+	}
+	defer rawReader.Close()
+
+	fh, err := os.Create(tarPath)
+	if err != nil {
+		return 0, fmt.Errorf("unable to create sparse layer tar=%q : %w", tarPath, err)
+	}
+	defer fh.Close()
+
+	var skipped int64
+	var kept int
+	reader := tar.NewReader(rawReader)
+	writer := tar.NewWriter(fh)
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("unable to read layer tar: %w", err)
+		}
+
+		if !s.keep(header) {
+			skipped += header.Size
+			continue
+		}
+
+		if err := writer.WriteHeader(header); err != nil {
+			return 0, fmt.Errorf("unable to write sparse layer tar=%q : %w", tarPath, err)
+		}
+		if _, err := file.Copy(writer, reader); err != nil {
+			return 0, fmt.Errorf("unable to write sparse layer tar=%q : %w", tarPath, err)
+		}
+		kept++
+	}
+
+	if err := writer.Close(); err != nil {
+		return 0, fmt.Errorf("unable to write sparse layer tar=%q : %w", tarPath, err)
+	}
+	log.Debugf("sparse layer tar=%q retains %d entries (skipped %d bytes)", tarPath, kept, skipped)
+	return skipped, nil
+}
@@ -0,0 +1,73 @@
+package image
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithSparseExtraction(t *testing.T) {
+	layers := [][]testEntry{
+		{
+			testDir("var"),
+			testDir("var/lib"),
+			testDir("var/lib/dpkg"),
+			testFile("var/lib/dpkg/status", "Package: bash"),
+			testDir("usr"),
+			testDir("usr/bin"),
+			testFile("usr/bin/bash", strings.Repeat("a", 1000)),
+			testFile("etc/package.json", `{"name": "removed"}`),
+		},
+		{
+			testFile("app/lib/app.jar", "jar"),
+			testFile("app/package.json", `{"name": "app"}`),
+			testFile("app/main.go", "package main"),
+			testSymlink("app/status", "/var/lib/dpkg/status"),
+			testFile("etc/.wh.package.json", ""),
+		},
+	}
+
+	full := newTestImage(t, layers...)
+
+	sparse := NewImage(newTestV1Image(t, layers...), t.TempDir(),
+		WithSparseExtraction("/var/lib/dpkg/status", "**/*.jar", "**/package.json"),
+	)
+	require.NoError(t, sparse.Read())
+
+	for _, p := range []string{"/var/lib/dpkg/status", "/app/lib/app.jar", "/app/package.json", "/app/status"} {
+		expected, err := full.ReadFile(p)
+		require.NoError(t, err)
+		actual, err := sparse.ReadFile(p)
+		require.NoError(t, err, "path=%q", p)
+		assert.Equal(t, expected, actual, "path=%q", p)
+	}
+
+	for _, p := range []string{"/usr/bin/bash", "/app/main.go", "/etc/package.json"} {
+		_, err := sparse.ReadFile(p)
+		assert.True(t, errors.Is(err, fs.ErrNotExist), "path=%q: expected not found, got %+v", p, err)
+	}
+
+	// directories are retained even when none of their files are
+	assert.True(t, sparse.SquashedTree().HasPath("/usr/bin"))
+	assert.Less(t, len(sparse.FileCatalog.catalog), len(full.FileCatalog.catalog))
+
+	// sizes account for the content that was skipped
+	assert.Equal(t, full.Metadata.Size, sparse.Metadata.Size)
+	for idx := range full.Layers {
+		assert.Equal(t, full.Layers[idx].Metadata.Size, sparse.Layers[idx].Metadata.Size)
+	}
+}
+
+func TestWithSparseExtraction_Patterns(t *testing.T) {
+	img := NewImage(newTestV1Image(t, []testEntry{testFile("etc/os-release", "ID=alpine")}), t.TempDir())
+
+	assert.Error(t, WithSparseExtraction()(img))
+	assert.Error(t, WithSparseExtraction("[")(img))
+
+	require.NoError(t, WithSparseExtraction("etc/os-release", "**/*.jar")(img))
+	assert.Equal(t, []string{"/etc/os-release", "**/*.jar"}, img.sparseExtraction.patterns)
+}
@@ -0,0 +1,90 @@
+package image
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"sort"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+// SquashedDigest returns a deterministic digest (e.g. "sha256:...") of the image squash tree, derived from every path
+// along with its type, mode, owner, link target, and content digest. Images that squash to the same filesystem have
+// the same digest regardless of how the content is spread across layers (or the image config), making this suitable
+// as a cache key for analysis results. The digest is computed upon the first call (which reads the contents of every
+// regular file within the squash tree) and is retained for later calls.
+func (i *Image) SquashedDigest() (string, error) {
+	if i.squashedDigest != "" {
+		return i.squashedDigest, nil
+	}
+
+	tree := i.SquashedTree()
+	var paths []file.Path
+	for _, p := range tree.AllRealPaths() {
+		if !p.IsWhiteout() {
+			paths = append(paths, p)
+		}
+	}
+	sort.Sort(file.Paths(paths))
+
+	hasher := sha256.New()
+	for _, p := range paths {
+		_, ref, err := tree.File(p)
+		if err != nil {
+			return "", fmt.Errorf("unable to find path=%q within squash tree: %w", p, err)
+		}
+		if err := i.writeSquashedDigestEntry(hasher, p, ref); err != nil {
+			return "", err
+		}
+	}
+
+	i.squashedDigest = fmt.Sprintf("sha256:%x", hasher.Sum(nil))
+	log.Debugf("image=%q squashed digest=%q", i.Metadata.ID, i.squashedDigest)
+	return i.squashedDigest, nil
+}
+
+// writeSquashedDigestEntry writes the digest input for a single path within the squash tree.
+func (i *Image) writeSquashedDigestEntry(w hash.Hash, p file.Path, ref *file.Reference) error {
+	if ref == nil {
+		// a directory that is implied by the paths of other files (e.g. the root), but has no tar entry
+		_, err := fmt.Fprintf(w, "%s\x00%c\x00%o\x00\x00\x00\x00\x00\n", p, file.TypeDir, fs.ModeDir|0755)
+		return err
+	}
+
+	entry, err := i.FileCatalog.Get(*ref)
+	if err != nil {
+		return fmt.Errorf("unable to find path=%q within file catalog: %w", p, err)
+	}
+	m := entry.Metadata
+
+	var contentDigest string
+	if m.TypeFlag == byte(file.TypeReg) && entry.Contents != nil {
+		// note: the max file read size does not apply here since all content must be accounted for
+		contentDigest, err = digestContents(entry.Contents)
+		if err != nil {
+			return fmt.Errorf("unable to digest contents of path=%q: %w", p, err)
+		}
+	}
+
+	_, err = fmt.Fprintf(w, "%s\x00%c\x00%o\x00%d\x00%d\x00%s\x00%s\n", p, m.TypeFlag, m.Mode, m.UserID, m.GroupID, m.Linkname, contentDigest)
+	return err
+}
+
+func digestContents(opener file.Opener) (string, error) {
+	reader := opener()
+	defer func() {
+		if err := reader.Close(); err != nil {
+			log.Warnf("unable to close file while computing squashed digest: %+v", err)
+		}
+	}()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, reader); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
+}
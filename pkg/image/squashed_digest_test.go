@@ -0,0 +1,69 @@
+package image
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImage_SquashedDigest(t *testing.T) {
+	singleLayer := newTestImage(t,
+		[]testEntry{
+			testDir("etc/"),
+			testFile("etc/passwd", "root"),
+			testSymlink("etc/alias", "passwd"),
+		},
+	)
+
+	multiLayer := newTestImage(t,
+		[]testEntry{
+			testDir("etc/"),
+			testFile("etc/passwd", "original"),
+			testFile("etc/removed", "removed"),
+		},
+		[]testEntry{
+			testFile("etc/passwd", "root"),
+			testFile("etc/.wh.removed", ""),
+		},
+		[]testEntry{
+			testSymlink("etc/alias", "passwd"),
+		},
+	)
+
+	differentContent := newTestImage(t,
+		[]testEntry{
+			testDir("etc/"),
+			testFile("etc/passwd", "user"),
+			testSymlink("etc/alias", "passwd"),
+		},
+	)
+
+	differentLink := newTestImage(t,
+		[]testEntry{
+			testDir("etc/"),
+			testFile("etc/passwd", "root"),
+			testSymlink("etc/alias", "/etc/passwd"),
+		},
+	)
+
+	expected, err := singleLayer.SquashedDigest()
+	require.NoError(t, err)
+	assert.Regexp(t, "^sha256:[0-9a-f]{64}$", expected)
+
+	again, err := singleLayer.SquashedDigest()
+	require.NoError(t, err)
+	assert.Equal(t, expected, again)
+
+	actual, err := multiLayer.SquashedDigest()
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual, "layering should not affect the digest")
+
+	actual, err = differentContent.SquashedDigest()
+	require.NoError(t, err)
+	assert.NotEqual(t, expected, actual, "file contents should affect the digest")
+
+	actual, err = differentLink.SquashedDigest()
+	require.NoError(t, err)
+	assert.NotEqual(t, expected, actual, "link targets should affect the digest")
+}
@@ -0,0 +1,154 @@
+package image
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+// SquashedTarOptions controls the normalizations applied by Image.WriteSquashedTar. The zero value applies every
+// normalization. Note: entries are always written in sorted path order, and the modification time is always fixed,
+// since the original modification times are not retained when the image is read.
+type SquashedTarOptions struct {
+	// ModTime is the modification time of every entry (the zero value is the Unix epoch)
+	ModTime time.Time
+	// PreserveOwnership keeps the uid and gid of each entry, otherwise every entry is owned by uid=0 and gid=0. User and
+	// group names are never written, since they are not retained when the image is read.
+	PreserveOwnership bool
+}
+
+// WriteSquashedTar writes the image squash tree (the image must be read first) as a tar to the given writer, where
+// repeated calls for the same squash produce byte-identical output (suitable for reproducible packaging and signing).
+// Entries are sorted by path (with hard links last, so their targets are always written first), have a fixed
+// modification time, and only carry the type, permissions, ownership, link target, and content of each file (no
+// access times, xattrs, or other PAX records). Whiteouts are never written.
+func (i *Image) WriteSquashedTar(w io.Writer, opts SquashedTarOptions) error {
+	modTime := opts.ModTime
+	if modTime.IsZero() {
+		modTime = time.Unix(0, 0)
+	}
+	modTime = modTime.UTC().Truncate(time.Second)
+
+	tree := i.SquashedTree()
+	var paths, hardLinks []file.Path
+	for _, p := range tree.AllRealPaths() {
+		if p.IsWhiteout() || p == file.DirSeparator {
+			continue
+		}
+		_, ref, err := tree.File(p)
+		if err != nil {
+			return fmt.Errorf("unable to find path=%q within squash tree: %w", p, err)
+		}
+		if ref != nil {
+			if entry, err := i.FileCatalog.Get(*ref); err == nil && entry.Metadata.TypeFlag == tar.TypeLink {
+				hardLinks = append(hardLinks, p)
+				continue
+			}
+		}
+		paths = append(paths, p)
+	}
+	sort.Sort(file.Paths(paths))
+	sort.Sort(file.Paths(hardLinks))
+
+	writer := tar.NewWriter(w)
+	for _, p := range append(paths, hardLinks...) {
+		if err := i.writeSquashedTarEntry(writer, p, modTime, opts); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
+
+// writeSquashedTarEntry writes the normalized tar entry (and content) for a single path within the squash tree.
+func (i *Image) writeSquashedTarEntry(writer *tar.Writer, p file.Path, modTime time.Time, opts SquashedTarOptions) error {
+	name := strings.TrimPrefix(string(p), file.DirSeparator)
+
+	_, ref, err := i.SquashedTree().File(p)
+	if err != nil {
+		return fmt.Errorf("unable to find path=%q within squash tree: %w", p, err)
+	}
+	if ref == nil {
+		// a directory that is implied by the paths of other files, but has no tar entry
+		return writer.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeDir,
+			Name:     name + file.DirSeparator,
+			Mode:     0755,
+			ModTime:  modTime,
+			Format:   tar.FormatPAX,
+		})
+	}
+
+	entry, err := i.FileCatalog.Get(*ref)
+	if err != nil {
+		return fmt.Errorf("unable to find path=%q within file catalog: %w", p, err)
+	}
+	m := entry.Metadata
+
+	header := &tar.Header{
+		Typeflag: m.TypeFlag,
+		Name:     name,
+		Linkname: m.Linkname,
+		Mode:     tarMode(m.Mode),
+		ModTime:  modTime,
+		Format:   tar.FormatPAX,
+	}
+	if opts.PreserveOwnership {
+		header.Uid, header.Gid = m.UserID, m.GroupID
+	}
+
+	switch m.TypeFlag {
+	case tar.TypeDir:
+		header.Name += file.DirSeparator
+	case tar.TypeLink:
+		// hard link targets are relative to the root of the tar
+		header.Linkname = strings.TrimPrefix(m.Linkname, file.DirSeparator)
+	case tar.TypeReg, tar.TypeRegA:
+		header.Typeflag = tar.TypeReg
+		header.Size = m.Size
+	}
+
+	if err := writer.WriteHeader(header); err != nil {
+		return fmt.Errorf("unable to write tar header for path=%q: %w", p, err)
+	}
+
+	if header.Typeflag != tar.TypeReg || header.Size == 0 {
+		return nil
+	}
+	if entry.Contents == nil {
+		return fmt.Errorf("no contents available for path=%q", p)
+	}
+
+	reader := entry.Contents()
+	defer func() {
+		if err := reader.Close(); err != nil {
+			log.Warnf("unable to close file while writing squashed tar: %+v", err)
+		}
+	}()
+	if _, err := file.Copy(writer, reader); err != nil {
+		return fmt.Errorf("unable to write contents for path=%q: %w", p, err)
+	}
+	return nil
+}
+
+// tarMode returns the tar header mode (the permission bits along with setuid, setgid, and sticky bits) for the given
+// file mode.
+func tarMode(mode os.FileMode) int64 {
+	result := int64(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		result |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		result |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		result |= 01000
+	}
+	return result
+}
@@ -0,0 +1,141 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSquashedTarTestImage(t *testing.T) *Image {
+	t.Helper()
+
+	return newTestImage(t,
+		[]testEntry{
+			testDir("etc/"),
+			testFile("etc/passwd", "root"),
+			testFile("tmp/scratch", "deleted"),
+			{name: "usr/bin/app", typeflag: tar.TypeReg, contents: "app", uid: 1000},
+		},
+		[]testEntry{
+			testFile("tmp/.wh.scratch", ""),
+			testSymlink("usr/bin/alias", "app"),
+			{name: "usr/bin/hardlink", typeflag: tar.TypeLink, linkname: "usr/bin/app"},
+			testFile("etc/passwd", "root\nuser"),
+		},
+	)
+}
+
+type squashedTarEntry struct {
+	name     string
+	typeflag byte
+	linkname string
+	uid      int
+	modTime  time.Time
+	contents string
+}
+
+func readSquashedTar(t *testing.T, contents []byte) []squashedTarEntry {
+	t.Helper()
+
+	var entries []squashedTarEntry
+	reader := tar.NewReader(bytes.NewReader(contents))
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		body, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		entries = append(entries, squashedTarEntry{
+			name:     header.Name,
+			typeflag: header.Typeflag,
+			linkname: header.Linkname,
+			uid:      header.Uid,
+			modTime:  header.ModTime.UTC(),
+			contents: string(body),
+		})
+	}
+	return entries
+}
+
+func TestImage_WriteSquashedTar_Reproducible(t *testing.T) {
+	var outputs [][]byte
+	for run := 0; run < 2; run++ {
+		// note: each run reads the image again, as a separate process would
+		img := newSquashedTarTestImage(t)
+		buf := &bytes.Buffer{}
+		require.NoError(t, img.WriteSquashedTar(buf, SquashedTarOptions{}))
+		outputs = append(outputs, buf.Bytes())
+	}
+
+	require.NotEmpty(t, outputs[0])
+	assert.Equal(t, outputs[0], outputs[1])
+}
+
+func TestImage_WriteSquashedTar(t *testing.T) {
+	epoch := time.Unix(0, 0).UTC()
+	fixed := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		opts     SquashedTarOptions
+		wantTime time.Time
+		wantUID  int
+	}{
+		{
+			name:     "all normalizations",
+			wantTime: epoch,
+			wantUID:  0,
+		},
+		{
+			name:     "fixed mod time and preserved ownership",
+			opts:     SquashedTarOptions{ModTime: fixed.Add(500 * time.Millisecond), PreserveOwnership: true},
+			wantTime: fixed,
+			wantUID:  1000,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			img := newSquashedTarTestImage(t)
+			buf := &bytes.Buffer{}
+			require.NoError(t, img.WriteSquashedTar(buf, test.opts))
+
+			entries := readSquashedTar(t, buf.Bytes())
+
+			var names []string
+			for _, entry := range entries {
+				names = append(names, entry.name)
+				assert.Equal(t, test.wantTime, entry.modTime, entry.name)
+			}
+			// sorted paths (implied parent dirs included), with hard links last and no whiteouts or deleted files
+			assert.Equal(t, []string{
+				"etc/",
+				"etc/passwd",
+				"tmp/",
+				"usr/",
+				"usr/bin/",
+				"usr/bin/alias",
+				"usr/bin/app",
+				"usr/bin/hardlink",
+			}, names)
+
+			byName := make(map[string]squashedTarEntry)
+			for _, entry := range entries {
+				byName[entry.name] = entry
+			}
+			assert.Equal(t, "root\nuser", byName["etc/passwd"].contents)
+			assert.Equal(t, test.wantUID, byName["usr/bin/app"].uid)
+			assert.Equal(t, byte(tar.TypeSymlink), byName["usr/bin/alias"].typeflag)
+			assert.Equal(t, "app", byName["usr/bin/alias"].linkname)
+			assert.Equal(t, byte(tar.TypeLink), byName["usr/bin/hardlink"].typeflag)
+			assert.Equal(t, "usr/bin/app", byName["usr/bin/hardlink"].linkname)
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package image
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/filetree"
+	"github.com/anchore/stereoscope/pkg/filetree/filenode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImage_SymlinkCycles(t *testing.T) {
+	img := newTestImage(t,
+		[]testEntry{
+			testDir("etc"),
+			testFile("etc/os-release", "ID=alpine"),
+			// direct cycles
+			testSymlink("self", "self"),
+			testSymlink("a", "b"),
+			testSymlink("b", "a"),
+		},
+		[]testEntry{
+			// indirect cycle through ancestor links (across layers)
+			testSymlink("x", "/y/1"),
+			testSymlink("y", "/x/2"),
+			// a link to a directory already being traversed
+			testSymlink("etc/root", "/"),
+		},
+	)
+
+	for _, p := range []string{"/self", "/a", "/a/file.txt", "/x/file.txt", "/y"} {
+		t.Run(p, func(t *testing.T) {
+			_, err := img.ReadFile(p)
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, filetree.ErrLinkCycleDetected), "unexpected error: %+v", err)
+
+			var cycleErr *filetree.ErrSymlinkCycle
+			assert.True(t, errors.As(err, &cycleErr))
+
+			// the links themselves can be found, but not resolved
+			_, ref, err := img.SquashedTree().File(file.Path(p))
+			if err != nil {
+				// the cycle is within an ancestor of the path
+				assert.True(t, errors.Is(err, filetree.ErrLinkCycleDetected), "unexpected error: %+v", err)
+			} else if ref != nil {
+				_, err = img.ResolveLinkByImageSquash(*ref)
+				assert.True(t, errors.Is(err, filetree.ErrLinkCycleDetected), "unexpected error: %+v", err)
+			}
+		})
+	}
+
+	// files reached through a link to an ancestor directory are still readable
+	contents, err := img.ReadFile("/etc/root/etc/os-release")
+	require.NoError(t, err)
+	assert.Equal(t, "ID=alpine", string(contents))
+
+	// walks visit the links without descending through the cycles
+	var visited []string
+	err = img.SquashedTree().Walk(func(path file.Path, _ filenode.FileNode) error {
+		visited = append(visited, string(path))
+		return nil
+	}, nil)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"/", "/a", "/b", "/etc", "/etc/os-release", "/etc/root", "/self", "/x", "/y"}, visited)
+
+	matches, err := fs.Glob(img.FS(), "**/os-release")
+	require.NoError(t, err)
+	assert.NotEmpty(t, matches)
+}
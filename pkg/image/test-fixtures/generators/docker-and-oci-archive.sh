@@ -0,0 +1,42 @@
+#!/usr/bin/env bash
+set -ue
+
+# creates an image archive holding both a docker-archive manifest.json and an OCI image layout (as written by
+# "docker save" with docker v25+), which is valid when read as either kind of archive
+# usage: docker-and-oci-archive.sh <path-to-output-tar>
+
+OUTPUT_TAR_PATH=$(cd $(dirname $1) && pwd)/$(basename $1)
+
+WORK_DIR=$(mktemp -d)
+trap "rm -rf ${WORK_DIR}" EXIT
+
+blob() {
+  local digest=$(sha256sum "$1" | cut -d' ' -f1)
+  mkdir -p ${WORK_DIR}/archive/blobs/sha256
+  cp "$1" ${WORK_DIR}/archive/blobs/sha256/${digest}
+  echo ${digest}
+}
+
+pushd ${WORK_DIR}
+  mkdir -p rootfs/etc archive
+  printf 'dual marker\n' > rootfs/etc/marker.txt
+  tar --owner=0 --group=0 --mtime='2020-01-01' --sort=name -C rootfs -cf layer.tar etc
+
+  LAYER_DIGEST=$(blob layer.tar)
+
+  printf '{"architecture":"amd64","os":"linux","config":{},"rootfs":{"type":"layers","diff_ids":["sha256:%s"]}}' \
+    ${LAYER_DIGEST} > config.json
+  CONFIG_DIGEST=$(blob config.json)
+
+  printf '{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"sha256:%s","size":%d},"layers":[{"mediaType":"application/vnd.oci.image.layer.v1.tar","digest":"sha256:%s","size":%d}]}' \
+    ${CONFIG_DIGEST} $(stat -c %s config.json) ${LAYER_DIGEST} $(stat -c %s layer.tar) > image-manifest.json
+  MANIFEST_DIGEST=$(blob image-manifest.json)
+
+  printf '{"schemaVersion":2,"manifests":[{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"sha256:%s","size":%d,"annotations":{"io.containerd.image.name":"docker.io/library/dual-marker:latest","org.opencontainers.image.ref.name":"latest"}}]}' \
+    ${MANIFEST_DIGEST} $(stat -c %s image-manifest.json) > archive/index.json
+  printf '[{"Config":"blobs/sha256/%s","RepoTags":["dual-marker:latest"],"Layers":["blobs/sha256/%s"]}]' \
+    ${CONFIG_DIGEST} ${LAYER_DIGEST} > archive/manifest.json
+  printf '{"imageLayoutVersion":"1.0.0"}' > archive/oci-layout
+
+  tar --owner=0 --group=0 --mtime='2020-01-01' --sort=name -C archive -cf ${OUTPUT_TAR_PATH} blobs index.json manifest.json oci-layout
+popd
@@ -0,0 +1,38 @@
+#!/usr/bin/env bash
+set -ue
+
+# creates an OCI archive holding a Helm chart (as written by "helm push" to an OCI layout), which is not a container image
+# usage: helm-chart-oci-archive.sh <path-to-output-tar>
+
+OUTPUT_TAR_PATH=$(cd $(dirname $1) && pwd)/$(basename $1)
+
+WORK_DIR=$(mktemp -d)
+trap "rm -rf ${WORK_DIR}" EXIT
+
+blob() {
+  local digest=$(sha256sum "$1" | cut -d' ' -f1)
+  mkdir -p ${WORK_DIR}/layout/blobs/sha256
+  cp "$1" ${WORK_DIR}/layout/blobs/sha256/${digest}
+  echo ${digest}
+}
+
+pushd ${WORK_DIR}
+  mkdir -p chart/mychart layout
+  printf 'apiVersion: v2\nname: mychart\nversion: 0.1.0\n' > chart/mychart/Chart.yaml
+  tar --owner=0 --group=0 --mtime='2020-01-01' -C chart -cf - mychart | gzip -n > chart.tgz
+
+  printf '{"name":"mychart","version":"0.1.0","apiVersion":"v2"}' > config.json
+
+  CONFIG_DIGEST=$(blob config.json)
+  CHART_DIGEST=$(blob chart.tgz)
+
+  printf '{"schemaVersion":2,"config":{"mediaType":"application/vnd.cncf.helm.config.v1+json","digest":"sha256:%s","size":%d},"layers":[{"mediaType":"application/vnd.cncf.helm.chart.content.v1.tar+gzip","digest":"sha256:%s","size":%d}]}' \
+    ${CONFIG_DIGEST} $(stat -c %s config.json) ${CHART_DIGEST} $(stat -c %s chart.tgz) > manifest.json
+  MANIFEST_DIGEST=$(blob manifest.json)
+
+  printf '{"schemaVersion":2,"manifests":[{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"sha256:%s","size":%d,"annotations":{"org.opencontainers.image.ref.name":"0.1.0"}}]}' \
+    ${MANIFEST_DIGEST} $(stat -c %s manifest.json) > layout/index.json
+  printf '{"imageLayoutVersion":"1.0.0"}' > layout/oci-layout
+
+  tar --owner=0 --group=0 --mtime='2020-01-01' --sort=name -C layout -cf ${OUTPUT_TAR_PATH} oci-layout index.json blobs
+popd
@@ -0,0 +1,19 @@
+#!/usr/bin/env bash
+set -ue
+
+# creates a layer tar with a single (mostly empty) multi-GB sparse file, which is only a few KB on disk
+# usage: sparse-file-layer.sh <path-to-output-tar>
+# note: this requires GNU tar for sparse file support
+
+OUTPUT_TAR_PATH=$(cd $(dirname $1) && pwd)/$(basename $1)
+
+WORK_DIR=$(mktemp -d)
+trap "rm -rf ${WORK_DIR}" EXIT
+
+pushd ${WORK_DIR}
+  printf 'sparse file header\n' > big.bin
+  truncate -s 4G big.bin
+  printf 'a small file\n' > small.txt
+
+  tar --sparse --format=gnu --owner=0 --group=0 --mtime='2020-01-01' -cf ${OUTPUT_TAR_PATH} big.bin small.txt
+popd
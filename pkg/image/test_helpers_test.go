@@ -0,0 +1,88 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/require"
+)
+
+// testEntry is a single tar entry within a test layer.
+type testEntry struct {
+	name     string
+	typeflag byte
+	linkname string
+	contents string
+	uid      int
+}
+
+func testDir(name string) testEntry {
+	return testEntry{name: name, typeflag: tar.TypeDir}
+}
+
+func testFile(name, contents string) testEntry {
+	return testEntry{name: name, typeflag: tar.TypeReg, contents: contents}
+}
+
+func testSymlink(name, linkname string) testEntry {
+	return testEntry{name: name, typeflag: tar.TypeSymlink, linkname: linkname}
+}
+
+// newTestImage creates and reads an image made of the given layers (in build order).
+func newTestImage(t *testing.T, layers ...[]testEntry) *Image {
+	t.Helper()
+
+	result := NewImage(newTestV1Image(t, layers...), t.TempDir())
+	require.NoError(t, result.Read())
+	return result
+}
+
+// newTestV1Image creates an (unread) image with a layer for each of the given sets of entries.
+func newTestV1Image(t *testing.T, layers ...[]testEntry) v1.Image {
+	t.Helper()
+
+	var img v1.Image = empty.Image
+	for _, entries := range layers {
+		raw := testLayerTar(t, entries)
+		layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(raw)), nil
+		})
+		require.NoError(t, err)
+
+		img, err = mutate.AppendLayers(img, layer)
+		require.NoError(t, err)
+	}
+	return img
+}
+
+func testLayerTar(t *testing.T, entries []testEntry) []byte {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	writer := tar.NewWriter(buf)
+	for _, entry := range entries {
+		mode := int64(0644)
+		if entry.typeflag == tar.TypeDir {
+			mode = 0755
+		}
+		require.NoError(t, writer.WriteHeader(&tar.Header{
+			Name:     entry.name,
+			Typeflag: entry.typeflag,
+			Linkname: entry.linkname,
+			Mode:     mode,
+			Uid:      entry.uid,
+			Size:     int64(len(entry.contents)),
+		}))
+		_, err := writer.Write([]byte(entry.contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
@@ -0,0 +1,13 @@
+package image
+
+import "github.com/anchore/stereoscope/internal/log"
+
+// WithTraceMode enables (or disables) trace logging of how a single image is read (every tar entry indexed within each
+// layer and every decision made while squashing layers), regardless of the process-wide default (see
+// stereoscope.SetTraceMode). Trace messages are logged at the debug level.
+func WithTraceMode(enabled bool) AdditionalMetadata {
+	return func(image *Image) error {
+		image.tracer = log.Tracer(enabled)
+		return nil
+	}
+}
@@ -0,0 +1,93 @@
+package image
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type traceRecorder struct {
+	messages []string
+}
+
+func (l *traceRecorder) Errorf(string, ...interface{}) {}
+func (l *traceRecorder) Error(...interface{})          {}
+func (l *traceRecorder) Warnf(string, ...interface{})  {}
+func (l *traceRecorder) Warn(...interface{})           {}
+func (l *traceRecorder) Infof(string, ...interface{})  {}
+func (l *traceRecorder) Info(...interface{})           {}
+func (l *traceRecorder) Debug(...interface{})          {}
+func (l *traceRecorder) Debugf(format string, args ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, args...))
+}
+
+func (l *traceRecorder) traces() []string {
+	var traces []string
+	for _, message := range l.messages {
+		if strings.HasPrefix(message, "[trace] ") {
+			traces = append(traces, message)
+		}
+	}
+	return traces
+}
+
+func TestWithTraceMode(t *testing.T) {
+	v1Image := newTestV1Image(t,
+		[]testEntry{testFile("/etc/removed.txt", "lower")},
+		[]testEntry{testFile("/etc/.wh.removed.txt", "")},
+	)
+
+	tests := []struct {
+		name       string
+		defaultOn  bool
+		options    []AdditionalMetadata
+		wantTraces bool
+	}{
+		{
+			name: "off by default",
+		},
+		{
+			name:       "on by default",
+			defaultOn:  true,
+			wantTraces: true,
+		},
+		{
+			name:       "enabled for the image",
+			options:    []AdditionalMetadata{WithTraceMode(true)},
+			wantTraces: true,
+		},
+		{
+			name:      "disabled for the image",
+			defaultOn: true,
+			options:   []AdditionalMetadata{WithTraceMode(false)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			original := log.Log
+			recorder := &traceRecorder{}
+			log.Log = recorder
+			log.SetTraceMode(test.defaultOn)
+			t.Cleanup(func() {
+				log.Log = original
+				log.SetTraceMode(false)
+			})
+
+			img := NewImage(v1Image, t.TempDir(), test.options...)
+			require.NoError(t, img.Read())
+
+			if !test.wantTraces {
+				assert.Empty(t, recorder.traces())
+				return
+			}
+			traces := strings.Join(recorder.traces(), "\n")
+			assert.Contains(t, traces, `path="/etc/removed.txt"`)
+			assert.Contains(t, traces, `squash: whiteout="/etc/.wh.removed.txt" in upper layer removes path="/etc/removed.txt"`)
+		})
+	}
+}
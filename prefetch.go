@@ -0,0 +1,74 @@
+package stereoscope
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+var (
+	layerCacheLock sync.RWMutex
+	layerCache     *image.LayerCache
+)
+
+// SetLayerCache sets the layer cache used by all images read afterwards with GetImage, GetImageFromSource, and
+// GetImages (unless BatchOptions.LayerCache is given), so that layers already in the cache (e.g. warmed with Prefetch)
+// are not fetched again. A nil cache (the default) restores reading layers into a cache per image. The caller is
+// responsible for removing the cache dir once all images using the cache are no longer needed.
+func SetLayerCache(cache *image.LayerCache) {
+	layerCacheLock.Lock()
+	defer layerCacheLock.Unlock()
+
+	layerCache = cache
+}
+
+func currentLayerCache() *image.LayerCache {
+	layerCacheLock.RLock()
+	defer layerCacheLock.RUnlock()
+
+	return layerCache
+}
+
+// layerCacheOptions returns the read options for the layer cache set with SetLayerCache (if any).
+func layerCacheOptions() []image.AdditionalMetadata {
+	if cache := currentLayerCache(); cache != nil {
+		return []image.AdditionalMetadata{image.WithLayerCache(cache)}
+	}
+	return nil
+}
+
+// Prefetch warms the given layer cache with the layers of the image for each of the given user inputs (see
+// PrefetchContext).
+func Prefetch(inputs []string, cache *image.LayerCache, options BatchOptions) error {
+	return PrefetchContext(context.Background(), inputs, cache, options)
+}
+
+// PrefetchContext fetches and uncompresses the layers of the image for each of the given user inputs into the given
+// layer cache, without reading the images (no file trees or catalogs are built). Images read afterwards with the same
+// cache (see SetLayerCache, BatchOptions.LayerCache, and image.WithLayerCache) do not fetch the cached layers again.
+// Any temp files used while fetching are removed before returning. If any image cannot be fetched then the remaining
+// fetches are cancelled and the first error is returned (layers already cached remain in the cache).
+func PrefetchContext(ctx context.Context, inputs []string, cache *image.LayerCache, options BatchOptions) error {
+	if cache == nil {
+		return fmt.Errorf("no layer cache given to prefetch into")
+	}
+
+	tmpDirGen := file.NewTempDirGeneratorWithCreator(currentTempDirCreator())
+	defer func() {
+		if err := tmpDirGen.Cleanup(); err != nil {
+			log.Errorf("failed to cleanup prefetch temp dirs: %+v", err)
+		}
+	}()
+
+	return forEachInput(ctx, inputs, options.Concurrency, func(ctx context.Context, _ int, input string) error {
+		source, imgStr, err := detectSource(ctx, input, options.RegistryOptions, options.DetectSourceOptions, &tmpDirGen)
+		if err != nil {
+			return err
+		}
+		return withImageFromSource(ctx, imgStr, source, options.RegistryOptions, &tmpDirGen, cache.Prefetch)
+	})
+}
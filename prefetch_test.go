@@ -0,0 +1,48 @@
+package stereoscope
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefetch(t *testing.T) {
+	fixture := "pkg/image/docker/test-fixtures/docker-save-classic.tar"
+
+	cacheDir := t.TempDir()
+	cache := image.NewLayerCache(cacheDir)
+	require.NoError(t, Prefetch([]string{fixture, "docker-archive:" + fixture}, cache, BatchOptions{Concurrency: 2}))
+
+	cached, err := ioutil.ReadDir(cacheDir)
+	require.NoError(t, err)
+	require.Len(t, cached, 1)
+
+	SetLayerCache(cache)
+	t.Cleanup(func() {
+		SetLayerCache(nil)
+	})
+
+	img, err := GetImage(fixture, nil)
+	require.NoError(t, err)
+	t.Cleanup(Cleanup)
+
+	require.Len(t, img.Layers, 1)
+	reader, err := img.OpenLayerTar(img.Layers[0].Metadata.DiffID)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = reader.Close()
+	})
+	fh, ok := reader.(*os.File)
+	require.True(t, ok)
+	assert.Equal(t, filepath.Join(cacheDir, cached[0].Name()), fh.Name())
+}
+
+func TestPrefetch_NoCache(t *testing.T) {
+	err := Prefetch([]string{"pkg/image/docker/test-fixtures/docker-save-classic.tar"}, nil, BatchOptions{})
+	require.Error(t, err)
+}
@@ -0,0 +1,231 @@
+package stereoscope
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/anchore/stereoscope/pkg/image/oci"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// sharedImages are the images fetched with GetSharedImage that are still held by at least one caller.
+var sharedImages = newSharedImageRegistry()
+
+// GetSharedImage fetches and reads the image for the user provided image string, sharing the fetch with other callers
+// (see GetSharedImageContext).
+func GetSharedImage(userStr string, registryOptions *image.RegistryOptions, options ...image.DetectSourceOption) (*image.Image, func() error, error) {
+	return GetSharedImageContext(context.Background(), userStr, registryOptions, options...)
+}
+
+// GetSharedImageContext fetches and reads the image for the user provided image string (as with GetImageContext),
+// where concurrent callers asking for the same image share a single fetch and extraction: the first caller fetches the
+// image while the others wait for it, and all callers are given the same image. The image remains shared (and later
+// callers are given it without fetching) for as long as any caller holds it. Each caller is given a release function
+// that must be called once the image is no longer needed; the temp files of the image are removed once the last holder
+// releases it (the image must not be used after release). Since the image is shared it must be treated as read-only.
+//
+// Images are the same when the source, the location, and the registry options (including the credentials and the
+// requested platform) are the same, thus callers with different credentials never share an image. Registry references
+// are resolved to the manifest digest with a HEAD request beforehand (thus "alpine", "docker.io/library/alpine:latest",
+// and the digest of the image are the same), such that a tag that has moved is fetched again instead of being given the
+// image of the previous digest. If the digest cannot be resolved then the image is shared by the fully qualified
+// reference. Note: the image is fetched by the given reference (not the resolved digest), thus if the tag moves between
+// the HEAD request and the fetch the image may be of the newer digest. Images from the docker daemon are shared by the
+// given reference (the image ID is not resolved beforehand).
+// The image is fetched with the context and registry options of the first caller, thus if the first caller is cancelled
+// then all callers waiting on the fetch are given the error. A caller that is cancelled while waiting is given the
+// context error (without affecting the fetch). Images that fail to be fetched are never shared with later callers.
+func GetSharedImageContext(ctx context.Context, userStr string, registryOptions *image.RegistryOptions, options ...image.DetectSourceOption) (*image.Image, func() error, error) {
+	tmpDirGen := file.NewTempDirGeneratorWithCreator(currentTempDirCreator())
+
+	source, imgStr, err := detectSource(ctx, userStr, registryOptions, options, &tmpDirGen)
+	if err != nil {
+		_ = tmpDirGen.Cleanup()
+		return nil, nil, err
+	}
+
+	entry, leader := sharedImages.join(sharedImageKey(source, imgStr, resolveSharedImageDigest(ctx, source, imgStr, registryOptions), registryOptions))
+	if !leader {
+		// the leader fetches into temp dirs of its own
+		_ = tmpDirGen.Cleanup()
+		return sharedImages.wait(ctx, entry)
+	}
+
+	img, err := getImageFromSource(ctx, imgStr, source, registryOptions, &tmpDirGen, layerCacheOptions()...)
+	sharedImages.complete(entry, img, err, tmpDirGen.Cleanup)
+	if err != nil {
+		return nil, nil, err
+	}
+	return img, sharedImages.releaser(entry), nil
+}
+
+// sharedImageKey identifies an image fetched from the given source and location (and the resolved manifest digest for
+// registry images, if known) with the given registry options.
+func sharedImageKey(source image.Source, location string, digest string, registryOptions *image.RegistryOptions) string {
+	if source == image.OciRegistrySource {
+		if ref, err := name.ParseReference(location); err == nil {
+			location = ref.Name()
+			if digest != "" {
+				location = ref.Context().Name() + "@" + digest
+			}
+		}
+	}
+
+	return fmt.Sprintf("%s:%s#%s", source, location, registryOptionsFingerprint(registryOptions))
+}
+
+// registryOptionsFingerprint returns a digest of the given registry options, such that images are only shared between
+// callers with the same credentials, transport options, and platform. Note: the credentials are never kept in the clear.
+func registryOptionsFingerprint(registryOptions *image.RegistryOptions) string {
+	if registryOptions == nil {
+		registryOptions = &image.RegistryOptions{}
+	}
+	b, err := json.Marshal(registryOptions)
+	if err != nil {
+		// this should never happen, however, images must never be shared by mistake
+		log.Errorf("unable to fingerprint registry options: %+v", err)
+		return fmt.Sprintf("unshared-%p", registryOptions)
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(b))
+}
+
+// resolveSharedImageDigest returns the digest that the registry reference currently refers to, such that a tag that
+// has moved since the shared image was fetched is fetched again. An empty digest is returned for other sources, or if
+// the digest cannot be resolved (in which case the image is shared by reference).
+func resolveSharedImageDigest(ctx context.Context, source image.Source, location string, registryOptions *image.RegistryOptions) string {
+	if source != image.OciRegistrySource {
+		return ""
+	}
+	if registryOptions == nil {
+		registryOptions = &image.RegistryOptions{}
+	}
+
+	digest, err := oci.NewProviderFromRegistry(location, nil, registryOptions).Digest(ctx)
+	if err != nil {
+		log.Debugf("unable to resolve digest for shared image=%q (sharing by reference): %+v", location, err)
+		return ""
+	}
+	return digest.String()
+}
+
+// sharedImage is an image fetched once and held by one or more callers.
+type sharedImage struct {
+	key string
+	// done is closed once the fetch has completed (with either the image or the error)
+	done    chan struct{}
+	img     *image.Image
+	err     error
+	cleanup func() error
+	// holders is the number of callers that have not yet released the image (or are still waiting on the fetch)
+	holders int
+}
+
+// sharedImageRegistry tracks the shared images by key.
+type sharedImageRegistry struct {
+	lock   sync.Mutex
+	images map[string]*sharedImage
+}
+
+func newSharedImageRegistry() *sharedImageRegistry {
+	return &sharedImageRegistry{
+		images: make(map[string]*sharedImage),
+	}
+}
+
+// join adds a holder to the shared image with the given key, returning the image entry and whether the caller is the
+// leader (the first holder, which must fetch the image and call complete).
+func (r *sharedImageRegistry) join(key string) (*sharedImage, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if entry, ok := r.images[key]; ok {
+		entry.holders++
+		return entry, false
+	}
+
+	entry := &sharedImage{
+		key:     key,
+		done:    make(chan struct{}),
+		holders: 1,
+	}
+	r.images[key] = entry
+	return entry, true
+}
+
+// complete records the result of the fetch by the leader, waking all waiting holders. Failed fetches are removed such
+// that later callers fetch the image again, and the temp files of the failed fetch are removed.
+func (r *sharedImageRegistry) complete(entry *sharedImage, img *image.Image, err error, cleanup func() error) {
+	r.lock.Lock()
+	entry.img, entry.err, entry.cleanup = img, err, cleanup
+	if err != nil {
+		r.remove(entry)
+	}
+	r.lock.Unlock()
+
+	if err != nil {
+		if cleanupErr := cleanup(); cleanupErr != nil {
+			log.Errorf("failed to cleanup shared image=%q: %+v", entry.key, cleanupErr)
+		}
+	}
+	close(entry.done)
+}
+
+// wait blocks until the fetch of the given shared image has completed or the given context is done.
+func (r *sharedImageRegistry) wait(ctx context.Context, entry *sharedImage) (*image.Image, func() error, error) {
+	select {
+	case <-entry.done:
+	case <-ctx.Done():
+		_ = r.release(entry)
+		return nil, nil, ctx.Err()
+	}
+
+	if entry.err != nil {
+		return nil, nil, entry.err
+	}
+	return entry.img, r.releaser(entry), nil
+}
+
+// releaser returns a function that releases the given shared image for one holder (at most once).
+func (r *sharedImageRegistry) releaser(entry *sharedImage) func() error {
+	var once sync.Once
+	var err error
+	return func() error {
+		once.Do(func() {
+			err = r.release(entry)
+		})
+		return err
+	}
+}
+
+// release removes a holder from the given shared image, removing the temp files of the image once there are no holders.
+func (r *sharedImageRegistry) release(entry *sharedImage) error {
+	r.lock.Lock()
+	entry.holders--
+	last := entry.holders == 0
+	if last {
+		r.remove(entry)
+	}
+	// note: the temp files of a failed fetch have already been removed, and the leader (which never releases before
+	// the fetch completes) is always a holder while the fetch is in flight
+	cleanup := entry.cleanup
+	failed := entry.err != nil
+	r.lock.Unlock()
+
+	if !last || failed || cleanup == nil {
+		return nil
+	}
+	return cleanup()
+}
+
+// remove stops sharing the given image with later callers (the lock must be held).
+func (r *sharedImageRegistry) remove(entry *sharedImage) {
+	if r.images[entry.key] == entry {
+		delete(r.images, entry.key)
+	}
+}
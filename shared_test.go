@@ -0,0 +1,198 @@
+package stereoscope
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// holders returns the number of holders of the shared image with the given key.
+func (r *sharedImageRegistry) holders(key string) int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if entry, ok := r.images[key]; ok {
+		return entry.holders
+	}
+	return 0
+}
+
+func TestGetSharedImage(t *testing.T) {
+	// once pushed, manifest requests are counted and held until released
+	var pushed int32
+	var manifestRequests int32
+	gate := make(chan struct{})
+	handler := registry.New()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&pushed) == 1 && r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/manifests/") {
+			atomic.AddInt32(&manifestRequests, 1)
+			<-gate
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	imageStr := fmt.Sprintf("%s/stereoscope/shared:latest", u.Host)
+	ref, err := name.ParseReference(imageStr, name.Insecure)
+	require.NoError(t, err)
+
+	img, err := random.Image(1024, 2)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+	atomic.StoreInt32(&pushed, 1)
+	digest, err := img.Digest()
+	require.NoError(t, err)
+
+	registryOptions := &image.RegistryOptions{InsecureUseHTTP: true}
+	key := sharedImageKey(image.OciRegistrySource, imageStr, digest.String(), registryOptions)
+
+	const callers = 4
+	var (
+		wg       sync.WaitGroup
+		images   = make([]*image.Image, callers)
+		releases = make([]func() error, callers)
+		errs     = make([]error, callers)
+	)
+	for idx := 0; idx < callers; idx++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			images[idx], releases[idx], errs[idx] = GetSharedImage("registry:"+imageStr, registryOptions)
+		}(idx)
+	}
+
+	// release the fetch only once all callers are waiting on it
+	require.Eventually(t, func() bool {
+		return sharedImages.holders(key) == callers
+	}, 5*time.Second, 10*time.Millisecond)
+	close(gate)
+	wg.Wait()
+
+	for idx := 0; idx < callers; idx++ {
+		require.NoError(t, errs[idx])
+		assert.Same(t, images[0], images[idx])
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&manifestRequests))
+
+	// the shared extraction remains until the last holder releases the image
+	reader, err := images[0].OpenLayerTar(images[0].Layers[0].Metadata.DiffID)
+	require.NoError(t, err)
+	fh, ok := reader.(*os.File)
+	require.True(t, ok)
+	layerTar := fh.Name()
+	require.NoError(t, reader.Close())
+
+	for idx := 0; idx < callers-1; idx++ {
+		require.NoError(t, releases[idx]())
+		// releasing more than once has no effect
+		require.NoError(t, releases[idx]())
+		assert.FileExists(t, layerTar)
+	}
+	assert.Equal(t, 1, sharedImages.holders(key))
+
+	require.NoError(t, releases[callers-1]())
+	assert.Equal(t, 0, sharedImages.holders(key))
+	_, err = os.Stat(layerTar)
+	assert.True(t, os.IsNotExist(err), "expected the shared layer tar to be removed: %+v", err)
+
+	// once released, the image is fetched again
+	again, release, err := GetSharedImage("registry:"+imageStr, registryOptions)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, release())
+	})
+	assert.NotSame(t, images[0], again)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&manifestRequests))
+}
+
+func TestGetSharedImage_Error(t *testing.T) {
+	_, _, err := GetSharedImage("oci-dir:test-fixtures/does-not-exist", nil)
+	require.Error(t, err)
+	assert.Empty(t, sharedImages.images)
+}
+
+func TestGetSharedImage_NotShared(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	imageStr := fmt.Sprintf("%s/stereoscope/shared:latest", u.Host)
+	ref, err := name.ParseReference(imageStr, name.Insecure)
+	require.NoError(t, err)
+
+	push := func() {
+		img, err := random.Image(1024, 1)
+		require.NoError(t, err)
+		require.NoError(t, remote.Write(ref, img))
+	}
+	get := func(registryOptions *image.RegistryOptions) *image.Image {
+		img, release, err := GetSharedImage("registry:"+imageStr, registryOptions)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			assert.NoError(t, release())
+		})
+		return img
+	}
+
+	push()
+	tenantA := &image.RegistryOptions{InsecureUseHTTP: true, Credentials: []image.RegistryCredentials{{Token: "a"}}}
+	tenantB := &image.RegistryOptions{InsecureUseHTTP: true, Credentials: []image.RegistryCredentials{{Token: "b"}}}
+
+	first := get(tenantA)
+	assert.Same(t, first, get(tenantA))
+
+	// callers with different credentials never share an image
+	assert.NotSame(t, first, get(tenantB))
+
+	// a moved tag is fetched again, even while the image of the previous digest is held
+	push()
+	assert.NotSame(t, first, get(tenantA))
+}
+
+func Test_sharedImageKey(t *testing.T) {
+	short := sharedImageKey(image.OciRegistrySource, "alpine", "", nil)
+	assert.Equal(t, short, sharedImageKey(image.OciRegistrySource, "docker.io/library/alpine:latest", "", nil))
+	assert.NotEqual(t, short, sharedImageKey(image.OciRegistrySource, "alpine:3.18", "", nil))
+	assert.NotEqual(t, short, sharedImageKey(image.DockerDaemonSource, "alpine", "", nil))
+	assert.NotEqual(t, short, sharedImageKey(image.OciRegistrySource, "alpine", "", &image.RegistryOptions{
+		Platform: &v1.Platform{OS: "linux", Architecture: "arm64"},
+	}))
+	assert.NotEqual(t, short, sharedImageKey(image.OciRegistrySource, "alpine", "", &image.RegistryOptions{
+		Credentials: []image.RegistryCredentials{{Username: "user", Password: "secret"}},
+	}))
+	assert.NotEqual(t, short, sharedImageKey(image.OciRegistrySource, "alpine", "", &image.RegistryOptions{
+		HostOverrides: map[string]string{"docker.io": "10.0.0.1"},
+	}))
+	assert.NotContains(t, sharedImageKey(image.OciRegistrySource, "alpine", "", &image.RegistryOptions{
+		Credentials: []image.RegistryCredentials{{Username: "user", Password: "secret"}},
+	}), "secret")
+
+	// tags are keyed by the resolved digest
+	digest := "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	assert.Equal(t,
+		sharedImageKey(image.OciRegistrySource, "alpine:3.18", digest, nil),
+		sharedImageKey(image.OciRegistrySource, "alpine:latest", digest, nil),
+	)
+	assert.NotEqual(t, short, sharedImageKey(image.OciRegistrySource, "alpine", digest, nil))
+}